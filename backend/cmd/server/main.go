@@ -1,67 +1,234 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"flag"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	_ "modernc.org/sqlite"
-
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/blob"
+	"todoapp/backend/internal/caldav"
+	"todoapp/backend/internal/config"
 	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/digest"
+	"todoapp/backend/internal/email"
+	"todoapp/backend/internal/logging"
+	"todoapp/backend/internal/mcp"
+	"todoapp/backend/internal/oidc"
+	"todoapp/backend/internal/openapi"
+	"todoapp/backend/internal/ratelimit"
+	"todoapp/backend/internal/reminder"
+	"todoapp/backend/internal/routes"
+	"todoapp/backend/internal/rpc"
+	"todoapp/backend/internal/sharing"
 	"todoapp/backend/internal/todo"
+	"todoapp/backend/internal/tracing"
+	"todoapp/backend/internal/web"
+	"todoapp/backend/internal/webhook"
 )
 
 func main() {
-	var (
-		addr   = flag.String("addr", ":8080", "server listen address")
-		dbPath = flag.String("db", "./todo.db", "sqlite database path")
-	)
-	flag.Parse()
+	args := os.Args[1:]
+	cfg, opts, err := config.Load(args, os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fatal("load config", err)
+	}
+
+	if opts.PrintConfig {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			fatal("print config", err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
 
-	database, err := sql.Open("sqlite", *dbPath)
+	settings, err := newRuntimeSettings(cfg, args, os.LookupEnv)
 	if err != nil {
-		log.Fatalf("open db: %v", err)
+		fatal("apply config", err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, settings.LogLevel())
+	if err != nil {
+		fatal("create logger", err)
+	}
+	slog.SetDefault(logger)
+	if opts.Path != "" {
+		slog.Info("loaded config file", "path", opts.Path)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingOptions())
+	if err != nil {
+		fatal("set up tracing", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("shut down tracing", "error", err)
+		}
+	}()
+
+	database, err := db.Open(cfg.DB)
+	if err != nil {
+		fatal("open db", err)
 	}
 	defer database.Close()
 
 	if err := db.Migrate(database); err != nil {
-		log.Fatalf("migrate db: %v", err)
+		fatal("migrate db", err)
 	}
 	if err := db.SeedIfEmpty(database); err != nil {
-		log.Fatalf("seed db: %v", err)
+		fatal("seed db", err)
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		fatal("load openapi document", err)
 	}
 
-	repo := todo.NewRepository(database)
-	handler := todo.NewHandler(repo)
+	blobs, err := blob.NewFS(cfg.Attachments.Dir)
+	if err != nil {
+		fatal("open attachment store", err)
+	}
+
+	webhooks := webhook.NewStore(database)
+	repo := todo.NewRepository(database, todo.WithBlobStore(blobs), todo.WithEvents(webhooks))
+	lists := sharing.NewStore(database)
+	mcpServer := mcp.NewServer(repo, append(cfg.MCPOptions(), mcp.WithPermissions(lists))...)
+	if opts.MCPStdio {
+		// Whoever can start the server owns its database, so local agents
+		// act as the admin.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx = auth.WithPrincipal(ctx, auth.Principal{Kind: auth.KindAdmin, Name: "admin", Scope: auth.ScopeReadWrite})
+		slog.Info("serving MCP on stdio")
+		if err := mcpServer.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+			fatal("serve mcp", err)
+		}
+		return
+	}
+	feedTokens := auth.NewFeedTokenStore(database)
+	reminders := reminder.NewStore(database)
+	digests := digest.NewStore(database)
+	digestBuilder := digest.NewBuilder(repo, lists)
+	handler := todo.NewHandler(repo, append(cfg.HandlerOptions(),
+		todo.WithPermissions(lists),
+		todo.WithComments(repo),
+		todo.WithAttachments(repo, cfg.Attachments.MaxFileBytes, cfg.Attachments.QuotaBytes),
+		todo.WithReminders(repo),
+		todo.WithImportExport(repo),
+		todo.WithCalendar(repo, feedTokens),
+		todo.WithTaskImport(repo),
+		todo.WithSync(repo),
+		todo.WithGraphQL(lists, !cfg.Auth.Required),
+	)...)
+	limiter := ratelimit.New(cfg.RateLimitOptions())
+	keys := auth.NewKeyStore(database)
+	sessions := auth.NewSessionStore(database, time.Duration(cfg.OIDC.SessionTTL))
+	authenticator := auth.NewAuthenticator(keys, sessions, cfg.Auth.AdminToken)
 
+	todoService := rpc.NewService(repo, append(cfg.RPCOptions(),
+		rpc.WithPermissions(lists),
+		rpc.WithWatch(repo),
+	)...)
+	grpcServer := rpc.NewServer(todoService)
+	handlers := routes.Handlers{
+		Todos:         handler,
+		Connect:       rpc.NewConnectHandler(todoService, cfg.Limits.MaxBodyBytes),
+		MCP:           mcp.NewHandler(mcpServer, cfg.Limits.MaxBodyBytes),
+		CalDAV:        caldav.NewHandler(repo, append(cfg.CalDAVOptions(), caldav.WithLists(lists))...),
+		Notifications: reminder.NewHandler(reminders),
+		Spec:          openapi.NewHandler(spec),
+	}
+	if cfg.Auth.AdminToken != "" {
+		handlers.Keys = auth.NewKeyHandler(keys)
+		handlers.Webhooks = webhook.NewHandler(webhooks)
+	}
+	if cfg.OIDCEnabled() {
+		handlers.Login = auth.NewLoginHandler(oidc.NewProvider(cfg.OIDCOptions()), sessions, cfg.LoginOptions())
+		handlers.Lists = sharing.NewHandler(lists)
+		handlers.FeedTokens = auth.NewFeedTokenHandler(feedTokens)
+		handlers.Digest = digest.NewHandler(digests, digestBuilder)
+	}
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /api/todos", handler.ListTodos)
-	mux.HandleFunc("POST /api/todos", handler.CreateTodo)
-	mux.HandleFunc("PATCH /api/todos/{id}", handler.UpdateTodo)
-	mux.HandleFunc("DELETE /api/todos/{id}", handler.DeleteTodo)
+	routes.Register(mux, handlers, routes.WithRateLimit(limiter), routes.WithAnonymousWrites(!cfg.Auth.Required))
 
+	// The frontend answers only what no route does, so it cannot shadow the
+	// API.
+	var app http.Handler = mux
+	if files, ok := web.Files(cfg.Frontend.Dir); ok {
+		frontend, err := web.NewHandler(files, cfg.FrontendOptions()...)
+		if err != nil {
+			fatal("load frontend", err)
+		}
+		app = frontend.Fallback(mux)
+		slog.Info("serving frontend", "dir", cfg.Frontend.Dir)
+	}
+
+	// gRPC clients speak HTTP/2 without TLS, so the server accepts it
+	// alongside HTTP/1.1.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
 	server := &http.Server{
-		Addr:    *addr,
-		Handler: withCORS(mux),
+		Addr:      cfg.Addr,
+		Handler:   tracing.Middleware(logging.Middleware(logger)(settings.CORS(authenticator.Middleware(rpc.Multiplex(limiter.Limit("grpc", grpcServer), app))))),
+		Protocols: protocols,
 	}
 
-	log.Printf("server started on %s", *addr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go webhook.NewDispatcher(webhooks).Run(ctx, 2*time.Second)
+	notifiers := []reminder.Notifier{reminder.NewWebhookNotifier(webhooks, repo)}
+	if cfg.SMTPEnabled() {
+		mailer := email.NewSender(cfg.EmailOptions())
+		notifiers = append(notifiers, reminder.NewEmailNotifier(mailer))
+		go digest.NewJob(digests, digestBuilder, mailer).Run(ctx, time.Minute)
 	}
-}
+	go reminder.NewScheduler(reminders, notifiers...).Run(ctx, 15*time.Second)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
 
-func withCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server started", "addr", cfg.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+	for {
+		select {
+		case err := <-serverErr:
+			if err != nil && err != http.ErrServerClosed {
+				fatal("server error", err)
+			}
+			return
+		case <-reload:
+			if err := settings.Reload(); err != nil {
+				slog.Error("reload config", "error", err)
+			}
+		case <-ctx.Done():
+			slog.Info("shutting down server")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				slog.Error("shut down server", "error", err)
+			}
 			return
 		}
-		next.ServeHTTP(w, r)
-	})
+	}
+}
+
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"todoapp/backend/internal/config"
+	"todoapp/backend/internal/cors"
+	"todoapp/backend/internal/logging"
+)
+
+// runtimeSettings holds the settings that can change while the server is
+// running. Reload re-reads every configuration layer with the original
+// arguments and applies only the reloadable settings.
+type runtimeSettings struct {
+	args      []string
+	lookupEnv func(string) (string, bool)
+
+	mu         sync.Mutex
+	current    config.Config
+	logLevel   slog.LevelVar
+	corsPolicy atomic.Pointer[cors.Policy]
+}
+
+func newRuntimeSettings(cfg config.Config, args []string, lookupEnv func(string) (string, bool)) (*runtimeSettings, error) {
+	s := &runtimeSettings{args: args, lookupEnv: lookupEnv, current: cfg}
+	if err := s.apply(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *runtimeSettings) Reload() error {
+	next, _, err := config.Load(s.args, s.lookupEnv, io.Discard)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed, restartRequired := config.Diff(s.current, next)
+	if len(restartRequired) > 0 {
+		slog.Warn("ignoring config changes that require a restart", "settings", restartRequired)
+	}
+	if err := s.apply(next); err != nil {
+		return err
+	}
+	s.current.Log.Level = next.Log.Level
+	s.current.CORS = next.CORS
+	slog.Info("config reloaded", "changed", changed)
+	return nil
+}
+
+func (s *runtimeSettings) apply(cfg config.Config) error {
+	level, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	policy, err := cors.New(cfg.CORSOptions())
+	if err != nil {
+		return fmt.Errorf("cors: %w", err)
+	}
+	s.logLevel.Set(level)
+	s.corsPolicy.Store(policy)
+	return nil
+}
+
+func (s *runtimeSettings) LogLevel() slog.Leveler {
+	return &s.logLevel
+}
+
+func (s *runtimeSettings) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.corsPolicy.Load().Handler(next).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"todoapp/backend/internal/config"
+)
+
+func TestRuntimeSettings_ReloadAppliesSafeSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+	}
+	writeConfig("log:\n  level: info\ncors:\n  origins: [\"https://old.example.com\"]\n")
+
+	args := []string{"-config", path}
+	lookupEnv := func(string) (string, bool) { return "", false }
+	cfg, _, err := config.Load(args, lookupEnv, nil)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	settings, err := newRuntimeSettings(cfg, args, lookupEnv)
+	if err != nil {
+		t.Fatalf("new runtime settings: %v", err)
+	}
+	handler := settings.CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	allowedOrigin := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if got := allowedOrigin("https://new.example.com"); got != "" {
+		t.Fatalf("expected new origin to be rejected before reload, got %q", got)
+	}
+	if settings.LogLevel().Level() != slog.LevelInfo {
+		t.Fatalf("expected info level before reload")
+	}
+
+	writeConfig("addr: \":9999\"\nlog:\n  level: debug\ncors:\n  origins: [\"https://new.example.com\"]\n")
+	if err := settings.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	if got := allowedOrigin("https://new.example.com"); got != "https://new.example.com" {
+		t.Fatalf("expected new origin after reload, got %q", got)
+	}
+	if settings.LogLevel().Level() != slog.LevelDebug {
+		t.Fatalf("expected debug level after reload")
+	}
+	if settings.current.Addr != cfg.Addr {
+		t.Fatalf("expected addr to require a restart, got %q", settings.current.Addr)
+	}
+}
+
+func TestRuntimeSettings_ReloadKeepsCurrentOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	if err := os.WriteFile(path, []byte("log:\n  level: warn\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	args := []string{"-config", path}
+	lookupEnv := func(string) (string, bool) { return "", false }
+	cfg, _, err := config.Load(args, lookupEnv, nil)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	settings, err := newRuntimeSettings(cfg, args, lookupEnv)
+	if err != nil {
+		t.Fatalf("new runtime settings: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("log:\n  level: loud\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := settings.Reload(); err == nil {
+		t.Fatalf("expected reload error for invalid config")
+	}
+	if settings.LogLevel().Level() != slog.LevelWarn {
+		t.Fatalf("expected warn level to be kept, got %v", settings.LogLevel().Level())
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/backend/pkg/client"
+)
+
+func (c *cli) list(fs *flag.FlagSet) func(args []string) error {
+	listID := fs.Int64("list", 0, "show the shared list with this `id` instead of the default list")
+	done := fs.Bool("done", false, "only completed todos")
+	pending := fs.Bool("pending", false, "only todos not completed")
+	overdue := fs.Bool("overdue", false, "only todos not completed and past their due date")
+	dueToday := fs.Bool("due-today", false, "only todos due today")
+	search := fs.String("search", "", "only todos whose title or notes contain `text`")
+	priority := fs.Int("priority", 0, "only todos with this `priority` or a higher one (1 is highest)")
+	asJSON := fs.Bool("json", false, "print JSON")
+	return func(args []string) error {
+		if len(args) > 0 {
+			return usageError("list takes no arguments")
+		}
+		if *done && (*pending || *overdue) {
+			return usageError("--done cannot be combined with --pending or --overdue")
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		items, err := api.List(c.ctx, optionalID(*listID))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		endOfDay := startOfDay.AddDate(0, 0, 1)
+		needle := strings.ToLower(*search)
+		matched := make([]client.Item, 0, len(items))
+		for _, item := range items {
+			due := localDue(item.Due)
+			switch {
+			case *done && !item.Completed,
+				(*pending || *overdue) && item.Completed,
+				*overdue && (due == nil || !due.Before(now)),
+				*dueToday && (due == nil || due.Before(startOfDay) || !due.Before(endOfDay)),
+				*priority > 0 && (item.Priority == 0 || item.Priority > *priority),
+				needle != "" && !strings.Contains(strings.ToLower(item.Title+"\n"+item.Notes), needle):
+				continue
+			}
+			matched = append(matched, item)
+		}
+
+		if *asJSON {
+			return printJSON(c.stdout, matched)
+		}
+		for _, item := range matched {
+			printItem(c.stdout, item)
+		}
+		return nil
+	}
+}
+
+func (c *cli) add(fs *flag.FlagSet) func(args []string) error {
+	notes := fs.String("notes", "", "Markdown `notes`")
+	due := fs.String("due", "", "due `date`: YYYY-MM-DD, an RFC 3339 time, today or tomorrow")
+	priority := fs.Int("priority", 0, "`priority` from 1 (highest) to 9 (lowest)")
+	listID := fs.Int64("list", 0, "add to the shared list with this `id`")
+	asJSON := fs.Bool("json", false, "print the created todo as JSON")
+	return func(args []string) error {
+		title := joinArgs(args)
+		if title == "" {
+			return usageError("a title is required")
+		}
+		item := client.Item{Title: title, Notes: *notes, ListID: optionalID(*listID), Priority: *priority}
+		if *due != "" {
+			parsed, err := parseDue(*due)
+			if err != nil {
+				return usageError(err.Error())
+			}
+			item.Due = &parsed
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		item, err = api.Create(c.ctx, item)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(c.stdout, item)
+		}
+		printItem(c.stdout, item)
+		return nil
+	}
+}
+
+func (c *cli) done(fs *flag.FlagSet) func(args []string) error {
+	return c.setCompleted(fs, true)
+}
+
+func (c *cli) undone(fs *flag.FlagSet) func(args []string) error {
+	return c.setCompleted(fs, false)
+}
+
+func (c *cli) setCompleted(fs *flag.FlagSet, completed bool) func(args []string) error {
+	asJSON := fs.Bool("json", false, "print the updated todos as JSON")
+	return func(args []string) error {
+		ids, err := parseIDs(args)
+		if err != nil {
+			return err
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		var updated []client.Item
+		for _, id := range ids {
+			item, err := api.UpdateCompleted(c.ctx, id, completed)
+			if err != nil {
+				return fmt.Errorf("todo %d: %w", id, err)
+			}
+			updated = append(updated, item)
+		}
+		if *asJSON {
+			return printJSON(c.stdout, updated)
+		}
+		for _, item := range updated {
+			printItem(c.stdout, item)
+		}
+		return nil
+	}
+}
+
+func (c *cli) edit(fs *flag.FlagSet) func(args []string) error {
+	var changes client.Changes
+	fs.Func("title", "new `title`", func(v string) error { changes.Title = &v; return nil })
+	fs.Func("notes", "new Markdown `notes`; an empty string clears them", func(v string) error { changes.Notes = &v; return nil })
+	fs.Func("due", "new due `date`: YYYY-MM-DD, an RFC 3339 time, today, tomorrow or none", func(v string) error {
+		changes.SetDue, changes.Due = true, nil
+		if v == "none" {
+			return nil
+		}
+		due, err := parseDue(v)
+		if err != nil {
+			return err
+		}
+		changes.Due = &due
+		return nil
+	})
+	fs.Func("priority", "new `priority` from 1 (highest) to 9 (lowest), 0 for none", func(v string) error {
+		priority, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.New("priority must be a number")
+		}
+		changes.Priority = &priority
+		return nil
+	})
+	asJSON := fs.Bool("json", false, "print the updated todo as JSON")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usageError("exactly one todo ID is required")
+		}
+		ids, err := parseIDs(args)
+		if err != nil {
+			return err
+		}
+		if changes.Title == nil && changes.Notes == nil && !changes.SetDue && changes.Priority == nil {
+			return usageError("nothing to change; use --title, --notes, --due or --priority")
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		item, err := api.Update(c.ctx, ids[0], changes)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(c.stdout, item)
+		}
+		printItem(c.stdout, item)
+		return nil
+	}
+}
+
+func (c *cli) rm(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		ids, err := parseIDs(args)
+		if err != nil {
+			return err
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := api.Delete(c.ctx, id); err != nil {
+				return fmt.Errorf("todo %d: %w", id, err)
+			}
+		}
+		fmt.Fprintf(c.stdout, "Deleted %s\n", plural(len(ids), "todo"))
+		return nil
+	}
+}
+
+// defaultTasksFile is where takt keeps its task queue, relative to the
+// repository it works on.
+const defaultTasksFile = ".takt/tasks.yaml"
+
+func (c *cli) importTakt(fs *flag.FlagSet) func(args []string) error {
+	listID := fs.Int64("list", 0, "import into the shared list with this `id`")
+	watch := fs.Bool("watch", false, "keep running and import the file again whenever it changes")
+	interval := fs.Duration("interval", 2*time.Second, "how often --watch checks the file")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	return func(args []string) error {
+		if len(args) > 1 {
+			return usageError("at most one file is allowed")
+		}
+		if *interval <= 0 {
+			return usageError("--interval must be positive")
+		}
+		path := defaultTasksFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		importFile := func(data []byte) error {
+			result, err := api.ImportTasks(c.ctx, data, optionalID(*listID))
+			if err != nil {
+				return err
+			}
+			if *asJSON {
+				return printJSON(c.stdout, result)
+			}
+			fmt.Fprintf(c.stdout, "%s: %d created, %d updated, %d unchanged\n", path, result.Created, result.Updated, result.Unchanged)
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil && !*watch {
+			return err
+		}
+		if !*watch {
+			return importFile(data)
+		}
+
+		// The file is polled rather than watched for events, which editors
+		// and takt's rewrites deliver inconsistently. Failures are reported
+		// and retried on the next change, so that a half-written file or
+		// a restarting server does not end the watch.
+		var last []byte
+		imported := false
+		var lastErr string
+		report := func(err error) {
+			if err == nil {
+				lastErr = ""
+				return
+			}
+			if err.Error() != lastErr {
+				fmt.Fprintf(c.stderr, "todo import-takt: %v\n", err)
+			}
+			lastErr = err.Error()
+		}
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for {
+			switch {
+			case err != nil:
+				report(err)
+			case !imported || !bytes.Equal(data, last):
+				err = importFile(data)
+				report(err)
+				if err == nil {
+					last, imported = data, true
+				}
+			}
+			select {
+			case <-c.ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			data, err = os.ReadFile(path)
+		}
+	}
+}
+
+func parseIDs(args []string) ([]int64, error) {
+	if len(args) == 0 {
+		return nil, usageError("at least one todo ID is required")
+	}
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, usageError(fmt.Sprintf("invalid todo ID %q", arg))
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func optionalID(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// parseDue accepts the same formats as the API plus today and tomorrow,
+// which are dates in the local timezone. Dates are returned as midnight
+// UTC, the API's convention for date-only due dates.
+func parseDue(value string) (time.Time, error) {
+	now := time.Now()
+	switch strings.ToLower(value) {
+	case "today":
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+	case "tomorrow":
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC), nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid due date %q; use YYYY-MM-DD, an RFC 3339 time, today or tomorrow", value)
+}
+
+// dateOnly reports whether due is a date without a time of day.
+func dateOnly(due time.Time) bool {
+	due = due.UTC()
+	return due.Equal(due.Truncate(24 * time.Hour))
+}
+
+// localDue places due in the local timezone, reading a date-only due date
+// as local midnight.
+func localDue(due *time.Time) *time.Time {
+	if due == nil {
+		return nil
+	}
+	local := due.Local()
+	if dateOnly(*due) {
+		utc := due.UTC()
+		local = time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.Local)
+	}
+	return &local
+}
+
+// printItem prints one line per todo, e.g.
+//
+//	3 [ ] Buy milk  (due 2026-03-02, priority 1)
+func printItem(w io.Writer, item client.Item) {
+	mark := " "
+	if item.Completed {
+		mark = "x"
+	}
+	var details []string
+	if item.Due != nil {
+		if dateOnly(*item.Due) {
+			details = append(details, "due "+item.Due.UTC().Format(time.DateOnly))
+		} else {
+			details = append(details, "due "+item.Due.Local().Format("2006-01-02 15:04"))
+		}
+	}
+	if item.Priority > 0 {
+		details = append(details, "priority "+strconv.Itoa(item.Priority))
+	}
+	if item.ListID != nil {
+		details = append(details, "list "+strconv.FormatInt(*item.ListID, 10))
+	}
+	line := fmt.Sprintf("%4d [%s] %s", item.ID, mark, item.Title)
+	if len(details) > 0 {
+		line += "  (" + strings.Join(details, ", ") + ")"
+	}
+	fmt.Fprintln(w, line)
+}
+
+func printJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
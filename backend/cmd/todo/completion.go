@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (c *cli) completion(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usageError("a shell is required: bash, zsh or fish")
+		}
+		switch args[0] {
+		case "bash":
+			writeBashCompletion(c.stdout)
+		case "zsh":
+			// zsh runs the bash script through bashcompinit.
+			fmt.Fprintln(c.stdout, "#compdef todo\nautoload -U +X bashcompinit && bashcompinit")
+			writeBashCompletion(c.stdout)
+		case "fish":
+			writeFishCompletion(c.stdout)
+		default:
+			return usageError(fmt.Sprintf("unsupported shell %q; use bash, zsh or fish", args[0]))
+		}
+		return nil
+	}
+}
+
+// ids prints "ID<TAB>title" for the todos on the default list, for
+// completing the arguments of done, undone, edit and rm.
+func (c *cli) ids(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		items, err := api.List(c.ctx, nil)
+		if err != nil {
+			return err
+		}
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		for _, item := range items {
+			if (filter == "pending" && item.Completed) || (filter == "done" && !item.Completed) {
+				continue
+			}
+			fmt.Fprintf(c.stdout, "%d\t%s\n", item.ID, item.Title)
+		}
+		return nil
+	}
+}
+
+// idFilter says which todos complete the arguments of a command, or false
+// when its arguments are not todo IDs.
+func idFilter(name string) (string, bool) {
+	switch name {
+	case "done":
+		return "pending", true
+	case "undone":
+		return "done", true
+	case "edit", "rm":
+		return "all", true
+	}
+	return "", false
+}
+
+// commandFlags returns the flags of cmd as they are typed, e.g. "--json".
+func commandFlags(cmd command) []string {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.setup(new(cli), fs)
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, "--"+f.Name) })
+	return names
+}
+
+func commandNames() []string {
+	var names []string
+	for _, cmd := range visibleCommands() {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintln(w, "_todo() {")
+	fmt.Fprintln(w, `	local cur=${COMP_WORDS[COMP_CWORD]} i cmd=""`)
+	fmt.Fprintln(w, `	for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `		case ${COMP_WORDS[i]} in`)
+	fmt.Fprintln(w, `			--server|--token|--config) ((i++)) ;;`)
+	fmt.Fprintln(w, `			-*) ;;`)
+	fmt.Fprintln(w, `			*) cmd=${COMP_WORDS[i]}; break ;;`)
+	fmt.Fprintln(w, `		esac`)
+	fmt.Fprintln(w, `	done`)
+	fmt.Fprintln(w, `	local words=""`)
+	fmt.Fprintln(w, `	case $cmd in`)
+	fmt.Fprintf(w, "\t\t\"\") words=\"%s --server --token --config\" ;;\n", strings.Join(commandNames(), " "))
+	for _, cmd := range visibleCommands() {
+		words := commandFlags(cmd)
+		switch cmd.name {
+		case "completion":
+			words = append(words, "bash", "zsh", "fish")
+		case "help":
+			words = append(words, commandNames()...)
+		}
+		if filter, ok := idFilter(cmd.name); ok {
+			fmt.Fprintf(w, "\t\t%s) [[ $cur == -* ]] || words=$(todo __ids %s 2>/dev/null | cut -f1); words=\"$words %s\" ;;\n",
+				cmd.name, filter, strings.Join(words, " "))
+			continue
+		}
+		if cmd.name == "import-takt" {
+			fmt.Fprintf(w, "\t\t%s) [[ $cur == -* ]] || words=$(compgen -f -- \"$cur\"); words=\"$words %s\" ;;\n",
+				cmd.name, strings.Join(words, " "))
+			continue
+		}
+		fmt.Fprintf(w, "\t\t%s) words=\"%s\" ;;\n", cmd.name, strings.Join(words, " "))
+	}
+	fmt.Fprintln(w, `	esac`)
+	fmt.Fprintln(w, `	COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _todo todo")
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "complete -c todo -f")
+	fmt.Fprintln(w, "complete -c todo -n __fish_use_subcommand -l server -r -d 'Server URL'")
+	fmt.Fprintln(w, "complete -c todo -n __fish_use_subcommand -l token -r -d 'API key'")
+	fmt.Fprintln(w, "complete -c todo -n __fish_use_subcommand -l config -r -F -d 'Config file'")
+	for _, cmd := range visibleCommands() {
+		fmt.Fprintf(w, "complete -c todo -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+		condition := "'__fish_seen_subcommand_from " + cmd.name + "'"
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		cmd.setup(new(cli), fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			_, usage := flag.UnquoteUsage(f)
+			fmt.Fprintf(w, "complete -c todo -n %s -l %s -d %s\n", condition, f.Name, fishQuote(usage))
+		})
+		switch cmd.name {
+		case "completion":
+			fmt.Fprintf(w, "complete -c todo -n %s -a 'bash zsh fish'\n", condition)
+		case "help":
+			fmt.Fprintf(w, "complete -c todo -n %s -a '%s'\n", condition, strings.Join(commandNames(), " "))
+		}
+		if filter, ok := idFilter(cmd.name); ok {
+			fmt.Fprintf(w, "complete -c todo -n %s -a '(todo __ids %s 2>/dev/null)'\n", condition, filter)
+		}
+		if cmd.name == "import-takt" {
+			fmt.Fprintf(w, "complete -c todo -n %s -F\n", condition)
+		}
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
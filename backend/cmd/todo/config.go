@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// config is where the CLI finds the server. It is read from a TOML file,
+// then overridden by TODO_SERVER and TODO_TOKEN, then by flags.
+type config struct {
+	Server string `toml:"server"`
+	// Token is an API key, sent as a bearer token.
+	Token string `toml:"token"`
+}
+
+// configPath returns the file named by TODO_CLI_CONFIG (not TODO_CONFIG,
+// which names the server's config file), or config.toml in the
+// user's config directory, e.g. ~/.config/todo/config.toml.
+func configPath(getenv func(string) string) string {
+	if path := getenv("TODO_CLI_CONFIG"); path != "" {
+		return path
+	}
+	dir := getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return ""
+		}
+	}
+	return filepath.Join(dir, "todo", "config.toml")
+}
+
+// loadConfig reads path, which may be missing, and applies the environment.
+func loadConfig(path string, getenv func(string) string) (config, error) {
+	cfg := config{Server: defaultServer}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return config{}, err
+		default:
+			meta, err := toml.Decode(string(data), &cfg)
+			if err != nil {
+				return config{}, fmt.Errorf("%s: %w", path, err)
+			}
+			if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+				return config{}, fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+			}
+		}
+	}
+	if server := getenv("TODO_SERVER"); server != "" {
+		cfg.Server = server
+	}
+	if token := getenv("TODO_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	return cfg, nil
+}
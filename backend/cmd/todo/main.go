@@ -0,0 +1,268 @@
+// Command todo manages todos on a todoapp server from the terminal.
+//
+//	todo add Buy milk --due tomorrow
+//	todo list --pending
+//	todo done 3
+//
+// Run "todo help" for every command. The server URL and API key come from
+// ~/.config/todo/config.toml, TODO_SERVER and TODO_TOKEN, or --server and
+// --token.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"todoapp/backend/pkg/client"
+)
+
+// command is a subcommand; names starting with "__" are internal and left
+// out of help and completion. setup defines its flags on fs and returns the
+// function that runs it with the remaining arguments; completion calls
+// setup alone to learn the flags.
+type command struct {
+	name    string
+	args    string
+	summary string
+	setup   func(c *cli, fs *flag.FlagSet) func(args []string) error
+}
+
+// commands is filled in by init to break the reference cycle with the
+// help and completion commands.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"list", "", "List todos", (*cli).list},
+		{"add", "TITLE...", "Add a todo", (*cli).add},
+		{"done", "ID...", "Mark todos completed", (*cli).done},
+		{"undone", "ID...", "Mark todos not completed", (*cli).undone},
+		{"edit", "ID", "Change a todo's title, notes, due date or priority", (*cli).edit},
+		{"rm", "ID...", "Delete todos", (*cli).rm},
+		{"import-takt", "[FILE]", "Import takt tasks from .takt/tasks.yaml", (*cli).importTakt},
+		{"completion", "bash|zsh|fish", "Print a shell completion script", (*cli).completion},
+		{"help", "[COMMAND]", "Show help", (*cli).help},
+		{"__ids", "[pending|done]", "Print todo IDs and titles for shell completion", (*cli).ids},
+	}
+}
+
+func lookup(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func visibleCommands() []command {
+	var visible []command
+	for _, cmd := range commands {
+		if !strings.HasPrefix(cmd.name, "__") {
+			visible = append(visible, cmd)
+		}
+	}
+	return visible
+}
+
+type cli struct {
+	ctx    context.Context
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+	// flags set before the command, overriding the config file.
+	configFile, server, token string
+	apiClient                 *client.Client
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Getenv, os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit status: 0 on
+// success, 1 when the command failed and 2 for usage errors.
+func run(ctx context.Context, args []string, getenv func(string) string, stdout io.Writer, stderr io.Writer) int {
+	c := &cli{ctx: ctx, stdout: stdout, stderr: stderr, getenv: getenv}
+
+	global := c.globalFlags()
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			c.usage(stdout, global)
+			return 0
+		}
+		fmt.Fprintf(stderr, "todo: %v\n", err)
+		c.usage(stderr, global)
+		return 2
+	}
+	if global.NArg() == 0 {
+		c.usage(stderr, global)
+		return 2
+	}
+
+	cmd, ok := lookup(global.Arg(0))
+	if !ok {
+		fmt.Fprintf(stderr, "todo: unknown command %q\n", global.Arg(0))
+		c.usage(stderr, global)
+		return 2
+	}
+	fs := flag.NewFlagSet("todo "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	runCmd := cmd.setup(c, fs)
+	rest, err := parseInterspersed(fs, global.Args()[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			commandUsage(stdout, cmd, fs)
+			return 0
+		}
+		fmt.Fprintf(stderr, "todo %s: %v\n", cmd.name, err)
+		commandUsage(stderr, cmd, fs)
+		return 2
+	}
+	if err := runCmd(rest); err != nil {
+		var usage usageError
+		if errors.As(err, &usage) {
+			fmt.Fprintf(stderr, "todo %s: %v\n", cmd.name, err)
+			commandUsage(stderr, cmd, fs)
+			return 2
+		}
+		if errors.Is(err, client.ErrUnauthorized) {
+			err = fmt.Errorf("%w (set an API key with --token, TODO_TOKEN or token in the config file)", err)
+		}
+		fmt.Fprintf(stderr, "todo %s: %v\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+// usageError is an error in how a command was invoked.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// globalFlags defines the flags that come before the command.
+func (c *cli) globalFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&c.configFile, "config", "", "config `file` (default $TODO_CLI_CONFIG or ~/.config/todo/config.toml)")
+	fs.StringVar(&c.server, "server", "", "server `URL` (default $TODO_SERVER or "+defaultServer+")")
+	fs.StringVar(&c.token, "token", "", "API `key` (default $TODO_TOKEN)")
+	return fs
+}
+
+// parseInterspersed parses flags that come before, between or after
+// positional arguments, so that "todo add Buy milk --due today" works.
+// Everything after "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// api returns the client, configured on first use.
+func (c *cli) api() (*client.Client, error) {
+	if c.apiClient != nil {
+		return c.apiClient, nil
+	}
+	path := c.configFile
+	if path == "" {
+		path = configPath(c.getenv)
+	}
+	cfg, err := loadConfig(path, c.getenv)
+	if err != nil {
+		return nil, err
+	}
+	if c.server != "" {
+		cfg.Server = c.server
+	}
+	if c.token != "" {
+		cfg.Token = c.token
+	}
+	if c.apiClient, err = client.New(cfg.Server, client.WithToken(cfg.Token), client.WithUserAgent("todo-cli")); err != nil {
+		return nil, err
+	}
+	return c.apiClient, nil
+}
+
+func (c *cli) usage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: todo [--server URL] [--token KEY] [--config FILE] COMMAND [ARGS]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range visibleCommands() {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	global.SetOutput(w)
+	global.PrintDefaults()
+	global.SetOutput(io.Discard)
+	fmt.Fprintln(w, "\nRun \"todo help COMMAND\" for a command's flags.")
+}
+
+func commandUsage(w io.Writer, cmd command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: todo %s", cmd.name)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprint(w, " [FLAGS]")
+	}
+	if cmd.args != "" {
+		fmt.Fprint(w, " "+cmd.args)
+	}
+	fmt.Fprintf(w, "\n\n%s.\n", cmd.summary)
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+		fs.SetOutput(io.Discard)
+	}
+}
+
+func (c *cli) help(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) == 0 {
+			c.usage(c.stdout, new(cli).globalFlags())
+			return nil
+		}
+		cmd, ok := lookup(args[0])
+		if !ok {
+			return usageError(fmt.Sprintf("unknown command %q", args[0]))
+		}
+		cmdFlags := flag.NewFlagSet("todo "+cmd.name, flag.ContinueOnError)
+		cmd.setup(c, cmdFlags)
+		commandUsage(c.stdout, cmd, cmdFlags)
+		return nil
+	}
+}
+
+// plural returns "1 todo" or "2 todos".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// joinArgs joins positional arguments into a title, so quoting is optional.
+func joinArgs(args []string) string {
+	return strings.TrimSpace(strings.Join(args, " "))
+}
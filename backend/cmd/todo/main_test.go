@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/todo"
+)
+
+// newServer serves the real todo handlers, so the CLI is tested against
+// the API it talks to in production.
+func newServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	repo := todo.NewRepository(database)
+	h := todo.NewHandler(repo, todo.WithTaskImport(repo))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/todos", h.ListTodos)
+	mux.HandleFunc("POST /api/todos", h.CreateTodo)
+	mux.HandleFunc("PATCH /api/todos/{id}", h.UpdateTodo)
+	mux.HandleFunc("DELETE /api/todos/{id}", h.DeleteTodo)
+	mux.HandleFunc("POST /api/import/takt", h.ImportTasks)
+	var mu sync.Mutex
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &tokens
+}
+
+// todoCLI runs the CLI with env as its environment and returns its output.
+func todoCLI(t *testing.T, env map[string]string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	getenv := func(key string) string { return env[key] }
+	code := run(context.Background(), args, getenv, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestCLI_ManagesTodos(t *testing.T) {
+	server, tokens := newServer(t)
+	env := map[string]string{"TODO_SERVER": server.URL, "TODO_TOKEN": "key-123", "TODO_CLI_CONFIG": filepath.Join(t.TempDir(), "missing.toml")}
+
+	stdout, stderr, code := todoCLI(t, env, "add", "Buy", "milk", "--due", "2026-03-02", "--priority", "1")
+	if code != 0 || stdout != "   1 [ ] Buy milk  (due 2026-03-02, priority 1)\n" {
+		t.Fatalf("add: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+	todoCLI(t, env, "add", "--notes", "about the trip", "Call mom")
+	todoCLI(t, env, "add", "Water plants")
+
+	if stdout, _, code := todoCLI(t, env, "done", "2", "3"); code != 0 || !strings.Contains(stdout, "   2 [x] Call mom") {
+		t.Fatalf("done: exit %d, stdout %q", code, stdout)
+	}
+	if stdout, _, _ := todoCLI(t, env, "undone", "#3"); stdout != "   3 [ ] Water plants\n" {
+		t.Fatalf("undone: unexpected output %q", stdout)
+	}
+	if stdout, _, _ := todoCLI(t, env, "edit", "3", "--title", "Water the plants", "--due", "2026-03-01T09:30:00Z"); !strings.Contains(stdout, "Water the plants  (due 2026-03-01 ") {
+		t.Fatalf("edit: unexpected output %q", stdout)
+	}
+
+	if stdout, _, _ := todoCLI(t, env, "list", "--pending"); stdout != "   1 [ ] Buy milk  (due 2026-03-02, priority 1)\n   3 [ ] Water the plants  (due 2026-03-01 "+time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC).Local().Format("15:04")+")\n" {
+		t.Fatalf("list --pending: unexpected output %q", stdout)
+	}
+	if stdout, _, _ := todoCLI(t, env, "list", "--search", "TRIP"); !strings.Contains(stdout, "Call mom") || strings.Count(stdout, "\n") != 1 {
+		t.Fatalf("list --search: unexpected output %q", stdout)
+	}
+	if stdout, _, _ := todoCLI(t, env, "list", "--overdue", "--priority", "1"); !strings.Contains(stdout, "Buy milk") || strings.Count(stdout, "\n") != 1 {
+		t.Fatalf("list --overdue --priority: unexpected output %q", stdout)
+	}
+
+	stdout, _, _ = todoCLI(t, env, "list", "--done", "--json")
+	var items []todo.Item
+	if err := json.Unmarshal([]byte(stdout), &items); err != nil || len(items) != 1 || items[0].Title != "Call mom" || items[0].Notes != "about the trip" {
+		t.Fatalf("list --json: unexpected output %q: %v", stdout, err)
+	}
+
+	if stdout, _, code := todoCLI(t, env, "edit", "1", "--due", "none"); code != 0 || stdout != "   1 [ ] Buy milk  (priority 1)\n" {
+		t.Fatalf("edit --due none: exit %d, stdout %q", code, stdout)
+	}
+	if stdout, _, code := todoCLI(t, env, "rm", "1", "2"); code != 0 || stdout != "Deleted 2 todos\n" {
+		t.Fatalf("rm: exit %d, stdout %q", code, stdout)
+	}
+	if stdout, _, _ := todoCLI(t, env, "__ids"); stdout != "3\tWater the plants\n" {
+		t.Fatalf("__ids: unexpected output %q", stdout)
+	}
+
+	for _, token := range *tokens {
+		if token != "Bearer key-123" {
+			t.Fatalf("expected every request to carry the API key, got %q", token)
+		}
+	}
+}
+
+func TestCLI_ImportTakt(t *testing.T) {
+	server, _ := newServer(t)
+	env := map[string]string{"TODO_SERVER": server.URL, "TODO_CLI_CONFIG": filepath.Join(t.TempDir(), "missing.toml")}
+	path := filepath.Join(t.TempDir(), "tasks.yaml")
+	writeTasks := func(status string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("tasks:\n  - name: fix-build\n    slug: fix-build\n    status: "+status+"\n    content: Fix the build.\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTasks("running")
+
+	if stdout, stderr, code := todoCLI(t, env, "import-takt", path); code != 0 || stdout != path+": 1 created, 0 updated, 0 unchanged\n" {
+		t.Fatalf("import-takt: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+	if _, stderr, code := todoCLI(t, env, "import-takt", filepath.Join(t.TempDir(), "missing.yaml")); code != 1 || !strings.Contains(stderr, "no such file") {
+		t.Fatalf("missing file: exit %d, stderr %q", code, stderr)
+	}
+
+	// Watching imports the file as it is, then again after each change.
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr lockedBuffer
+	done := make(chan int, 1)
+	go func() {
+		done <- run(ctx, []string{"import-takt", "--watch", "--interval", "10ms", path}, func(key string) string { return env[key] }, &stdout, &stderr)
+	}()
+	// The watcher reports each import only after the server answers, so
+	// waiting for the report rather than the todo avoids cancelling a
+	// request in flight.
+	waitFor := func(report string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if strings.Contains(stdout.String(), path+": "+report+"\n") {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %q, stdout %q, stderr %q", report, stdout.String(), stderr.String())
+	}
+	waitFor("0 created, 0 updated, 1 unchanged")
+	writeTasks("completed")
+	waitFor("0 created, 1 updated, 0 unchanged")
+	cancel()
+	if code := <-done; code != 0 {
+		t.Fatalf("import-takt --watch: exit %d, stderr %q", code, stderr.String())
+	}
+	if out, _, _ := todoCLI(t, env, "list"); out != "   1 [x] fix-build\n" {
+		t.Fatalf("expected the watched import to complete the todo, got %q", out)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that the test can read while a command
+// writes to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCLI_Errors(t *testing.T) {
+	server, _ := newServer(t)
+	env := map[string]string{"TODO_SERVER": server.URL, "TODO_CLI_CONFIG": filepath.Join(t.TempDir(), "missing.toml")}
+
+	tests := []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{"frobnicate"}, 2, `unknown command "frobnicate"`},
+		{[]string{"add"}, 2, "a title is required"},
+		{[]string{"add", "x", "--due", "someday"}, 2, `invalid due date "someday"`},
+		{[]string{"edit", "1"}, 2, "nothing to change"},
+		{[]string{"done", "abc"}, 2, `invalid todo ID "abc"`},
+		{[]string{"list", "--bogus"}, 2, "flag provided but not defined: -bogus"},
+		{[]string{"done", "42"}, 1, "todo 42: 404 Not Found: todo not found"},
+		{[]string{"add", "x", "--priority", "10"}, 1, "400 Bad Request: priority must be between 0 and 9"},
+		{[]string{"--server", "localhost:8080", "list"}, 1, "base URL must be an absolute http or https URL"},
+	}
+	for _, tt := range tests {
+		_, stderr, code := todoCLI(t, env, tt.args...)
+		if code != tt.code || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%v: expected exit %d with %q, got %d with %q", tt.args, tt.code, tt.stderr, code, stderr)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("server = \"https://todo.example.com\"\ntoken = \"from-file\"\n"), 0o600)
+
+	cfg, err := loadConfig(path, func(string) string { return "" })
+	if err != nil || cfg != (config{Server: "https://todo.example.com", Token: "from-file"}) {
+		t.Fatalf("unexpected config %#v: %v", cfg, err)
+	}
+
+	env := map[string]string{"TODO_TOKEN": "from-env"}
+	cfg, _ = loadConfig(path, func(key string) string { return env[key] })
+	if cfg.Server != "https://todo.example.com" || cfg.Token != "from-env" {
+		t.Fatalf("expected the environment to override the file, got %#v", cfg)
+	}
+
+	if cfg, err := loadConfig(filepath.Join(t.TempDir(), "missing.toml"), func(string) string { return "" }); err != nil || cfg.Server != defaultServer {
+		t.Fatalf("expected defaults without a config file, got %#v: %v", cfg, err)
+	}
+
+	os.WriteFile(path, []byte("sever = \"typo\"\n"), 0o600)
+	if _, err := loadConfig(path, func(string) string { return "" }); err == nil || !strings.Contains(err.Error(), `unknown setting "sever"`) {
+		t.Fatalf("expected unknown settings to be rejected, got %v", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	stdout, _, code := todoCLI(t, nil, "completion", "bash")
+	if code != 0 || !strings.Contains(stdout, "complete -F _todo todo") || !strings.Contains(stdout, `list) words="--done --due-today --json`) {
+		t.Fatalf("unexpected bash completion %q", stdout)
+	}
+	if strings.Contains(stdout, "__ids)") {
+		t.Fatalf("expected internal commands to be left out of completion")
+	}
+	stdout, _, _ = todoCLI(t, nil, "completion", "fish")
+	if !strings.Contains(stdout, "complete -c todo -n '__fish_seen_subcommand_from done' -a '(todo __ids pending 2>/dev/null)'") {
+		t.Fatalf("unexpected fish completion %q", stdout)
+	}
+	if _, stderr, code := todoCLI(t, nil, "completion", "powershell"); code != 2 || !strings.Contains(stderr, "unsupported shell") {
+		t.Fatalf("expected an unsupported shell to be a usage error, got %d %q", code, stderr)
+	}
+}
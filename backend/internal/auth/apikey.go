@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	tokenPrefix = "todo"
+	// lastUsedResolution limits how often a busy key rewrites last_used_at.
+	lastUsedResolution = time.Minute
+)
+
+var (
+	ErrNotFound   = errors.New("api key not found")
+	ErrInvalidKey = errors.New("invalid api key")
+)
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scope      Scope      `json:"scope"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+}
+
+// KeyStore persists API keys. Only a SHA-256 hash of each token is stored;
+// tokens carry 256 bits of randomness so a slow hash adds nothing.
+type KeyStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewKeyStore(db *sql.DB) *KeyStore {
+	return &KeyStore{db: db, now: time.Now}
+}
+
+// Create stores a new key and returns it with its plaintext token, which is
+// not recoverable afterwards.
+func (s *KeyStore) Create(ctx context.Context, name string, scope Scope, expiresAt *time.Time) (APIKey, string, error) {
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return APIKey{}, "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return APIKey{}, "", err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+	token := tokenPrefix + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key := APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		Scope:     scope,
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO api_keys (name, prefix, hash, scope, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		key.Name, key.Prefix, HashToken(token), string(key.Scope), key.CreatedAt, nullTime(key.ExpiresAt),
+	)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if key.ID, err = result.LastInsertId(); err != nil {
+		return APIKey{}, "", err
+	}
+	return key, token, nil
+}
+
+func (s *KeyStore) List(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+keyColumns+` FROM api_keys ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		key, _, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *KeyStore) Revoke(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, s.now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	revokedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revokedRows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves token to an active key and records its use.
+func (s *KeyStore) Authenticate(ctx context.Context, token string) (APIKey, error) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != tokenPrefix {
+		return APIKey{}, ErrInvalidKey
+	}
+
+	key, hash, err := scanKey(s.db.QueryRowContext(ctx, `SELECT `+keyColumns+` FROM api_keys WHERE prefix = ?`, parts[1]))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIKey{}, ErrInvalidKey
+		}
+		return APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(HashToken(token))) != 1 {
+		return APIKey{}, ErrInvalidKey
+	}
+
+	now := s.now().UTC()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return APIKey{}, ErrInvalidKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if _, err := s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now, key.ID); err != nil {
+			return APIKey{}, err
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+const keyColumns = `id, name, prefix, scope, created_at, expires_at, last_used_at, revoked_at, hash`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKey(row rowScanner) (APIKey, string, error) {
+	var (
+		key                              APIKey
+		scope, hash                      string
+		expiresAt, lastUsedAt, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &scope, &key.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt, &hash); err != nil {
+		return APIKey{}, "", err
+	}
+	key.Scope = Scope(scope)
+	key.ExpiresAt = timePtr(expiresAt)
+	key.LastUsedAt = timePtr(lastUsedAt)
+	key.RevokedAt = timePtr(revokedAt)
+	return key, hash, nil
+}
+
+// HashToken returns the hex SHA-256 of a secret token. Only the hash is
+// stored, so reading the database does not reveal usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
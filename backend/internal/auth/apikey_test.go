@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+)
+
+func setupKeyStore(t *testing.T) (*KeyStore, *time.Time) {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := NewKeyStore(database)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestKeyStoreCreateAndAuthenticate(t *testing.T) {
+	store, now := setupKeyStore(t)
+	ctx := context.Background()
+
+	key, token, err := store.Create(ctx, "ci", ScopeReadWrite, nil)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	if !strings.HasPrefix(token, "todo_"+key.Prefix+"_") {
+		t.Fatalf("unexpected token format: %q", token)
+	}
+
+	authenticated, err := store.Authenticate(ctx, token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if authenticated.ID != key.ID || authenticated.Scope != ScopeReadWrite {
+		t.Fatalf("unexpected key: %#v", authenticated)
+	}
+	if authenticated.LastUsedAt == nil || !authenticated.LastUsedAt.Equal(*now) {
+		t.Fatalf("expected last used to be recorded, got %v", authenticated.LastUsedAt)
+	}
+
+	keys, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Fatalf("unexpected keys: %#v", keys)
+	}
+}
+
+func TestKeyStoreStoresOnlyHash(t *testing.T) {
+	store, _ := setupKeyStore(t)
+
+	_, token, err := store.Create(context.Background(), "ci", ScopeRead, nil)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+
+	var count int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM api_keys WHERE hash = ? OR hash = ?`, token, HashToken(token)).Scan(&count); err != nil {
+		t.Fatalf("query hash: %v", err)
+	}
+	var plaintext int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM api_keys WHERE hash = ?`, token).Scan(&plaintext); err != nil {
+		t.Fatalf("query plaintext: %v", err)
+	}
+	if count != 1 || plaintext != 0 {
+		t.Fatalf("expected only the hash to be stored")
+	}
+}
+
+func TestKeyStoreAuthenticate_Rejects(t *testing.T) {
+	store, now := setupKeyStore(t)
+	ctx := context.Background()
+
+	expiresAt := now.Add(time.Hour)
+	_, expiring, err := store.Create(ctx, "expiring", ScopeRead, &expiresAt)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	revokedKey, revoked, err := store.Create(ctx, "revoked", ScopeRead, nil)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	if err := store.Revoke(ctx, revokedKey.ID); err != nil {
+		t.Fatalf("revoke key: %v", err)
+	}
+
+	if _, err := store.Authenticate(ctx, expiring); err != nil {
+		t.Fatalf("expected key to be valid before expiry: %v", err)
+	}
+	*now = now.Add(2 * time.Hour)
+
+	tampered := expiring[:len(expiring)-1] + "x"
+	for _, token := range []string{expiring, revoked, tampered, "todo_deadbeef_nope", "not-a-key"} {
+		if _, err := store.Authenticate(ctx, token); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("expected ErrInvalidKey for %q, got %v", token, err)
+		}
+	}
+}
+
+func TestKeyStoreRevoke_NotFound(t *testing.T) {
+	store, _ := setupKeyStore(t)
+
+	if err := store.Revoke(context.Background(), 999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"todoapp/backend/internal/respond"
+)
+
+var ErrInvalidFeedToken = errors.New("invalid feed token")
+
+// FeedTokenStore keeps the secret tokens that authenticate calendar
+// subscription URLs. Calendar apps cannot send headers or cookies, so the
+// token travels in the URL; each user has at most one, and issuing a new
+// one revokes the old.
+type FeedTokenStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewFeedTokenStore(db *sql.DB) *FeedTokenStore {
+	return &FeedTokenStore{db: db, now: time.Now}
+}
+
+// Issue returns a new token for userID, replacing any previous one.
+func (s *FeedTokenStore) Issue(ctx context.Context, userID int64) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO feed_tokens (user_id, token_hash, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`,
+		userID, HashToken(token), s.now().UTC())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *FeedTokenStore) Revoke(ctx context.Context, userID int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM feed_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+// Authenticate returns the user a token was issued to.
+func (s *FeedTokenStore) Authenticate(ctx context.Context, token string) (int64, error) {
+	var userID int64
+	err := s.db.QueryRowContext(ctx, `SELECT user_id FROM feed_tokens WHERE token_hash = ?`, HashToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidFeedToken
+	}
+	return userID, err
+}
+
+type FeedTokenHandler struct {
+	tokens *FeedTokenStore
+}
+
+func NewFeedTokenHandler(tokens *FeedTokenStore) *FeedTokenHandler {
+	return &FeedTokenHandler{tokens: tokens}
+}
+
+type feedTokenResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// IssueFeedToken creates the signed-in user's calendar token. The URL is
+// relative; clients prefix it with the server's public address.
+func (h *FeedTokenHandler) IssueFeedToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := feedUser(w, r)
+	if !ok {
+		return
+	}
+	token, err := h.tokens.Issue(r.Context(), userID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to issue feed token", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusCreated, feedTokenResponse{Token: token, URL: "/api/calendar.ics?token=" + token})
+}
+
+func (h *FeedTokenHandler) RevokeFeedToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := feedUser(w, r)
+	if !ok {
+		return
+	}
+	if err := h.tokens.Revoke(r.Context(), userID); err != nil {
+		respond.ServerError(w, r, "failed to revoke feed token", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func feedUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return 0, false
+	}
+	if principal.Kind != KindUser {
+		http.Error(w, "calendar feeds require a signed-in user", http.StatusForbidden)
+		return 0, false
+	}
+	return principal.ID, true
+}
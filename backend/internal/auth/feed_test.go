@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFeedTokenStore_IssueRotatesAndRevokes(t *testing.T) {
+	sessions, _ := setupSessionStore(t)
+	store := NewFeedTokenStore(sessions.db)
+	ctx := context.Background()
+
+	user, err := sessions.UpsertUser(ctx, "https://idp.example.com", "u1", "a@example.com", "Alice")
+	if err != nil {
+		t.Fatalf("upsert user: %v", err)
+	}
+
+	first, err := store.Issue(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if userID, err := store.Authenticate(ctx, first); err != nil || userID != user.ID {
+		t.Fatalf("expected token to authenticate user %d, got %d (%v)", user.ID, userID, err)
+	}
+
+	second, err := store.Issue(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("reissue: %v", err)
+	}
+	if _, err := store.Authenticate(ctx, first); !errors.Is(err, ErrInvalidFeedToken) {
+		t.Fatalf("expected the old token to stop working, got %v", err)
+	}
+
+	if err := store.Revoke(ctx, user.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := store.Authenticate(ctx, second); !errors.Is(err, ErrInvalidFeedToken) {
+		t.Fatalf("expected a revoked token to fail, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/backend/internal/respond"
+)
+
+const maxRequestBytes = 1 << 16
+
+type KeyManager interface {
+	Create(ctx context.Context, name string, scope Scope, expiresAt *time.Time) (APIKey, string, error)
+	List(ctx context.Context) ([]APIKey, error)
+	Revoke(ctx context.Context, id int64) error
+}
+
+type KeyHandler struct {
+	keys KeyManager
+	now  func() time.Time
+}
+
+func NewKeyHandler(keys KeyManager) *KeyHandler {
+	return &KeyHandler{keys: keys, now: time.Now}
+}
+
+func (h *KeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.List(r.Context())
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch api keys", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, keys)
+}
+
+type createKeyRequest struct {
+	Name      string     `json:"name"`
+	Scope     Scope      `json:"scope"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type createKeyResponse struct {
+	APIKey
+	Token string `json:"token"`
+}
+
+func (h *KeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req createKeyRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = ScopeRead
+	}
+	if !req.Scope.Valid() {
+		http.Error(w, "scope must be read or read-write", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(h.now()) {
+		http.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	key, token, err := h.keys.Create(r.Context(), name, req.Scope, req.ExpiresAt)
+	if err != nil {
+		respond.ServerError(w, r, "failed to create api key", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusCreated, createKeyResponse{APIKey: key, Token: token})
+}
+
+func (h *KeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid api key id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.keys.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "api key not found", http.StatusNotFound)
+			return
+		}
+		respond.ServerError(w, r, "failed to revoke api key", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
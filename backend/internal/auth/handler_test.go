@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeKeyManager struct {
+	createName      string
+	createScope     Scope
+	createExpiresAt *time.Time
+	createErr       error
+
+	listKeys []APIKey
+
+	revokeID  int64
+	revokeErr error
+}
+
+func (f *fakeKeyManager) Create(_ context.Context, name string, scope Scope, expiresAt *time.Time) (APIKey, string, error) {
+	f.createName = name
+	f.createScope = scope
+	f.createExpiresAt = expiresAt
+	if f.createErr != nil {
+		return APIKey{}, "", f.createErr
+	}
+	return APIKey{ID: 7, Name: name, Prefix: "abcd1234", Scope: scope}, "todo_abcd1234_secret", nil
+}
+
+func (f *fakeKeyManager) List(_ context.Context) ([]APIKey, error) {
+	return f.listKeys, nil
+}
+
+func (f *fakeKeyManager) Revoke(_ context.Context, id int64) error {
+	f.revokeID = id
+	return f.revokeErr
+}
+
+func TestCreateKey_Success(t *testing.T) {
+	keys := &fakeKeyManager{}
+	h := NewKeyHandler(keys)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(`{"name":" ci ","scope":"read-write","expiresAt":"2999-01-01T00:00:00Z"}`))
+	rr := httptest.NewRecorder()
+
+	h.CreateKey(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rr.Code)
+	}
+	if keys.createName != "ci" || keys.createScope != ScopeReadWrite || keys.createExpiresAt == nil {
+		t.Fatalf("unexpected create args: %#v", keys)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body["token"] != "todo_abcd1234_secret" || body["prefix"] != "abcd1234" {
+		t.Fatalf("unexpected response body: %#v", body)
+	}
+}
+
+func TestCreateKey_DefaultsToReadScope(t *testing.T) {
+	keys := &fakeKeyManager{}
+	h := NewKeyHandler(keys)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(`{"name":"dashboard"}`))
+	rr := httptest.NewRecorder()
+
+	h.CreateKey(rr, req)
+
+	if rr.Code != http.StatusCreated || keys.createScope != ScopeRead {
+		t.Fatalf("expected read scope, got status %d scope %q", rr.Code, keys.createScope)
+	}
+}
+
+func TestCreateKey_Invalid(t *testing.T) {
+	bodies := []string{
+		`{"name":`,
+		`{"name":"  "}`,
+		`{"name":"ci","scope":"admin"}`,
+		`{"name":"ci","expiresAt":"2000-01-01T00:00:00Z"}`,
+	}
+	for _, body := range bodies {
+		h := NewKeyHandler(&fakeKeyManager{})
+		req := httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		h.CreateKey(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestListKeys_Success(t *testing.T) {
+	h := NewKeyHandler(&fakeKeyManager{listKeys: []APIKey{{ID: 1, Name: "ci", Prefix: "abcd1234", Scope: ScopeRead}}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/keys", nil)
+	rr := httptest.NewRecorder()
+
+	h.ListKeys(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "hash") || strings.Contains(rr.Body.String(), "token") {
+		t.Fatalf("expected no secrets in list response: %s", rr.Body.String())
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	cases := []struct {
+		id   string
+		err  error
+		want int
+	}{
+		{id: "3", want: http.StatusNoContent},
+		{id: "abc", want: http.StatusBadRequest},
+		{id: "4", err: ErrNotFound, want: http.StatusNotFound},
+		{id: "5", err: errors.New("boom"), want: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		keys := &fakeKeyManager{revokeErr: tc.err}
+		h := NewKeyHandler(keys)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/keys/"+tc.id, nil)
+		req.SetPathValue("id", tc.id)
+		rr := httptest.NewRecorder()
+
+		h.RevokeKey(rr, req)
+
+		if rr.Code != tc.want {
+			t.Fatalf("id %s: expected status %d, got %d", tc.id, tc.want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"todoapp/backend/internal/oidc"
+	"todoapp/backend/internal/respond"
+)
+
+const (
+	SessionCookie    = "todo_session"
+	loginStateCookie = "todo_login_state"
+)
+
+type LoginOptions struct {
+	// Issuer is recorded with each user so subjects from different
+	// providers never collide.
+	Issuer     string
+	EmailClaim string
+	NameClaim  string
+	// CookieSecure marks cookies Secure; enable it whenever the server is
+	// reached over HTTPS.
+	CookieSecure bool
+	// PostLogoutURL is where the provider sends the browser after logout.
+	PostLogoutURL string
+}
+
+// LoginHandler implements the OIDC authorization code flow with PKCE and
+// keeps the resulting sessions in a SessionStore.
+type LoginHandler struct {
+	provider *oidc.Provider
+	sessions *SessionStore
+	opts     LoginOptions
+}
+
+func NewLoginHandler(provider *oidc.Provider, sessions *SessionStore, opts LoginOptions) *LoginHandler {
+	if opts.EmailClaim == "" {
+		opts.EmailClaim = "email"
+	}
+	if opts.NameClaim == "" {
+		opts.NameClaim = "name"
+	}
+	return &LoginHandler{provider: provider, sessions: sessions, opts: opts}
+}
+
+// Login redirects the browser to the identity provider. The optional
+// return_to parameter must be a path on this server.
+func (h *LoginHandler) Login(w http.ResponseWriter, r *http.Request) {
+	returnTo := r.URL.Query().Get("return_to")
+	if !isLocalPath(returnTo) {
+		returnTo = "/"
+	}
+
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		respond.ServerError(w, r, "failed to start login", err)
+		return
+	}
+	nonce, err := oidc.RandomString(24)
+	if err != nil {
+		respond.ServerError(w, r, "failed to start login", err)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		respond.ServerError(w, r, "failed to start login", err)
+		return
+	}
+
+	authURL, err := h.provider.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to reach identity provider", "error", err)
+		http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	login := LoginState{State: state, Nonce: nonce, CodeVerifier: verifier, ReturnTo: returnTo}
+	if err := h.sessions.SaveLoginState(r.Context(), login); err != nil {
+		respond.ServerError(w, r, "failed to start login", err)
+		return
+	}
+
+	// The state cookie ties the callback to the browser that started the
+	// login, which stops an attacker from logging a victim into their account.
+	http.SetCookie(w, h.cookie(loginStateCookie, state, int(loginStateTTL/time.Second)))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *LoginHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		slog.WarnContext(r.Context(), "identity provider rejected login", "error", errCode, "description", query.Get("error_description"))
+		http.Error(w, "login failed: "+errCode, http.StatusBadRequest)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(loginStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, h.cookie(loginStateCookie, "", -1))
+
+	login, err := h.sessions.ConsumeLoginState(r.Context(), state)
+	if err != nil {
+		if errors.Is(err, ErrInvalidState) {
+			http.Error(w, "invalid login state", http.StatusBadRequest)
+			return
+		}
+		respond.ServerError(w, r, "failed to complete login", err)
+		return
+	}
+
+	tokens, err := h.provider.Exchange(r.Context(), query.Get("code"), login.CodeVerifier)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to exchange authorization code", "error", err)
+		http.Error(w, "failed to complete login", http.StatusBadGateway)
+		return
+	}
+	claims, err := h.provider.Verify(r.Context(), tokens.IDToken, login.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			slog.WarnContext(r.Context(), "rejected id token", "error", err)
+			http.Error(w, "invalid id token", http.StatusUnauthorized)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to verify id token", "error", err)
+		http.Error(w, "failed to complete login", http.StatusBadGateway)
+		return
+	}
+
+	user, err := h.sessions.UpsertUser(r.Context(), h.opts.Issuer, claims.String("sub"),
+		claims.String(h.opts.EmailClaim), claims.String(h.opts.NameClaim))
+	if err != nil {
+		respond.ServerError(w, r, "failed to save user", err)
+		return
+	}
+	token, expiresAt, err := h.sessions.CreateSession(r.Context(), user.ID, tokens.IDToken)
+	if err != nil {
+		respond.ServerError(w, r, "failed to create session", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "user logged in", "user_id", user.ID)
+	http.SetCookie(w, h.cookie(SessionCookie, token, int(time.Until(expiresAt)/time.Second)))
+	http.Redirect(w, r, login.ReturnTo, http.StatusFound)
+}
+
+// Logout ends the local session and, when the provider supports it, sends
+// the browser on to the provider's logout endpoint.
+func (h *LoginHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var idToken string
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		idToken, err = h.sessions.DeleteSession(r.Context(), cookie.Value)
+		if err != nil && !errors.Is(err, ErrInvalidSession) {
+			respond.ServerError(w, r, "failed to log out", err)
+			return
+		}
+	}
+	http.SetCookie(w, h.cookie(SessionCookie, "", -1))
+
+	target := h.opts.PostLogoutURL
+	if idToken != "" {
+		endSessionURL, err := h.provider.EndSessionURL(r.Context(), idToken, h.opts.PostLogoutURL)
+		if err != nil {
+			slog.WarnContext(r.Context(), "failed to build end session url", "error", err)
+		} else if endSessionURL != "" {
+			target = endSessionURL
+		}
+	}
+	if target == "" {
+		target = "/"
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// Me returns the user behind the session cookie.
+func (h *LoginHandler) Me(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+	session, err := h.sessions.AuthenticateSession(r.Context(), cookie.Value)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSession) {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		respond.ServerError(w, r, "failed to load session", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, session.User)
+}
+
+func (h *LoginHandler) cookie(name string, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.opts.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// isLocalPath accepts only paths on this origin, rejecting absolute and
+// protocol-relative URLs that would turn login into an open redirect.
+func isLocalPath(target string) bool {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return false
+	}
+	parsed, err := url.Parse(target)
+	return err == nil && parsed.Scheme == "" && parsed.Host == ""
+}
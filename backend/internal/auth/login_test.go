@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/oidc"
+	"todoapp/backend/internal/oidc/oidctest"
+)
+
+type loginFixture struct {
+	idp      *oidctest.Server
+	app      *httptest.Server
+	sessions *SessionStore
+	client   *http.Client
+}
+
+// newLoginFixture serves the login routes in front of a page that echoes the
+// authenticated principal, all against a mock identity provider.
+func newLoginFixture(t *testing.T) *loginFixture {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	f := &loginFixture{idp: oidctest.NewServer(t, "todo-app"), sessions: NewSessionStore(database, time.Hour)}
+	mux := http.NewServeMux()
+	f.app = httptest.NewServer(NewAuthenticator(nil, f.sessions, "").Middleware(mux))
+	t.Cleanup(f.app.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:      f.idp.Issuer(),
+		ClientID:    "todo-app",
+		RedirectURL: f.app.URL + "/auth/callback",
+	})
+	login := NewLoginHandler(provider, f.sessions, LoginOptions{Issuer: f.idp.Issuer(), PostLogoutURL: f.app.URL + "/"})
+	mux.HandleFunc("GET /auth/login", login.Login)
+	mux.HandleFunc("GET /auth/callback", login.Callback)
+	mux.HandleFunc("POST /auth/logout", login.Logout)
+	mux.HandleFunc("GET /auth/me", login.Me)
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			io.WriteString(w, "anonymous "+r.URL.Path)
+			return
+		}
+		io.WriteString(w, principal.Kind+":"+principal.Name+" "+r.URL.Path)
+	})
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookie jar: %v", err)
+	}
+	f.client = &http.Client{Jar: jar}
+	return f
+}
+
+func (f *loginFixture) get(t *testing.T, path string) (int, string) {
+	t.Helper()
+
+	resp, err := f.client.Get(f.app.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, strings.TrimSpace(string(body))
+}
+
+func TestLoginFlow(t *testing.T) {
+	f := newLoginFixture(t)
+
+	status, body := f.get(t, "/auth/login?return_to=/lists/1")
+	if status != http.StatusOK || body != "user:Alice /lists/1" {
+		t.Fatalf("unexpected page after login: %d %q", status, body)
+	}
+
+	status, body = f.get(t, "/auth/me")
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	var user User
+	if err := json.Unmarshal([]byte(body), &user); err != nil {
+		t.Fatalf("decode user: %v", err)
+	}
+	if user.Subject != "user-1" || user.Email != "alice@example.com" || user.Issuer != f.idp.Issuer() {
+		t.Fatalf("unexpected user: %#v", user)
+	}
+
+	// A second login maps to the same user and picks up profile changes.
+	f.idp.User["name"] = "Alice Smith"
+	if _, body = f.get(t, "/auth/login"); body != "user:Alice Smith /" {
+		t.Fatalf("unexpected page after second login: %q", body)
+	}
+	var users int
+	if err := f.sessions.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&users); err != nil || users != 1 {
+		t.Fatalf("expected one user, got %d (%v)", users, err)
+	}
+
+	noRedirect := *f.client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := noRedirect.Post(f.app.URL+"/auth/logout", "", nil)
+	if err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || !strings.HasPrefix(resp.Header.Get("Location"), f.idp.URL+"/logout?") {
+		t.Fatalf("expected redirect to end session endpoint, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if status, _ := f.get(t, "/auth/me"); status != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 after logout, got %d", status)
+	}
+}
+
+func TestLogin_RejectsOpenRedirect(t *testing.T) {
+	f := newLoginFixture(t)
+
+	for _, target := range []string{"https://evil.example.com/", "//evil.example.com/", "/\\evil.example.com"} {
+		if _, body := f.get(t, "/auth/login?return_to="+target); body != "user:Alice /" {
+			t.Fatalf("%s: expected redirect to /, got %q", target, body)
+		}
+	}
+}
+
+func TestCallback_RejectsUnknownState(t *testing.T) {
+	f := newLoginFixture(t)
+
+	status, _ := f.get(t, "/auth/callback?code=abc&state=forged")
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", status)
+	}
+	if status, _ := f.get(t, "/auth/callback?error=access_denied"); status != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for provider error, got %d", status)
+	}
+}
+
+func TestCallback_RejectsReplayedState(t *testing.T) {
+	f := newLoginFixture(t)
+
+	noRedirect := *f.client
+	noRedirect.CheckRedirect = func(req *http.Request, _ []*http.Request) error {
+		if strings.HasPrefix(req.URL.String(), f.app.URL+"/auth/callback") {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	resp, err := noRedirect.Get(f.app.URL + "/auth/login")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	resp.Body.Close()
+	callback := resp.Header.Get("Location")
+
+	if status, _ := f.get(t, strings.TrimPrefix(callback, f.app.URL)); status != http.StatusOK {
+		t.Fatalf("expected first callback to succeed, got %d", status)
+	}
+	if status, _ := f.get(t, strings.TrimPrefix(callback, f.app.URL)); status != http.StatusBadRequest {
+		t.Fatalf("expected replayed callback to fail, got %d", status)
+	}
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	KindAPIKey = "api_key"
+	KindAdmin  = "admin"
+	KindUser   = "user"
+)
+
+var (
+	ErrUnauthenticated   = errors.New("authentication required")
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (APIKey, error)
+}
+
+type SessionAuthenticator interface {
+	AuthenticateSession(ctx context.Context, token string) (Session, error)
+}
+
+// Authenticator resolves bearer credentials and session cookies into a
+// Principal. HTTP Basic credentials are accepted too, with the token as the
+// password, for clients such as CalDAV apps that cannot send bearer tokens.
+// It only identifies callers; routes enforce access with RequireScope and
+// RequireAdmin.
+type Authenticator struct {
+	keys       KeyAuthenticator
+	sessions   SessionAuthenticator
+	adminToken string
+}
+
+// NewAuthenticator returns an Authenticator; sessions may be nil when
+// browser login is not configured.
+func NewAuthenticator(keys KeyAuthenticator, sessions SessionAuthenticator, adminToken string) *Authenticator {
+	return &Authenticator{keys: keys, sessions: sessions, adminToken: adminToken}
+}
+
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, basic, ok := credentials(r)
+		if !ok {
+			a.serveSession(w, r, next)
+			return
+		}
+
+		if a.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) == 1 {
+			serveAs(w, r, next, Principal{Kind: KindAdmin, Name: "admin", Scope: ScopeReadWrite})
+			return
+		}
+
+		key, err := a.keys.Authenticate(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, ErrInvalidKey) && basic {
+				BasicChallenge(w)
+				return
+			}
+			if errors.Is(err, ErrInvalidKey) {
+				unauthorized(w, `error="invalid_token"`)
+				return
+			}
+			slog.ErrorContext(r.Context(), "failed to authenticate api key", "error", err)
+			http.Error(w, "failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		serveAs(w, r, next, Principal{Kind: KindAPIKey, ID: key.ID, Name: key.Name, Scope: key.Scope})
+	})
+}
+
+// serveSession authenticates the session cookie, if any. A stale cookie
+// is not an error: the request simply continues anonymously.
+func (a *Authenticator) serveSession(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	cookie, err := r.Cookie(SessionCookie)
+	if a.sessions == nil || err != nil || cookie.Value == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	session, err := a.sessions.AuthenticateSession(r.Context(), cookie.Value)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSession) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to authenticate session", "error", err)
+		http.Error(w, "failed to authenticate", http.StatusInternalServerError)
+		return
+	}
+
+	name := session.User.Name
+	if name == "" {
+		name = session.User.Email
+	}
+	serveAs(w, r, next, Principal{Kind: KindUser, ID: session.User.ID, Name: name, Scope: ScopeReadWrite})
+}
+
+// serveAs runs next as principal on a copy of r, then copies the ServeMux
+// pattern set on that copy back onto r for the logging and tracing
+// middleware wrapping this one.
+func serveAs(w http.ResponseWriter, r *http.Request, next http.Handler, principal Principal) {
+	authenticated := r.WithContext(WithPrincipal(r.Context(), principal))
+	next.ServeHTTP(w, authenticated)
+	r.Pattern = authenticated.Pattern
+}
+
+// checkScope returns ErrUnauthenticated for an anonymous caller, unless
+// allowAnonymous is set, and ErrInsufficientScope for credentials that lack
+// scope.
+func checkScope(ctx context.Context, scope Scope, allowAnonymous bool) error {
+	principal, ok := PrincipalFromContext(ctx)
+	switch {
+	case !ok && allowAnonymous:
+		return nil
+	case !ok:
+		return ErrUnauthenticated
+	case !principal.Scope.Allows(scope):
+		return ErrInsufficientScope
+	}
+	return nil
+}
+
+// RequireScope rejects callers whose credentials lack scope. Anonymous
+// callers are let through only when allowAnonymous is set.
+func RequireScope(scope Scope, allowAnonymous bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch err := checkScope(r.Context(), scope, allowAnonymous); err {
+		case ErrUnauthenticated:
+			unauthorized(w, "")
+			return
+		case ErrInsufficientScope:
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CheckWrite is the check RequireScope makes on the REST routes that
+// write, for transports that are not routed per operation. Callers map
+// ErrUnauthenticated and ErrInsufficientScope to their own errors.
+func CheckWrite(ctx context.Context, allowAnonymous bool) error {
+	return checkScope(ctx, ScopeReadWrite, allowAnonymous)
+}
+
+// UserID returns the ID of the signed-in user in ctx, to credit with a
+// completion, or nil for anonymous, API key and admin callers.
+func UserID(ctx context.Context) *int64 {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok || principal.Kind != KindUser {
+		return nil
+	}
+	return &principal.ID
+}
+
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			unauthorized(w, "")
+			return
+		}
+		if principal.Kind != KindAdmin {
+			http.Error(w, "admin token required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// credentials returns the bearer token, or the password of HTTP Basic
+// credentials, in which case basic is set. The Basic user name is ignored.
+func credentials(r *http.Request) (token string, basic bool, ok bool) {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password, true, password != ""
+	}
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false, false
+	}
+	token = strings.TrimSpace(token)
+	return token, false, token != ""
+}
+
+// BasicChallenge asks for HTTP Basic credentials, which clients such as
+// CalDAV apps prompt the user for.
+func BasicChallenge(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="todoapp", charset="UTF-8"`)
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+}
+
+func unauthorized(w http.ResponseWriter, params string) {
+	challenge := "Bearer"
+	if params != "" {
+		challenge += " " + params
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+}
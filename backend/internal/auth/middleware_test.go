@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"todoapp/backend/internal/logging"
+	"todoapp/backend/internal/tracing"
+)
+
+type fakeKeys struct {
+	keys map[string]APIKey
+	err  error
+}
+
+func (f *fakeKeys) Authenticate(_ context.Context, token string) (APIKey, error) {
+	if f.err != nil {
+		return APIKey{}, f.err
+	}
+	key, ok := f.keys[token]
+	if !ok {
+		return APIKey{}, ErrInvalidKey
+	}
+	return key, nil
+}
+
+type fakeSessions struct {
+	sessions map[string]Session
+}
+
+func (f *fakeSessions) AuthenticateSession(_ context.Context, token string) (Session, error) {
+	session, ok := f.sessions[token]
+	if !ok {
+		return Session{}, ErrInvalidSession
+	}
+	return session, nil
+}
+
+func newTestAuthenticator() *Authenticator {
+	return NewAuthenticator(&fakeKeys{keys: map[string]APIKey{
+		"rw-token": {ID: 1, Name: "ci", Scope: ScopeReadWrite},
+		"ro-token": {ID: 2, Name: "dashboard", Scope: ScopeRead},
+	}}, &fakeSessions{sessions: map[string]Session{
+		"session-token": {ID: 1, User: User{ID: 7, Email: "alice@example.com"}},
+	}}, "admin-secret")
+}
+
+func serveWithAuth(a *Authenticator, guard func(http.Handler) http.Handler, authorization string) (*httptest.ResponseRecorder, *Principal) {
+	var seen *Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal, ok := PrincipalFromContext(r.Context()); ok {
+			seen = &principal
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rr := httptest.NewRecorder()
+	a.Middleware(guard(next)).ServeHTTP(rr, req)
+	return rr, seen
+}
+
+func requireWrite(allowAnonymous bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return RequireScope(ScopeReadWrite, allowAnonymous, next)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	a := newTestAuthenticator()
+
+	cases := []struct {
+		name           string
+		authorization  string
+		allowAnonymous bool
+		want           int
+	}{
+		{name: "anonymous rejected", want: http.StatusUnauthorized},
+		{name: "anonymous allowed", allowAnonymous: true, want: http.StatusOK},
+		{name: "read-write key", authorization: "Bearer rw-token", want: http.StatusOK},
+		{name: "lowercase scheme", authorization: "bearer rw-token", want: http.StatusOK},
+		{name: "read-only key", authorization: "Bearer ro-token", allowAnonymous: true, want: http.StatusForbidden},
+		{name: "unknown key", authorization: "Bearer nope", allowAnonymous: true, want: http.StatusUnauthorized},
+		{name: "admin token", authorization: "Bearer admin-secret", want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		rr, _ := serveWithAuth(a, requireWrite(tc.allowAnonymous), tc.authorization)
+		if rr.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.want, rr.Code)
+		}
+		if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%s: expected WWW-Authenticate challenge", tc.name)
+		}
+	}
+}
+
+func TestCheckWriteAndUserID(t *testing.T) {
+	anonymous := context.Background()
+	user := WithPrincipal(anonymous, Principal{Kind: KindUser, ID: 3, Scope: ScopeReadWrite})
+	readOnly := WithPrincipal(anonymous, Principal{Kind: KindAPIKey, ID: 3, Scope: ScopeRead})
+
+	if err := CheckWrite(anonymous, false); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("expected ErrUnauthenticated, got %v", err)
+	}
+	if err := CheckWrite(anonymous, true); err != nil {
+		t.Fatalf("expected anonymous writes to be allowed, got %v", err)
+	}
+	if err := CheckWrite(readOnly, true); !errors.Is(err, ErrInsufficientScope) {
+		t.Fatalf("expected ErrInsufficientScope, got %v", err)
+	}
+	if err := CheckWrite(user, false); err != nil {
+		t.Fatalf("expected the user to write, got %v", err)
+	}
+
+	if id := UserID(user); id == nil || *id != 3 {
+		t.Fatalf("expected user 3, got %v", id)
+	}
+	if id := UserID(readOnly); id != nil {
+		t.Fatalf("expected no user for an api key, got %d", *id)
+	}
+}
+
+func TestMiddleware_SetsPrincipal(t *testing.T) {
+	rr, principal := serveWithAuth(newTestAuthenticator(), requireWrite(false), "Bearer rw-token")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if principal == nil || principal.Kind != KindAPIKey || principal.ID != 1 || principal.Key() != "api_key:1" {
+		t.Fatalf("unexpected principal: %#v", principal)
+	}
+}
+
+func TestMiddleware_ExposesRouteToOuterMiddleware(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/todos/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	// The same order as cmd/server.
+	handler := tracing.Middleware(logging.Middleware(logger)(newTestAuthenticator().Middleware(mux)))
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+	}{
+		{name: "admin token", header: "Authorization", value: "Bearer admin-secret"},
+		{name: "api key", header: "Authorization", value: "Bearer rw-token"},
+		{name: "basic", header: "Authorization", value: "Basic " + base64.StdEncoding.EncodeToString([]byte("anyone:rw-token"))},
+		{name: "session", header: "Cookie", value: SessionCookie + "=session-token"},
+		{name: "anonymous"},
+	}
+	for _, tc := range cases {
+		logs.Reset()
+		spans.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/api/todos/7", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d", tc.name, rr.Code)
+		}
+
+		var entry struct {
+			Route string `json:"route"`
+		}
+		if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: decode log entry %q: %v", tc.name, logs.String(), err)
+		}
+		if entry.Route != "GET /api/todos/{id}" {
+			t.Fatalf("%s: unexpected logged route %q", tc.name, entry.Route)
+		}
+		ended := spans.Ended()
+		if len(ended) != 1 {
+			t.Fatalf("%s: expected 1 span, got %d", tc.name, len(ended))
+		}
+		var route attribute.Value
+		for _, attr := range ended[0].Attributes() {
+			if attr.Key == "http.route" {
+				route = attr.Value
+			}
+		}
+		if route.AsString() != "GET /api/todos/{id}" {
+			t.Fatalf("%s: unexpected span route %q", tc.name, route.AsString())
+		}
+	}
+}
+
+func TestMiddleware_BasicAuth(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("anyone:rw-token"))
+	rr, principal := serveWithAuth(newTestAuthenticator(), requireWrite(false), basic)
+	if rr.Code != http.StatusOK || principal == nil || principal.ID != 1 {
+		t.Fatalf("expected the password to authenticate as the key, got %d %#v", rr.Code, principal)
+	}
+
+	wrong := "Basic " + base64.StdEncoding.EncodeToString([]byte("anyone:wrong"))
+	rr, _ = serveWithAuth(newTestAuthenticator(), requireWrite(false), wrong)
+	if rr.Code != http.StatusUnauthorized || !strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), "Basic ") {
+		t.Fatalf("expected a Basic challenge, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestMiddleware_SessionCookie(t *testing.T) {
+	a := newTestAuthenticator()
+
+	serve := func(cookie string) (int, *Principal) {
+		var seen *Principal
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal, ok := PrincipalFromContext(r.Context()); ok {
+				seen = &principal
+			}
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/todos", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: cookie})
+		rr := httptest.NewRecorder()
+		a.Middleware(RequireScope(ScopeReadWrite, true, next)).ServeHTTP(rr, req)
+		return rr.Code, seen
+	}
+
+	code, principal := serve("session-token")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if principal == nil || principal.Kind != KindUser || principal.ID != 7 || principal.Name != "alice@example.com" {
+		t.Fatalf("unexpected principal: %#v", principal)
+	}
+
+	code, principal = serve("stale-token")
+	if code != http.StatusOK || principal != nil {
+		t.Fatalf("expected stale cookie to fall back to anonymous, got %d %#v", code, principal)
+	}
+}
+
+func TestMiddleware_StoreError(t *testing.T) {
+	a := NewAuthenticator(&fakeKeys{err: errors.New("boom")}, nil, "")
+
+	rr, _ := serveWithAuth(a, requireWrite(true), "Bearer whatever")
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", rr.Code)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	a := newTestAuthenticator()
+
+	cases := map[string]int{
+		"":                    http.StatusUnauthorized,
+		"Bearer rw-token":     http.StatusForbidden,
+		"Bearer admin-secret": http.StatusOK,
+	}
+	for authorization, want := range cases {
+		rr, _ := serveWithAuth(a, RequireAdmin, authorization)
+		if rr.Code != want {
+			t.Fatalf("%q: expected status %d, got %d", authorization, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+)
+
+type Scope string
+
+const (
+	ScopeRead      Scope = "read"
+	ScopeReadWrite Scope = "read-write"
+)
+
+func (s Scope) Valid() bool {
+	return s == ScopeRead || s == ScopeReadWrite
+}
+
+func (s Scope) Allows(required Scope) bool {
+	return s == ScopeReadWrite || s == required
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Kind identifies the credential type, e.g. "api_key".
+	Kind  string
+	ID    int64
+	Name  string
+	Scope Scope
+}
+
+// Key is a stable identifier for per-caller bookkeeping such as rate limits.
+func (p Principal) Key() string {
+	return p.Kind + ":" + strconv.FormatInt(p.ID, 10)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultSessionTTL = 7 * 24 * time.Hour
+	// loginStateTTL bounds how long a user may take at the identity provider.
+	loginStateTTL = 10 * time.Minute
+)
+
+var (
+	ErrInvalidSession = errors.New("invalid session")
+	ErrInvalidState   = errors.New("invalid login state")
+)
+
+type User struct {
+	ID        int64     `json:"id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Session struct {
+	ID        int64
+	User      User
+	IDToken   string
+	ExpiresAt time.Time
+}
+
+// LoginState is what the server remembers between redirecting a browser to
+// the identity provider and receiving the callback.
+type LoginState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	ReturnTo     string
+}
+
+// SessionStore persists users signed in through OIDC and their browser
+// sessions. As with API keys, only a hash of each session token is stored.
+type SessionStore struct {
+	db  *sql.DB
+	ttl time.Duration
+	now func() time.Time
+}
+
+func NewSessionStore(db *sql.DB, ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &SessionStore{db: db, ttl: ttl, now: time.Now}
+}
+
+// UpsertUser finds the user identified by issuer and subject, creating it on
+// first login and refreshing email and name from the latest ID token.
+func (s *SessionStore) UpsertUser(ctx context.Context, issuer string, subject string, email string, name string) (User, error) {
+	now := s.now().UTC()
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO users (issuer, subject, email, name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (issuer, subject) DO UPDATE SET email = excluded.email, name = excluded.name, updated_at = excluded.updated_at`,
+		issuer, subject, email, name, now, now,
+	); err != nil {
+		return User{}, err
+	}
+
+	var user User
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, issuer, subject, email, name, created_at, updated_at FROM users WHERE issuer = ? AND subject = ?`,
+		issuer, subject,
+	).Scan(&user.ID, &user.Issuer, &user.Subject, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// CreateSession starts a session for userID and returns its token, which is
+// only ever sent to the browser as a cookie.
+func (s *SessionStore) CreateSession(ctx context.Context, userID int64, idToken string) (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := s.now().UTC()
+	expiresAt := now.Add(s.ttl)
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO sessions (token_hash, user_id, id_token, created_at, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		HashToken(token), userID, idToken, now, expiresAt,
+	); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func (s *SessionStore) AuthenticateSession(ctx context.Context, token string) (Session, error) {
+	var session Session
+	err := s.db.QueryRowContext(ctx, `
+		SELECT sessions.id, sessions.id_token, sessions.expires_at,
+			users.id, users.issuer, users.subject, users.email, users.name, users.created_at, users.updated_at
+		FROM sessions JOIN users ON users.id = sessions.user_id
+		WHERE sessions.token_hash = ?`, HashToken(token),
+	).Scan(
+		&session.ID, &session.IDToken, &session.ExpiresAt,
+		&session.User.ID, &session.User.Issuer, &session.User.Subject, &session.User.Email, &session.User.Name,
+		&session.User.CreatedAt, &session.User.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Session{}, ErrInvalidSession
+		}
+		return Session{}, err
+	}
+	if !s.now().Before(session.ExpiresAt) {
+		return Session{}, ErrInvalidSession
+	}
+	return session, nil
+}
+
+// DeleteSession ends the session for token and returns its ID token so the
+// caller can pass it to the provider's logout endpoint.
+func (s *SessionStore) DeleteSession(ctx context.Context, token string) (string, error) {
+	var idToken string
+	err := s.db.QueryRowContext(ctx,
+		`DELETE FROM sessions WHERE token_hash = ? RETURNING id_token`, HashToken(token),
+	).Scan(&idToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrInvalidSession
+		}
+		return "", err
+	}
+	return idToken, nil
+}
+
+// SaveLoginState records an in-flight login and prunes abandoned ones and
+// expired sessions.
+func (s *SessionStore) SaveLoginState(ctx context.Context, state LoginState) error {
+	now := s.now().UTC()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM login_states WHERE created_at < ?`, now.Add(-loginStateTTL)); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < ?`, now); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO login_states (state, nonce, code_verifier, return_to, created_at) VALUES (?, ?, ?, ?, ?)`,
+		state.State, state.Nonce, state.CodeVerifier, state.ReturnTo, now,
+	)
+	return err
+}
+
+// ConsumeLoginState returns and deletes the login started with state, so each
+// authorization response can be redeemed once.
+func (s *SessionStore) ConsumeLoginState(ctx context.Context, state string) (LoginState, error) {
+	var (
+		login     = LoginState{State: state}
+		createdAt time.Time
+	)
+	err := s.db.QueryRowContext(ctx,
+		`DELETE FROM login_states WHERE state = ? RETURNING nonce, code_verifier, return_to, created_at`, state,
+	).Scan(&login.Nonce, &login.CodeVerifier, &login.ReturnTo, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return LoginState{}, ErrInvalidState
+		}
+		return LoginState{}, err
+	}
+	if s.now().Sub(createdAt) > loginStateTTL {
+		return LoginState{}, ErrInvalidState
+	}
+	return login, nil
+}
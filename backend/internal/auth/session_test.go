@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+)
+
+func setupSessionStore(t *testing.T) (*SessionStore, *time.Time) {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := NewSessionStore(database, time.Hour)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestSessionStore_SessionExpires(t *testing.T) {
+	store, now := setupSessionStore(t)
+	ctx := context.Background()
+
+	user, err := store.UpsertUser(ctx, "https://idp.example.com", "u1", "a@example.com", "Alice")
+	if err != nil {
+		t.Fatalf("upsert user: %v", err)
+	}
+	token, _, err := store.CreateSession(ctx, user.ID, "id-token")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	session, err := store.AuthenticateSession(ctx, token)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if session.User.ID != user.ID || session.IDToken != "id-token" {
+		t.Fatalf("unexpected session: %#v", session)
+	}
+
+	*now = now.Add(time.Hour)
+	if _, err := store.AuthenticateSession(ctx, token); !errors.Is(err, ErrInvalidSession) {
+		t.Fatalf("expected expired session to be rejected, got %v", err)
+	}
+	if _, err := store.AuthenticateSession(ctx, "unknown"); !errors.Is(err, ErrInvalidSession) {
+		t.Fatalf("expected unknown token to be rejected, got %v", err)
+	}
+}
+
+func TestSessionStore_LoginStateIsSingleUse(t *testing.T) {
+	store, now := setupSessionStore(t)
+	ctx := context.Background()
+
+	if err := store.SaveLoginState(ctx, LoginState{State: "s1", Nonce: "n1", CodeVerifier: "v1", ReturnTo: "/"}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	login, err := store.ConsumeLoginState(ctx, "s1")
+	if err != nil || login.Nonce != "n1" || login.CodeVerifier != "v1" {
+		t.Fatalf("unexpected login state %#v: %v", login, err)
+	}
+	if _, err := store.ConsumeLoginState(ctx, "s1"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected consumed state to be rejected, got %v", err)
+	}
+
+	if err := store.SaveLoginState(ctx, LoginState{State: "s2", Nonce: "n2", CodeVerifier: "v2", ReturnTo: "/"}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	*now = now.Add(loginStateTTL + time.Second)
+	if _, err := store.ConsumeLoginState(ctx, "s2"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected stale state to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+// Package blob stores attachment content addressed by its SHA-256 digest,
+// so identical uploads share one copy.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store is the storage backend for attachments. FS keeps blobs on the local
+// disk; an object store such as S3 can be plugged in by implementing the
+// same three methods.
+type Store interface {
+	// Put stores content and returns its key, the hex SHA-256 of the bytes,
+	// and its size. Storing content that already exists is not an error.
+	Put(ctx context.Context, content io.Reader) (key string, size int64, err error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the blob; deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func validKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFS_PutOpenDelete(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFS(dir)
+	if err != nil {
+		t.Fatalf("new fs: %v", err)
+	}
+	ctx := context.Background()
+
+	key, size, err := store.Put(ctx, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	sum := sha256.Sum256([]byte("hello"))
+	if key != hex.EncodeToString(sum[:]) || size != 5 {
+		t.Fatalf("unexpected key %q size %d", key, size)
+	}
+
+	// Identical content is stored once.
+	if again, _, err := store.Put(ctx, strings.NewReader("hello")); err != nil || again != key {
+		t.Fatalf("expected duplicate put to return the same key, got %q (%v)", again, err)
+	}
+
+	file, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "hello" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("expected deleting a missing blob to succeed: %v", err)
+	}
+
+	leftovers, _ := os.ReadDir(filepath.Join(dir, "tmp"))
+	if len(leftovers) != 0 {
+		t.Fatalf("expected temp files to be cleaned up, found %d", len(leftovers))
+	}
+}
+
+func TestFS_RejectsInvalidKeys(t *testing.T) {
+	store, err := NewFS(t.TempDir())
+	if err != nil {
+		t.Fatalf("new fs: %v", err)
+	}
+
+	for _, key := range []string{"../../etc/passwd", "abc", strings.Repeat("g", 64)} {
+		if _, err := store.Open(context.Background(), key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("%q: expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestFS_PutFailureLeavesNoBlob(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFS(dir)
+	if err != nil {
+		t.Fatalf("new fs: %v", err)
+	}
+
+	if _, _, err := store.Put(context.Background(), io.MultiReader(strings.NewReader("partial"), failingReader{})); err == nil {
+		t.Fatalf("expected put to fail")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected only the tmp directory, found %d entries", len(entries))
+	}
+	leftovers, _ := os.ReadDir(filepath.Join(dir, "tmp"))
+	if len(leftovers) != 0 {
+		t.Fatalf("expected temp file to be removed, found %d", len(leftovers))
+	}
+}
@@ -0,0 +1,512 @@
+// Package caldav serves todo lists as CalDAV task collections (RFC 4791),
+// so that native task apps can sync todos two-way with the same data the
+// REST API uses.
+//
+// The server exposes one principal per caller and one calendar per list:
+//
+//	/dav/principal/                    the caller
+//	/dav/calendars/default/            the default list
+//	/dav/calendars/list-{id}/          a shared list
+//	/dav/calendars/{calendar}/{uid}.ics  one todo as a VTODO
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/ical"
+	"todoapp/backend/internal/respond"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+)
+
+// Prefix is the path the handler must be mounted at.
+const Prefix = "/dav/"
+
+const (
+	defaultCalendar = "default"
+	listCalendar    = "list-"
+	objectSuffix    = ".ics"
+	syncTokenPrefix = "urn:todoapp:sync:"
+)
+
+// stamp is the DTSTAMP of every served VTODO. The repository does not track
+// modification times, and a fixed stamp keeps a todo's serialization, and
+// so its ETag, stable until the todo changes.
+var stamp = time.Unix(0, 0)
+
+type Store interface {
+	List(ctx context.Context, listID *int64) ([]todo.Item, error)
+	GetByUID(ctx context.Context, listID *int64, uid string) (todo.Item, error)
+	UpsertByUID(ctx context.Context, listID *int64, items []todo.Item) ([]todo.ImportOutcome, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+// Lists returns the shared lists a user belongs to, with their role.
+type Lists interface {
+	Lists(ctx context.Context, userID int64) ([]sharing.List, error)
+}
+
+type Handler struct {
+	store          Store
+	lists          Lists
+	allowAnonymous bool
+	maxBodyBytes   int64
+	maxTitleLength int
+}
+
+type Option func(*Handler)
+
+// WithLists publishes the shared lists of signed-in users. Without it only
+// the default list is served.
+func WithLists(l Lists) Option {
+	return func(h *Handler) { h.lists = l }
+}
+
+// WithAnonymous serves the default list to callers without credentials,
+// matching a server that does not require authentication.
+func WithAnonymous(allow bool) Option {
+	return func(h *Handler) { h.allowAnonymous = allow }
+}
+
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *Handler) { h.maxBodyBytes = n }
+}
+
+func WithMaxTitleLength(n int) Option {
+	return func(h *Handler) { h.maxTitleLength = n }
+}
+
+func NewHandler(store Store, opts ...Option) *Handler {
+	h := &Handler{
+		store:          store,
+		maxBodyBytes:   todo.DefaultMaxBodyBytes,
+		maxTitleLength: todo.DefaultMaxTitleLength,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+var (
+	errNotFound        = errors.New("not found")
+	errUnauthenticated = errors.New("authentication required")
+)
+
+type resourceKind int
+
+const (
+	kindRoot resourceKind = iota
+	kindPrincipal
+	kindHome
+	kindCalendar
+	kindObject
+)
+
+// target is the resource a request path names.
+type target struct {
+	kind     resourceKind
+	calendar string
+	uid      string
+}
+
+// calendar is a list the caller may access.
+type calendar struct {
+	segment string
+	listID  *int64
+	name    string
+	write   bool
+}
+
+func (c calendar) href() string {
+	return Prefix + "calendars/" + c.segment + "/"
+}
+
+func (c calendar) objectHref(uid string) string {
+	return c.href() + url.PathEscape(uid) + objectSuffix
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.PrincipalFromContext(r.Context()); !ok && !h.allowAnonymous {
+		auth.BasicChallenge(w)
+		return
+	}
+	if r.Method == http.MethodOptions {
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	t, err := parsePath(r.URL.EscapedPath())
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case "PROPFIND":
+		h.propfind(w, r, t)
+	case "PROPPATCH":
+		h.proppatch(w, r, t)
+	case "REPORT":
+		h.report(w, r, t)
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, t)
+	case http.MethodPut:
+		h.put(w, r, t)
+	case http.MethodDelete:
+		h.delete(w, r, t)
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+const allowedMethods = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT"
+
+// parsePath resolves an escaped request path or href below Prefix.
+func parsePath(path string) (target, error) {
+	rest, ok := strings.CutPrefix(path, Prefix)
+	if !ok {
+		if path+"/" == Prefix {
+			return target{kind: kindRoot}, nil
+		}
+		return target{}, errNotFound
+	}
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimSuffix(rest, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return target{}, errNotFound
+		}
+		segments = append(segments, unescaped)
+	}
+
+	switch {
+	case len(segments) == 0:
+		return target{kind: kindRoot}, nil
+	case len(segments) == 1 && segments[0] == "principal":
+		return target{kind: kindPrincipal}, nil
+	case segments[0] != "calendars" || len(segments) > 3:
+		return target{}, errNotFound
+	case len(segments) == 1:
+		return target{kind: kindHome}, nil
+	case len(segments) == 2:
+		return target{kind: kindCalendar, calendar: segments[1]}, nil
+	}
+	uid, ok := strings.CutSuffix(segments[2], objectSuffix)
+	if !ok || uid == "" {
+		return target{}, errNotFound
+	}
+	return target{kind: kindObject, calendar: segments[1], uid: uid}, nil
+}
+
+// calendars returns every calendar the caller can see: the default list and
+// the shared lists a signed-in user belongs to.
+func (h *Handler) calendars(ctx context.Context) ([]calendar, error) {
+	calendars := []calendar{{segment: defaultCalendar, name: "Todos", write: canWrite(ctx, h.allowAnonymous)}}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || principal.Kind != auth.KindUser || h.lists == nil {
+		return calendars, nil
+	}
+	lists, err := h.lists.Lists(ctx, principal.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		calendars = append(calendars, calendar{
+			segment: listCalendar + strconv.FormatInt(list.ID, 10),
+			listID:  &list.ID,
+			name:    list.Name,
+			write:   list.Role.CanEdit() && canWrite(ctx, h.allowAnonymous),
+		})
+	}
+	return calendars, nil
+}
+
+// calendar resolves a calendar segment with the same rules as the REST
+// API: lists the caller does not belong to are not found.
+func (h *Handler) calendar(ctx context.Context, segment string) (calendar, error) {
+	if segment == defaultCalendar {
+		return calendar{segment: segment, name: "Todos", write: canWrite(ctx, h.allowAnonymous)}, nil
+	}
+	raw, ok := strings.CutPrefix(segment, listCalendar)
+	if !ok {
+		return calendar{}, errNotFound
+	}
+	listID, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || listID <= 0 || strconv.FormatInt(listID, 10) != raw {
+		return calendar{}, errNotFound
+	}
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	switch {
+	case !ok:
+		return calendar{}, errUnauthenticated
+	case principal.Kind == auth.KindAdmin:
+		return calendar{segment: segment, listID: &listID, name: "List " + raw, write: true}, nil
+	}
+	calendars, err := h.calendars(ctx)
+	if err != nil {
+		return calendar{}, err
+	}
+	for _, c := range calendars {
+		if c.segment == segment {
+			return c, nil
+		}
+	}
+	return calendar{}, errNotFound
+}
+
+// canWrite reports whether the caller's credentials allow changes at all;
+// list roles further restrict shared lists.
+func canWrite(ctx context.Context, allowAnonymous bool) bool {
+	return auth.CheckWrite(ctx, allowAnonymous) == nil
+}
+
+// resolve looks up the calendar of t, answering the request itself when it
+// cannot be used.
+func (h *Handler) resolve(w http.ResponseWriter, r *http.Request, segment string) (calendar, bool) {
+	c, err := h.calendar(r.Context(), segment)
+	switch {
+	case errors.Is(err, errUnauthenticated):
+		auth.BasicChallenge(w)
+		return calendar{}, false
+	case errors.Is(err, errNotFound):
+		http.Error(w, "calendar not found", http.StatusNotFound)
+		return calendar{}, false
+	case err != nil:
+		respond.ServerError(w, r, "failed to fetch lists", err)
+		return calendar{}, false
+	}
+	return c, true
+}
+
+// object resolves the todo a target names.
+func (h *Handler) object(w http.ResponseWriter, r *http.Request, c calendar, uid string) (todo.Item, bool) {
+	item, err := h.store.GetByUID(r.Context(), c.listID, uid)
+	if err != nil {
+		if errors.Is(err, todo.ErrNotFound) {
+			http.Error(w, "todo not found", http.StatusNotFound)
+			return todo.Item{}, false
+		}
+		respond.ServerError(w, r, "failed to fetch todo", err)
+		return todo.Item{}, false
+	}
+	return item, true
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "collections cannot be downloaded", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := h.resolve(w, r, t.calendar)
+	if !ok {
+		return
+	}
+	item, ok := h.object(w, r, c, t.uid)
+	if !ok {
+		return
+	}
+
+	data := calendarData(item)
+	tag := etag(data)
+	w.Header().Set("ETag", tag)
+	if matchesETag(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", objectContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(data)
+}
+
+// put creates or replaces a todo. The resource name must be the VTODO's UID
+// so that the todo can be found again under the same URL.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "only calendar objects can be written", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := h.resolve(w, r, t.calendar)
+	if !ok {
+		return
+	}
+	if !c.write {
+		http.Error(w, "calendar is read-only", http.StatusForbidden)
+		return
+	}
+
+	todos, err := ical.Decode(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		preconditionFailed(w, http.StatusBadRequest, caldavNS, "valid-calendar-data", err.Error())
+		return
+	}
+	if len(todos) != 1 {
+		preconditionFailed(w, http.StatusForbidden, caldavNS, "supported-calendar-component", "a calendar object must contain exactly one VTODO")
+		return
+	}
+	if todos[0].UID != t.uid {
+		preconditionFailed(w, http.StatusForbidden, caldavNS, "valid-calendar-object-resource", "the resource name must be the VTODO's UID followed by .ics")
+		return
+	}
+	item, err := todo.ItemFromVTODO(todos[0], h.maxTitleLength)
+	if err != nil {
+		preconditionFailed(w, http.StatusForbidden, caldavNS, "valid-calendar-object-resource", err.Error())
+		return
+	}
+
+	current, err := h.store.GetByUID(r.Context(), c.listID, t.uid)
+	exists := err == nil
+	if err != nil && !errors.Is(err, todo.ErrNotFound) {
+		respond.ServerError(w, r, "failed to fetch todo", err)
+		return
+	}
+	var currentTag string
+	if exists {
+		currentTag = etag(calendarData(current))
+	}
+	if !preconditionsHold(r, exists, currentTag) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	if _, err := h.store.UpsertByUID(r.Context(), c.listID, []todo.Item{item}); err != nil {
+		respond.ServerError(w, r, "failed to store todo", err)
+		return
+	}
+	stored, ok := h.object(w, r, c, t.uid)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("ETag", etag(calendarData(stored)))
+	if exists {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "collections cannot be deleted", http.StatusForbidden)
+		return
+	}
+	c, ok := h.resolve(w, r, t.calendar)
+	if !ok {
+		return
+	}
+	if !c.write {
+		http.Error(w, "calendar is read-only", http.StatusForbidden)
+		return
+	}
+	item, ok := h.object(w, r, c, t.uid)
+	if !ok {
+		return
+	}
+	if !preconditionsHold(r, true, etag(calendarData(item))) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	if err := h.store.Delete(r.Context(), item.ID); err != nil {
+		if errors.Is(err, todo.ErrNotFound) {
+			http.Error(w, "todo not found", http.StatusNotFound)
+			return
+		}
+		respond.ServerError(w, r, "failed to delete todo", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// preconditionsHold evaluates If-Match and If-None-Match, which clients
+// send to avoid overwriting changes made elsewhere since their last sync.
+func preconditionsHold(r *http.Request, exists bool, current string) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !exists || !matchesETag(ifMatch, current) {
+			return false
+		}
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if exists && matchesETag(ifNoneMatch, current) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesETag(header string, current string) bool {
+	if current == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == current {
+			return true
+		}
+	}
+	return false
+}
+
+const objectContentType = "text/calendar; charset=utf-8; component=VTODO"
+
+// calendarData serializes item as a calendar object resource.
+func calendarData(item todo.Item) []byte {
+	var b bytes.Buffer
+	writer := ical.NewWriter(&b, "", stamp)
+	writer.WriteTodo(todo.VTODO(item))
+	writer.Close()
+	return b.Bytes()
+}
+
+func etag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// syncToken identifies the state of a calendar's todos. Any change to the
+// set or to one of its todos produces a new token.
+func syncToken(items []todo.Item) string {
+	tags := make([]string, len(items))
+	for i, item := range items {
+		tags[i] = item.UID + " " + etag(calendarData(item))
+	}
+	slices.Sort(tags)
+	sum := sha256.Sum256([]byte(strings.Join(tags, "\n")))
+	return syncTokenPrefix + hex.EncodeToString(sum[:16])
+}
+
+// preconditionFailed reports a violated WebDAV precondition with the
+// DAV:error body clients use to explain the failure.
+func preconditionFailed(w http.ResponseWriter, status int, namespace string, condition string, message string) {
+	w.Header().Set("Content-Type", xmlContentType)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+`<d:error xmlns:d="DAV:"><%s xmlns="%s"/><message xmlns="urn:todoapp">%s</message></d:error>`,
+		condition, namespace, escape(message))
+}
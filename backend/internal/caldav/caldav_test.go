@@ -0,0 +1,291 @@
+package caldav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+)
+
+func setup(t *testing.T, opts ...Option) (*Handler, *todo.Repository) {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := todo.NewRepository(database)
+	return NewHandler(repo, opts...), repo
+}
+
+var writer = &auth.Principal{Kind: auth.KindAPIKey, ID: 1, Name: "phone", Scope: auth.ScopeReadWrite}
+
+func serve(h http.Handler, method string, target string, body string, principal *auth.Principal, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func vtodo(uid string, summary string, extra string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VTODO\r\nUID:" + uid +
+		"\r\nSUMMARY:" + summary + "\r\n" + extra + "END:VTODO\r\nEND:VCALENDAR\r\n"
+}
+
+const (
+	objectPath   = "/dav/calendars/default/abc-123.ics"
+	calendarPath = "/dav/calendars/default/"
+)
+
+func TestHandler_ObjectLifecycle(t *testing.T) {
+	h, repo := setup(t)
+
+	rr := serve(h, http.MethodPut, objectPath, vtodo("abc-123", "Buy milk", ""), writer, map[string]string{"If-None-Match": "*"})
+	if rr.Code != http.StatusCreated || rr.Header().Get("ETag") == "" {
+		t.Fatalf("expected 201 with an ETag, got %d: %s", rr.Code, rr.Body.String())
+	}
+	created := rr.Header().Get("ETag")
+
+	rr = serve(h, http.MethodPut, objectPath, vtodo("abc-123", "Buy milk", ""), writer, map[string]string{"If-None-Match": "*"})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected If-None-Match: * to refuse an existing todo, got %d", rr.Code)
+	}
+
+	rr = serve(h, http.MethodGet, objectPath, "", writer, nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != created || !strings.Contains(rr.Body.String(), "SUMMARY:Buy milk") {
+		t.Fatalf("unexpected GET: %d %q %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+
+	// The todo is the same one the REST API sees.
+	items, err := repo.List(context.Background(), nil)
+	if err != nil || len(items) != 1 || items[0].Title != "Buy milk" || items[0].UID != "abc-123" {
+		t.Fatalf("unexpected todos: %#v %v", items, err)
+	}
+
+	rr = serve(h, http.MethodPut, objectPath, vtodo("abc-123", "Buy oat milk", "STATUS:COMPLETED\r\n"), writer, map[string]string{"If-Match": `"stale"`})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected a stale If-Match to fail, got %d", rr.Code)
+	}
+	rr = serve(h, http.MethodPut, objectPath, vtodo("abc-123", "Buy oat milk", "STATUS:COMPLETED\r\n"), writer, map[string]string{"If-Match": created})
+	if rr.Code != http.StatusNoContent || rr.Header().Get("ETag") == created {
+		t.Fatalf("expected 204 with a new ETag, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	updated := rr.Header().Get("ETag")
+	if item, _ := repo.Get(context.Background(), items[0].ID); item.Title != "Buy oat milk" || !item.Completed {
+		t.Fatalf("expected the update to reach the repository: %#v", item)
+	}
+
+	rr = serve(h, http.MethodDelete, objectPath, "", writer, map[string]string{"If-Match": created})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected deleting with an old ETag to fail, got %d", rr.Code)
+	}
+	rr = serve(h, http.MethodDelete, objectPath, "", writer, map[string]string{"If-Match": updated})
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve(h, http.MethodGet, objectPath, "", writer, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the todo to be gone, got %d", rr.Code)
+	}
+}
+
+func TestHandler_ServesTodosCreatedThroughREST(t *testing.T) {
+	h, repo := setup(t)
+	item, err := repo.Create(context.Background(), todo.Item{Title: "From the web"})
+	if err != nil {
+		t.Fatalf("create todo: %v", err)
+	}
+
+	rr := serve(h, "PROPFIND", calendarPath, `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:prop><d:getetag/><cs:getctag/><d:resourcetype/><d:quota-used-bytes/></d:prop></d:propfind>`,
+		writer, map[string]string{"Depth": "1"})
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	href := "/dav/calendars/default/todo-1@todoapp.ics"
+	for _, want := range []string{"<d:href>" + href + "</d:href>", "<c:calendar/>", "<cs:getctag>urn:todoapp:sync:", "<d:quota-used-bytes/>", "404 Not Found"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in %s", want, body)
+		}
+	}
+
+	rr = serve(h, "REPORT", calendarPath, `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+<d:prop><d:getetag/><c:calendar-data/></d:prop><d:href>`+href+`</d:href><d:href>/dav/calendars/default/missing.ics</d:href></c:calendar-multiget>`, writer, nil)
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "UID:"+item.UID) || !strings.Contains(rr.Body.String(), "SUMMARY:From the web") {
+		t.Fatalf("expected calendar-data of the todo, got %s", rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "<d:href>/dav/calendars/default/missing.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>") {
+		t.Fatalf("expected the unknown href to be reported missing, got %s", rr.Body.String())
+	}
+
+	// Editing through CalDAV keeps the todo's id.
+	rr = serve(h, http.MethodPut, href, vtodo(item.UID, "Edited on the phone", ""), writer, nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got, _ := repo.Get(context.Background(), item.ID); got.Title != "Edited on the phone" {
+		t.Fatalf("expected todo %d to be updated, got %#v", item.ID, got)
+	}
+}
+
+var syncTokenPattern = regexp.MustCompile(`<d:sync-token>([^<]+)</d:sync-token>`)
+
+func TestHandler_SyncCollection(t *testing.T) {
+	h, repo := setup(t)
+	if _, err := repo.Create(context.Background(), todo.Item{Title: "First"}); err != nil {
+		t.Fatalf("create todo: %v", err)
+	}
+	report := func(token string) *httptest.ResponseRecorder {
+		return serve(h, "REPORT", calendarPath, `<d:sync-collection xmlns:d="DAV:"><d:sync-token>`+token+`</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`, writer, nil)
+	}
+
+	rr := report("")
+	match := syncTokenPattern.FindStringSubmatch(rr.Body.String())
+	if rr.Code != http.StatusMultiStatus || match == nil || !strings.Contains(rr.Body.String(), "todo-1@todoapp.ics") {
+		t.Fatalf("expected an initial sync with a token, got %d: %s", rr.Code, rr.Body.String())
+	}
+	token := match[1]
+
+	rr = report(token)
+	if rr.Code != http.StatusMultiStatus || strings.Contains(rr.Body.String(), "<d:response>") {
+		t.Fatalf("expected no changes for the current token, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if _, err := repo.Create(context.Background(), todo.Item{Title: "Second"}); err != nil {
+		t.Fatalf("create todo: %v", err)
+	}
+	rr = report(token)
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "valid-sync-token") {
+		t.Fatalf("expected an outdated token to force a full sync, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_CalendarQueryFilters(t *testing.T) {
+	h, repo := setup(t)
+	open, _ := repo.Create(context.Background(), todo.Item{Title: "Open"})
+	done, _ := repo.Create(context.Background(), todo.Item{Title: "Done"})
+	if _, err := repo.UpdateCompleted(context.Background(), done.ID, true, nil); err != nil {
+		t.Fatalf("complete todo: %v", err)
+	}
+
+	query := func(filter string) string {
+		rr := serve(h, "REPORT", calendarPath, `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/></d:prop>
+<c:filter><c:comp-filter name="VCALENDAR">`+filter+`</c:comp-filter></c:filter></c:calendar-query>`, writer, map[string]string{"Depth": "1"})
+		if rr.Code != http.StatusMultiStatus {
+			t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	body := query(`<c:comp-filter name="VTODO"><c:prop-filter name="COMPLETED"><c:is-not-defined/></c:prop-filter></c:comp-filter>`)
+	if !strings.Contains(body, open.UID) || strings.Contains(body, done.UID) {
+		t.Fatalf("expected only the open todo, got %s", body)
+	}
+	if body := query(`<c:comp-filter name="VEVENT"/>`); strings.Contains(body, "<d:response>") {
+		t.Fatalf("expected no events, got %s", body)
+	}
+}
+
+func TestHandler_RejectsInvalidObjects(t *testing.T) {
+	h, _ := setup(t)
+	event := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:abc-123\r\nSUMMARY:Meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	cases := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{name: "event", path: objectPath, body: event, want: http.StatusForbidden},
+		{name: "uid mismatch", path: "/dav/calendars/default/other.ics", body: vtodo("abc-123", "Buy milk", ""), want: http.StatusForbidden},
+		{name: "empty summary", path: objectPath, body: vtodo("abc-123", " ", ""), want: http.StatusForbidden},
+		{name: "not icalendar", path: objectPath, body: "hello", want: http.StatusBadRequest},
+		{name: "collection", path: calendarPath, body: vtodo("abc-123", "Buy milk", ""), want: http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		if rr := serve(h, http.MethodPut, tc.path, tc.body, writer, nil); rr.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d: %s", tc.name, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+type fakeLists map[int64][]sharing.List
+
+func (f fakeLists) Lists(_ context.Context, userID int64) ([]sharing.List, error) {
+	return f[userID], nil
+}
+
+func TestHandler_Access(t *testing.T) {
+	lists := fakeLists{7: {{ID: 1, Name: "Household", Role: sharing.RoleViewer}}}
+	h, _ := setup(t, WithLists(lists))
+	alice := &auth.Principal{Kind: auth.KindUser, ID: 7, Name: "alice", Scope: auth.ScopeReadWrite}
+	reader := &auth.Principal{Kind: auth.KindAPIKey, ID: 2, Scope: auth.ScopeRead}
+
+	rr := serve(h, "PROPFIND", "/dav/", "", nil, nil)
+	if rr.Code != http.StatusUnauthorized || !strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), "Basic ") {
+		t.Fatalf("expected a Basic challenge, got %d %q", rr.Code, rr.Header().Get("WWW-Authenticate"))
+	}
+
+	rr = serve(h, "PROPFIND", "/dav/calendars/", "", alice, map[string]string{"Depth": "1"})
+	if rr.Code != http.StatusMultiStatus || !strings.Contains(rr.Body.String(), "/dav/calendars/list-1/") || !strings.Contains(rr.Body.String(), "Household") {
+		t.Fatalf("expected the shared list as a calendar, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Viewers and read-only keys may not change anything.
+	if rr := serve(h, http.MethodPut, "/dav/calendars/list-1/abc-123.ics", vtodo("abc-123", "x", ""), alice, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected viewer PUT to be forbidden, got %d", rr.Code)
+	}
+	if rr := serve(h, http.MethodPut, objectPath, vtodo("abc-123", "x", ""), reader, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected read-only PUT to be forbidden, got %d", rr.Code)
+	}
+
+	// Lists the caller does not belong to do not exist for them.
+	if rr := serve(h, "PROPFIND", "/dav/calendars/list-2/", "", alice, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another list, got %d", rr.Code)
+	}
+	if rr := serve(h, "PROPFIND", "/dav/calendars/list-1/", "", writer, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for api keys on shared lists, got %d", rr.Code)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	cases := map[string]target{
+		"/dav":                                 {kind: kindRoot},
+		"/dav/":                                {kind: kindRoot},
+		"/dav/principal/":                      {kind: kindPrincipal},
+		"/dav/calendars":                       {kind: kindHome},
+		"/dav/calendars/list-3/":               {kind: kindCalendar, calendar: "list-3"},
+		"/dav/calendars/default/a%2Fb%40c.ics": {kind: kindObject, calendar: "default", uid: "a/b@c"},
+	}
+	for path, want := range cases {
+		got, err := parsePath(path)
+		if err != nil || got != want {
+			t.Fatalf("%s: got %#v, %v", path, got, err)
+		}
+	}
+	for _, path := range []string{"/api/todos", "/dav/other/", "/dav/calendars/default/x.txt", "/dav/calendars/default/a/b.ics"} {
+		if _, err := parsePath(path); err == nil {
+			t.Fatalf("%s: expected an error", path)
+		}
+	}
+}
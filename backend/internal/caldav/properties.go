@@ -0,0 +1,228 @@
+package caldav
+
+import (
+	"context"
+	"net/http"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/respond"
+	"todoapp/backend/internal/todo"
+)
+
+const (
+	principalHref = Prefix + "principal/"
+	homeHref      = Prefix + "calendars/"
+)
+
+// propfind answers with the properties of t and, at Depth 1, of its
+// members. Depth infinity is served as Depth 1.
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, t target) {
+	var body propfindBody
+	if _, ok := decodeBody(w, r, h.maxBodyBytes, &body); !ok {
+		return
+	}
+	depth := r.Header.Get("Depth") != "0"
+
+	var m multistatus
+	switch t.kind {
+	case kindRoot:
+		m.response(Prefix, body.Prop.selectFrom(rootProps()))
+		if depth {
+			m.response(principalHref, body.Prop.selectFrom(principalProps(r.Context())))
+			m.response(homeHref, body.Prop.selectFrom(homeProps()))
+		}
+	case kindPrincipal:
+		m.response(principalHref, body.Prop.selectFrom(principalProps(r.Context())))
+	case kindHome:
+		m.response(homeHref, body.Prop.selectFrom(homeProps()))
+		if depth {
+			calendars, err := h.calendars(r.Context())
+			if err != nil {
+				respond.ServerError(w, r, "failed to fetch lists", err)
+				return
+			}
+			for _, c := range calendars {
+				items, err := h.store.List(r.Context(), c.listID)
+				if err != nil {
+					respond.ServerError(w, r, "failed to fetch todos", err)
+					return
+				}
+				m.response(c.href(), body.Prop.selectFrom(calendarProps(c, items)))
+			}
+		}
+	case kindCalendar:
+		c, ok := h.resolve(w, r, t.calendar)
+		if !ok {
+			return
+		}
+		items, err := h.store.List(r.Context(), c.listID)
+		if err != nil {
+			respond.ServerError(w, r, "failed to fetch todos", err)
+			return
+		}
+		m.response(c.href(), body.Prop.selectFrom(calendarProps(c, items)))
+		if depth {
+			for _, item := range items {
+				m.response(c.objectHref(item.UID), body.Prop.selectFrom(objectProps(item, body.Prop)))
+			}
+		}
+	case kindObject:
+		c, ok := h.resolve(w, r, t.calendar)
+		if !ok {
+			return
+		}
+		item, ok := h.object(w, r, c, t.uid)
+		if !ok {
+			return
+		}
+		m.response(c.objectHref(item.UID), body.Prop.selectFrom(objectProps(item, body.Prop)))
+	}
+	m.write(w)
+}
+
+// proppatch refuses every change: names and colors of calendars come from
+// the lists themselves. Clients expect a per-property answer.
+func (h *Handler) proppatch(w http.ResponseWriter, r *http.Request, t target) {
+	var body proppatchBody
+	if _, ok := decodeBody(w, r, h.maxBodyBytes, &body); !ok {
+		return
+	}
+	var refused []prop
+	for _, set := range append(body.Set, body.Remove...) {
+		for _, n := range set.Names {
+			refused = append(refused, prop{name: n.XMLName})
+		}
+	}
+	var m multistatus
+	m.response(r.URL.EscapedPath(), []propstat{{props: refused, status: http.StatusForbidden}})
+	m.write(w)
+}
+
+// report serves calendar-query, calendar-multiget and sync-collection on a
+// calendar.
+func (h *Handler) report(w http.ResponseWriter, r *http.Request, t target) {
+	var body reportBody
+	present, ok := decodeBody(w, r, h.maxBodyBytes, &body)
+	if !ok {
+		return
+	}
+	supported := body.XMLName == reportCalendarQuery || body.XMLName == reportCalendarMultiget || body.XMLName == reportSyncCollection
+	if !present || !supported || t.kind != kindCalendar {
+		preconditionFailed(w, http.StatusForbidden, davNS, "supported-report", "unsupported report")
+		return
+	}
+	c, ok := h.resolve(w, r, t.calendar)
+	if !ok {
+		return
+	}
+	items, err := h.store.List(r.Context(), c.listID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch todos", err)
+		return
+	}
+
+	var m multistatus
+	switch body.XMLName {
+	case reportCalendarQuery:
+		for _, item := range items {
+			if body.Filter.matches(item) {
+				m.response(c.objectHref(item.UID), body.Prop.selectFrom(objectProps(item, body.Prop)))
+			}
+		}
+	case reportCalendarMultiget:
+		byUID := make(map[string]todo.Item, len(items))
+		for _, item := range items {
+			byUID[item.UID] = item
+		}
+		for _, href := range body.Hrefs {
+			ref, err := parsePath(hrefPath(href))
+			item, found := byUID[ref.uid]
+			if err != nil || ref.kind != kindObject || ref.calendar != c.segment || !found {
+				m.status(href, http.StatusNotFound)
+				continue
+			}
+			m.response(href, body.Prop.selectFrom(objectProps(item, body.Prop)))
+		}
+	case reportSyncCollection:
+		// Tokens name a state rather than a point in a change log, so only
+		// the current state is known. Older tokens are rejected and the
+		// client falls back to a full sync with an empty token.
+		current := syncToken(items)
+		switch body.SyncToken {
+		case current:
+		case "":
+			for _, item := range items {
+				m.response(c.objectHref(item.UID), body.Prop.selectFrom(objectProps(item, body.Prop)))
+			}
+		default:
+			preconditionFailed(w, http.StatusForbidden, davNS, "valid-sync-token", "the sync token is out of date")
+			return
+		}
+		m.syncToken(current)
+	}
+	m.write(w)
+}
+
+func rootProps() []prop {
+	return []prop{
+		{name: propResourceType, value: "<d:collection/>"},
+		{name: propPrincipal, value: hrefValue(principalHref)},
+	}
+}
+
+func principalProps(ctx context.Context) []prop {
+	name := "anonymous"
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && principal.Name != "" {
+		name = principal.Name
+	}
+	return []prop{
+		{name: propResourceType, value: "<d:collection/><d:principal/>"},
+		{name: propDisplayName, value: escape(name)},
+		{name: propPrincipal, value: hrefValue(principalHref)},
+		{name: propPrincipalURL, value: hrefValue(principalHref)},
+		{name: propHomeSet, value: hrefValue(homeHref)},
+	}
+}
+
+func homeProps() []prop {
+	return []prop{
+		{name: propResourceType, value: "<d:collection/>"},
+		{name: propPrincipal, value: hrefValue(principalHref)},
+	}
+}
+
+func calendarProps(c calendar, items []todo.Item) []prop {
+	privileges := "<d:privilege><d:read/></d:privilege>"
+	if c.write {
+		privileges += "<d:privilege><d:write/></d:privilege><d:privilege><d:write-content/></d:privilege>" +
+			"<d:privilege><d:bind/></d:privilege><d:privilege><d:unbind/></d:privilege>"
+	}
+	token := syncToken(items)
+	return []prop{
+		{name: propResourceType, value: "<d:collection/><c:calendar/>"},
+		{name: propDisplayName, value: escape(c.name)},
+		{name: propPrincipal, value: hrefValue(principalHref)},
+		{name: propComponentSet, value: `<c:comp name="VTODO"/>`},
+		{name: propSupportedReports, value: "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>"},
+		{name: propPrivileges, value: privileges},
+		{name: propCTag, value: escape(token)},
+		{name: propSyncToken, value: escape(token)},
+	}
+}
+
+// objectProps returns the properties of a todo. calendar-data is only
+// rendered when asked for, as it is the bulk of the response.
+func objectProps(item todo.Item, req *propRequest) []prop {
+	data := calendarData(item)
+	props := []prop{
+		{name: propResourceType},
+		{name: propETag, value: escape(etag(data))},
+		{name: propContentType, value: escape(objectContentType)},
+	}
+	if req.wants(propCalendarData) {
+		props = append(props, prop{name: propCalendarData, value: escape(string(data))})
+	}
+	return props
+}
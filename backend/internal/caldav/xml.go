@@ -0,0 +1,272 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"todoapp/backend/internal/todo"
+)
+
+const (
+	davNS          = "DAV:"
+	caldavNS       = "urn:ietf:params:xml:ns:caldav"
+	calendarServer = "http://calendarserver.org/ns/"
+
+	xmlContentType = "application/xml; charset=utf-8"
+)
+
+// prefixes are declared once on the multistatus element; properties in
+// other namespaces declare their own.
+var prefixes = map[string]string{davNS: "d", caldavNS: "c", calendarServer: "cs"}
+
+var (
+	propResourceType       = xml.Name{Space: davNS, Local: "resourcetype"}
+	propDisplayName        = xml.Name{Space: davNS, Local: "displayname"}
+	propPrincipal          = xml.Name{Space: davNS, Local: "current-user-principal"}
+	propPrincipalURL       = xml.Name{Space: davNS, Local: "principal-URL"}
+	propPrivileges         = xml.Name{Space: davNS, Local: "current-user-privilege-set"}
+	propSupportedReports   = xml.Name{Space: davNS, Local: "supported-report-set"}
+	propSyncToken          = xml.Name{Space: davNS, Local: "sync-token"}
+	propETag               = xml.Name{Space: davNS, Local: "getetag"}
+	propContentType        = xml.Name{Space: davNS, Local: "getcontenttype"}
+	propHomeSet            = xml.Name{Space: caldavNS, Local: "calendar-home-set"}
+	propComponentSet       = xml.Name{Space: caldavNS, Local: "supported-calendar-component-set"}
+	propCalendarData       = xml.Name{Space: caldavNS, Local: "calendar-data"}
+	propCTag               = xml.Name{Space: calendarServer, Local: "getctag"}
+	reportCalendarQuery    = xml.Name{Space: caldavNS, Local: "calendar-query"}
+	reportCalendarMultiget = xml.Name{Space: caldavNS, Local: "calendar-multiget"}
+	reportSyncCollection   = xml.Name{Space: davNS, Local: "sync-collection"}
+)
+
+// prop is a property with its value as XML content.
+type prop struct {
+	name  xml.Name
+	value string
+}
+
+type propstat struct {
+	props  []prop
+	status int
+}
+
+// propRequest is the DAV:prop selection of a PROPFIND or REPORT body. An
+// empty selection asks for every property except calendar-data.
+type propRequest struct {
+	Names []anyElement `xml:",any"`
+}
+
+type anyElement struct {
+	XMLName xml.Name
+}
+
+func (p *propRequest) wants(name xml.Name) bool {
+	if p == nil || len(p.Names) == 0 {
+		return name != propCalendarData
+	}
+	for _, n := range p.Names {
+		if n.XMLName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFrom splits props into the requested ones and the names requested
+// but not defined on the resource.
+func (p *propRequest) selectFrom(props []prop) []propstat {
+	found := make([]prop, 0, len(props))
+	for _, prop := range props {
+		if p.wants(prop.name) {
+			found = append(found, prop)
+		}
+	}
+	var missing []prop
+	if p != nil {
+		for _, n := range p.Names {
+			if !hasProp(props, n.XMLName) {
+				missing = append(missing, prop{name: n.XMLName})
+			}
+		}
+	}
+
+	var stats []propstat
+	if len(found) > 0 {
+		stats = append(stats, propstat{props: found, status: http.StatusOK})
+	}
+	if len(missing) > 0 {
+		stats = append(stats, propstat{props: missing, status: http.StatusNotFound})
+	}
+	return stats
+}
+
+func hasProp(props []prop, name xml.Name) bool {
+	for _, prop := range props {
+		if prop.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type propfindBody struct {
+	XMLName xml.Name     `xml:"DAV: propfind"`
+	Prop    *propRequest `xml:"DAV: prop"`
+}
+
+type proppatchBody struct {
+	XMLName xml.Name      `xml:"DAV: propertyupdate"`
+	Set     []propRequest `xml:"DAV: set>prop"`
+	Remove  []propRequest `xml:"DAV: remove>prop"`
+}
+
+type reportBody struct {
+	XMLName   xml.Name
+	Prop      *propRequest `xml:"DAV: prop"`
+	Hrefs     []string     `xml:"DAV: href"`
+	SyncToken string       `xml:"DAV: sync-token"`
+	Filter    *compFilter  `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+// compFilter is a calendar-query filter. Only component names and
+// is-not-defined on COMPLETED, which task apps use to fetch open todos,
+// are evaluated; other conditions match every todo.
+type compFilter struct {
+	Name    string       `xml:"name,attr"`
+	Comps   []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	Props   []propFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+	Missing *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+}
+
+type propFilter struct {
+	Name    string    `xml:"name,attr"`
+	Missing *struct{} `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+}
+
+func (f *compFilter) matches(item todo.Item) bool {
+	if f == nil {
+		return true
+	}
+	if !strings.EqualFold(f.Name, "VCALENDAR") {
+		return false
+	}
+	for _, comp := range f.Comps {
+		if !comp.matchesTodo(item) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f compFilter) matchesTodo(item todo.Item) bool {
+	if !strings.EqualFold(f.Name, "VTODO") {
+		return f.Missing != nil
+	}
+	if f.Missing != nil {
+		return false
+	}
+	for _, prop := range f.Props {
+		if strings.EqualFold(prop.Name, "COMPLETED") && prop.Missing != nil && item.Completed && item.CompletedAt != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBody reads an optional XML request body into v, reporting whether
+// one was present.
+func decodeBody(w http.ResponseWriter, r *http.Request, maxBytes int64, v any) (bool, bool) {
+	err := xml.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes)).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return false, true
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return false, false
+		}
+		http.Error(w, "invalid XML body", http.StatusBadRequest)
+		return false, false
+	}
+	return true, true
+}
+
+// multistatus builds a 207 Multi-Status response.
+type multistatus struct {
+	b strings.Builder
+}
+
+func (m *multistatus) response(href string, stats []propstat) {
+	m.b.WriteString("<d:response><d:href>")
+	m.b.WriteString(escape(href))
+	m.b.WriteString("</d:href>")
+	for _, stat := range stats {
+		m.b.WriteString("<d:propstat><d:prop>")
+		for _, prop := range stat.props {
+			writeProp(&m.b, prop)
+		}
+		fmt.Fprintf(&m.b, "</d:prop><d:status>%s</d:status></d:propstat>", statusLine(stat.status))
+	}
+	m.b.WriteString("</d:response>")
+}
+
+// status reports a member that has no properties, such as a removed todo.
+func (m *multistatus) status(href string, status int) {
+	fmt.Fprintf(&m.b, "<d:response><d:href>%s</d:href><d:status>%s</d:status></d:response>", escape(href), statusLine(status))
+}
+
+func (m *multistatus) syncToken(token string) {
+	fmt.Fprintf(&m.b, "<d:sync-token>%s</d:sync-token>", escape(token))
+}
+
+func (m *multistatus) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", xmlContentType)
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n")
+	fmt.Fprintf(w, `<d:multistatus xmlns:d="%s" xmlns:c="%s" xmlns:cs="%s">`, davNS, caldavNS, calendarServer)
+	io.WriteString(w, m.b.String())
+	io.WriteString(w, "</d:multistatus>")
+}
+
+func writeProp(b *strings.Builder, p prop) {
+	tag := p.name.Local
+	attrs := ""
+	if prefix, ok := prefixes[p.name.Space]; ok {
+		tag = prefix + ":" + tag
+	} else if p.name.Space != "" {
+		attrs = ` xmlns="` + escape(p.name.Space) + `"`
+	}
+	if p.value == "" {
+		fmt.Fprintf(b, "<%s%s/>", tag, attrs)
+		return
+	}
+	fmt.Fprintf(b, "<%s%s>%s</%s>", tag, attrs, p.value, tag)
+}
+
+func statusLine(status int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status))
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func hrefValue(href string) string {
+	return "<d:href>" + escape(href) + "</d:href>"
+}
+
+// hrefPath returns the escaped path of an href, which may be a full URL.
+func hrefPath(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	return u.EscapedPath()
+}
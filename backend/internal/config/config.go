@@ -0,0 +1,473 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/caldav"
+	"todoapp/backend/internal/cors"
+	"todoapp/backend/internal/email"
+	"todoapp/backend/internal/logging"
+	"todoapp/backend/internal/mcp"
+	"todoapp/backend/internal/oidc"
+	"todoapp/backend/internal/ratelimit"
+	"todoapp/backend/internal/rpc"
+	"todoapp/backend/internal/todo"
+	"todoapp/backend/internal/tracing"
+	"todoapp/backend/internal/web"
+)
+
+const redacted = "REDACTED"
+
+// Config is the effective server configuration. Fields tagged secret:"true"
+// are redacted by Redacted.
+type Config struct {
+	Addr        string            `yaml:"addr" toml:"addr"`
+	DB          string            `yaml:"db" toml:"db"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing"`
+	CORS        CORSConfig        `yaml:"cors" toml:"cors"`
+	Limits      LimitsConfig      `yaml:"limits" toml:"limits"`
+	Attachments AttachmentsConfig `yaml:"attachments" toml:"attachments"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	OIDC        OIDCConfig        `yaml:"oidc" toml:"oidc"`
+	SMTP        SMTPConfig        `yaml:"smtp" toml:"smtp"`
+	Frontend    FrontendConfig    `yaml:"frontend" toml:"frontend"`
+}
+
+type LogConfig struct {
+	Format string `yaml:"format" toml:"format"`
+	Level  string `yaml:"level" toml:"level"`
+}
+
+type TracingConfig struct {
+	Exporter     string            `yaml:"exporter" toml:"exporter"`
+	Output       string            `yaml:"output" toml:"output"`
+	OTLPEndpoint string            `yaml:"otlp_endpoint" toml:"otlp_endpoint"`
+	OTLPInsecure bool              `yaml:"otlp_insecure" toml:"otlp_insecure"`
+	OTLPHeaders  map[string]string `yaml:"otlp_headers" toml:"otlp_headers" secret:"true"`
+}
+
+type CORSConfig struct {
+	Origins       []string `yaml:"origins" toml:"origins"`
+	Methods       []string `yaml:"methods" toml:"methods"`
+	Headers       []string `yaml:"headers" toml:"headers"`
+	ExposeHeaders []string `yaml:"expose_headers" toml:"expose_headers"`
+	MaxAge        Duration `yaml:"max_age" toml:"max_age"`
+	Credentials   bool     `yaml:"credentials" toml:"credentials"`
+}
+
+type LimitsConfig struct {
+	MaxBodyBytes   int64 `yaml:"max_body_bytes" toml:"max_body_bytes"`
+	MaxTitleLength int   `yaml:"max_title_length" toml:"max_title_length"`
+}
+
+type AttachmentsConfig struct {
+	// Dir is where uploaded files are stored, named by their SHA-256.
+	Dir          string `yaml:"dir" toml:"dir"`
+	MaxFileBytes int64  `yaml:"max_file_bytes" toml:"max_file_bytes"`
+	// QuotaBytes caps the total size of the files each user uploads.
+	QuotaBytes int64 `yaml:"quota_bytes" toml:"quota_bytes"`
+}
+
+type RateLimitConfig struct {
+	Enabled    bool                `yaml:"enabled" toml:"enabled"`
+	Rate       float64             `yaml:"rate" toml:"rate"`
+	Burst      int                 `yaml:"burst" toml:"burst"`
+	Routes     map[string]RateRule `yaml:"routes" toml:"routes"`
+	TrustProxy bool                `yaml:"trust_proxy" toml:"trust_proxy"`
+	// ProxyHops is how many trusted proxies append to X-Forwarded-For.
+	ProxyHops int `yaml:"proxy_hops" toml:"proxy_hops"`
+}
+
+type AuthConfig struct {
+	// Required rejects anonymous mutations; reads stay public.
+	Required   bool   `yaml:"required" toml:"required"`
+	AdminToken string `yaml:"admin_token" toml:"admin_token" secret:"true"`
+}
+
+// OIDCConfig enables browser login through an OpenID Connect provider when
+// Issuer is set.
+type OIDCConfig struct {
+	Issuer        string   `yaml:"issuer" toml:"issuer"`
+	ClientID      string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret  string   `yaml:"client_secret" toml:"client_secret" secret:"true"`
+	RedirectURL   string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes        []string `yaml:"scopes" toml:"scopes"`
+	EmailClaim    string   `yaml:"email_claim" toml:"email_claim"`
+	NameClaim     string   `yaml:"name_claim" toml:"name_claim"`
+	SessionTTL    Duration `yaml:"session_ttl" toml:"session_ttl"`
+	CookieSecure  bool     `yaml:"cookie_secure" toml:"cookie_secure"`
+	PostLogoutURL string   `yaml:"post_logout_url" toml:"post_logout_url"`
+}
+
+// SMTPConfig enables email notifications when Addr is set.
+type SMTPConfig struct {
+	Addr     string `yaml:"addr" toml:"addr"`
+	From     string `yaml:"from" toml:"from"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password" secret:"true"`
+}
+
+// FrontendConfig serves the built frontend from Dir, or from the copy
+// embedded at build time when Dir is empty.
+type FrontendConfig struct {
+	Dir string `yaml:"dir" toml:"dir"`
+	// APIBaseURL is where the frontend sends API requests; empty means the
+	// server it was loaded from.
+	APIBaseURL string `yaml:"api_base_url" toml:"api_base_url"`
+}
+
+// RateRule allows Rate requests per second with bursts of up to Burst.
+type RateRule struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+}
+
+// Duration is a time.Duration written as "10m" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func Default() Config {
+	return Config{
+		Addr: ":8080",
+		DB:   "./todo.db",
+		Log: LogConfig{
+			Format: "text",
+			Level:  "info",
+		},
+		Tracing: TracingConfig{
+			Exporter: "none",
+		},
+		CORS: CORSConfig{
+			Origins:       []string{"*"},
+			Methods:       []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			Headers:       []string{"Authorization", "Content-Type", "X-Request-ID", "Connect-Protocol-Version", "Mcp-Session-Id"},
+			ExposeHeaders: []string{"X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Mcp-Session-Id"},
+		},
+		Limits: LimitsConfig{
+			MaxBodyBytes:   todo.DefaultMaxBodyBytes,
+			MaxTitleLength: todo.DefaultMaxTitleLength,
+		},
+		Attachments: AttachmentsConfig{
+			Dir:          "./attachments",
+			MaxFileBytes: todo.DefaultMaxAttachmentBytes,
+			QuotaBytes:   todo.DefaultAttachmentQuota,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Rate:    10,
+			Burst:   20,
+			Routes: map[string]RateRule{
+				"POST /api/todos": {Rate: 2, Burst: 10},
+			},
+			ProxyHops: 1,
+		},
+		OIDC: OIDCConfig{
+			Scopes:     []string{"openid", "profile", "email"},
+			EmailClaim: "email",
+			NameClaim:  "name",
+			SessionTTL: Duration(auth.DefaultSessionTTL),
+		},
+	}
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr is required"))
+	}
+	if c.DB == "" {
+		errs = append(errs, errors.New("db is required"))
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log format must be text or json, got %q", c.Log.Format))
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("trace exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	if _, err := cors.New(c.CORSOptions()); err != nil {
+		errs = append(errs, fmt.Errorf("cors: %w", err))
+	}
+	if c.Limits.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("max body bytes must be positive"))
+	}
+	if c.Limits.MaxTitleLength <= 0 {
+		errs = append(errs, errors.New("max title length must be positive"))
+	}
+	if c.Attachments.Dir == "" {
+		errs = append(errs, errors.New("attachments dir is required"))
+	}
+	if c.Attachments.MaxFileBytes <= 0 {
+		errs = append(errs, errors.New("max attachment bytes must be positive"))
+	}
+	if c.Attachments.QuotaBytes <= 0 {
+		errs = append(errs, errors.New("attachment quota must be positive"))
+	}
+	if err := (RateRule{Rate: c.RateLimit.Rate, Burst: c.RateLimit.Burst}).validate(); err != nil {
+		errs = append(errs, fmt.Errorf("rate limit: %w", err))
+	}
+	for route, rule := range c.RateLimit.Routes {
+		if err := rule.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rate limit for %q: %w", route, err))
+		}
+	}
+	if c.RateLimit.ProxyHops <= 0 {
+		errs = append(errs, errors.New("proxy hops must be positive"))
+	}
+	if c.OIDCEnabled() {
+		errs = append(errs, c.OIDC.validate()...)
+	}
+	if c.SMTPEnabled() {
+		if _, _, err := net.SplitHostPort(c.SMTP.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("smtp addr must be host:port, got %q", c.SMTP.Addr))
+		}
+		if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			errs = append(errs, fmt.Errorf("smtp from must be an email address, got %q", c.SMTP.From))
+		}
+	}
+	if c.Frontend.APIBaseURL != "" {
+		if base, err := url.Parse(c.Frontend.APIBaseURL); err != nil || (base.Host == "" && !strings.HasPrefix(base.Path, "/")) {
+			errs = append(errs, fmt.Errorf("frontend api base url must be an absolute URL or path, got %q", c.Frontend.APIBaseURL))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o OIDCConfig) validate() []error {
+	var errs []error
+	if issuer, err := url.Parse(o.Issuer); err != nil || issuer.Scheme == "" || issuer.Host == "" {
+		errs = append(errs, fmt.Errorf("oidc issuer must be an absolute URL, got %q", o.Issuer))
+	}
+	if o.ClientID == "" {
+		errs = append(errs, errors.New("oidc client id is required"))
+	}
+	if redirect, err := url.Parse(o.RedirectURL); err != nil || redirect.Scheme == "" || redirect.Host == "" {
+		errs = append(errs, fmt.Errorf("oidc redirect url must be an absolute URL, got %q", o.RedirectURL))
+	}
+	if !slices.Contains(o.Scopes, "openid") {
+		errs = append(errs, errors.New("oidc scopes must include openid"))
+	}
+	if o.EmailClaim == "" || o.NameClaim == "" {
+		errs = append(errs, errors.New("oidc email and name claims are required"))
+	}
+	if o.SessionTTL <= 0 {
+		errs = append(errs, errors.New("oidc session ttl must be positive"))
+	}
+	return errs
+}
+
+func (r RateRule) validate() error {
+	if r.Rate < 0 {
+		return errors.New("rate must not be negative")
+	}
+	if r.Rate > 0 && r.Burst < 1 {
+		return errors.New("burst must be at least 1")
+	}
+	return nil
+}
+
+func (c Config) RateLimitOptions() ratelimit.Options {
+	routes := make(map[string]ratelimit.Rule, len(c.RateLimit.Routes))
+	for route, rule := range c.RateLimit.Routes {
+		routes[route] = ratelimit.Rule{Rate: rule.Rate, Burst: rule.Burst}
+	}
+	return ratelimit.Options{
+		Enabled:    c.RateLimit.Enabled,
+		Default:    ratelimit.Rule{Rate: c.RateLimit.Rate, Burst: c.RateLimit.Burst},
+		Routes:     routes,
+		TrustProxy: c.RateLimit.TrustProxy,
+		ProxyHops:  c.RateLimit.ProxyHops,
+	}
+}
+
+func (c Config) HandlerOptions() []todo.Option {
+	return []todo.Option{
+		todo.WithMaxBodyBytes(c.Limits.MaxBodyBytes),
+		todo.WithMaxTitleLength(c.Limits.MaxTitleLength),
+	}
+}
+
+func (c Config) CalDAVOptions() []caldav.Option {
+	return []caldav.Option{
+		caldav.WithMaxBodyBytes(c.Limits.MaxBodyBytes),
+		caldav.WithMaxTitleLength(c.Limits.MaxTitleLength),
+		caldav.WithAnonymous(!c.Auth.Required),
+	}
+}
+
+func (c Config) RPCOptions() []rpc.Option {
+	return []rpc.Option{
+		rpc.WithMaxTitleLength(c.Limits.MaxTitleLength),
+		rpc.WithAnonymous(!c.Auth.Required),
+	}
+}
+
+func (c Config) MCPOptions() []mcp.Option {
+	return []mcp.Option{
+		mcp.WithMaxTitleLength(c.Limits.MaxTitleLength),
+		mcp.WithAnonymous(!c.Auth.Required),
+	}
+}
+
+func (c Config) FrontendOptions() []web.Option {
+	return []web.Option{
+		web.WithAPIBaseURL(c.Frontend.APIBaseURL),
+	}
+}
+
+func (c Config) OIDCEnabled() bool {
+	return c.OIDC.Issuer != ""
+}
+
+func (c Config) SMTPEnabled() bool {
+	return c.SMTP.Addr != ""
+}
+
+func (c Config) EmailOptions() email.Config {
+	return email.Config{
+		Addr:     c.SMTP.Addr,
+		From:     c.SMTP.From,
+		Username: c.SMTP.Username,
+		Password: c.SMTP.Password,
+	}
+}
+
+func (c Config) OIDCOptions() oidc.Config {
+	return oidc.Config{
+		Issuer:       c.OIDC.Issuer,
+		ClientID:     c.OIDC.ClientID,
+		ClientSecret: c.OIDC.ClientSecret,
+		RedirectURL:  c.OIDC.RedirectURL,
+		Scopes:       c.OIDC.Scopes,
+	}
+}
+
+func (c Config) LoginOptions() auth.LoginOptions {
+	return auth.LoginOptions{
+		Issuer:        c.OIDC.Issuer,
+		EmailClaim:    c.OIDC.EmailClaim,
+		NameClaim:     c.OIDC.NameClaim,
+		CookieSecure:  c.OIDC.CookieSecure,
+		PostLogoutURL: c.OIDC.PostLogoutURL,
+	}
+}
+
+func (c Config) CORSOptions() cors.Options {
+	return cors.Options{
+		AllowedOrigins:   c.CORS.Origins,
+		AllowedMethods:   c.CORS.Methods,
+		AllowedHeaders:   c.CORS.Headers,
+		ExposedHeaders:   c.CORS.ExposeHeaders,
+		MaxAge:           time.Duration(c.CORS.MaxAge),
+		AllowCredentials: c.CORS.Credentials,
+	}
+}
+
+func (c Config) TracingOptions() tracing.Config {
+	return tracing.Config{
+		Exporter: c.Tracing.Exporter,
+		Output:   c.Tracing.Output,
+		Endpoint: c.Tracing.OTLPEndpoint,
+		Insecure: c.Tracing.OTLPInsecure,
+		Headers:  c.Tracing.OTLPHeaders,
+	}
+}
+
+// Redacted returns a copy of c with every secret:"true" field masked.
+func (c Config) Redacted() Config {
+	redactValue(reflect.ValueOf(&c).Elem())
+	return c
+}
+
+func redactValue(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			redactValue(field)
+		case v.Type().Field(i).Tag.Get("secret") != "true":
+		case field.Kind() == reflect.String && field.Len() > 0:
+			field.SetString(redacted)
+		case field.Kind() == reflect.Map && field.Len() > 0:
+			masked := reflect.MakeMapWithSize(field.Type(), field.Len())
+			for _, key := range field.MapKeys() {
+				masked.SetMapIndex(key, reflect.ValueOf(redacted))
+			}
+			field.Set(masked)
+		}
+	}
+}
+
+func (c Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("parse %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q (want .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/cors"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestDefault_IsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("expected defaults to be valid: %v", err)
+	}
+}
+
+func TestDefault_CORSAllowsEveryAPIMethod(t *testing.T) {
+	policy, err := cors.New(Default().CORSOptions())
+	if err != nil {
+		t.Fatalf("new cors policy: %v", err)
+	}
+	handler := policy.Handler(http.NotFoundHandler())
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		req := httptest.NewRequest(http.MethodOptions, "/api/digest/settings", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "content-type")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent || !strings.Contains(rr.Header().Get("Access-Control-Allow-Methods"), method) {
+			t.Fatalf("expected %s preflight to be allowed, got %d %q", method, rr.Code, rr.Header().Get("Access-Control-Allow-Methods"))
+		}
+	}
+}
+
+func TestDefault_CORSAllowsConnectAndMCPHeaders(t *testing.T) {
+	policy, err := cors.New(Default().CORSOptions())
+	if err != nil {
+		t.Fatalf("new cors policy: %v", err)
+	}
+	handler := policy.Handler(http.NotFoundHandler())
+	for _, header := range []string{"connect-protocol-version", "mcp-session-id"} {
+		req := httptest.NewRequest(http.MethodOptions, "/mcp", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type, "+header)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("expected a preflight with %s to be allowed, got %d", header, rr.Code)
+		}
+	}
+	if exposed := Default().CORS.ExposeHeaders; !slices.Contains(exposed, "Mcp-Session-Id") {
+		t.Fatalf("expected the MCP session header to be exposed, got %v", exposed)
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Addr = ""
+	cfg.Log.Format = "xml"
+	cfg.Tracing.Exporter = "zipkin"
+	cfg.CORS.Credentials = true
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{"addr is required", "log format", "trace exporter", "cors:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidate_OIDC(t *testing.T) {
+	cfg := Default()
+	cfg.OIDC.Issuer = "https://idp.example.com"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{"client id is required", "redirect url"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %q, got %v", want, err)
+		}
+	}
+
+	cfg.OIDC.ClientID = "todo"
+	cfg.OIDC.RedirectURL = "https://todo.example.com/auth/callback"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected valid oidc config: %v", err)
+	}
+
+	cfg.OIDC.Scopes = []string{"profile"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "openid") {
+		t.Fatalf("expected missing openid scope to be rejected, got %v", err)
+	}
+}
+
+func TestValidate_SMTP(t *testing.T) {
+	cfg := Default()
+	cfg.SMTP.Addr = "localhost"
+	cfg.SMTP.From = "todo"
+
+	err := cfg.Validate()
+	for _, want := range []string{"smtp addr", "smtp from"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %q, got %v", want, err)
+		}
+	}
+
+	cfg.SMTP.Addr = "localhost:25"
+	cfg.SMTP.From = "Todo <todo@example.com>"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected valid smtp config: %v", err)
+	}
+}
+
+func TestValidate_FrontendAPIBaseURL(t *testing.T) {
+	cfg := Default()
+	for _, valid := range []string{"", "https://api.example.com", "/todo"} {
+		cfg.Frontend.APIBaseURL = valid
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected %q to be valid: %v", valid, err)
+		}
+	}
+
+	cfg.Frontend.APIBaseURL = "api.example.com"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "frontend api base url") {
+		t.Fatalf("expected relative api base url to be rejected, got %v", err)
+	}
+}
+
+func TestLoadFile_YAML(t *testing.T) {
+	path := writeFile(t, "server.yaml", `
+addr: ":9090"
+log:
+  level: debug
+cors:
+  origins: ["https://app.example.com"]
+  max_age: 10m
+  credentials: true
+`)
+	cfg := Default()
+	if err := loadFile(path, &cfg); err != nil {
+		t.Fatalf("load yaml: %v", err)
+	}
+
+	if cfg.Addr != ":9090" || cfg.Log.Level != "debug" || cfg.Log.Format != "text" {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+	if time.Duration(cfg.CORS.MaxAge) != 10*time.Minute || !cfg.CORS.Credentials {
+		t.Fatalf("unexpected cors config: %#v", cfg.CORS)
+	}
+}
+
+func TestLoadFile_TOML(t *testing.T) {
+	path := writeFile(t, "server.toml", `
+db = "/var/lib/todo.db"
+
+[tracing]
+exporter = "otlp"
+otlp_headers = { "x-api-key" = "secret" }
+`)
+	cfg := Default()
+	if err := loadFile(path, &cfg); err != nil {
+		t.Fatalf("load toml: %v", err)
+	}
+
+	if cfg.DB != "/var/lib/todo.db" || cfg.Tracing.Exporter != "otlp" {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+	if cfg.Tracing.OTLPHeaders["x-api-key"] != "secret" {
+		t.Fatalf("unexpected headers: %#v", cfg.Tracing.OTLPHeaders)
+	}
+}
+
+func TestLoadFile_RejectsUnknownKeys(t *testing.T) {
+	for name, content := range map[string]string{
+		"server.yaml": "adr: \":9090\"\n",
+		"server.toml": "adr = \":9090\"\n",
+	} {
+		cfg := Default()
+		if err := loadFile(writeFile(t, name, content), &cfg); err == nil {
+			t.Fatalf("%s: expected unknown key to be rejected", name)
+		}
+	}
+}
+
+func TestRedacted_MasksSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Tracing.OTLPHeaders = map[string]string{"x-api-key": "secret"}
+	cfg.OIDC.ClientSecret = "client-secret"
+
+	redactedCfg := cfg.Redacted()
+
+	if redactedCfg.Tracing.OTLPHeaders["x-api-key"] != redacted {
+		t.Fatalf("expected header to be redacted, got %#v", redactedCfg.Tracing.OTLPHeaders)
+	}
+	if redactedCfg.OIDC.ClientSecret != redacted {
+		t.Fatalf("expected client secret to be redacted, got %q", redactedCfg.OIDC.ClientSecret)
+	}
+	if redactedCfg.OIDC.ClientSecret != redacted {
+		t.Fatalf("expected client secret to be redacted, got %q", redactedCfg.OIDC.ClientSecret)
+	}
+	if cfg.Tracing.OTLPHeaders["x-api-key"] != "secret" {
+		t.Fatalf("expected original config to be untouched")
+	}
+
+	out, err := redactedCfg.YAML()
+	if err != nil {
+		t.Fatalf("marshal yaml: %v", err)
+	}
+	if strings.Contains(string(out), ": secret") || strings.Contains(string(out), "client-secret") || !strings.Contains(string(out), "max_age: 0s") {
+		t.Fatalf("unexpected yaml output:\n%s", out)
+	}
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envPrefix = "TODO_"
+
+type Options struct {
+	// Path is the config file that was loaded, if any.
+	Path        string
+	PrintConfig bool
+	// MCPStdio serves the MCP server on stdin and stdout instead of
+	// starting the HTTP server.
+	MCPStdio bool
+}
+
+// setting binds one configuration value to its flag and TODO_* environment
+// variable. Reloadable settings are applied on SIGHUP without a restart.
+type setting struct {
+	name       string
+	usage      string
+	value      flag.Value
+	reloadable bool
+}
+
+func settings(c *Config) []setting {
+	return []setting{
+		{name: "addr", usage: "server listen address", value: (*stringValue)(&c.Addr)},
+		{name: "db", usage: "sqlite database path", value: (*stringValue)(&c.DB)},
+		{name: "log-format", usage: "log output format (text or json)", value: (*stringValue)(&c.Log.Format)},
+		{name: "log-level", usage: "minimum log level (debug, info, warn or error)", value: (*stringValue)(&c.Log.Level), reloadable: true},
+		{name: "trace-exporter", usage: "trace exporter (none, stdout or otlp)", value: (*stringValue)(&c.Tracing.Exporter)},
+		{name: "trace-output", usage: "file written by the stdout trace exporter (default stderr)", value: (*stringValue)(&c.Tracing.Output)},
+		{name: "otlp-endpoint", usage: "OTLP/HTTP collector host:port (default from OTEL_EXPORTER_OTLP_ENDPOINT)", value: (*stringValue)(&c.Tracing.OTLPEndpoint)},
+		{name: "otlp-insecure", usage: "use plain HTTP for the OTLP exporter", value: (*boolValue)(&c.Tracing.OTLPInsecure)},
+		{name: "otlp-headers", usage: "comma-separated key=value headers sent to the OTLP collector", value: (*mapValue)(&c.Tracing.OTLPHeaders)},
+		{name: "cors-origins", usage: "comma-separated allowed origins; supports * and https://*.example.com", value: (*listValue)(&c.CORS.Origins), reloadable: true},
+		{name: "cors-methods", usage: "comma-separated allowed methods", value: (*listValue)(&c.CORS.Methods), reloadable: true},
+		{name: "cors-headers", usage: "comma-separated allowed request headers", value: (*listValue)(&c.CORS.Headers), reloadable: true},
+		{name: "cors-expose-headers", usage: "comma-separated response headers exposed to browsers", value: (*listValue)(&c.CORS.ExposeHeaders), reloadable: true},
+		{name: "cors-max-age", usage: "how long browsers may cache preflight results", value: (*durationValue)(&c.CORS.MaxAge), reloadable: true},
+		{name: "cors-credentials", usage: "allow cookies and credentials (requires explicit origins)", value: (*boolValue)(&c.CORS.Credentials), reloadable: true},
+		{name: "max-body-bytes", usage: "maximum request body size in bytes", value: (*int64Value)(&c.Limits.MaxBodyBytes)},
+		{name: "max-title-length", usage: "maximum todo title length in characters", value: (*intValue)(&c.Limits.MaxTitleLength)},
+		{name: "attachments-dir", usage: "directory for uploaded attachments", value: (*stringValue)(&c.Attachments.Dir)},
+		{name: "attachment-max-bytes", usage: "maximum size of a single attachment in bytes", value: (*int64Value)(&c.Attachments.MaxFileBytes)},
+		{name: "attachment-quota-bytes", usage: "maximum total attachment size per user in bytes", value: (*int64Value)(&c.Attachments.QuotaBytes)},
+		{name: "rate-limit", usage: "enable per-client rate limiting", value: (*boolValue)(&c.RateLimit.Enabled)},
+		{name: "rate-limit-rate", usage: "default requests per second allowed per client and route", value: (*floatValue)(&c.RateLimit.Rate)},
+		{name: "rate-limit-burst", usage: "default burst size per client and route", value: (*intValue)(&c.RateLimit.Burst)},
+		{name: "rate-limit-routes", usage: `comma-separated per-route overrides, e.g. "POST /api/todos=2:10" (rate:burst)`, value: (*rateRulesValue)(&c.RateLimit.Routes)},
+		{name: "require-auth", usage: "reject todo mutations without a read-write credential", value: (*boolValue)(&c.Auth.Required)},
+		{name: "admin-token", usage: "bearer token for the API key management endpoints (disabled when empty)", value: (*stringValue)(&c.Auth.AdminToken)},
+		{name: "oidc-issuer", usage: "OpenID Connect issuer URL; enables browser login when set", value: (*stringValue)(&c.OIDC.Issuer)},
+		{name: "oidc-client-id", usage: "OpenID Connect client ID", value: (*stringValue)(&c.OIDC.ClientID)},
+		{name: "oidc-client-secret", usage: "OpenID Connect client secret (empty for public clients)", value: (*stringValue)(&c.OIDC.ClientSecret)},
+		{name: "oidc-redirect-url", usage: "absolute URL of this server's /auth/callback", value: (*stringValue)(&c.OIDC.RedirectURL)},
+		{name: "oidc-scopes", usage: "comma-separated scopes requested at login", value: (*listValue)(&c.OIDC.Scopes)},
+		{name: "oidc-email-claim", usage: "ID token claim stored as the user's email", value: (*stringValue)(&c.OIDC.EmailClaim)},
+		{name: "oidc-name-claim", usage: "ID token claim stored as the user's display name", value: (*stringValue)(&c.OIDC.NameClaim)},
+		{name: "session-ttl", usage: "lifetime of a browser login session", value: (*durationValue)(&c.OIDC.SessionTTL)},
+		{name: "cookie-secure", usage: "mark session cookies Secure (enable behind HTTPS)", value: (*boolValue)(&c.OIDC.CookieSecure)},
+		{name: "oidc-post-logout-url", usage: "URL the identity provider redirects to after logout", value: (*stringValue)(&c.OIDC.PostLogoutURL)},
+		{name: "smtp-addr", usage: "SMTP server host:port for email notifications (disabled when empty)", value: (*stringValue)(&c.SMTP.Addr)},
+		{name: "smtp-from", usage: "sender address of email notifications", value: (*stringValue)(&c.SMTP.From)},
+		{name: "smtp-username", usage: "SMTP username (no authentication when empty)", value: (*stringValue)(&c.SMTP.Username)},
+		{name: "smtp-password", usage: "SMTP password", value: (*stringValue)(&c.SMTP.Password)},
+		{name: "frontend-dir", usage: "serve the built frontend from this directory instead of the embedded copy", value: (*stringValue)(&c.Frontend.Dir)},
+		{name: "frontend-api-base-url", usage: "API base URL handed to the frontend (default same origin)", value: (*stringValue)(&c.Frontend.APIBaseURL)},
+		{name: "trust-proxy", usage: "use X-Forwarded-For to identify clients behind a reverse proxy", value: (*boolValue)(&c.RateLimit.TrustProxy)},
+		{name: "proxy-hops", usage: "number of trusted reverse proxies appending to X-Forwarded-For", value: (*intValue)(&c.RateLimit.ProxyHops)},
+	}
+}
+
+func envName(settingName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(settingName, "-", "_"))
+}
+
+// Load builds the configuration from defaults, then the config file, then
+// TODO_* environment variables and finally command-line flags, each layer
+// overriding the previous one. The result is validated.
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (Config, Options, error) {
+	flagValues := Default()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	var opts Options
+	fs.StringVar(&opts.Path, "config", "", "path to a .yaml, .yml or .toml config file (env "+envName("config")+")")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	fs.BoolVar(&opts.MCPStdio, "mcp-stdio", false, "serve the MCP server on stdin and stdout, as the admin, instead of HTTP")
+	for _, s := range settings(&flagValues) {
+		fs.Var(s.value, s.name, fmt.Sprintf("%s (env %s)", s.usage, envName(s.name)))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, Options{}, err
+	}
+	if opts.Path == "" {
+		opts.Path, _ = lookupEnv(envName("config"))
+	}
+
+	cfg := Default()
+	if opts.Path != "" {
+		if err := loadFile(opts.Path, &cfg); err != nil {
+			return Config{}, Options{}, err
+		}
+	}
+
+	for _, s := range settings(&cfg) {
+		raw, ok := lookupEnv(envName(s.name))
+		if !ok {
+			continue
+		}
+		if err := s.value.Set(raw); err != nil {
+			return Config{}, Options{}, fmt.Errorf("invalid %s: %w", envName(s.name), err)
+		}
+	}
+
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = f.Value.String() })
+	for _, s := range settings(&cfg) {
+		if raw, ok := explicit[s.name]; ok {
+			if err := s.value.Set(raw); err != nil {
+				return Config{}, Options{}, fmt.Errorf("invalid -%s: %w", s.name, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, Options{}, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, opts, nil
+}
+
+// Diff reports which settings differ between previous and next, split into
+// those that can be applied at runtime and those that need a restart.
+func Diff(previous Config, next Config) (reloadable []string, restartRequired []string) {
+	before := settings(&previous)
+	after := settings(&next)
+	for i := range before {
+		if before[i].value.String() == after[i].value.String() {
+			continue
+		}
+		if before[i].reloadable {
+			reloadable = append(reloadable, before[i].name)
+		} else {
+			restartRequired = append(restartRequired, before[i].name)
+		}
+	}
+	return reloadable, restartRequired
+}
+
+type stringValue string
+
+func (v *stringValue) Set(raw string) error { *v = stringValue(raw); return nil }
+func (v *stringValue) String() string       { return string(*v) }
+
+type boolValue bool
+
+func (v *boolValue) Set(raw string) error {
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(parsed)
+	return nil
+}
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type intValue int
+
+func (v *intValue) Set(raw string) error {
+	parsed, err := strconv.Atoi(raw)
+	if err != nil {
+		return err
+	}
+	*v = intValue(parsed)
+	return nil
+}
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type int64Value int64
+
+func (v *int64Value) Set(raw string) error {
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = int64Value(parsed)
+	return nil
+}
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+type floatValue float64
+
+func (v *floatValue) Set(raw string) error {
+	parsed, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return err
+	}
+	*v = floatValue(parsed)
+	return nil
+}
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+type durationValue Duration
+
+func (v *durationValue) Set(raw string) error {
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(parsed)
+	return nil
+}
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+type listValue []string
+
+func (v *listValue) Set(raw string) error {
+	values := make([]string, 0)
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	*v = values
+	return nil
+}
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+type mapValue map[string]string
+
+func (v *mapValue) Set(raw string) error {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	*v = values
+	return nil
+}
+
+func (v *mapValue) String() string {
+	pairs := make([]string, 0, len(*v))
+	for key, value := range *v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+type rateRulesValue map[string]RateRule
+
+func (v *rateRulesValue) Set(raw string) error {
+	rules := make(map[string]RateRule)
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		route, spec, ok := strings.Cut(entry, "=")
+		rateRaw, burstRaw, hasBurst := strings.Cut(spec, ":")
+		if !ok || !hasBurst || strings.TrimSpace(route) == "" {
+			return fmt.Errorf("expected route=rate:burst, got %q", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateRaw), 64)
+		if err != nil {
+			return fmt.Errorf("invalid rate in %q: %w", entry, err)
+		}
+		burst, err := strconv.Atoi(strings.TrimSpace(burstRaw))
+		if err != nil {
+			return fmt.Errorf("invalid burst in %q: %w", entry, err)
+		}
+		rules[strings.TrimSpace(route)] = RateRule{Rate: rate, Burst: burst}
+	}
+	*v = rules
+	return nil
+}
+
+func (v *rateRulesValue) String() string {
+	entries := make([]string, 0, len(*v))
+	for route, rule := range *v {
+		entries = append(entries, fmt.Sprintf("%s=%s:%d", route, strconv.FormatFloat(rule.Rate, 'g', -1, 64), rule.Burst))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
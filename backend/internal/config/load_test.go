@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func envFrom(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, opts, err := Load(nil, envFrom(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Fatalf("expected defaults, got %#v", cfg)
+	}
+	if opts.Path != "" || opts.PrintConfig {
+		t.Fatalf("unexpected options: %#v", opts)
+	}
+}
+
+func TestLoad_LayerPrecedence(t *testing.T) {
+	path := writeFile(t, "server.yaml", `
+addr: ":7000"
+db: "./file.db"
+log:
+  level: warn
+  format: json
+`)
+	env := envFrom(map[string]string{
+		"TODO_CONFIG":       path,
+		"TODO_DB":           "./env.db",
+		"TODO_LOG_LEVEL":    "error",
+		"TODO_CORS_ORIGINS": "https://a.example.com, https://b.example.com",
+	})
+
+	cfg, opts, err := Load([]string{"-log-level", "debug", "-print-config"}, env, io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if opts.Path != path || !opts.PrintConfig {
+		t.Fatalf("unexpected options: %#v", opts)
+	}
+	if cfg.Addr != ":7000" || cfg.Log.Format != "json" {
+		t.Fatalf("expected file values, got addr=%q format=%q", cfg.Addr, cfg.Log.Format)
+	}
+	if cfg.DB != "./env.db" {
+		t.Fatalf("expected env to override file, got %q", cfg.DB)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Fatalf("expected flag to override env, got %q", cfg.Log.Level)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(cfg.CORS.Origins, want) {
+		t.Fatalf("unexpected origins: %v", cfg.CORS.Origins)
+	}
+}
+
+func TestLoad_ConfigFlagOverridesEnv(t *testing.T) {
+	flagPath := writeFile(t, "flag.toml", `addr = ":7100"`)
+	envPath := writeFile(t, "env.toml", `addr = ":7200"`)
+
+	cfg, _, err := Load([]string{"-config", flagPath}, envFrom(map[string]string{"TODO_CONFIG": envPath}), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Addr != ":7100" {
+		t.Fatalf("expected -config to win, got %q", cfg.Addr)
+	}
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	cases := []struct {
+		args []string
+		env  map[string]string
+	}{
+		{env: map[string]string{"TODO_OTLP_INSECURE": "maybe"}},
+		{args: []string{"-cors-max-age", "soon"}},
+		{args: []string{"-log-level", "loud"}},
+		{env: map[string]string{"TODO_OTLP_HEADERS": "novalue"}},
+	}
+	for _, tc := range cases {
+		if _, _, err := Load(tc.args, envFrom(tc.env), io.Discard); err == nil {
+			t.Fatalf("expected error for args=%v env=%v", tc.args, tc.env)
+		}
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	_, _, err := Load([]string{"-h"}, envFrom(nil), io.Discard)
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	previous := Default()
+	next := Default()
+	next.Log.Level = "debug"
+	next.CORS.Origins = []string{"https://app.example.com"}
+	next.Addr = ":9999"
+
+	reloadable, restartRequired := Diff(previous, next)
+
+	if want := []string{"log-level", "cors-origins"}; !reflect.DeepEqual(reloadable, want) {
+		t.Fatalf("unexpected reloadable settings: %v", reloadable)
+	}
+	if want := []string{"addr"}; !reflect.DeepEqual(restartRequired, want) {
+		t.Fatalf("unexpected restart-required settings: %v", restartRequired)
+	}
+}
+
+func TestLoad_RateLimitRoutes(t *testing.T) {
+	env := envFrom(map[string]string{
+		"TODO_RATE_LIMIT_ROUTES": "POST /api/todos=0.5:3, DELETE /api/todos/{id}=1:2",
+	})
+
+	cfg, _, err := Load([]string{"-max-title-length", "80"}, env, io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	want := map[string]RateRule{
+		"POST /api/todos":        {Rate: 0.5, Burst: 3},
+		"DELETE /api/todos/{id}": {Rate: 1, Burst: 2},
+	}
+	if !reflect.DeepEqual(cfg.RateLimit.Routes, want) {
+		t.Fatalf("unexpected routes: %#v", cfg.RateLimit.Routes)
+	}
+	if cfg.Limits.MaxTitleLength != 80 {
+		t.Fatalf("unexpected max title length: %d", cfg.Limits.MaxTitleLength)
+	}
+
+	if _, _, err := Load([]string{"-rate-limit-routes", "POST /api/todos=1:0"}, envFrom(nil), io.Discard); err == nil {
+		t.Fatalf("expected zero burst to be rejected")
+	}
+}
@@ -0,0 +1,192 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com"),
+	// wildcard subdomain patterns ("https://*.example.com") or "*".
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	MaxAge           time.Duration
+	AllowCredentials bool
+}
+
+type Policy struct {
+	anyOrigin        bool
+	exactOrigins     map[string]struct{}
+	wildcardOrigins  []wildcardOrigin
+	allowedMethods   map[string]struct{}
+	allowedHeaders   map[string]struct{}
+	methods          string
+	headers          string
+	exposedHeaders   string
+	maxAge           string
+	allowCredentials bool
+}
+
+type wildcardOrigin struct {
+	prefix string
+	suffix string
+}
+
+func New(opts Options) (*Policy, error) {
+	p := &Policy{
+		exactOrigins:     make(map[string]struct{}),
+		allowedMethods:   make(map[string]struct{}),
+		allowedHeaders:   make(map[string]struct{}),
+		methods:          strings.Join(opts.AllowedMethods, ", "),
+		headers:          strings.Join(opts.AllowedHeaders, ", "),
+		exposedHeaders:   strings.Join(opts.ExposedHeaders, ", "),
+		allowCredentials: opts.AllowCredentials,
+	}
+
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "":
+			continue
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Count(origin, "*") == 1:
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			if !strings.HasSuffix(prefix, "://") || !strings.HasPrefix(suffix, ".") {
+				return nil, fmt.Errorf("invalid wildcard origin %q: want scheme://*.domain", origin)
+			}
+			p.wildcardOrigins = append(p.wildcardOrigins, wildcardOrigin{prefix: prefix, suffix: suffix})
+		case strings.Contains(origin, "*"):
+			return nil, fmt.Errorf("invalid wildcard origin %q: only one * is allowed", origin)
+		default:
+			p.exactOrigins[origin] = struct{}{}
+		}
+	}
+	if p.anyOrigin && p.allowCredentials {
+		return nil, errors.New("credentials cannot be allowed for every origin")
+	}
+
+	for _, method := range opts.AllowedMethods {
+		p.allowedMethods[strings.ToUpper(strings.TrimSpace(method))] = struct{}{}
+	}
+	for _, header := range opts.AllowedHeaders {
+		p.allowedHeaders[http.CanonicalHeaderKey(strings.TrimSpace(header))] = struct{}{}
+	}
+	if opts.MaxAge < 0 {
+		return nil, errors.New("max age must not be negative")
+	}
+	if opts.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+	return p, nil
+}
+
+func (p *Policy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// Responses differ per origin unless every origin gets "*", so shared
+		// caches must key on it.
+		if !p.anyOrigin || p.allowCredentials {
+			w.Header().Add("Vary", "Origin")
+		}
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !p.originAllowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if preflight {
+			if !p.preflightAllowed(r) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			p.setAllowOrigin(w, origin)
+			w.Header().Set("Access-Control-Allow-Methods", p.methods)
+			if p.headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", p.headers)
+			}
+			if p.maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", p.maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		p.setAllowOrigin(w, origin)
+		if p.exposedHeaders != "" {
+			w.Header().Set("Access-Control-Expose-Headers", p.exposedHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *Policy) setAllowOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *Policy) originAllowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if _, ok := p.exactOrigins[origin]; ok {
+		return true
+	}
+	for _, wildcard := range p.wildcardOrigins {
+		if !strings.HasPrefix(origin, wildcard.prefix) || !strings.HasSuffix(origin, wildcard.suffix) {
+			continue
+		}
+		if len(origin) <= len(wildcard.prefix)+len(wildcard.suffix) {
+			continue
+		}
+		subdomain := origin[len(wildcard.prefix) : len(origin)-len(wildcard.suffix)]
+		if !strings.ContainsAny(subdomain, "/:@") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) preflightAllowed(r *http.Request) bool {
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if _, ok := p.allowedMethods[method]; !ok {
+		return false
+	}
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if _, ok := p.allowedHeaders[http.CanonicalHeaderKey(header)]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestPolicy(t *testing.T, opts Options) *Policy {
+	t.Helper()
+
+	if opts.AllowedMethods == nil {
+		opts.AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	if opts.AllowedHeaders == nil {
+		opts.AllowedHeaders = []string{"Content-Type"}
+	}
+	policy, err := New(opts)
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	return policy
+}
+
+func serve(policy *Policy, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	nextCalled := false
+	handler := policy.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr, nextCalled
+}
+
+func preflightRequest(origin string, method string, headers string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, "/api/todos/1", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return req
+}
+
+func TestHandler_AnyOriginSetsWildcard(t *testing.T) {
+	policy := newTestPolicy(t, Options{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Request-ID"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	rr, nextCalled := serve(policy, req)
+
+	if !nextCalled {
+		t.Fatalf("expected next handler to be called")
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("unexpected allow origin: %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+		t.Fatalf("unexpected expose headers: %q", got)
+	}
+	if got := rr.Header().Values("Vary"); len(got) != 0 {
+		t.Fatalf("expected no Vary header for wildcard policy, got %v", got)
+	}
+}
+
+func TestHandler_HandlesPreflight(t *testing.T) {
+	policy := newTestPolicy(t, Options{AllowedOrigins: []string{"*"}, MaxAge: 10 * time.Minute})
+
+	rr, nextCalled := serve(policy, preflightRequest("http://localhost:5173", "PATCH", "content-type"))
+
+	if nextCalled {
+		t.Fatalf("expected next handler not to be called for preflight")
+	}
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, PUT, PATCH, DELETE, OPTIONS" {
+		t.Fatalf("unexpected allow methods: %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Fatalf("unexpected allow headers: %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Fatalf("unexpected max age: %q", got)
+	}
+}
+
+func TestHandler_ExactOriginWithCredentials(t *testing.T) {
+	policy := newTestPolicy(t, Options{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr, _ := serve(policy, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("unexpected allow origin: %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Fatalf("unexpected allow credentials: %q", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Origin" {
+		t.Fatalf("expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestHandler_WildcardSubdomain(t *testing.T) {
+	policy := newTestPolicy(t, Options{AllowedOrigins: []string{"https://*.example.com"}})
+
+	cases := map[string]bool{
+		"https://app.example.com":         true,
+		"https://a.b.example.com":         true,
+		"https://example.com":             false,
+		"http://app.example.com":          false,
+		"https://evil.com/.example.com":   false,
+		"https://app.example.com.evil.io": false,
+	}
+	for origin, allowed := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+		req.Header.Set("Origin", origin)
+		rr, nextCalled := serve(policy, req)
+
+		if !nextCalled {
+			t.Fatalf("%s: expected simple request to reach next handler", origin)
+		}
+		got := rr.Header().Get("Access-Control-Allow-Origin")
+		if allowed && got != origin {
+			t.Fatalf("%s: expected origin to be allowed, got %q", origin, got)
+		}
+		if !allowed && got != "" {
+			t.Fatalf("%s: expected origin to be rejected, got %q", origin, got)
+		}
+	}
+}
+
+func TestHandler_RejectsPreflightFromUnknownOrigin(t *testing.T) {
+	policy := newTestPolicy(t, Options{AllowedOrigins: []string{"https://app.example.com"}})
+
+	rr, nextCalled := serve(policy, preflightRequest("https://evil.example.org", "DELETE", ""))
+
+	if nextCalled {
+		t.Fatalf("expected next handler not to be called")
+	}
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no allow origin, got %q", got)
+	}
+	vary := rr.Header().Values("Vary")
+	if len(vary) != 3 || vary[0] != "Origin" {
+		t.Fatalf("unexpected Vary headers: %v", vary)
+	}
+}
+
+func TestHandler_RejectsPreflightForDisallowedHeader(t *testing.T) {
+	policy := newTestPolicy(t, Options{AllowedOrigins: []string{"*"}})
+
+	rr, _ := serve(policy, preflightRequest("http://localhost:5173", "POST", "Content-Type, X-Secret"))
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", rr.Code)
+	}
+}
+
+func TestHandler_PassesThroughWithoutOrigin(t *testing.T) {
+	policy := newTestPolicy(t, Options{AllowedOrigins: []string{"https://app.example.com"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+	rr, nextCalled := serve(policy, req)
+
+	if !nextCalled {
+		t.Fatalf("expected next handler to be called")
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no allow origin, got %q", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Origin" {
+		t.Fatalf("expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestNew_RejectsInvalidOptions(t *testing.T) {
+	cases := []Options{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"*.example.com"}},
+		{AllowedOrigins: []string{"https://*.*.example.com"}},
+		{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: -time.Second},
+	}
+	for _, opts := range cases {
+		if _, err := New(opts); err == nil {
+			t.Fatalf("expected error for options %#v", opts)
+		}
+	}
+}
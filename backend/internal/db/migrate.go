@@ -5,28 +5,270 @@ import (
 	"fmt"
 )
 
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		completed INTEGER NOT NULL DEFAULT 0,
+		list_id INTEGER REFERENCES lists(id) ON DELETE CASCADE,
+		completed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		completed_at DATETIME,
+		notes TEXT NOT NULL DEFAULT '',
+		due DATETIME,
+		priority INTEGER NOT NULL DEFAULT 0,
+		uid TEXT
+	);`,
+	`CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+		author_key TEXT NOT NULL,
+		author_name TEXT NOT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		deleted_at DATETIME
+	);`,
+	`CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		blob_key TEXT NOT NULL,
+		uploader_key TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS todo_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL UNIQUE,
+		list_id INTEGER,
+		uid TEXT,
+		deleted INTEGER NOT NULL DEFAULT 0
+	);`,
+	`CREATE TABLE IF NOT EXISTS todo_clocks (
+		todo_id INTEGER NOT NULL,
+		field TEXT NOT NULL,
+		time INTEGER NOT NULL,
+		client_id TEXT NOT NULL,
+		PRIMARY KEY (todo_id, field)
+	);`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL UNIQUE,
+		hash TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		issuer TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE (issuer, subject)
+	);`,
+	`CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash TEXT NOT NULL UNIQUE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		id_token TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS feed_tokens (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS lists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS list_members (
+		list_id INTEGER NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (list_id, user_id)
+	);`,
+	`CREATE TABLE IF NOT EXISTS list_invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		list_id INTEGER NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+		token_hash TEXT NOT NULL UNIQUE,
+		email TEXT NOT NULL DEFAULT '',
+		role TEXT NOT NULL,
+		invited_by INTEGER NOT NULL REFERENCES users(id),
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		accepted_by INTEGER REFERENCES users(id),
+		accepted_at DATETIME
+	);`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		secret TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_status INTEGER,
+		error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_attempt_at DATETIME,
+		next_attempt_at DATETIME
+	);`,
+	`CREATE TABLE IF NOT EXISTS reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		owner_key TEXT NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		remind_at DATETIME,
+		minutes_before INTEGER,
+		created_at DATETIME NOT NULL,
+		fired_at DATETIME
+	);`,
+	notificationsTable,
+	`CREATE TABLE IF NOT EXISTS digest_settings (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		frequency TEXT NOT NULL,
+		timezone TEXT NOT NULL,
+		hour INTEGER NOT NULL,
+		weekday TEXT NOT NULL,
+		last_sent_at DATETIME
+	);`,
+	`CREATE TABLE IF NOT EXISTS login_states (
+		state TEXT PRIMARY KEY,
+		nonce TEXT NOT NULL,
+		code_verifier TEXT NOT NULL,
+		return_to TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`,
+}
+
+// notificationsTable is shared with upgradeNotifications, which rebuilds
+// tables created before notifications referenced their todo.
+const notificationsTable = `CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recipient_key TEXT NOT NULL,
+		reminder_id INTEGER REFERENCES reminders(id) ON DELETE SET NULL,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		due DATETIME,
+		created_at DATETIME NOT NULL,
+		read_at DATETIME
+	);`
+
+// addedColumns lists columns introduced after their table was first created,
+// so databases created by older versions are upgraded in place.
+var addedColumns = []struct {
+	table      string
+	name       string
+	definition string
+}{
+	{table: "todos", name: "completed", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "todos", name: "list_id", definition: "INTEGER REFERENCES lists(id) ON DELETE CASCADE"},
+	{table: "todos", name: "completed_by", definition: "INTEGER REFERENCES users(id) ON DELETE SET NULL"},
+	{table: "todos", name: "completed_at", definition: "DATETIME"},
+	{table: "todos", name: "notes", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "todos", name: "due", definition: "DATETIME"},
+	{table: "todos", name: "priority", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "todos", name: "uid", definition: "TEXT"},
+}
+
+// indexes run after addedColumns because they may cover added columns.
+var indexes = []string{
+	`CREATE INDEX IF NOT EXISTS todos_list_id ON todos (list_id);`,
+	`CREATE INDEX IF NOT EXISTS todos_uid ON todos (uid);`,
+	`CREATE INDEX IF NOT EXISTS todo_changes_list_id_seq ON todo_changes (list_id, seq);`,
+	`CREATE INDEX IF NOT EXISTS list_members_user_id ON list_members (user_id);`,
+	`CREATE INDEX IF NOT EXISTS comments_todo_id ON comments (todo_id);`,
+	`CREATE INDEX IF NOT EXISTS attachments_todo_id ON attachments (todo_id);`,
+	`CREATE INDEX IF NOT EXISTS attachments_blob_key ON attachments (blob_key);`,
+	`CREATE INDEX IF NOT EXISTS attachments_uploader_key ON attachments (uploader_key);`,
+	`CREATE INDEX IF NOT EXISTS reminders_todo_id ON reminders (todo_id);`,
+	`CREATE INDEX IF NOT EXISTS reminders_pending ON reminders (fired_at, remind_at);`,
+	`CREATE INDEX IF NOT EXISTS notifications_recipient_key ON notifications (recipient_key, id);`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id);`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);`,
+}
+
 func Migrate(database *sql.DB) error {
-	_, err := database.Exec(`
-		CREATE TABLE IF NOT EXISTS todos (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT NOT NULL,
-			completed INTEGER NOT NULL DEFAULT 0
-		);
-	`)
-	if err != nil {
+	for _, statement := range schema {
+		if _, err := database.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	for _, column := range addedColumns {
+		exists, err := hasColumn(database, column.table, column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := database.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, column.table, column.name, column.definition)); err != nil {
+			return err
+		}
+	}
+
+	if err := upgradeNotifications(database); err != nil {
 		return err
 	}
 
-	hasCompleted, err := hasColumn(database, "todos", "completed")
-	if err != nil {
+	for _, statement := range indexes {
+		if _, err := database.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upgradeNotifications gives a notifications table created without foreign
+// keys the ones notificationsTable declares. SQLite cannot add constraints
+// to a table, so the rows are copied into a new one, dropping those whose
+// todo is already gone.
+func upgradeNotifications(database *sql.DB) error {
+	var keys int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM pragma_foreign_key_list('notifications')`).Scan(&keys); err != nil {
 		return err
 	}
-	if hasCompleted {
+	if keys > 0 {
 		return nil
 	}
-
-	_, err = database.Exec(`ALTER TABLE todos ADD COLUMN completed INTEGER NOT NULL DEFAULT 0;`)
-	return err
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range []string{
+		`ALTER TABLE notifications RENAME TO notifications_old;`,
+		notificationsTable,
+		`INSERT INTO notifications (id, recipient_key, reminder_id, todo_id, title, due, created_at, read_at)
+		SELECT id, recipient_key, CASE WHEN reminder_id IN (SELECT id FROM reminders) THEN reminder_id END,
+			todo_id, title, due, created_at, read_at
+		FROM notifications_old WHERE todo_id IN (SELECT id FROM todos);`,
+		`DROP TABLE notifications_old;`,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func SeedIfEmpty(database *sql.DB) error {
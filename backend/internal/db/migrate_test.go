@@ -10,10 +10,11 @@ import (
 func openTestDB(t *testing.T) *sql.DB {
 	t.Helper()
 
-	database, err := sql.Open("sqlite", ":memory:")
+	database, err := Open(":memory:")
 	if err != nil {
 		t.Fatalf("open sqlite: %v", err)
 	}
+	database.SetMaxOpenConns(1)
 	return database
 }
 
@@ -30,6 +31,21 @@ func TestMigrateCreatesTodosWithCompleted(t *testing.T) {
 	}
 }
 
+func TestMigrateIsIdempotentAndCreatesAPIKeys(t *testing.T) {
+	database := openTestDB(t)
+	defer database.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(database); err != nil {
+			t.Fatalf("migrate run %d: %v", i+1, err)
+		}
+	}
+
+	if !columnExists(t, database, "api_keys", "hash") {
+		t.Fatalf("expected api_keys table to exist")
+	}
+}
+
 func TestMigrateAddsCompletedToExistingTable(t *testing.T) {
 	database := openTestDB(t)
 	defer database.Close()
@@ -48,8 +64,86 @@ func TestMigrateAddsCompletedToExistingTable(t *testing.T) {
 		t.Fatalf("migrate old schema: %v", err)
 	}
 
-	if !columnExists(t, database, "todos", "completed") {
-		t.Fatalf("expected completed column to exist")
+	for _, column := range []string{"completed", "list_id", "completed_by", "completed_at", "notes"} {
+		if !columnExists(t, database, "todos", column) {
+			t.Fatalf("expected %s column to exist", column)
+		}
+	}
+}
+
+func TestOpen_DeletingAListCascades(t *testing.T) {
+	database := openTestDB(t)
+	defer database.Close()
+	if err := Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	for _, statement := range []string{
+		`INSERT INTO users (id, issuer, subject, email, name, created_at, updated_at) VALUES (1, 'https://idp.example.com', 'u1', '', '', 0, 0)`,
+		`INSERT INTO lists (id, name, created_at) VALUES (1, 'Household', 0)`,
+		`INSERT INTO list_members (list_id, user_id, role, created_at) VALUES (1, 1, 'owner', 0)`,
+		`INSERT INTO todos (id, title, list_id) VALUES (1, 'Shared', 1), (2, 'Private', NULL)`,
+		`INSERT INTO reminders (id, todo_id, owner_key, created_at) VALUES (1, 1, 'user:1', 0)`,
+		`INSERT INTO notifications (recipient_key, reminder_id, todo_id, title, created_at) VALUES ('user:1', 1, 1, 'Shared', 0), ('user:1', NULL, 2, 'Private', 0)`,
+	} {
+		if _, err := database.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	if _, err := database.Exec(`DELETE FROM lists WHERE id = 1`); err != nil {
+		t.Fatalf("delete list: %v", err)
+	}
+
+	for table, want := range map[string]int{"todos": 1, "list_members": 0, "reminders": 0, "notifications": 1} {
+		var count int
+		if err := database.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if count != want {
+			t.Fatalf("expected %d rows left in %s, got %d", want, table, count)
+		}
+	}
+}
+
+func TestMigrateAddsForeignKeysToNotifications(t *testing.T) {
+	database := openTestDB(t)
+	defer database.Close()
+
+	for _, statement := range []string{
+		`CREATE TABLE todos (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL)`,
+		`CREATE TABLE notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			recipient_key TEXT NOT NULL,
+			reminder_id INTEGER,
+			todo_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			due DATETIME,
+			created_at DATETIME NOT NULL,
+			read_at DATETIME
+		)`,
+		`INSERT INTO todos (id, title) VALUES (1, 'Kept')`,
+		`INSERT INTO notifications (recipient_key, reminder_id, todo_id, title, created_at) VALUES ('user:1', 9, 1, 'Kept', 0), ('user:1', 9, 2, 'Orphaned', 0)`,
+	} {
+		if _, err := database.Exec(statement); err != nil {
+			t.Fatalf("create old schema: %v", err)
+		}
+	}
+	if err := Migrate(database); err != nil {
+		t.Fatalf("migrate old schema: %v", err)
+	}
+
+	var title string
+	var reminderID sql.NullInt64
+	if err := database.QueryRow(`SELECT title, reminder_id FROM notifications`).Scan(&title, &reminderID); err != nil || title != "Kept" || reminderID.Valid {
+		t.Fatalf("expected only the notification whose todo exists, without its missing reminder, got %q %v (%v)", title, reminderID, err)
+	}
+	if _, err := database.Exec(`DELETE FROM todos WHERE id = 1`); err != nil {
+		t.Fatalf("delete todo: %v", err)
+	}
+	var count int
+	database.QueryRow(`SELECT COUNT(*) FROM notifications`).Scan(&count)
+	if count != 0 {
+		t.Fatalf("expected the notification to go with its todo, %d left", count)
 	}
 }
 
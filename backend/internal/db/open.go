@@ -0,0 +1,19 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// Open opens the SQLite database at dsn. SQLite enforces foreign keys, and
+// so the ON DELETE clauses of the schema, only on connections that ask for
+// it, so the pragma goes in the DSN to apply to every pooled connection.
+func Open(dsn string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return sql.Open("sqlite", dsn+separator+"_pragma=foreign_keys(1)")
+}
@@ -0,0 +1,20 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// InTx runs fn in a transaction, committing when it returns nil and rolling
+// back otherwise.
+func InTx(ctx context.Context, database *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
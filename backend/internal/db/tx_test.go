@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestInTx(t *testing.T) {
+	database := openTestDB(t)
+	defer database.Close()
+	// Every connection to :memory: is a separate database.
+	database.SetMaxOpenConns(1)
+	ctx := context.Background()
+	if _, err := database.ExecContext(ctx, `CREATE TABLE things (name TEXT)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	insert := func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO things (name) VALUES ('a')`)
+		return err
+	}
+
+	failure := errors.New("boom")
+	err := InTx(ctx, database, func(tx *sql.Tx) error {
+		if err := insert(tx); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the callback's error, got %v", err)
+	}
+	if err := InTx(ctx, database, insert); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	var count int
+	if err := database.QueryRowContext(ctx, `SELECT COUNT(*) FROM things`).Scan(&count); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected only the committed row, got %d", count)
+	}
+}
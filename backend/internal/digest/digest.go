@@ -0,0 +1,149 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+)
+
+// defaultListName labels todos on the default list, which has no name of
+// its own.
+const defaultListName = "Todos"
+
+type Todos interface {
+	List(ctx context.Context, listID *int64) ([]todo.Item, error)
+}
+
+type Lists interface {
+	Lists(ctx context.Context, userID int64) ([]sharing.List, error)
+}
+
+// Entry is a todo in a digest with the name of the list it is on.
+type Entry struct {
+	todo.Item
+	List string
+	loc  *time.Location
+}
+
+// DueLabel formats the due date in the user's timezone, leaving out the
+// time for date-only due dates.
+func (e Entry) DueLabel() string {
+	if e.Due == nil {
+		return ""
+	}
+	if dateOnly(*e.Due) {
+		return "due " + localDue(*e.Due, e.loc).Format("Mon 2 Jan")
+	}
+	return "due " + e.Due.In(e.loc).Format("Mon 2 Jan 15:04")
+}
+
+// Digest summarises a user's todos as of Date, in their timezone.
+type Digest struct {
+	Name      string
+	Frequency Frequency
+	Date      time.Time
+	Overdue   []Entry
+	DueToday  []Entry
+	Completed []Entry
+}
+
+func (d Digest) Empty() bool {
+	return len(d.Overdue) == 0 && len(d.DueToday) == 0 && len(d.Completed) == 0
+}
+
+func (d Digest) Subject() string {
+	period := "Daily"
+	if d.Frequency == FrequencyWeekly {
+		period = "Weekly"
+	}
+	return fmt.Sprintf("%s todo digest: %d overdue, %d due today", period, len(d.Overdue), len(d.DueToday))
+}
+
+type source struct {
+	id   *int64
+	name string
+}
+
+// Builder gathers the todos for a digest from the default list and every
+// shared list the user belongs to.
+type Builder struct {
+	todos Todos
+	lists Lists
+}
+
+func NewBuilder(todos Todos, lists Lists) *Builder {
+	return &Builder{todos: todos, lists: lists}
+}
+
+// Build returns userID's digest as of now. Todos are overdue when due
+// before the start of the user's day and due today when due within it;
+// a date-only due date counts as that date wherever the user is.
+// Completed todos are those completed in the last day, or the last week
+// for weekly digests.
+func (b *Builder) Build(ctx context.Context, userID int64, name string, settings Settings, now time.Time) (Digest, error) {
+	loc := settings.Location()
+	local := now.In(loc)
+	startOfDay := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	endOfDay := startOfDay.AddDate(0, 0, 1)
+	completedSince := now.AddDate(0, 0, -1)
+	if settings.Frequency == FrequencyWeekly {
+		completedSince = now.AddDate(0, 0, -7)
+	}
+
+	lists, err := b.lists.Lists(ctx, userID)
+	if err != nil {
+		return Digest{}, err
+	}
+	sources := []source{{nil, defaultListName}}
+	for _, list := range lists {
+		sources = append(sources, source{&list.ID, list.Name})
+	}
+
+	d := Digest{Name: name, Frequency: settings.Frequency, Date: startOfDay}
+	for _, source := range sources {
+		items, err := b.todos.List(ctx, source.id)
+		if err != nil {
+			return Digest{}, err
+		}
+		for _, item := range items {
+			entry := Entry{Item: item, List: source.name, loc: loc}
+			switch {
+			case item.Completed:
+				if item.CompletedAt != nil && !item.CompletedAt.Before(completedSince) {
+					d.Completed = append(d.Completed, entry)
+				}
+			case item.Due == nil:
+			case localDue(*item.Due, loc).Before(startOfDay):
+				d.Overdue = append(d.Overdue, entry)
+			case localDue(*item.Due, loc).Before(endOfDay):
+				d.DueToday = append(d.DueToday, entry)
+			}
+		}
+	}
+
+	byDue := func(a, b Entry) int { return localDue(*a.Due, loc).Compare(localDue(*b.Due, loc)) }
+	slices.SortStableFunc(d.Overdue, byDue)
+	slices.SortStableFunc(d.DueToday, byDue)
+	slices.SortStableFunc(d.Completed, func(a, b Entry) int { return b.CompletedAt.Compare(*a.CompletedAt) })
+	return d, nil
+}
+
+// dateOnly reports whether due is a date without a time of day.
+func dateOnly(due time.Time) bool {
+	due = due.UTC()
+	return due.Equal(due.Truncate(24 * time.Hour))
+}
+
+// localDue places due in loc, reading a date-only due date as midnight
+// there rather than midnight UTC.
+func localDue(due time.Time, loc *time.Location) time.Time {
+	if dateOnly(due) {
+		due = due.UTC()
+		return time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, loc)
+	}
+	return due.In(loc)
+}
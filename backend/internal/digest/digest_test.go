@@ -0,0 +1,145 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+)
+
+func setupDigest(t *testing.T) (*sql.DB, *Store, *Builder, *todo.Repository) {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := database.Exec(`
+		INSERT INTO users (id, issuer, subject, email, name, created_at, updated_at) VALUES
+			(1, 'https://idp.example.com', 'alice', 'alice@example.com', 'Alice', ?, ?),
+			(2, 'https://idp.example.com', 'bob', 'bob@example.com', '', ?, ?),
+			(3, 'https://idp.example.com', 'carol', '', 'Carol', ?, ?)`,
+		created, created, created, created, created, created); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+
+	repo := todo.NewRepository(database)
+	return database, NewStore(database), NewBuilder(repo, sharing.NewStore(database)), repo
+}
+
+func addTodo(t *testing.T, database *sql.DB, repo *todo.Repository, item todo.Item, completedAt *time.Time) {
+	t.Helper()
+	created, err := repo.Create(context.Background(), item)
+	if err != nil {
+		t.Fatalf("create todo: %v", err)
+	}
+	if completedAt != nil {
+		if _, err := database.Exec(`UPDATE todos SET completed = 1, completed_at = ? WHERE id = ?`, *completedAt, created.ID); err != nil {
+			t.Fatalf("complete todo: %v", err)
+		}
+	}
+}
+
+func titles(entries []Entry) []string {
+	titles := make([]string, len(entries))
+	for i, entry := range entries {
+		titles[i] = entry.Title + "@" + entry.List
+	}
+	return titles
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestBuilder_UsesTimezoneAndLists(t *testing.T) {
+	database, _, builder, repo := setupDigest(t)
+	ctx := context.Background()
+	lists := sharing.NewStore(database)
+	groceries, _ := lists.CreateList(ctx, "Groceries", 1)
+	hidden, _ := lists.CreateList(ctx, "Bob's", 2)
+
+	// Noon on Monday 2 March in Los Angeles.
+	now := time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)
+	database.Exec(`DELETE FROM todos`)
+	addTodo(t, database, repo, todo.Item{Title: "Undated"}, nil)
+	addTodo(t, database, repo, todo.Item{Title: "Taxes", Due: ptr(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))}, nil)
+	// Date-only, so due on 2 March in Los Angeles too, not at 16:00 the day before.
+	addTodo(t, database, repo, todo.Item{Title: "Rent", Due: ptr(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC))}, nil)
+	// 21:00 on 2 March in Los Angeles, already 3 March in UTC.
+	addTodo(t, database, repo, todo.Item{Title: "Call", Due: ptr(time.Date(2026, 3, 3, 5, 0, 0, 0, time.UTC))}, nil)
+	addTodo(t, database, repo, todo.Item{Title: "Dentist", Due: ptr(time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC))}, nil)
+	addTodo(t, database, repo, todo.Item{Title: "Milk", ListID: &groceries.ID, Due: ptr(time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC))}, nil)
+	addTodo(t, database, repo, todo.Item{Title: "Secret", ListID: &hidden.ID, Due: ptr(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))}, nil)
+	addTodo(t, database, repo, todo.Item{Title: "Laundry"}, ptr(now.Add(-2*time.Hour)))
+	addTodo(t, database, repo, todo.Item{Title: "Eggs", ListID: &groceries.ID}, ptr(now.Add(-3*24*time.Hour)))
+
+	settings := Settings{Frequency: FrequencyDaily, Timezone: "America/Los_Angeles", Hour: 8, Weekday: "monday"}
+	d, err := builder.Build(ctx, 1, "Alice", settings, now)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	assertEntries(t, "overdue", d.Overdue, "Taxes@Todos")
+	assertEntries(t, "due today", d.DueToday, "Rent@Todos", "Milk@Groceries", "Call@Todos")
+	assertEntries(t, "completed", d.Completed, "Laundry@Todos")
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, settings.Location()); !d.Date.Equal(want) {
+		t.Fatalf("expected digest date %v, got %v", want, d.Date)
+	}
+	if label := d.DueToday[0].DueLabel(); label != "due Mon 2 Mar" {
+		t.Fatalf("unexpected label for a date-only due date %q", label)
+	}
+	if label := d.DueToday[2].DueLabel(); label != "due Mon 2 Mar 21:00" {
+		t.Fatalf("unexpected label for a due time %q", label)
+	}
+
+	settings.Frequency = FrequencyWeekly
+	if d, _ = builder.Build(ctx, 1, "Alice", settings, now); len(d.Completed) != 2 {
+		t.Fatalf("expected a weekly digest to include the last week's completions, got %v", titles(d.Completed))
+	}
+}
+
+func assertEntries(t *testing.T, name string, entries []Entry, want ...string) {
+	t.Helper()
+	got := titles(entries)
+	if len(got) != len(want) {
+		t.Fatalf("expected %s %v, got %v", name, want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %s %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestRender_EscapesHTML(t *testing.T) {
+	d := Digest{
+		Name:      "Alice",
+		Frequency: FrequencyDaily,
+		Date:      time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		Overdue:   []Entry{{Item: todo.Item{Title: "<script>alert(1)</script>"}, List: "Todos", loc: time.UTC}},
+	}
+	msg, err := Message(d, "alice@example.com")
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if msg.Subject != "Daily todo digest: 1 overdue, 0 due today" {
+		t.Fatalf("unexpected subject %q", msg.Subject)
+	}
+	if want := "&lt;script&gt;alert(1)&lt;/script&gt;"; !contains(msg.HTML, want) || contains(msg.HTML, "<script>") {
+		t.Fatalf("expected the title to be escaped in %q", msg.HTML)
+	}
+	if !contains(msg.Text, "Overdue (1)\n- <script>alert(1)</script> [Todos]\n") {
+		t.Fatalf("unexpected text part %q", msg.Text)
+	}
+}
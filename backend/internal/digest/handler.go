@@ -0,0 +1,140 @@
+package digest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/respond"
+)
+
+const maxRequestBytes = 1 << 16
+
+type Handler struct {
+	store   *Store
+	builder *Builder
+	now     func() time.Time
+}
+
+func NewHandler(store *Store, builder *Builder) *Handler {
+	return &Handler{store: store, builder: builder, now: time.Now}
+}
+
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	settings, err := h.store.Settings(r.Context(), userID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch digest settings", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, settings)
+}
+
+// UpdateSettings replaces the caller's digest settings. Omitted fields take
+// their defaults; a frequency of "off" opts out.
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	settings := DefaultSettings
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !settings.Frequency.Valid() {
+		http.Error(w, `frequency must be "daily", "weekly" or "off"`, http.StatusBadRequest)
+		return
+	}
+	if _, err := time.LoadLocation(settings.Timezone); err != nil || settings.Timezone == "" || settings.Timezone == "Local" {
+		http.Error(w, "timezone must be an IANA time zone such as Europe/Berlin", http.StatusBadRequest)
+		return
+	}
+	if settings.Hour < 0 || settings.Hour > 23 {
+		http.Error(w, "hour must be between 0 and 23", http.StatusBadRequest)
+		return
+	}
+	weekday, ok := parseWeekday(settings.Weekday)
+	if !ok {
+		http.Error(w, "weekday must be a day of the week such as monday", http.StatusBadRequest)
+		return
+	}
+	settings.Weekday = strings.ToLower(weekday.String())
+
+	if err := h.store.SaveSettings(r.Context(), userID, settings); err != nil {
+		respond.ServerError(w, r, "failed to save digest settings", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, settings)
+}
+
+// Preview renders the caller's digest as it would be sent now, as HTML or,
+// with ?format=text, as plain text. ?frequency=daily or weekly overrides
+// the saved frequency, so the digest can be previewed while opted out.
+func (h *Handler) Preview(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+	settings, err := h.store.Settings(r.Context(), userID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch digest settings", err)
+		return
+	}
+	switch frequency := Frequency(r.URL.Query().Get("frequency")); frequency {
+	case "":
+		if settings.Frequency == FrequencyOff {
+			settings.Frequency = FrequencyDaily
+		}
+	case FrequencyDaily, FrequencyWeekly:
+		settings.Frequency = frequency
+	default:
+		http.Error(w, `frequency must be "daily" or "weekly"`, http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "html" && format != "text" {
+		http.Error(w, `format must be "html" or "text"`, http.StatusBadRequest)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	d, err := h.builder.Build(r.Context(), userID, principal.Name, settings, h.now())
+	if err != nil {
+		respond.ServerError(w, r, "failed to build digest", err)
+		return
+	}
+	render, contentType := RenderHTML, "text/html; charset=utf-8"
+	if format == "text" {
+		render, contentType = RenderText, "text/plain; charset=utf-8"
+	}
+	body, err := render(d)
+	if err != nil {
+		respond.ServerError(w, r, "failed to render digest", err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(body))
+}
+
+func currentUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return 0, false
+	}
+	if principal.Kind != auth.KindUser {
+		http.Error(w, "digests require a signed-in user", http.StatusForbidden)
+		return 0, false
+	}
+	return principal.ID, true
+}
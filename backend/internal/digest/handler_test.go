@@ -0,0 +1,99 @@
+package digest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/todo"
+)
+
+func serve(h http.HandlerFunc, method string, target string, body string, principal *auth.Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func TestHandler_Settings(t *testing.T) {
+	_, store, builder, _ := setupDigest(t)
+	h := NewHandler(store, builder)
+	alice := &auth.Principal{Kind: auth.KindUser, ID: 1, Name: "Alice", Scope: auth.ScopeReadWrite}
+
+	rr := serve(h.GetSettings, http.MethodGet, "/api/digest/settings", "", alice)
+	var settings Settings
+	json.Unmarshal(rr.Body.Bytes(), &settings)
+	if rr.Code != http.StatusOK || settings != DefaultSettings {
+		t.Fatalf("expected the default settings, got %d %#v", rr.Code, settings)
+	}
+
+	for _, body := range []string{
+		`{"frequency":"hourly"}`,
+		`{"timezone":"Mars/Olympus"}`,
+		`{"timezone":"Local"}`,
+		`{"hour":24}`,
+		`{"weekday":"someday"}`,
+		`{"email":"x"}`,
+	} {
+		if rr := serve(h.UpdateSettings, http.MethodPut, "/api/digest/settings", body, alice); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for %s, got %d", body, rr.Code)
+		}
+	}
+
+	rr = serve(h.UpdateSettings, http.MethodPut, "/api/digest/settings", `{"frequency":"weekly","timezone":"Europe/Berlin","hour":7,"weekday":"Friday"}`, alice)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	rr = serve(h.GetSettings, http.MethodGet, "/api/digest/settings", "", alice)
+	json.Unmarshal(rr.Body.Bytes(), &settings)
+	if want := (Settings{Frequency: FrequencyWeekly, Timezone: "Europe/Berlin", Hour: 7, Weekday: "friday"}); settings != want {
+		t.Fatalf("expected %#v, got %#v", want, settings)
+	}
+
+	apiKey := &auth.Principal{Kind: auth.KindAPIKey, ID: 1, Scope: auth.ScopeReadWrite}
+	if rr := serve(h.GetSettings, http.MethodGet, "/api/digest/settings", "", apiKey); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for an API key, got %d", rr.Code)
+	}
+	if rr := serve(h.GetSettings, http.MethodGet, "/api/digest/settings", "", nil); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without credentials, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Preview(t *testing.T) {
+	database, store, builder, repo := setupDigest(t)
+	h := NewHandler(store, builder)
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	alice := &auth.Principal{Kind: auth.KindUser, ID: 1, Name: "Alice", Scope: auth.ScopeRead}
+	database.Exec(`DELETE FROM todos`)
+	addTodo(t, database, repo, todo.Item{Title: "Fish & chips", Due: ptr(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC))}, nil)
+
+	rr := serve(h.Preview, http.MethodGet, "/api/digest/preview", "", alice)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("expected an HTML preview, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "Hi Alice") || !strings.Contains(body, "Fish &amp; chips") {
+		t.Fatalf("unexpected preview %q", body)
+	}
+
+	rr = serve(h.Preview, http.MethodGet, "/api/digest/preview?format=text&frequency=weekly", "", alice)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Fatalf("expected a text preview, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "weekly todo digest") || !strings.Contains(body, "- Fish & chips [Todos] due Mon 2 Mar") {
+		t.Fatalf("unexpected preview %q", body)
+	}
+
+	for _, query := range []string{"?format=pdf", "?frequency=off"} {
+		if rr := serve(h.Preview, http.MethodGet, "/api/digest/preview"+query, "", alice); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
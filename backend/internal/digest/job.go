@@ -0,0 +1,109 @@
+package digest
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todoapp/backend/internal/email"
+)
+
+type Mailer interface {
+	Send(ctx context.Context, msg email.Message) error
+}
+
+// maxLateness is how long after its scheduled time a digest is still sent,
+// e.g. when the server was down at the time.
+const maxLateness = 6 * time.Hour
+
+// Job mails digests to users whose scheduled time has come.
+type Job struct {
+	store   *Store
+	builder *Builder
+	mailer  Mailer
+	now     func() time.Time
+}
+
+func NewJob(store *Store, builder *Builder, mailer Mailer) *Job {
+	return &Job{store: store, builder: builder, mailer: mailer, now: time.Now}
+}
+
+// Run sends due digests every interval until ctx is done.
+func (j *Job) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.SendDue(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to send digests", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends every digest that is due and returns how many it sent. A
+// digest is due once its scheduled time in the user's timezone has passed
+// and it has not been handled since; one missed by more than maxLateness
+// is skipped rather than sent late. Digests with nothing in them are
+// recorded as handled but not sent. A failure to build or send one user's
+// digest is logged and retried on the next run.
+func (j *Job) SendDue(ctx context.Context) (int, error) {
+	recipients, err := j.store.recipients(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := j.now()
+	sent := 0
+	for _, r := range recipients {
+		if r.Settings.Frequency == FrequencyOff {
+			continue
+		}
+		scheduled := lastScheduled(r.Settings, now)
+		if r.LastSentAt != nil && !r.LastSentAt.Before(scheduled) {
+			continue
+		}
+		if now.Sub(scheduled) <= maxLateness {
+			ok, err := j.send(ctx, r, now)
+			if err != nil {
+				slog.WarnContext(ctx, "failed to send digest", "user", r.UserID, "error", err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+		if err := j.store.markSent(ctx, r.UserID, now); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (j *Job) send(ctx context.Context, r recipient, now time.Time) (bool, error) {
+	d, err := j.builder.Build(ctx, r.UserID, r.Name, r.Settings, now)
+	if err != nil || d.Empty() {
+		return false, err
+	}
+	msg, err := Message(d, r.Email)
+	if err != nil {
+		return false, err
+	}
+	return true, j.mailer.Send(ctx, msg)
+}
+
+// lastScheduled returns the latest time at or before now that a digest
+// with settings was scheduled for.
+func lastScheduled(settings Settings, now time.Time) time.Time {
+	loc := settings.Location()
+	local := now.In(loc)
+	at := time.Date(local.Year(), local.Month(), local.Day(), settings.Hour, 0, 0, 0, loc)
+	weekday, weekly := parseWeekday(settings.Weekday)
+	weekly = weekly && settings.Frequency == FrequencyWeekly
+	for at.After(local) || (weekly && at.Weekday() != weekday) {
+		at = time.Date(at.Year(), at.Month(), at.Day()-1, settings.Hour, 0, 0, 0, loc)
+	}
+	return at
+}
@@ -0,0 +1,114 @@
+package digest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/email"
+	"todoapp/backend/internal/email/emailtest"
+	"todoapp/backend/internal/todo"
+)
+
+func contains(s, substr string) bool {
+	return strings.Contains(s, substr)
+}
+
+func TestJob_SendsDueDigests(t *testing.T) {
+	database, store, builder, repo := setupDigest(t)
+	server := emailtest.NewServer(t)
+	job := NewJob(store, builder, email.NewSender(email.Config{Addr: server.Addr, From: "todo@example.com"}))
+	ctx := context.Background()
+
+	// 07:30 on Monday 2 March in Tokyo.
+	now := time.Date(2026, 3, 1, 22, 30, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+	store.SaveSettings(ctx, 1, Settings{Frequency: FrequencyDaily, Timezone: "Asia/Tokyo", Hour: 8, Weekday: "monday"})
+	store.SaveSettings(ctx, 2, Settings{Frequency: FrequencyOff, Timezone: "UTC", Hour: 0, Weekday: "monday"})
+	addTodo(t, database, repo, todo.Item{Title: "Pay rent", Due: ptr(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC))}, nil)
+
+	if sent, err := job.SendDue(ctx); err != nil || sent != 0 {
+		t.Fatalf("expected no digest before 08:00, sent %d: %v", sent, err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if sent, err := job.SendDue(ctx); err != nil || sent != 1 {
+		t.Fatalf("expected one digest at 08:00, sent %d: %v", sent, err)
+	}
+	if sent, _ := job.SendDue(ctx); sent != 0 {
+		t.Fatalf("expected the digest to be sent once, sent %d more", sent)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "alice@example.com" {
+		t.Fatalf("expected one digest to alice, got %#v", messages)
+	}
+	msg, err := messages[0].Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if subject := msg.Header.Get("Subject"); subject != "Daily todo digest: 0 overdue, 1 due today" {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+	if !contains(messages[0].Data, "multipart/alternative") || !contains(messages[0].Data, "Pay rent") {
+		t.Fatalf("expected an HTML and text digest listing the todo, got %q", messages[0].Data)
+	}
+
+	now = now.Add(24 * time.Hour)
+	if sent, _ := job.SendDue(ctx); sent != 1 {
+		t.Fatalf("expected the next day's digest, sent %d", sent)
+	}
+}
+
+func TestJob_SkipsEmptyAndStaleDigests(t *testing.T) {
+	database, store, builder, repo := setupDigest(t)
+	server := emailtest.NewServer(t)
+	job := NewJob(store, builder, email.NewSender(email.Config{Addr: server.Addr, From: "todo@example.com"}))
+	ctx := context.Background()
+	database.Exec(`DELETE FROM todos`)
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+	if sent, err := job.SendDue(ctx); err != nil || sent != 0 {
+		t.Fatalf("expected empty digests not to be sent, sent %d: %v", sent, err)
+	}
+	var handled int
+	database.QueryRow(`SELECT COUNT(*) FROM digest_settings WHERE last_sent_at IS NOT NULL`).Scan(&handled)
+	if handled != 2 {
+		t.Fatalf("expected both users with an address to be marked handled, got %d", handled)
+	}
+
+	// The server was down until well after the next morning's digests were
+	// due: they are not sent late.
+	addTodo(t, database, repo, todo.Item{Title: "Taxes", Due: ptr(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))}, nil)
+	now = time.Date(2026, 3, 3, 15, 0, 0, 0, time.UTC)
+	if sent, _ := job.SendDue(ctx); sent != 0 || len(server.Messages()) != 0 {
+		t.Fatalf("expected stale digests to be skipped, sent %d", sent)
+	}
+	now = time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)
+	if sent, _ := job.SendDue(ctx); sent != 2 {
+		t.Fatalf("expected the next digests to be sent on time, sent %d", sent)
+	}
+}
+
+func TestLastScheduled(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC) // Wednesday
+	tests := []struct {
+		settings Settings
+		want     time.Time
+	}{
+		{Settings{Frequency: FrequencyDaily, Timezone: "UTC", Hour: 8}, time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)},
+		{Settings{Frequency: FrequencyDaily, Timezone: "UTC", Hour: 13}, time.Date(2026, 3, 3, 13, 0, 0, 0, time.UTC)},
+		{Settings{Frequency: FrequencyWeekly, Timezone: "UTC", Hour: 8, Weekday: "monday"}, time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)},
+		{Settings{Frequency: FrequencyWeekly, Timezone: "UTC", Hour: 13, Weekday: "wednesday"}, time.Date(2026, 2, 25, 13, 0, 0, 0, time.UTC)},
+		// 21:00 on Wednesday in Tokyo is 12:00 UTC.
+		{Settings{Frequency: FrequencyDaily, Timezone: "Asia/Tokyo", Hour: 21}, time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)},
+		{Settings{Frequency: FrequencyDaily, Timezone: "Nowhere/Invalid", Hour: 8}, time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := lastScheduled(tt.settings, now); !got.Equal(tt.want) {
+			t.Errorf("lastScheduled(%+v) = %v, want %v", tt.settings, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	"todoapp/backend/internal/email"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+type section struct {
+	Title   string
+	Entries []Entry
+}
+
+func newSection(title string, entries []Entry) section {
+	return section{Title: title, Entries: entries}
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html.tmpl").
+			Funcs(htmltemplate.FuncMap{"section": newSection}).
+			ParseFS(templateFS, "templates/digest.html.tmpl"))
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt.tmpl").
+			Funcs(texttemplate.FuncMap{"section": newSection}).
+			ParseFS(templateFS, "templates/digest.txt.tmpl"))
+)
+
+// RenderHTML renders d as an HTML document; todo titles and list names are
+// escaped.
+func RenderHTML(d Digest) (string, error) {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func RenderText(d Digest) (string, error) {
+	var b bytes.Buffer
+	if err := textTemplate.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Message renders d as an email to address with HTML and plain text parts.
+func Message(d Digest, address string) (email.Message, error) {
+	html, err := RenderHTML(d)
+	if err != nil {
+		return email.Message{}, err
+	}
+	text, err := RenderText(d)
+	if err != nil {
+		return email.Message{}, err
+	}
+	return email.Message{To: []string{address}, Subject: d.Subject(), Text: text, HTML: html}, nil
+}
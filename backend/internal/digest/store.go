@@ -0,0 +1,132 @@
+// Package digest emails users a periodic summary of their overdue, due and
+// recently completed todos.
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezones must resolve on hosts without zoneinfo
+)
+
+type Frequency string
+
+const (
+	FrequencyDaily  Frequency = "daily"
+	FrequencyWeekly Frequency = "weekly"
+	FrequencyOff    Frequency = "off"
+)
+
+func (f Frequency) Valid() bool {
+	return f == FrequencyDaily || f == FrequencyWeekly || f == FrequencyOff
+}
+
+// Settings say when a user gets their digest: at Hour o'clock in Timezone,
+// every day or, for weekly digests, on Weekday.
+type Settings struct {
+	Frequency Frequency `json:"frequency"`
+	Timezone  string    `json:"timezone"`
+	Hour      int       `json:"hour"`
+	Weekday   string    `json:"weekday"`
+}
+
+// DefaultSettings apply to users who have not changed theirs.
+var DefaultSettings = Settings{Frequency: FrequencyDaily, Timezone: "UTC", Hour: 8, Weekday: "monday"}
+
+// Location returns the settings' timezone, UTC if it does not resolve.
+func (s Settings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// recipient is a user with an email address and their settings.
+type recipient struct {
+	UserID     int64
+	Email      string
+	Name       string
+	Settings   Settings
+	LastSentAt *time.Time
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Settings(ctx context.Context, userID int64) (Settings, error) {
+	settings := DefaultSettings
+	err := s.db.QueryRowContext(ctx,
+		`SELECT frequency, timezone, hour, weekday FROM digest_settings WHERE user_id = ?`, userID,
+	).Scan(&settings.Frequency, &settings.Timezone, &settings.Hour, &settings.Weekday)
+	if err == sql.ErrNoRows {
+		return DefaultSettings, nil
+	}
+	return settings, err
+}
+
+func (s *Store) SaveSettings(ctx context.Context, userID int64, settings Settings) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO digest_settings (user_id, frequency, timezone, hour, weekday) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			frequency = excluded.frequency, timezone = excluded.timezone, hour = excluded.hour, weekday = excluded.weekday`,
+		userID, settings.Frequency, settings.Timezone, settings.Hour, settings.Weekday)
+	return err
+}
+
+func (s *Store) recipients(ctx context.Context) ([]recipient, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT users.id, users.email, COALESCE(NULLIF(users.name, ''), users.email),
+			COALESCE(digest_settings.frequency, ?), COALESCE(digest_settings.timezone, ?),
+			COALESCE(digest_settings.hour, ?), COALESCE(digest_settings.weekday, ?), digest_settings.last_sent_at
+		FROM users LEFT JOIN digest_settings ON digest_settings.user_id = users.id
+		WHERE users.email != ''
+		ORDER BY users.id ASC`,
+		DefaultSettings.Frequency, DefaultSettings.Timezone, DefaultSettings.Hour, DefaultSettings.Weekday)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []recipient
+	for rows.Next() {
+		var (
+			r          recipient
+			lastSentAt sql.NullTime
+		)
+		if err := rows.Scan(&r.UserID, &r.Email, &r.Name, &r.Settings.Frequency, &r.Settings.Timezone,
+			&r.Settings.Hour, &r.Settings.Weekday, &lastSentAt); err != nil {
+			return nil, err
+		}
+		if lastSentAt.Valid {
+			r.LastSentAt = &lastSentAt.Time
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
+}
+
+// markSent records that userID's digest for the period ending at was
+// handled, keeping their settings or the defaults.
+func (s *Store) markSent(ctx context.Context, userID int64, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO digest_settings (user_id, frequency, timezone, hour, weekday, last_sent_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET last_sent_at = excluded.last_sent_at`,
+		userID, DefaultSettings.Frequency, DefaultSettings.Timezone, DefaultSettings.Hour, DefaultSettings.Weekday, at.UTC())
+	return err
+}
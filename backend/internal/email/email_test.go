@@ -0,0 +1,74 @@
+package email
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/email/emailtest"
+)
+
+func TestSender_Send(t *testing.T) {
+	server := emailtest.NewServer(t)
+	sender := NewSender(Config{Addr: server.Addr, From: "Todo <todo@example.com>"})
+
+	err := sender.Send(context.Background(), Message{
+		To:      []string{"alice@example.com"},
+		Subject: "Rappel : café",
+		Text:    "Buy coffee\nbefore 9:00",
+		HTML:    "<p>Buy coffee</p>",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].From != "todo@example.com" || len(messages[0].To) != 1 || messages[0].To[0] != "alice@example.com" {
+		t.Fatalf("unexpected envelope %#v", messages)
+	}
+	msg, err := messages[0].Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Rappel : café" || msg.Header.Get("To") != "alice@example.com" {
+		t.Fatalf("unexpected headers %v", msg.Header)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type %q", mediaType)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		bodies = append(bodies, part.Header.Get("Content-Type")+": "+string(body))
+	}
+	if len(bodies) != 2 || bodies[0] != "text/plain; charset=utf-8: Buy coffee\nbefore 9:00" || !strings.HasPrefix(bodies[1], "text/html; charset=utf-8: <p>Buy coffee</p>") {
+		t.Fatalf("unexpected parts %q", bodies)
+	}
+}
+
+func TestSender_Errors(t *testing.T) {
+	server := emailtest.NewServer(t)
+	if err := NewSender(Config{Addr: server.Addr, From: "not an address"}).Send(context.Background(), Message{To: []string{"a@example.com"}}); err == nil {
+		t.Fatal("expected an invalid sender to be rejected")
+	}
+	if err := NewSender(Config{Addr: server.Addr, From: "todo@example.com"}).Send(context.Background(), Message{}); err == nil {
+		t.Fatal("expected a message without recipients to be rejected")
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatal("expected nothing to be sent")
+	}
+}
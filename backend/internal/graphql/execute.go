@@ -0,0 +1,703 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+)
+
+// Request is a GraphQL request as clients send it.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent when the request
+// failed before execution started, and null when a non-null root field
+// failed.
+type Response struct {
+	Data   *ResultMap
+	Errors []*Error
+
+	executed bool
+}
+
+func (r *Response) MarshalJSON() ([]byte, error) {
+	var wire struct {
+		Errors []*Error `json:"errors,omitempty"`
+		Data   *any     `json:"data,omitempty"`
+	}
+	wire.Errors = r.Errors
+	if r.executed {
+		var data any
+		if r.Data != nil {
+			data = r.Data
+		}
+		wire.Data = &data
+	}
+	return json.Marshal(wire)
+}
+
+// ResultMap is an object in a response, keeping the order of the query.
+type ResultMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newResultMap(size int) *ResultMap {
+	return &ResultMap{keys: make([]string, 0, size), values: make(map[string]any, size)}
+}
+
+func (m *ResultMap) set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of key in the response.
+func (m *ResultMap) Get(key string) any {
+	return m.values[key]
+}
+
+func (m *ResultMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Error is an error in a response.
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations,omitempty"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Error codes, reported as extensions.code.
+const (
+	CodeParseFailed      = "GRAPHQL_PARSE_FAILED"
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	CodeBadUserInput     = "BAD_USER_INPUT"
+	CodeInternal         = "INTERNAL_SERVER_ERROR"
+)
+
+// codedError is an error whose message is safe to show to clients.
+type codedError struct {
+	code    string
+	message string
+}
+
+func (e *codedError) Error() string {
+	return e.message
+}
+
+// Errorf returns an error for a resolver to return whose message is shown
+// to clients, with code as extensions.code. Other errors a resolver returns
+// are logged and reported as an internal error.
+func Errorf(code string, format string, args ...any) error {
+	return &codedError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCode returns the code of an error made by Errorf, or "".
+func ErrorCode(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return ""
+}
+
+func requestError(code string, message string, locs ...Location) *Response {
+	return &Response{Errors: []*Error{{Message: message, Locations: locs, Extensions: map[string]any{"code": code}}}}
+}
+
+// prepared is a request that has been parsed, validated and had its
+// variables coerced.
+type prepared struct {
+	doc       *document
+	op        *operation
+	variables map[string]any
+}
+
+func (s *Schema) prepare(req Request) (*prepared, *Response) {
+	if req.Query == "" {
+		return nil, requestError(CodeBadUserInput, "query is required")
+	}
+	doc, err := parse(req.Query)
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, requestError(CodeParseFailed, "Syntax error: "+syntaxErr.Message, syntaxErr.Loc)
+		}
+		return nil, requestError(CodeParseFailed, err.Error())
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return nil, requestError(CodeBadUserInput, err.Error())
+	}
+	variables, err := s.coerceVariables(op, req.Variables)
+	if err != nil {
+		return nil, requestError(CodeBadUserInput, err.Error())
+	}
+	if errs := s.validate(doc, op, variables); len(errs) > 0 {
+		return nil, &Response{Errors: errs}
+	}
+	return &prepared{doc: doc, op: op, variables: variables}, nil
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	names := make(map[string]bool)
+	for _, op := range doc.operations {
+		if op.name == "" && len(doc.operations) > 1 {
+			return nil, errors.New("an anonymous operation must be the only operation in the document")
+		}
+		if names[op.name] {
+			return nil, fmt.Errorf("there can be only one operation named %q", op.name)
+		}
+		names[op.name] = true
+	}
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("operationName is required when the document has several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func (s *Schema) coerceVariables(op *operation, raw map[string]any) (map[string]any, error) {
+	coerced := make(map[string]any, len(op.variables))
+	for _, def := range op.variables {
+		t, err := s.inputType(def.typ)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", def.name, err)
+		}
+		if value, ok := raw[def.name]; ok {
+			if coerced[def.name], err = coerceValue(t, value); err != nil {
+				return nil, fmt.Errorf("variable $%s got an invalid value: %w", def.name, err)
+			}
+			continue
+		}
+		switch {
+		case def.defaultValue != nil:
+			if coerced[def.name], _, err = coerceLiteral(t, def.defaultValue, nil); err != nil {
+				return nil, fmt.Errorf("variable $%s has an invalid default: %w", def.name, err)
+			}
+		case def.typ.nonNull:
+			return nil, fmt.Errorf("variable $%s of required type %s was not provided", def.name, def.typ)
+		}
+	}
+	return coerced, nil
+}
+
+func (s *Schema) rootType(op *operation) *Object {
+	switch op.kind {
+	case "mutation":
+		return s.mutation
+	case "subscription":
+		return s.subscription
+	}
+	return s.query
+}
+
+// Execute runs a query or mutation. Subscriptions are rejected; use
+// Subscribe for them.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	p, errResp := s.prepare(req)
+	if errResp != nil {
+		return errResp
+	}
+	if p.op.kind == "subscription" {
+		return requestError(CodeBadUserInput, "subscriptions are not supported over this transport")
+	}
+	return s.execute(ctx, p, nil)
+}
+
+// OperationKind returns "query", "mutation" or "subscription" for the
+// operation req would run, or "" when the query does not parse.
+func OperationKind(req Request) string {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return ""
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return ""
+	}
+	return op.kind
+}
+
+// Subscribe starts a subscription, sending a response for each event of
+// its source stream until ctx is done or the stream ends. A request that
+// fails before the stream starts is answered with the error response and
+// a nil channel.
+func (s *Schema) Subscribe(ctx context.Context, req Request) (<-chan *Response, *Response) {
+	p, errResp := s.prepare(req)
+	if errResp != nil {
+		return nil, errResp
+	}
+	if p.op.kind != "subscription" {
+		return nil, requestError(CodeBadUserInput, "the operation is not a subscription")
+	}
+
+	e := &executor{schema: s, prepared: p}
+	groups := e.collectFields(s.subscription, p.op.selections)
+	group := groups[0]
+	def := s.subscription.Field(group.fields[0].name)
+	args, err := coerceArguments(def.Args, group.fields[0].arguments, p.variables, "field "+def.Name)
+	if err != nil {
+		return nil, requestError(CodeBadUserInput, err.Error(), group.fields[0].loc)
+	}
+	source, err := def.Subscribe(ctx, ResolveParams{Args: args})
+	if err != nil {
+		e.fieldError(ctx, err, group.fields[0].loc, []any{group.key})
+		return nil, &Response{Errors: e.errors}
+	}
+
+	responses := make(chan *Response)
+	go func() {
+		defer close(responses)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-source:
+				if !ok {
+					return
+				}
+				resp := s.execute(ctx, p, &subscriptionEvent{value: event})
+				select {
+				case responses <- resp:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return responses, nil
+}
+
+// subscriptionEvent is a value from a subscription's source stream.
+type subscriptionEvent struct {
+	value any
+}
+
+func (s *Schema) execute(ctx context.Context, p *prepared, event *subscriptionEvent) *Response {
+	if s.requestContext != nil {
+		ctx = s.requestContext(ctx)
+	}
+	e := &executor{schema: s, prepared: p, event: event}
+	root := s.rootType(p.op)
+	groups := e.collectFields(root, p.op.selections)
+	results := e.executeObjects(ctx, root, []any{nil}, groups, []path{nil}, p.op.kind == "mutation")
+
+	resp := &Response{executed: true}
+	if data, ok := results[0].(*ResultMap); ok {
+		resp.Data = data
+	}
+	resp.Errors = e.errors
+	return resp
+}
+
+type path []any
+
+func (p path) with(key any) path {
+	return append(p[:len(p):len(p)], key)
+}
+
+type executor struct {
+	schema *Schema
+	*prepared
+	event  *subscriptionEvent
+	errors []*Error
+}
+
+// bubble marks a value that failed a non-null check. The nearest nullable
+// ancestor becomes null; the error has already been recorded.
+var bubble = &struct{ bubble bool }{}
+
+// fieldGroup holds the fields of a selection set that share a response key.
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// collectFields flattens a selection set on t, applying fragments and the
+// @skip and @include directives.
+func (e *executor) collectFields(t *Object, selections []selection) []*fieldGroup {
+	var groups []*fieldGroup
+	index := make(map[string]*fieldGroup)
+	var collect func(selections []selection, visited map[string]bool)
+	collect = func(selections []selection, visited map[string]bool) {
+		for _, sel := range selections {
+			switch sel := sel.(type) {
+			case *field:
+				if !e.included(sel.directives) {
+					continue
+				}
+				key := sel.responseKey()
+				if group, ok := index[key]; ok {
+					group.fields = append(group.fields, sel)
+					continue
+				}
+				group := &fieldGroup{key: key, fields: []*field{sel}}
+				index[key] = group
+				groups = append(groups, group)
+			case *fragmentSpread:
+				if visited[sel.name] || !e.included(sel.directives) {
+					continue
+				}
+				visited[sel.name] = true
+				if f, ok := e.doc.fragments[sel.name]; ok && f.typeCondition == t.Name {
+					collect(f.selections, visited)
+				}
+			case *inlineFragment:
+				if !e.included(sel.directives) || sel.typeCondition != "" && sel.typeCondition != t.Name {
+					continue
+				}
+				collect(sel.selections, visited)
+			}
+		}
+	}
+	collect(selections, make(map[string]bool))
+	return groups
+}
+
+// included evaluates @skip and @include.
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		def, ok := directiveDefs[d.name]
+		if !ok || (d.name != "skip" && d.name != "include") {
+			continue
+		}
+		args, err := coerceArguments(def.args, d.arguments, e.variables, "@"+d.name)
+		if err != nil {
+			continue
+		}
+		if args["if"] == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// subfields merges the selection sets of fields, which share a response
+// key, on the object type t.
+func (e *executor) subfields(t *Object, fields []*field) []*fieldGroup {
+	var selections []selection
+	for _, f := range fields {
+		selections = append(selections, f.selections...)
+	}
+	return e.collectFields(t, selections)
+}
+
+// fieldDef returns the definition of a field of t, including the
+// introspection fields.
+func (s *Schema) fieldDef(t *Object, name string) *Field {
+	switch {
+	case name == typenameField.Name:
+		return typenameField
+	case t == s.query && name == schemaField.Name:
+		return schemaField
+	case t == s.query && name == typeField.Name:
+		return typeField
+	}
+	return t.Field(name)
+}
+
+// executeObjects resolves groups on every source, which all have type t,
+// and returns a *ResultMap or bubble for each. Every field is resolved on
+// every source before any Thunk is forced, and the values of each field
+// are completed together, so loaders see all the keys of a level at once.
+// Serial execution, for mutations, finishes each field before starting
+// the next.
+func (e *executor) executeObjects(ctx context.Context, t *Object, sources []any, groups []*fieldGroup, paths []path, serial bool) []any {
+	results := make([]any, len(sources))
+	for i := range sources {
+		results[i] = newResultMap(len(groups))
+	}
+
+	values := make([][]any, len(groups))
+	resolve := func(g int) {
+		values[g] = make([]any, len(sources))
+		for i, source := range sources {
+			values[g][i] = e.resolveField(ctx, t, source, groups[g], paths[i].with(groups[g].key))
+		}
+	}
+	force := func(g int) {
+		for i, value := range values[g] {
+			thunk, ok := value.(Thunk)
+			if !ok {
+				continue
+			}
+			forced, err := e.call(ctx, func() (any, error) { return thunk() })
+			if err != nil {
+				e.fieldError(ctx, err, groups[g].fields[0].loc, paths[i].with(groups[g].key))
+				forced = bubble
+			}
+			values[g][i] = forced
+		}
+	}
+	complete := func(g int) {
+		def := e.schema.fieldDef(t, groups[g].fields[0].name)
+		fieldPaths := make([]path, len(sources))
+		for i := range sources {
+			fieldPaths[i] = paths[i].with(groups[g].key)
+		}
+		completed := e.complete(ctx, def.Type, groups[g].fields, values[g], fieldPaths)
+		for i, value := range completed {
+			if value == bubble {
+				results[i] = bubble
+				continue
+			}
+			if m, ok := results[i].(*ResultMap); ok {
+				m.set(groups[g].key, value)
+			}
+		}
+	}
+
+	if serial {
+		for g := range groups {
+			resolve(g)
+			force(g)
+			complete(g)
+		}
+		return results
+	}
+	for g := range groups {
+		resolve(g)
+	}
+	for g := range groups {
+		force(g)
+	}
+	for g := range groups {
+		complete(g)
+	}
+	return results
+}
+
+// resolveField returns the value of a field, a Thunk, or bubble after
+// recording an error.
+func (e *executor) resolveField(ctx context.Context, t *Object, source any, group *fieldGroup, p path) any {
+	f := group.fields[0]
+	def := e.schema.fieldDef(t, f.name)
+	if def == typenameField {
+		return t.Name
+	}
+	if def == schemaField || def == typeField {
+		source = e.schema
+	}
+
+	args, err := coerceArguments(def.Args, f.arguments, e.variables, "field "+f.name)
+	if err != nil {
+		e.errors = append(e.errors, &Error{Message: err.Error(), Locations: []Location{f.loc}, Path: p, Extensions: map[string]any{"code": CodeBadUserInput}})
+		return bubble
+	}
+	value, err := e.call(ctx, func() (any, error) {
+		if e.event != nil && t == e.schema.subscription {
+			if def.Resolve == nil {
+				return e.event.value, nil
+			}
+			return def.Resolve(ctx, ResolveParams{Source: e.event.value, Args: args})
+		}
+		return def.Resolve(ctx, ResolveParams{Source: source, Args: args})
+	})
+	if err != nil {
+		e.fieldError(ctx, err, f.loc, p)
+		return bubble
+	}
+	return value
+}
+
+// call runs a resolver, turning a panic into an error.
+func (e *executor) call(ctx context.Context, fn func() (any, error)) (value any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("resolver panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func (e *executor) fieldError(ctx context.Context, err error, loc Location, p path) {
+	gqlErr := &Error{Locations: []Location{loc}, Path: p}
+	var coded *codedError
+	switch {
+	case errors.As(err, &coded):
+		gqlErr.Message = coded.message
+		gqlErr.Extensions = map[string]any{"code": coded.code}
+	default:
+		slog.ErrorContext(ctx, "graphql resolver failed", "path", fmt.Sprint(p), "error", err)
+		gqlErr.Message = "internal error"
+		gqlErr.Extensions = map[string]any{"code": CodeInternal}
+	}
+	e.errors = append(e.errors, gqlErr)
+}
+
+// complete completes the values of one field across a batch of parents.
+// Failures in a nullable position become null.
+func (e *executor) complete(ctx context.Context, t Type, fields []*field, values []any, paths []path) []any {
+	completed := e.completeRaw(ctx, t, fields, values, paths)
+	if _, nonNull := t.(*NonNull); !nonNull {
+		for i, value := range completed {
+			if value == bubble {
+				completed[i] = nil
+			}
+		}
+	}
+	return completed
+}
+
+func (e *executor) completeRaw(ctx context.Context, t Type, fields []*field, values []any, paths []path) []any {
+	completed := make([]any, len(values))
+	if nonNull, ok := t.(*NonNull); ok {
+		completed = e.completeRaw(ctx, nonNull.OfType, fields, values, paths)
+		for i, value := range completed {
+			if value == nil {
+				e.errors = append(e.errors, &Error{
+					Message:   fmt.Sprintf("Cannot return null for non-nullable field %s.", fields[0].name),
+					Locations: []Location{fields[0].loc},
+					Path:      paths[i],
+				})
+				completed[i] = bubble
+			}
+		}
+		return completed
+	}
+
+	// Only non-null values of values are completed below.
+	var pending []int
+	for i, value := range values {
+		switch {
+		case value == bubble:
+			completed[i] = bubble
+		case isNil(value):
+			completed[i] = nil
+		default:
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return completed
+	}
+
+	switch t := t.(type) {
+	case *List:
+		var items []any
+		var itemPaths []path
+		counts := make(map[int]int, len(pending))
+		for _, i := range pending {
+			rv := reflect.ValueOf(values[i])
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				e.fieldError(ctx, fmt.Errorf("field %s resolved to %T, not a list", fields[0].name, values[i]), fields[0].loc, paths[i])
+				completed[i] = bubble
+				continue
+			}
+			counts[i] = rv.Len()
+			for j := 0; j < rv.Len(); j++ {
+				items = append(items, rv.Index(j).Interface())
+				itemPaths = append(itemPaths, paths[i].with(j))
+			}
+		}
+		done := e.complete(ctx, t.OfType, fields, items, itemPaths)
+		offset := 0
+		for _, i := range pending {
+			n, ok := counts[i]
+			if !ok {
+				continue
+			}
+			list := done[offset : offset+n : offset+n]
+			offset += n
+			completed[i] = list
+			for _, item := range list {
+				if item == bubble {
+					completed[i] = bubble
+					break
+				}
+			}
+		}
+	case *Object:
+		sources := make([]any, len(pending))
+		sourcePaths := make([]path, len(pending))
+		for j, i := range pending {
+			sources[j] = values[i]
+			sourcePaths[j] = paths[i]
+		}
+		done := e.executeObjects(ctx, t, sources, e.subfields(t, fields), sourcePaths, false)
+		for j, i := range pending {
+			completed[i] = done[j]
+		}
+	case *Scalar, *Enum:
+		for _, i := range pending {
+			serialized, err := serialize(t, values[i])
+			if err != nil {
+				e.fieldError(ctx, err, fields[0].loc, paths[i])
+				serialized = bubble
+			}
+			completed[i] = serialized
+		}
+	default:
+		for _, i := range pending {
+			completed[i] = bubble
+		}
+		e.fieldError(ctx, fmt.Errorf("field %s has the non-output type %s", fields[0].name, t), fields[0].loc, paths[pending[0]])
+	}
+	return completed
+}
+
+func serialize(t Type, value any) (any, error) {
+	switch t := t.(type) {
+	case *Scalar:
+		return t.Serialize(value)
+	case *Enum:
+		if name, ok := t.nameOf(value); ok {
+			return name, nil
+		}
+		return nil, fmt.Errorf("%v is not a value of %s", value, t.Name)
+	}
+	return nil, fmt.Errorf("%s is not a leaf type", t)
+}
+
+// isNil reports whether value is nil or a nil pointer, slice or map.
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func:
+		return rv.IsNil() && rv.Kind() != reflect.Slice
+	}
+	return false
+}
@@ -0,0 +1,433 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type testBook struct {
+	ID       int
+	Title    string
+	AuthorID int
+}
+
+type testAuthor struct {
+	ID   int
+	Name string
+}
+
+type testLoaders struct {
+	authors *Loader[int, testAuthor]
+}
+
+type loadersKey struct{}
+
+// testSchema serves books whose authors are fetched through a loader that
+// records each batch it is asked for.
+func testSchema(t *testing.T, batches *[][]int) *Schema {
+	t.Helper()
+	books := []testBook{{1, "Dune", 10}, {2, "Emma", 20}, {3, "Children of Dune", 10}}
+	authors := map[int]testAuthor{10: {10, "Frank Herbert"}, 20: {20, "Jane Austen"}}
+
+	genre := &Enum{Name: "Genre", Values: []EnumValue{{Name: "SCIENCE_FICTION", Value: "sf"}, {Name: "CLASSIC", Value: "classic"}}}
+	author := &Object{Name: "Author", Fields: []*Field{
+		{Name: "name", Type: NewNonNull(String), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(testAuthor).Name, nil
+		}},
+	}}
+	book := &Object{Name: "Book", Description: "A book.", Fields: []*Field{
+		{Name: "id", Type: NewNonNull(ID), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(testBook).ID, nil
+		}},
+		{Name: "title", Type: NewNonNull(String), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(testBook).Title, nil
+		}},
+		{Name: "author", Type: author, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			load := ctx.Value(loadersKey{}).(*testLoaders).authors.Load(ctx, p.Source.(testBook).AuthorID)
+			return Thunk(func() (any, error) {
+				a, found, err := load()
+				if !found || err != nil {
+					return nil, err
+				}
+				return a, nil
+			}), nil
+		}},
+		{Name: "genre", Type: genre, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return "sf", nil
+		}},
+		{Name: "secret", Type: String, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return nil, errors.New("database password is hunter2")
+		}},
+		{Name: "required", Type: NewNonNull(String), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return nil, Errorf("NOT_FOUND", "required is missing")
+		}},
+	}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{
+			Name: "books", Type: NewNonNull(NewList(NewNonNull(book))),
+			Args: []*Argument{
+				{Name: "first", Type: Int, Default: 10, HasDefault: true},
+				{Name: "genres", Type: NewList(NewNonNull(genre))},
+			},
+			Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+				return books[:min(p.Args["first"].(int), len(books))], nil
+			},
+		},
+		{
+			Name: "book", Type: book, Args: []*Argument{{Name: "id", Type: NewNonNull(ID)}},
+			Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+				for _, b := range books {
+					if fmt.Sprint(b.ID) == p.Args["id"] {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		{
+			Name: "echo", Type: String,
+			Args: []*Argument{{Name: "input", Type: &InputObject{Name: "EchoInput", Fields: []*Argument{
+				{Name: "text", Type: NewNonNull(String)},
+				{Name: "note", Type: String},
+			}}}},
+			Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+				input := p.Args["input"].(map[string]any)
+				note, present := input["note"]
+				return fmt.Sprintf("%s null=%v present=%v", input["text"], note == nil, present), nil
+			},
+		},
+	}}
+
+	var log []string
+	mutation := &Object{Name: "Mutation", Fields: []*Field{
+		{Name: "append", Type: NewNonNull(NewList(NewNonNull(String))), Args: []*Argument{{Name: "word", Type: NewNonNull(String)}},
+			Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+				log = append(log, p.Args["word"].(string))
+				return slices.Clone(log), nil
+			}},
+	}}
+	subscription := &Object{Name: "Subscription", Fields: []*Field{
+		{
+			Name: "bookAdded", Type: NewNonNull(book),
+			Subscribe: func(ctx context.Context, p ResolveParams) (<-chan any, error) {
+				events := make(chan any)
+				go func() {
+					defer close(events)
+					for _, b := range books[:2] {
+						select {
+						case events <- b:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+				return events, nil
+			},
+		},
+	}}
+
+	schema, err := NewSchema(SchemaConfig{
+		Query:        query,
+		Mutation:     mutation,
+		Subscription: subscription,
+		RequestContext: func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, loadersKey{}, &testLoaders{
+				authors: NewLoader(func(ctx context.Context, ids []int) (map[int]testAuthor, error) {
+					*batches = append(*batches, slices.Clone(ids))
+					found := make(map[int]testAuthor)
+					for _, id := range ids {
+						if a, ok := authors[id]; ok {
+							found[id] = a
+						}
+					}
+					return found, nil
+				}),
+			})
+		},
+	})
+	if err != nil {
+		t.Fatalf("new schema: %v", err)
+	}
+	return schema
+}
+
+func execute(t *testing.T, schema *Schema, query string, variables map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(schema.Execute(context.Background(), Request{Query: query, Variables: variables}))
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	var batches [][]int
+	schema := testSchema(t, &batches)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		want      string
+	}{
+		{
+			name:  "aliases keep query order",
+			query: `{ second: book(id: 2) { title id } first: book(id: "1") { title } }`,
+			want:  `{"data":{"second":{"title":"Emma","id":"2"},"first":{"title":"Dune"}}}`,
+		},
+		{
+			name:      "fragments, directives and typename",
+			query:     `query Q($skip: Boolean!) { books(first: 1) { ...Parts ... on Book @skip(if: $skip) { genre } } } fragment Parts on Book { __typename title @include(if: true) id @skip(if: true) }`,
+			variables: map[string]any{"skip": false},
+			want:      `{"data":{"books":[{"__typename":"Book","title":"Dune","genre":"SCIENCE_FICTION"}]}}`,
+		},
+		{
+			name:      "variables, defaults and lists",
+			query:     `query($n: Int = 2, $genre: Genre!) { books(first: $n, genres: [$genre]) { id } }`,
+			variables: map[string]any{"genre": "CLASSIC"},
+			want:      `{"data":{"books":[{"id":"1"},{"id":"2"}]}}`,
+		},
+		{
+			name:  "input objects tell absent fields from null",
+			query: `{ absent: echo(input: {text: "a"}) explicit: echo(input: {text: "b", note: null}) }`,
+			want:  `{"data":{"absent":"a null=true present=false","explicit":"b null=true present=true"}}`,
+		},
+		{
+			name:  "internal errors are masked",
+			query: `{ book(id: 1) { secret } }`,
+			want:  `{"errors":[{"message":"internal error","locations":[{"line":1,"column":17}],"path":["book","secret"],"extensions":{"code":"INTERNAL_SERVER_ERROR"}}],"data":{"book":{"secret":null}}}`,
+		},
+		{
+			name:  "non-null errors null the nearest nullable parent",
+			query: `{ book(id: 1) { title required } }`,
+			want:  `{"errors":[{"message":"required is missing","locations":[{"line":1,"column":23}],"path":["book","required"],"extensions":{"code":"NOT_FOUND"}}],"data":{"book":null}}`,
+		},
+		{
+			name:  "non-null errors can null the whole result",
+			query: `{ books { required } }`,
+			want:  `"data":null`,
+		},
+		{
+			name:  "syntax errors have no data",
+			query: `{ books { id }`,
+			want:  `{"errors":[{"message":"Syntax error: expected a name, found end of query","locations":[{"line":1,"column":15}],"extensions":{"code":"GRAPHQL_PARSE_FAILED"}}]}`,
+		},
+		{
+			name:  "unknown fields fail validation",
+			query: `{ books { id isbn } }`,
+			want:  `cannot query field \"isbn\" on type \"Book\"`,
+		},
+		{
+			name:  "leaf fields need no selection",
+			query: `{ books { id { x } } }`,
+			want:  `must not have a selection of subfields`,
+		},
+		{
+			name:  "objects need a selection",
+			query: `{ books }`,
+			want:  `must have a selection of subfields`,
+		},
+		{
+			name:  "bad enum values are rejected",
+			query: `{ books(genres: ["CLASSIC"]) { id } }`,
+			want:  `is not a value of Genre`,
+		},
+		{
+			name:  "missing required arguments are rejected",
+			query: `{ book { id } }`,
+			want:  `requires \"id\"`,
+		},
+		{
+			name:      "variables must be provided",
+			query:     `query($id: ID!) { book(id: $id) { id } }`,
+			variables: map[string]any{},
+			want:      `variable $id of required type ID! was not provided`,
+		},
+		{
+			name:  "variables must fit where they are used",
+			query: `query($n: String) { books(first: $n) { id } }`,
+			want:  `variable $n of type String cannot be used where Int is expected`,
+		},
+		{
+			name:  "fragments cannot spread themselves",
+			query: `{ books { ...A } } fragment A on Book { ...B } fragment B on Book { ...A }`,
+			want:  `spreads itself`,
+		},
+		{
+			name:  "conflicting fields need aliases",
+			query: `{ book(id: 1) { id } book(id: 2) { id } }`,
+			want:  `use an alias`,
+		},
+		{
+			name:  "deep queries are rejected",
+			query: `{ __schema { types { fields { type ` + strings.Repeat(`{ ofType `, 12) + `{ name }` + strings.Repeat(` }`, 12) + ` } } } }`,
+			want:  `nested more than 15 levels deep`,
+		},
+		{
+			name:  "subscriptions need the subscription transport",
+			query: `subscription { bookAdded { id } }`,
+			want:  `subscriptions are not supported over this transport`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := execute(t, schema, tt.query, tt.variables)
+			if !strings.Contains(got, tt.want) {
+				t.Fatalf("expected %s in\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecute_BatchesLoads(t *testing.T) {
+	var batches [][]int
+	schema := testSchema(t, &batches)
+
+	got := execute(t, schema, `{ books { title author { name } } again: books { author { name } } }`, nil)
+	want := `{"data":{"books":[{"title":"Dune","author":{"name":"Frank Herbert"}},{"title":"Emma","author":{"name":"Jane Austen"}},` +
+		`{"title":"Children of Dune","author":{"name":"Frank Herbert"}}],"again":[{"author":{"name":"Frank Herbert"}},` +
+		`{"author":{"name":"Jane Austen"}},{"author":{"name":"Frank Herbert"}}]}}`
+	if got != want {
+		t.Fatalf("unexpected response:\n%s", got)
+	}
+	if len(batches) != 1 || !slices.Equal(batches[0], []int{10, 20}) {
+		t.Fatalf("expected one batch of both authors, got %v", batches)
+	}
+
+	execute(t, schema, `{ books { author { name } } }`, nil)
+	if len(batches) != 2 {
+		t.Fatalf("expected a fresh loader per request, got batches %v", batches)
+	}
+}
+
+func TestExecute_MutationsRunInOrder(t *testing.T) {
+	var batches [][]int
+	schema := testSchema(t, &batches)
+
+	got := execute(t, schema, `mutation { a: append(word: "a") b: append(word: "b") }`, nil)
+	if got != `{"data":{"a":["a"],"b":["a","b"]}}` {
+		t.Fatalf("unexpected response: %s", got)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	var batches [][]int
+	schema := testSchema(t, &batches)
+
+	responses, errResp := schema.Subscribe(context.Background(), Request{Query: `subscription { bookAdded { title author { name } } }`})
+	if errResp != nil {
+		t.Fatalf("subscribe: %v", errResp.Errors)
+	}
+	var got []string
+	for resp := range responses {
+		data, _ := json.Marshal(resp)
+		got = append(got, string(data))
+	}
+	want := []string{
+		`{"data":{"bookAdded":{"title":"Dune","author":{"name":"Frank Herbert"}}}}`,
+		`{"data":{"bookAdded":{"title":"Emma","author":{"name":"Jane Austen"}}}}`,
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected events:\n%s", strings.Join(got, "\n"))
+	}
+
+	if _, errResp := schema.Subscribe(context.Background(), Request{Query: `subscription { bookAdded { id } __typename }`}); errResp == nil {
+		t.Fatal("expected a subscription with two root fields to be rejected")
+	}
+	if _, errResp := schema.Subscribe(context.Background(), Request{Query: `{ books { id } }`}); errResp == nil {
+		t.Fatal("expected a query to be rejected")
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	var batches [][]int
+	schema := testSchema(t, &batches)
+
+	got := execute(t, schema, `{
+		__schema { queryType { name } subscriptionType { name } directives { name } }
+		__type(name: "Query") {
+			kind
+			fields { name args { name defaultValue type { kind name ofType { kind name } } } }
+		}
+	}`, nil)
+	for _, want := range []string{
+		`"queryType":{"name":"Query"}`,
+		`"subscriptionType":{"name":"Subscription"}`,
+		`{"name":"include"},{"name":"skip"},{"name":"deprecated"}`,
+		`{"name":"first","defaultValue":"10","type":{"kind":"SCALAR","name":"Int","ofType":null}}`,
+		`{"name":"id","defaultValue":null,"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"ID"}}}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %s in\n%s", want, got)
+		}
+	}
+}
+
+func TestSDL(t *testing.T) {
+	var batches [][]int
+	sdl := testSchema(t, &batches).SDL()
+	for _, want := range []string{
+		"type Query {\n  books(first: Int = 10, genres: [Genre!]): [Book!]!\n",
+		"\"A book.\"\ntype Book {\n",
+		"enum Genre {\n  SCIENCE_FICTION\n  CLASSIC\n}\n",
+		"input EchoInput {\n  text: String!\n  note: String\n}\n",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Fatalf("expected %q in\n%s", want, sdl)
+		}
+	}
+	if !strings.HasPrefix(sdl, "type Query {") || strings.Contains(sdl, "__") || strings.Contains(sdl, "scalar Int") {
+		t.Fatalf("unexpected SDL:\n%s", sdl)
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# comment
+		query Q($a: [Int!]! = [1, 2], $b: In = {x: "y", z: -1.5e3}) @skip(if: false) {
+			alias: field(arg: """
+				block
+				  string
+			""", e: ENUM, n: null)
+		}
+	`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	op := doc.operations[0]
+	if op.name != "Q" || op.variables[0].typ.String() != "[Int!]!" || literalString(op.variables[1].defaultValue) != `{x: "y", z: -1.5e3}` {
+		t.Fatalf("unexpected operation: %+v", op)
+	}
+	f := op.selections[0].(*field)
+	if f.alias != "alias" || f.arguments[0].value.raw != "block\n  string" || f.arguments[1].value.kind != enumValue {
+		t.Fatalf("unexpected field: %+v", f)
+	}
+
+	for query, want := range map[string]string{
+		`{ a(x: 01) }`:            "leading zero",
+		`{ a(x: "unterminated) }`: "unterminated string",
+		`{ a(x: "\q") }`:          "invalid escape",
+		`{ }`:                     "must not be empty",
+		`fragment on on T { a }`:  `cannot be named "on"`,
+		`{ a } { b }`:             "",
+	} {
+		doc, err := parse(query)
+		if want == "" {
+			if err != nil {
+				t.Fatalf("parse %s: %v", query, err)
+			}
+			if _, err := selectOperation(doc, ""); err == nil {
+				t.Fatalf("expected two anonymous operations to be rejected")
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("parse %s: expected %q, got %v", query, want, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package graphql
+
+import (
+	"context"
+	"sort"
+)
+
+// directiveDef is a directive the schema supports. executableLocations
+// uses the names the validator passes; locations are the introspection
+// names.
+type directiveDef struct {
+	name                string
+	description         string
+	locations           []string
+	executableLocations []string
+	args                []*Argument
+}
+
+var directiveDefs = map[string]*directiveDef{
+	"skip": {
+		name:                "skip",
+		description:         "Leaves out this field or fragment when the argument is true.",
+		locations:           []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		executableLocations: []string{"field", "fragment spread", "inline fragment"},
+		args:                []*Argument{{Name: "if", Description: "Skipped when true.", Type: NewNonNull(Boolean)}},
+	},
+	"include": {
+		name:                "include",
+		description:         "Includes this field or fragment only when the argument is true.",
+		locations:           []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		executableLocations: []string{"field", "fragment spread", "inline fragment"},
+		args:                []*Argument{{Name: "if", Description: "Included when true.", Type: NewNonNull(Boolean)}},
+	},
+	"deprecated": {
+		name:        "deprecated",
+		description: "Marks an element of the schema as no longer supported.",
+		locations:   []string{"FIELD_DEFINITION", "ARGUMENT_DEFINITION", "INPUT_FIELD_DEFINITION", "ENUM_VALUE"},
+		args: []*Argument{{
+			Name: "reason", Description: "Why the element is deprecated and what to use instead.",
+			Type: String, Default: "No longer supported", HasDefault: true,
+		}},
+	},
+}
+
+var introspectionNames = []string{
+	"__Schema", "__Type", "__Field", "__InputValue", "__EnumValue", "__Directive", "__TypeKind", "__DirectiveLocation",
+	"__schema", "__type", "__typename",
+}
+
+var (
+	typeKind = &Enum{
+		Name:        "__TypeKind",
+		Description: "The kinds of types.",
+		Values: []EnumValue{
+			{Name: "SCALAR", Value: "SCALAR"},
+			{Name: "OBJECT", Value: "OBJECT"},
+			{Name: "INTERFACE", Value: "INTERFACE"},
+			{Name: "UNION", Value: "UNION"},
+			{Name: "ENUM", Value: "ENUM"},
+			{Name: "INPUT_OBJECT", Value: "INPUT_OBJECT"},
+			{Name: "LIST", Value: "LIST"},
+			{Name: "NON_NULL", Value: "NON_NULL"},
+		},
+	}
+	directiveLocation = &Enum{
+		Name:        "__DirectiveLocation",
+		Description: "Where a directive may be used.",
+	}
+
+	introspectionSchema     = &Object{Name: "__Schema", Description: "The types and directives of the schema."}
+	introspectionType       = &Object{Name: "__Type", Description: "A type of the schema, or a list or non-null wrapper around one."}
+	introspectionField      = &Object{Name: "__Field", Description: "A field of an object type."}
+	introspectionInputValue = &Object{Name: "__InputValue", Description: "An argument or input object field."}
+	introspectionEnumValue  = &Object{Name: "__EnumValue", Description: "A value of an enum type."}
+	introspectionDirective  = &Object{Name: "__Directive", Description: "A directive the schema supports."}
+
+	typenameField = &Field{
+		Name:        "__typename",
+		Description: "The name of the object type.",
+		Type:        NewNonNull(String),
+		Resolve:     func(ctx context.Context, p ResolveParams) (any, error) { return nil, nil },
+	}
+	schemaField = &Field{
+		Name:        "__schema",
+		Description: "The schema.",
+		Type:        NewNonNull(introspectionSchema),
+		Resolve:     func(ctx context.Context, p ResolveParams) (any, error) { return p.Source, nil },
+	}
+	typeField = &Field{
+		Name:        "__type",
+		Description: "The type with the given name, if any.",
+		Type:        introspectionType,
+		Args:        []*Argument{{Name: "name", Type: NewNonNull(String)}},
+		Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			if t, ok := p.Source.(*Schema).types[p.Args["name"].(string)]; ok {
+				return t, nil
+			}
+			return nil, nil
+		},
+	}
+)
+
+func init() {
+	for _, name := range []string{
+		"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION", "FRAGMENT_SPREAD", "INLINE_FRAGMENT",
+		"VARIABLE_DEFINITION", "SCHEMA", "SCALAR", "OBJECT", "FIELD_DEFINITION", "ARGUMENT_DEFINITION", "INTERFACE",
+		"UNION", "ENUM", "ENUM_VALUE", "INPUT_OBJECT", "INPUT_FIELD_DEFINITION",
+	} {
+		directiveLocation.Values = append(directiveLocation.Values, EnumValue{Name: name, Value: name})
+	}
+
+	includeDeprecated := []*Argument{{Name: "includeDeprecated", Type: Boolean, Default: false, HasDefault: true}}
+	str := func(get func(source any) string) func(ctx context.Context, p ResolveParams) (any, error) {
+		return func(ctx context.Context, p ResolveParams) (any, error) {
+			if s := get(p.Source); s != "" {
+				return s, nil
+			}
+			return nil, nil
+		}
+	}
+
+	introspectionSchema.Fields = []*Field{
+		{Name: "description", Type: String, Resolve: str(func(any) string { return "" })},
+		{Name: "types", Type: NewNonNull(NewList(NewNonNull(introspectionType))), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			schema := p.Source.(*Schema)
+			names := make([]string, 0, len(schema.types))
+			for name := range schema.types {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			types := make([]Type, len(names))
+			for i, name := range names {
+				types[i] = schema.types[name]
+			}
+			return types, nil
+		}},
+		{Name: "queryType", Type: NewNonNull(introspectionType), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(*Schema).query, nil
+		}},
+		{Name: "mutationType", Type: introspectionType, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return nilIfNone(p.Source.(*Schema).mutation), nil
+		}},
+		{Name: "subscriptionType", Type: introspectionType, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return nilIfNone(p.Source.(*Schema).subscription), nil
+		}},
+		{Name: "directives", Type: NewNonNull(NewList(NewNonNull(introspectionDirective))), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return []*directiveDef{directiveDefs["include"], directiveDefs["skip"], directiveDefs["deprecated"]}, nil
+		}},
+	}
+
+	introspectionType.Fields = []*Field{
+		{Name: "kind", Type: NewNonNull(typeKind), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			switch p.Source.(type) {
+			case *Scalar:
+				return "SCALAR", nil
+			case *Object:
+				return "OBJECT", nil
+			case *Enum:
+				return "ENUM", nil
+			case *InputObject:
+				return "INPUT_OBJECT", nil
+			case *List:
+				return "LIST", nil
+			default:
+				return "NON_NULL", nil
+			}
+		}},
+		{Name: "name", Type: String, Resolve: str(func(source any) string {
+			if named, ok := source.(namedType); ok {
+				return named.typeName()
+			}
+			return ""
+		})},
+		{Name: "description", Type: String, Resolve: str(func(source any) string {
+			if named, ok := source.(namedType); ok {
+				return named.typeDescription()
+			}
+			return ""
+		})},
+		{Name: "specifiedByURL", Type: String, Resolve: str(func(any) string { return "" })},
+		{Name: "fields", Type: NewList(NewNonNull(introspectionField)), Args: includeDeprecated, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			object, ok := p.Source.(*Object)
+			if !ok {
+				return nil, nil
+			}
+			fields := make([]*Field, 0, len(object.Fields))
+			for _, f := range object.Fields {
+				if f.DeprecationReason == "" || p.Args["includeDeprecated"] == true {
+					fields = append(fields, f)
+				}
+			}
+			return fields, nil
+		}},
+		{Name: "interfaces", Type: NewList(NewNonNull(introspectionType)), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			if _, ok := p.Source.(*Object); ok {
+				return []Type{}, nil
+			}
+			return nil, nil
+		}},
+		{Name: "possibleTypes", Type: NewList(NewNonNull(introspectionType)), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return nil, nil
+		}},
+		{Name: "enumValues", Type: NewList(NewNonNull(introspectionEnumValue)), Args: includeDeprecated, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			if enum, ok := p.Source.(*Enum); ok {
+				return enum.Values, nil
+			}
+			return nil, nil
+		}},
+		{Name: "inputFields", Type: NewList(NewNonNull(introspectionInputValue)), Args: includeDeprecated, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			if object, ok := p.Source.(*InputObject); ok {
+				return object.Fields, nil
+			}
+			return nil, nil
+		}},
+		{Name: "ofType", Type: introspectionType, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			switch t := p.Source.(type) {
+			case *List:
+				return t.OfType, nil
+			case *NonNull:
+				return t.OfType, nil
+			}
+			return nil, nil
+		}},
+		{Name: "isOneOf", Type: Boolean, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			if _, ok := p.Source.(*InputObject); ok {
+				return false, nil
+			}
+			return nil, nil
+		}},
+	}
+
+	introspectionField.Fields = []*Field{
+		{Name: "name", Type: NewNonNull(String), Resolve: str(func(source any) string { return source.(*Field).Name })},
+		{Name: "description", Type: String, Resolve: str(func(source any) string { return source.(*Field).Description })},
+		{Name: "args", Type: NewNonNull(NewList(NewNonNull(introspectionInputValue))), Args: includeDeprecated, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return nonNilArgs(p.Source.(*Field).Args), nil
+		}},
+		{Name: "type", Type: NewNonNull(introspectionType), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(*Field).Type, nil
+		}},
+		{Name: "isDeprecated", Type: NewNonNull(Boolean), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(*Field).DeprecationReason != "", nil
+		}},
+		{Name: "deprecationReason", Type: String, Resolve: str(func(source any) string { return source.(*Field).DeprecationReason })},
+	}
+
+	introspectionInputValue.Fields = []*Field{
+		{Name: "name", Type: NewNonNull(String), Resolve: str(func(source any) string { return source.(*Argument).Name })},
+		{Name: "description", Type: String, Resolve: str(func(source any) string { return source.(*Argument).Description })},
+		{Name: "type", Type: NewNonNull(introspectionType), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(*Argument).Type, nil
+		}},
+		{Name: "defaultValue", Type: String, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			arg := p.Source.(*Argument)
+			if !arg.HasDefault {
+				return nil, nil
+			}
+			return printValue(arg.Type, arg.Default)
+		}},
+		{Name: "isDeprecated", Type: NewNonNull(Boolean), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return false, nil
+		}},
+		{Name: "deprecationReason", Type: String, Resolve: str(func(any) string { return "" })},
+	}
+
+	introspectionEnumValue.Fields = []*Field{
+		{Name: "name", Type: NewNonNull(String), Resolve: str(func(source any) string { return source.(EnumValue).Name })},
+		{Name: "description", Type: String, Resolve: str(func(source any) string { return source.(EnumValue).Description })},
+		{Name: "isDeprecated", Type: NewNonNull(Boolean), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return false, nil
+		}},
+		{Name: "deprecationReason", Type: String, Resolve: str(func(any) string { return "" })},
+	}
+
+	introspectionDirective.Fields = []*Field{
+		{Name: "name", Type: NewNonNull(String), Resolve: str(func(source any) string { return source.(*directiveDef).name })},
+		{Name: "description", Type: String, Resolve: str(func(source any) string { return source.(*directiveDef).description })},
+		{Name: "locations", Type: NewNonNull(NewList(NewNonNull(directiveLocation))), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(*directiveDef).locations, nil
+		}},
+		{Name: "args", Type: NewNonNull(NewList(NewNonNull(introspectionInputValue))), Args: includeDeprecated, Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return p.Source.(*directiveDef).args, nil
+		}},
+		{Name: "isRepeatable", Type: NewNonNull(Boolean), Resolve: func(ctx context.Context, p ResolveParams) (any, error) {
+			return false, nil
+		}},
+	}
+}
+
+// nilIfNone keeps a missing root type from becoming a non-nil interface.
+func nilIfNone(t *Object) any {
+	if t == nil {
+		return nil
+	}
+	return t
+}
+
+func nonNilArgs(args []*Argument) []*Argument {
+	if args == nil {
+		return []*Argument{}
+	}
+	return args
+}
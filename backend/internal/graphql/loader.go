@@ -0,0 +1,63 @@
+package graphql
+
+import "context"
+
+// Loader batches the keys resolvers ask for into one call of its fetch
+// function, DataLoader style, and caches the results for the rest of the
+// request. Because the executor resolves a field on every object of a level
+// before forcing any Thunk, returning Load's function from a Thunk fetches
+// the whole level at once. Loaders are not safe for concurrent use; make
+// one per request, for example in SchemaConfig.RequestContext.
+type Loader[K comparable, V any] struct {
+	fetch   func(ctx context.Context, keys []K) (map[K]V, error)
+	pending []K
+	queued  map[K]bool
+	results map[K]loaded[V]
+}
+
+type loaded[V any] struct {
+	value V
+	found bool
+	err   error
+}
+
+// NewLoader returns a loader that calls fetch with the distinct keys queued
+// since its last call. Keys missing from the map fetch returns are not
+// found.
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, queued: make(map[K]bool), results: make(map[K]loaded[V])}
+}
+
+// Load queues key and returns a function that yields its value, fetching
+// every queued key on first use.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, bool, error) {
+	if _, done := l.results[key]; !done && !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	return func() (V, bool, error) {
+		if _, done := l.results[key]; !done {
+			l.dispatch(ctx)
+		}
+		result := l.results[key]
+		return result.value, result.found, result.err
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	keys := l.pending
+	l.pending = nil
+	l.queued = make(map[K]bool)
+	values, err := l.fetch(ctx, keys)
+	for _, key := range keys {
+		value, found := values[key]
+		l.results[key] = loaded[V]{value: value, found: found, err: err}
+	}
+}
+
+// Prime caches value for key, as if fetched, unless key is already loaded.
+func (l *Loader[K, V]) Prime(key K, value V) {
+	if _, done := l.results[key]; !done {
+		l.results[key] = loaded[V]{value: value, found: true}
+	}
+}
@@ -0,0 +1,369 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Type is one of *Scalar, *Enum, *Object, *InputObject, *List or *NonNull.
+type Type interface {
+	String() string
+}
+
+// namedType is a type with a name of its own, as opposed to a wrapper.
+type namedType interface {
+	Type
+	typeName() string
+	typeDescription() string
+}
+
+// Scalar is a leaf type. Serialize turns a resolved value into its JSON
+// form; Parse turns an input, decoded from JSON or converted from a literal,
+// into the value resolvers receive. Numbers reach Parse as json.Number,
+// enum literals as EnumLiteral.
+type Scalar struct {
+	Name        string
+	Description string
+	Serialize   func(value any) (any, error)
+	Parse       func(value any) (any, error)
+}
+
+// EnumLiteral is an unquoted name written as an input value, which only
+// enums accept.
+type EnumLiteral string
+
+// Enum is a leaf type with a fixed set of names. Resolvers return and
+// receive the Value of each name.
+type Enum struct {
+	Name        string
+	Description string
+	Values      []EnumValue
+}
+
+type EnumValue struct {
+	Name        string
+	Description string
+	Value       any
+}
+
+// Object is an output type with fields.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+// ResolveParams are passed to a field's resolver. Args holds the coerced
+// arguments: present arguments and those with defaults.
+type ResolveParams struct {
+	Source any
+	Args   map[string]any
+}
+
+// Thunk defers a value until every sibling field of a batch has been
+// resolved, so that loaders can fetch their keys together.
+type Thunk func() (any, error)
+
+// Field is a field of an Object. Resolve returns the value or a Thunk. Root
+// subscription fields have Subscribe instead, which returns the stream of
+// source values; Resolve, when set, maps each of them.
+type Field struct {
+	Name              string
+	Description       string
+	Type              Type
+	Args              []*Argument
+	Resolve           func(ctx context.Context, p ResolveParams) (any, error)
+	Subscribe         func(ctx context.Context, p ResolveParams) (<-chan any, error)
+	DeprecationReason string
+}
+
+// Argument is a field argument or input object field. Default applies when
+// the input leaves it out; it is in the form resolvers receive.
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     any
+	HasDefault  bool
+}
+
+// InputObject is an input type with fields. Resolvers receive it as a
+// map[string]any holding only the fields the input set, so that an absent
+// field can be told apart from an explicit null.
+type InputObject struct {
+	Name        string
+	Description string
+	Fields      []*Argument
+}
+
+type List struct {
+	OfType Type
+}
+
+type NonNull struct {
+	OfType Type
+}
+
+func NewList(t Type) *List       { return &List{OfType: t} }
+func NewNonNull(t Type) *NonNull { return &NonNull{OfType: t} }
+
+func (t *Scalar) String() string      { return t.Name }
+func (t *Enum) String() string        { return t.Name }
+func (t *Object) String() string      { return t.Name }
+func (t *InputObject) String() string { return t.Name }
+func (t *List) String() string        { return "[" + t.OfType.String() + "]" }
+func (t *NonNull) String() string     { return t.OfType.String() + "!" }
+
+func (t *Scalar) typeName() string      { return t.Name }
+func (t *Enum) typeName() string        { return t.Name }
+func (t *Object) typeName() string      { return t.Name }
+func (t *InputObject) typeName() string { return t.Name }
+
+func (t *Scalar) typeDescription() string      { return t.Description }
+func (t *Enum) typeDescription() string        { return t.Description }
+func (t *Object) typeDescription() string      { return t.Description }
+func (t *InputObject) typeDescription() string { return t.Description }
+
+// Field returns the field called name, or nil.
+func (t *Object) Field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *InputObject) field(name string) *Argument {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *Enum) valueOf(name string) (any, bool) {
+	for _, v := range t.Values {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Enum) nameOf(value any) (string, bool) {
+	for _, v := range t.Values {
+		if v.Value == value {
+			return v.Name, true
+		}
+	}
+	return "", false
+}
+
+func findArgument(args []*Argument, name string) *Argument {
+	for _, a := range args {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// namedOf strips the list and non-null wrappers off t.
+func namedOf(t Type) namedType {
+	for {
+		switch wrapper := t.(type) {
+		case *List:
+			t = wrapper.OfType
+		case *NonNull:
+			t = wrapper.OfType
+		default:
+			return t.(namedType)
+		}
+	}
+}
+
+func isInputType(t Type) bool {
+	switch namedOf(t).(type) {
+	case *Scalar, *Enum, *InputObject:
+		return true
+	}
+	return false
+}
+
+func isLeafType(t Type) bool {
+	switch namedOf(t).(type) {
+	case *Scalar, *Enum:
+		return true
+	}
+	return false
+}
+
+// SchemaConfig describes a schema. Mutation and Subscription are optional.
+type SchemaConfig struct {
+	Query        *Object
+	Mutation     *Object
+	Subscription *Object
+	// RequestContext, when set, prepares the context of each execution,
+	// including each event of a subscription, for example with fresh
+	// loaders.
+	RequestContext func(ctx context.Context) context.Context
+}
+
+// Schema is a validated set of types rooted at the query, mutation and
+// subscription types.
+type Schema struct {
+	query          *Object
+	mutation       *Object
+	subscription   *Object
+	types          map[string]namedType
+	requestContext func(ctx context.Context) context.Context
+}
+
+// NewSchema collects the types reachable from the roots and checks them.
+func NewSchema(config SchemaConfig) (*Schema, error) {
+	if config.Query == nil {
+		return nil, errors.New("graphql: a schema needs a query type")
+	}
+	s := &Schema{
+		query:          config.Query,
+		mutation:       config.Mutation,
+		subscription:   config.Subscription,
+		types:          make(map[string]namedType),
+		requestContext: config.RequestContext,
+	}
+	for _, scalar := range []*Scalar{Int, Float, String, Boolean, ID} {
+		s.types[scalar.Name] = scalar
+	}
+	for _, root := range []*Object{config.Query, config.Mutation, config.Subscription, introspectionSchema} {
+		if root == nil {
+			continue
+		}
+		if err := s.add(root); err != nil {
+			return nil, err
+		}
+	}
+	if config.Subscription != nil {
+		for _, f := range config.Subscription.Fields {
+			if f.Subscribe == nil {
+				return nil, fmt.Errorf("graphql: subscription field %s has no Subscribe function", f.Name)
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) add(t Type) error {
+	named := namedOf(t)
+	name := named.typeName()
+	if existing, ok := s.types[name]; ok {
+		if existing != named {
+			return fmt.Errorf("graphql: two different types are named %s", name)
+		}
+		return nil
+	}
+	if !validName(name) {
+		return fmt.Errorf("graphql: invalid type name %q", name)
+	}
+	s.types[name] = named
+
+	switch named := named.(type) {
+	case *Object:
+		if len(named.Fields) == 0 {
+			return fmt.Errorf("graphql: object %s has no fields", name)
+		}
+		seen := make(map[string]bool)
+		for _, f := range named.Fields {
+			if !validName(f.Name) || seen[f.Name] {
+				return fmt.Errorf("graphql: invalid or repeated field name %s.%s", name, f.Name)
+			}
+			seen[f.Name] = true
+			if f.Type == nil || isInputType(f.Type) && !isLeafType(f.Type) {
+				return fmt.Errorf("graphql: field %s.%s needs an output type", name, f.Name)
+			}
+			if f.Resolve == nil && f.Subscribe == nil {
+				return fmt.Errorf("graphql: field %s.%s has no resolver", name, f.Name)
+			}
+			if err := s.add(f.Type); err != nil {
+				return err
+			}
+			if err := s.addArguments(name+"."+f.Name, f.Args); err != nil {
+				return err
+			}
+		}
+	case *InputObject:
+		if err := s.addArguments(name, named.Fields); err != nil {
+			return err
+		}
+	case *Enum:
+		for _, v := range named.Values {
+			if !validName(v.Name) || v.Name == "true" || v.Name == "false" || v.Name == "null" {
+				return fmt.Errorf("graphql: invalid enum value %s.%s", name, v.Name)
+			}
+		}
+	case *Scalar:
+		if named.Serialize == nil || named.Parse == nil {
+			return fmt.Errorf("graphql: scalar %s needs Serialize and Parse", name)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) addArguments(owner string, args []*Argument) error {
+	seen := make(map[string]bool)
+	for _, a := range args {
+		if !validName(a.Name) || seen[a.Name] {
+			return fmt.Errorf("graphql: invalid or repeated argument name %s(%s)", owner, a.Name)
+		}
+		seen[a.Name] = true
+		if a.Type == nil || !isInputType(a.Type) {
+			return fmt.Errorf("graphql: argument %s(%s) needs an input type", owner, a.Name)
+		}
+		if err := s.add(a.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputType resolves a type written in a variable definition.
+func (s *Schema) inputType(ref *typeRef) (Type, error) {
+	var t Type
+	if ref.elem != nil {
+		elem, err := s.inputType(ref.elem)
+		if err != nil {
+			return nil, err
+		}
+		t = NewList(elem)
+	} else {
+		named, ok := s.types[ref.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q", ref.name)
+		}
+		t = named
+	}
+	if !isInputType(t) {
+		return nil, fmt.Errorf("type %s is not an input type", ref)
+	}
+	if ref.nonNull {
+		t = NewNonNull(t)
+	}
+	return t, nil
+}
+
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") && !slices.Contains(introspectionNames, name) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '_' && !isLetter(c) && (i == 0 || !isDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
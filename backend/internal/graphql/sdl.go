@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SDL prints the schema in the GraphQL schema definition language,
+// leaving out the built-in scalars and introspection types.
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	roots := []string{s.query.Name}
+	if s.mutation != nil {
+		roots = append(roots, s.mutation.Name)
+	}
+	if s.subscription != nil {
+		roots = append(roots, s.subscription.Name)
+	}
+	// Print the roots first, then the rest alphabetically.
+	order := append([]string{}, roots...)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "__") && !builtinScalar(name) && !slices.Contains(roots, name) {
+			order = append(order, name)
+		}
+	}
+
+	for i, name := range order {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		switch t := s.types[name].(type) {
+		case *Scalar:
+			writeDescription(&b, t.Description, "")
+			b.WriteString("scalar " + t.Name + "\n")
+		case *Enum:
+			writeDescription(&b, t.Description, "")
+			b.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.Values {
+				writeDescription(&b, v.Description, "  ")
+				b.WriteString("  " + v.Name + "\n")
+			}
+			b.WriteString("}\n")
+		case *InputObject:
+			writeDescription(&b, t.Description, "")
+			b.WriteString("input " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				writeDescription(&b, f.Description, "  ")
+				b.WriteString("  " + inputValueSDL(f) + "\n")
+			}
+			b.WriteString("}\n")
+		case *Object:
+			writeDescription(&b, t.Description, "")
+			b.WriteString("type " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				writeDescription(&b, f.Description, "  ")
+				b.WriteString("  " + f.Name)
+				if len(f.Args) > 0 {
+					args := make([]string, len(f.Args))
+					for i, a := range f.Args {
+						args[i] = inputValueSDL(a)
+					}
+					b.WriteString("(" + strings.Join(args, ", ") + ")")
+				}
+				b.WriteString(": " + f.Type.String())
+				if f.DeprecationReason != "" {
+					b.WriteString(" @deprecated(reason: " + strconv.Quote(f.DeprecationReason) + ")")
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+func inputValueSDL(a *Argument) string {
+	s := a.Name + ": " + a.Type.String()
+	if a.HasDefault {
+		if printed, err := printValue(a.Type, a.Default); err == nil {
+			s += " = " + printed
+		}
+	}
+	return s
+}
+
+func writeDescription(b *strings.Builder, description string, indent string) {
+	if description == "" {
+		return
+	}
+	if !strings.Contains(description, "\n") && !strings.Contains(description, `"`) {
+		b.WriteString(indent + `"` + description + `"` + "\n")
+		return
+	}
+	b.WriteString(indent + `"""` + "\n")
+	for _, line := range strings.Split(strings.ReplaceAll(description, `"""`, `\"""`), "\n") {
+		b.WriteString(indent + line + "\n")
+	}
+	b.WriteString(indent + `"""` + "\n")
+}
+
+func builtinScalar(name string) bool {
+	switch name {
+	case "Int", "Float", "String", "Boolean", "ID":
+		return true
+	}
+	return false
+}
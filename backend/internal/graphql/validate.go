@@ -0,0 +1,343 @@
+package graphql
+
+import (
+	"fmt"
+	"slices"
+)
+
+// maxDepth limits how deeply selection sets may nest, which bounds the
+// work a single query can ask for. The introspection query tools send
+// needs about a dozen levels.
+const maxDepth = 15
+
+type validator struct {
+	schema    *Schema
+	doc       *document
+	variables map[string]any
+	errors    []*Error
+	used      map[string]bool
+}
+
+func (v *validator) errorf(loc Location, format string, args ...any) {
+	v.errors = append(v.errors, &Error{
+		Message:    fmt.Sprintf(format, args...),
+		Locations:  []Location{loc},
+		Extensions: map[string]any{"code": CodeValidationFailed},
+	})
+}
+
+// validate checks the operation that will run, and the fragments it uses,
+// against the schema. Arguments are checked with variables, which have
+// already been coerced, filled in.
+func (s *Schema) validate(doc *document, op *operation, variables map[string]any) []*Error {
+	v := &validator{schema: s, doc: doc, variables: variables, used: make(map[string]bool)}
+
+	root := s.rootType(op)
+	if root == nil {
+		v.errorf(op.loc, "the schema does not support %ss", op.kind)
+		return v.errors
+	}
+	v.directives(op.directives, op.kind)
+
+	defined := make(map[string]*variableDefinition)
+	for _, def := range op.variables {
+		if defined[def.name] != nil {
+			v.errorf(def.loc, "there can be only one variable named $%s", def.name)
+		}
+		defined[def.name] = def
+	}
+
+	for name, f := range doc.fragments {
+		if v.cyclic(f, map[string]bool{name: true}) {
+			v.errorf(f.loc, "fragment %q spreads itself", name)
+			return v.errors
+		}
+	}
+
+	usages := make(map[string][]variableUsage)
+	v.selections(root, op.selections, 1, usages, make(map[string]bool))
+
+	for name, list := range usages {
+		def, ok := defined[name]
+		if !ok {
+			for _, usage := range list {
+				v.errorf(usage.loc, "variable $%s is not defined", name)
+			}
+			continue
+		}
+		varType, err := s.inputType(def.typ)
+		if err != nil {
+			continue
+		}
+		for _, usage := range list {
+			if !compatible(varType, usage.typ, def.defaultValue != nil || usage.hasDefault) {
+				v.errorf(usage.loc, "variable $%s of type %s cannot be used where %s is expected", name, def.typ, usage.typ)
+			}
+		}
+	}
+	for _, def := range op.variables {
+		if _, ok := usages[def.name]; !ok {
+			v.errorf(def.loc, "variable $%s is never used", def.name)
+		}
+	}
+	for name, f := range doc.fragments {
+		if !v.used[name] && len(doc.operations) == 1 {
+			v.errorf(f.loc, "fragment %q is never used", name)
+		}
+	}
+
+	if op.kind == "subscription" && len(v.errors) == 0 {
+		e := &executor{schema: s, prepared: &prepared{doc: doc, op: op, variables: variables}}
+		groups := e.collectFields(root, op.selections)
+		if len(groups) != 1 || groups[0].fields[0].name == typenameField.Name {
+			v.errorf(op.loc, "a subscription must select exactly one field")
+		}
+	}
+	return v.errors
+}
+
+type variableUsage struct {
+	typ        Type
+	hasDefault bool
+	loc        Location
+}
+
+func (v *validator) cyclic(f *fragment, visiting map[string]bool) bool {
+	var spreads func(selections []selection) bool
+	spreads = func(selections []selection) bool {
+		for _, sel := range selections {
+			switch sel := sel.(type) {
+			case *field:
+				if spreads(sel.selections) {
+					return true
+				}
+			case *inlineFragment:
+				if spreads(sel.selections) {
+					return true
+				}
+			case *fragmentSpread:
+				if visiting[sel.name] {
+					return true
+				}
+				next, ok := v.doc.fragments[sel.name]
+				if !ok {
+					continue
+				}
+				visiting[sel.name] = true
+				cyclic := v.cyclic(next, visiting)
+				delete(visiting, sel.name)
+				if cyclic {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return spreads(f.selections)
+}
+
+func (v *validator) selections(t *Object, selections []selection, depth int, usages map[string][]variableUsage, spreading map[string]bool) {
+	if depth > maxDepth {
+		v.errorf(selections[0].location(), "the query is nested more than %d levels deep", maxDepth)
+		return
+	}
+	seen := make(map[string]*field)
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			v.directives(sel.directives, "field")
+			v.collectVariables(sel.directives, usages)
+			def := v.schema.fieldDef(t, sel.name)
+			if def == nil {
+				v.errorf(sel.loc, "cannot query field %q on type %q", sel.name, t.Name)
+				continue
+			}
+			if other, ok := seen[sel.responseKey()]; ok && !sameField(other, sel) {
+				v.errorf(sel.loc, "fields %q conflict because they select different fields or arguments; use an alias", sel.responseKey())
+			}
+			seen[sel.responseKey()] = sel
+			v.arguments(def.Args, sel.arguments, sel.loc, "field "+sel.name, usages)
+
+			named := namedOf(def.Type)
+			if object, ok := named.(*Object); ok {
+				if len(sel.selections) == 0 {
+					v.errorf(sel.loc, "field %q of type %s must have a selection of subfields", sel.name, def.Type)
+					continue
+				}
+				v.selections(object, sel.selections, depth+1, usages, spreading)
+			} else if len(sel.selections) > 0 {
+				v.errorf(sel.loc, "field %q of type %s must not have a selection of subfields", sel.name, def.Type)
+			}
+		case *fragmentSpread:
+			v.directives(sel.directives, "fragment spread")
+			v.collectVariables(sel.directives, usages)
+			f, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.errorf(sel.loc, "unknown fragment %q", sel.name)
+				continue
+			}
+			v.used[sel.name] = true
+			if !v.typeCondition(f.typeCondition, t, f.loc) || spreading[sel.name] {
+				continue
+			}
+			spreading[sel.name] = true
+			v.directives(f.directives, "fragment definition")
+			v.collectVariables(f.directives, usages)
+			v.selections(t, f.selections, depth, usages, spreading)
+			delete(spreading, sel.name)
+		case *inlineFragment:
+			v.directives(sel.directives, "inline fragment")
+			v.collectVariables(sel.directives, usages)
+			if sel.typeCondition != "" && !v.typeCondition(sel.typeCondition, t, sel.loc) {
+				continue
+			}
+			v.selections(t, sel.selections, depth, usages, spreading)
+		}
+	}
+}
+
+// typeCondition checks a fragment's type condition against the type it is
+// spread into. Without interfaces or unions the two must be the same.
+func (v *validator) typeCondition(name string, t *Object, loc Location) bool {
+	named, ok := v.schema.types[name]
+	switch {
+	case !ok:
+		v.errorf(loc, "unknown type %q", name)
+		return false
+	case named != namedType(t):
+		if _, isObject := named.(*Object); !isObject {
+			v.errorf(loc, "fragment cannot condition on non-object type %q", name)
+		} else {
+			v.errorf(loc, "fragment on %q can never apply to type %q", name, t.Name)
+		}
+		return false
+	}
+	return true
+}
+
+func (v *validator) arguments(defs []*Argument, args []*argument, loc Location, owner string, usages map[string][]variableUsage) {
+	names := make(map[string]bool)
+	for _, arg := range args {
+		if names[arg.name] {
+			v.errorf(arg.loc, "there can be only one argument named %q", arg.name)
+		}
+		names[arg.name] = true
+		def := findArgument(defs, arg.name)
+		if def == nil {
+			v.errorf(arg.loc, "unknown argument %q on %s", arg.name, owner)
+			continue
+		}
+		v.collectUsages(def.Type, arg.value, def.HasDefault, usages)
+	}
+	if _, err := coerceArguments(defs, args, v.variables, owner); err != nil {
+		v.errorf(loc, "%s", err)
+	}
+}
+
+// collectUsages records the variables in a literal with the type expected
+// where they appear.
+func (v *validator) collectUsages(t Type, literal *value, hasDefault bool, usages map[string][]variableUsage) {
+	switch literal.kind {
+	case variableValue:
+		usages[literal.raw] = append(usages[literal.raw], variableUsage{typ: t, hasDefault: hasDefault, loc: literal.loc})
+	case listValue:
+		if list, ok := unwrapNonNull(t).(*List); ok {
+			for _, item := range literal.list {
+				v.collectUsages(list.OfType, item, false, usages)
+			}
+		}
+	case objectValue:
+		if object, ok := unwrapNonNull(t).(*InputObject); ok {
+			for _, f := range literal.fields {
+				if def := object.field(f.name); def != nil {
+					v.collectUsages(def.Type, f.value, def.HasDefault, usages)
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) collectVariables(directives []*directive, usages map[string][]variableUsage) {
+	for _, d := range directives {
+		def, ok := directiveDefs[d.name]
+		if !ok {
+			continue
+		}
+		for _, arg := range d.arguments {
+			if a := findArgument(def.args, arg.name); a != nil {
+				v.collectUsages(a.Type, arg.value, a.HasDefault, usages)
+			}
+		}
+	}
+}
+
+// directives checks the directives at a location.
+func (v *validator) directives(directives []*directive, location string) {
+	seen := make(map[string]bool)
+	for _, d := range directives {
+		def, ok := directiveDefs[d.name]
+		if !ok {
+			v.errorf(d.loc, "unknown directive @%s", d.name)
+			continue
+		}
+		if !slices.Contains(def.executableLocations, location) {
+			v.errorf(d.loc, "directive @%s may not be used on a %s", d.name, location)
+			continue
+		}
+		if seen[d.name] {
+			v.errorf(d.loc, "directive @%s may be used only once here", d.name)
+		}
+		seen[d.name] = true
+		v.arguments(def.args, d.arguments, d.loc, "@"+d.name, map[string][]variableUsage{})
+	}
+}
+
+func unwrapNonNull(t Type) Type {
+	if nonNull, ok := t.(*NonNull); ok {
+		return nonNull.OfType
+	}
+	return t
+}
+
+// compatible reports whether a variable of type varType may be used where
+// locType is expected.
+func compatible(varType Type, locType Type, hasDefault bool) bool {
+	if locNonNull, ok := locType.(*NonNull); ok {
+		varNonNull, ok := varType.(*NonNull)
+		if !ok {
+			return hasDefault && compatible(varType, locNonNull.OfType, false)
+		}
+		return compatible(varNonNull.OfType, locNonNull.OfType, false)
+	}
+	if varNonNull, ok := varType.(*NonNull); ok {
+		return compatible(varNonNull.OfType, locType, false)
+	}
+	if locList, ok := locType.(*List); ok {
+		varList, ok := varType.(*List)
+		return ok && compatible(varList.OfType, locList.OfType, false)
+	}
+	if _, ok := varType.(*List); ok {
+		return false
+	}
+	return namedOf(varType) == namedOf(locType)
+}
+
+// sameField reports whether two fields with the same response key can be
+// merged: they must name the same field with the same arguments.
+func sameField(a, b *field) bool {
+	if a.name != b.name || len(a.arguments) != len(b.arguments) {
+		return false
+	}
+	for _, argA := range a.arguments {
+		found := false
+		for _, argB := range b.arguments {
+			if argA.name == argB.name && literalString(argA.value) == literalString(argB.value) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
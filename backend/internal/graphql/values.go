@@ -0,0 +1,460 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Built-in scalars.
+var (
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize: func(value any) (any, error) {
+			n, ok := toInt64(value)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent %v", value)
+			}
+			return n, nil
+		},
+		Parse: func(value any) (any, error) {
+			if n, ok := value.(json.Number); ok {
+				if i, err := strconv.ParseInt(string(n), 10, 32); err == nil {
+					return int(i), nil
+				}
+			}
+			return nil, fmt.Errorf("Int cannot represent %s", describe(value))
+		},
+	}
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating-point number.",
+		Serialize: func(value any) (any, error) {
+			if f, ok := value.(float64); ok && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return f, nil
+			}
+			if n, ok := toInt64(value); ok {
+				return float64(n), nil
+			}
+			return nil, fmt.Errorf("Float cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			if n, ok := value.(json.Number); ok {
+				if f, err := n.Float64(); err == nil && !math.IsInf(f, 0) {
+					return f, nil
+				}
+			}
+			return nil, fmt.Errorf("Float cannot represent %s", describe(value))
+		},
+	}
+	String = &Scalar{
+		Name:        "String",
+		Description: "UTF-8 text.",
+		Serialize: func(value any) (any, error) {
+			if s, ok := value.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			if s, ok := value.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent %s", describe(value))
+		},
+	}
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize: func(value any) (any, error) {
+			if b, ok := value.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			if b, ok := value.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %s", describe(value))
+		},
+	}
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "A unique identifier, serialized as a string. Integers are accepted as input.",
+		Serialize: func(value any) (any, error) {
+			if s, ok := value.(string); ok {
+				return s, nil
+			}
+			if n, ok := toInt64(value); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			switch v := value.(type) {
+			case string:
+				return v, nil
+			case json.Number:
+				if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+					return string(v), nil
+				}
+			}
+			return nil, fmt.Errorf("ID cannot represent %s", describe(value))
+		},
+	}
+)
+
+func toInt64(value any) (int64, bool) {
+	switch n := value.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+// describe names a raw input value for error messages.
+func describe(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case EnumLiteral:
+		return string(v)
+	case json.Number:
+		return string(v)
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprint(value)
+}
+
+// coerceValue coerces a variable's JSON value to t.
+func coerceValue(t Type, raw any) (any, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		if raw == nil {
+			return nil, fmt.Errorf("expected a non-null %s", nonNull.OfType)
+		}
+		return coerceValue(nonNull.OfType, raw)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := raw.([]any)
+		if !ok {
+			item, err := coerceValue(t.OfType, raw)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		coerced := make([]any, len(items))
+		for i, item := range items {
+			var err error
+			if coerced[i], err = coerceValue(t.OfType, item); err != nil {
+				return nil, fmt.Errorf("at index %d: %w", i, err)
+			}
+		}
+		return coerced, nil
+	case *InputObject:
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an object, not %s", t.Name, describe(raw))
+		}
+		for name := range fields {
+			if t.field(name) == nil {
+				return nil, fmt.Errorf("%s has no field %q", t.Name, name)
+			}
+		}
+		coerced := make(map[string]any, len(t.Fields))
+		for _, f := range t.Fields {
+			value, present := fields[f.Name]
+			if !present {
+				if err := applyDefault(coerced, f, t.Name); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			var err error
+			if coerced[f.Name], err = coerceValue(f.Type, value); err != nil {
+				return nil, fmt.Errorf("in field %q: %w", f.Name, err)
+			}
+		}
+		return coerced, nil
+	case *Enum:
+		if name, ok := raw.(string); ok {
+			if value, ok := t.valueOf(name); ok {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a value of %s", describe(raw), t.Name)
+	case *Scalar:
+		return t.Parse(raw)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// coerceLiteral coerces a literal to t, substituting coerced variables.
+// present is false when v is a variable that was not provided.
+func coerceLiteral(t Type, v *value, variables map[string]any) (coerced any, present bool, err error) {
+	if v.kind == variableValue {
+		value, ok := variables[v.raw]
+		if !ok {
+			return nil, false, nil
+		}
+		if _, nonNull := t.(*NonNull); nonNull && value == nil {
+			return nil, true, fmt.Errorf("variable $%s must not be null", v.raw)
+		}
+		return value, true, nil
+	}
+	if nonNull, ok := t.(*NonNull); ok {
+		if v.kind == nullValue {
+			return nil, true, fmt.Errorf("expected a non-null %s", nonNull.OfType)
+		}
+		return coerceLiteral(nonNull.OfType, v, variables)
+	}
+	if v.kind == nullValue {
+		return nil, true, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		if v.kind != listValue {
+			item, _, err := coerceLiteral(t.OfType, v, variables)
+			if err != nil {
+				return nil, true, err
+			}
+			return []any{item}, true, nil
+		}
+		items := make([]any, len(v.list))
+		for i, item := range v.list {
+			value, present, err := coerceLiteral(t.OfType, item, variables)
+			if err != nil {
+				return nil, true, fmt.Errorf("at index %d: %w", i, err)
+			}
+			if !present {
+				if _, nonNull := t.OfType.(*NonNull); nonNull {
+					return nil, true, fmt.Errorf("at index %d: variable $%s was not provided", i, item.raw)
+				}
+			}
+			items[i] = value
+		}
+		return items, true, nil
+	case *InputObject:
+		if v.kind != objectValue {
+			return nil, true, fmt.Errorf("%s must be an object", t.Name)
+		}
+		coerced := make(map[string]any, len(t.Fields))
+		for _, f := range v.fields {
+			if t.field(f.name) == nil {
+				return nil, true, fmt.Errorf("%s has no field %q", t.Name, f.name)
+			}
+		}
+		for _, f := range t.Fields {
+			var literal *value
+			for _, candidate := range v.fields {
+				if candidate.name == f.Name {
+					literal = candidate.value
+				}
+			}
+			present := false
+			if literal != nil {
+				value, ok, err := coerceLiteral(f.Type, literal, variables)
+				if err != nil {
+					return nil, true, fmt.Errorf("in field %q: %w", f.Name, err)
+				}
+				if ok {
+					coerced[f.Name] = value
+					present = true
+				}
+			}
+			if !present {
+				if err := applyDefault(coerced, f, t.Name); err != nil {
+					return nil, true, err
+				}
+			}
+		}
+		return coerced, true, nil
+	case *Enum:
+		if v.kind == enumValue {
+			if value, ok := t.valueOf(v.raw); ok {
+				return value, true, nil
+			}
+		}
+		return nil, true, fmt.Errorf("%s is not a value of %s", literalString(v), t.Name)
+	case *Scalar:
+		var raw any
+		switch v.kind {
+		case intValue, floatValue:
+			raw = json.Number(v.raw)
+		case stringValue:
+			raw = v.raw
+		case booleanValue:
+			raw = v.raw == "true"
+		case enumValue:
+			raw = EnumLiteral(v.raw)
+		default:
+			return nil, true, fmt.Errorf("%s cannot represent %s", t.Name, literalString(v))
+		}
+		value, err := t.Parse(raw)
+		return value, true, err
+	}
+	return nil, true, fmt.Errorf("%s is not an input type", t)
+}
+
+// applyDefault fills in an absent input field or argument.
+func applyDefault(values map[string]any, def *Argument, owner string) error {
+	switch {
+	case def.HasDefault:
+		values[def.Name] = def.Default
+	case isNonNull(def.Type):
+		return fmt.Errorf("%s requires %q of type %s", owner, def.Name, def.Type)
+	}
+	return nil
+}
+
+func isNonNull(t Type) bool {
+	_, ok := t.(*NonNull)
+	return ok
+}
+
+// coerceArguments coerces the arguments given to a field or directive.
+func coerceArguments(defs []*Argument, args []*argument, variables map[string]any, owner string) (map[string]any, error) {
+	coerced := make(map[string]any, len(defs))
+	for _, def := range defs {
+		var given *argument
+		for _, arg := range args {
+			if arg.name == def.Name {
+				given = arg
+			}
+		}
+		if given != nil {
+			value, present, err := coerceLiteral(def.Type, given.value, variables)
+			if err != nil {
+				return nil, fmt.Errorf("argument %q of %s: %w", def.Name, owner, err)
+			}
+			if present {
+				coerced[def.Name] = value
+				continue
+			}
+		}
+		if err := applyDefault(coerced, def, owner); err != nil {
+			return nil, err
+		}
+	}
+	return coerced, nil
+}
+
+// literalString prints a literal back as GraphQL.
+func literalString(v *value) string {
+	switch v.kind {
+	case variableValue:
+		return "$" + v.raw
+	case stringValue:
+		return strconv.Quote(v.raw)
+	case listValue:
+		s := "["
+		for i, item := range v.list {
+			if i > 0 {
+				s += ", "
+			}
+			s += literalString(item)
+		}
+		return s + "]"
+	case objectValue:
+		s := "{"
+		for i, f := range v.fields {
+			if i > 0 {
+				s += ", "
+			}
+			s += f.name + ": " + literalString(f.value)
+		}
+		return s + "}"
+	}
+	return v.raw
+}
+
+// printValue prints a value in the form resolvers receive as a GraphQL
+// literal of type t, for default values in introspection and SDL.
+func printValue(t Type, value any) (string, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		t = nonNull.OfType
+	}
+	if value == nil {
+		return "null", nil
+	}
+	switch t := t.(type) {
+	case *List:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice {
+			return printValue(t.OfType, value)
+		}
+		s := "["
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				s += ", "
+			}
+			item, err := printValue(t.OfType, rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			s += item
+		}
+		return s + "]", nil
+	case *InputObject:
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("%s default must be a map", t.Name)
+		}
+		s := "{"
+		first := true
+		for _, f := range t.Fields {
+			v, ok := fields[f.Name]
+			if !ok {
+				continue
+			}
+			printed, err := printValue(f.Type, v)
+			if err != nil {
+				return "", err
+			}
+			if !first {
+				s += ", "
+			}
+			first = false
+			s += f.Name + ": " + printed
+		}
+		return s + "}", nil
+	case *Enum:
+		if name, ok := t.nameOf(value); ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("%v is not a value of %s", value, t.Name)
+	case *Scalar:
+		serialized, err := t.Serialize(value)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(serialized)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return "", errors.New("not an input type")
+}
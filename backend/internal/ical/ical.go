@@ -0,0 +1,367 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) needed to
+// exchange todos as VTODO components.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+
+	// maxLineOctets is the longest content line RFC 5545 allows before it
+	// must be folded.
+	maxLineOctets = 75
+)
+
+// Todo is one VTODO component.
+type Todo struct {
+	UID         string
+	Summary     string
+	Description string
+	Completed   bool
+	CompletedAt *time.Time
+	// Due is written as a DATE when it falls on midnight UTC, which is how
+	// date-only due dates are stored, and as a UTC DATE-TIME otherwise.
+	Due *time.Time
+	// Priority follows RFC 5545: 1 is highest, 9 lowest, 0 undefined.
+	Priority     int
+	LastModified *time.Time
+}
+
+// Writer streams a VCALENDAR containing VTODOs.
+type Writer struct {
+	w     *bufio.Writer
+	stamp string
+	err   error
+}
+
+// NewWriter starts a calendar; name is shown by clients that support
+// X-WR-CALNAME. stamp is used as every component's DTSTAMP.
+func NewWriter(w io.Writer, name string, stamp time.Time) *Writer {
+	cw := &Writer{w: bufio.NewWriter(w), stamp: stamp.UTC().Format(dateTimeLayout) + "Z"}
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", "-//todoapp//todo//EN")
+	cw.line("CALSCALE", "GREGORIAN")
+	if name != "" {
+		cw.line("X-WR-CALNAME", escapeText(name))
+	}
+	return cw
+}
+
+func (cw *Writer) WriteTodo(todo Todo) error {
+	cw.line("BEGIN", "VTODO")
+	cw.line("UID", escapeText(todo.UID))
+	cw.line("DTSTAMP", cw.stamp)
+	cw.line("SUMMARY", escapeText(todo.Summary))
+	if todo.Description != "" {
+		cw.line("DESCRIPTION", escapeText(todo.Description))
+	}
+	if todo.Completed {
+		cw.line("STATUS", "COMPLETED")
+		if todo.CompletedAt != nil {
+			cw.line("COMPLETED", formatUTC(*todo.CompletedAt))
+		}
+	} else {
+		cw.line("STATUS", "NEEDS-ACTION")
+	}
+	if todo.Due != nil {
+		if due := todo.Due.UTC(); due.Equal(due.Truncate(24 * time.Hour)) {
+			cw.line("DUE;VALUE=DATE", due.Format(dateLayout))
+		} else {
+			cw.line("DUE", formatUTC(due))
+		}
+	}
+	if todo.Priority > 0 {
+		cw.line("PRIORITY", strconv.Itoa(todo.Priority))
+	}
+	if todo.LastModified != nil {
+		cw.line("LAST-MODIFIED", formatUTC(*todo.LastModified))
+	}
+	cw.line("END", "VTODO")
+	return cw.err
+}
+
+// Close ends the calendar and flushes it; it does not close the writer.
+func (cw *Writer) Close() error {
+	cw.line("END", "VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+// line writes a content line, folding it at 75 octets without splitting
+// UTF-8 sequences.
+func (cw *Writer) line(name string, value string) {
+	if cw.err != nil {
+		return
+	}
+	content := name + ":" + value
+	var b strings.Builder
+	width := 0
+	for _, r := range content {
+		size := utf8.RuneLen(r)
+		if width+size > maxLineOctets {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+	_, cw.err = cw.w.WriteString(b.String())
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout) + "Z"
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// ParseError reports malformed input along with the (unfolded) line it was
+// found on.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+	line   int
+}
+
+// Decode returns every VTODO in r. Other components such as VEVENT and
+// VTIMEZONE are skipped, as are properties this package does not use.
+func Decode(r io.Reader) ([]Todo, error) {
+	props, err := readProperties(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(props) == 0 || props[0].name != "BEGIN" || !strings.EqualFold(props[0].value, "VCALENDAR") {
+		return nil, &ParseError{Line: 1, Err: errors.New("not an iCalendar file")}
+	}
+
+	var (
+		todos   []Todo
+		current *Todo
+		depth   int
+	)
+	for _, prop := range props {
+		switch prop.name {
+		case "BEGIN":
+			depth++
+			if depth == 2 && strings.EqualFold(prop.value, "VTODO") {
+				current = &Todo{}
+			}
+			continue
+		case "END":
+			if depth == 2 && current != nil {
+				if current.UID == "" {
+					return nil, &ParseError{Line: prop.line, Err: errors.New("VTODO without UID")}
+				}
+				todos = append(todos, *current)
+				current = nil
+			}
+			depth--
+			continue
+		}
+		if current == nil || depth != 2 {
+			continue
+		}
+		if err := current.set(prop); err != nil {
+			return nil, &ParseError{Line: prop.line, Err: err}
+		}
+	}
+	if depth != 0 {
+		return nil, &ParseError{Line: props[len(props)-1].line, Err: errors.New("unterminated component")}
+	}
+	return todos, nil
+}
+
+func (t *Todo) set(prop property) error {
+	switch prop.name {
+	case "UID":
+		t.UID = unescapeText(prop.value)
+	case "SUMMARY":
+		t.Summary = unescapeText(prop.value)
+	case "DESCRIPTION":
+		t.Description = unescapeText(prop.value)
+	case "STATUS":
+		t.Completed = strings.EqualFold(prop.value, "COMPLETED")
+	case "COMPLETED":
+		completed, err := parseTime(prop)
+		if err != nil {
+			return err
+		}
+		t.CompletedAt = &completed
+		t.Completed = true
+	case "DUE":
+		due, err := parseTime(prop)
+		if err != nil {
+			return err
+		}
+		t.Due = &due
+	case "PRIORITY":
+		priority, err := strconv.Atoi(prop.value)
+		if err != nil || priority < 0 || priority > 9 {
+			return fmt.Errorf("invalid PRIORITY %q", prop.value)
+		}
+		t.Priority = priority
+	case "LAST-MODIFIED":
+		modified, err := parseTime(prop)
+		if err != nil {
+			return err
+		}
+		t.LastModified = &modified
+	}
+	return nil
+}
+
+// parseTime reads a DATE or DATE-TIME value. Dates become midnight UTC;
+// times with a TZID are converted from that zone and floating times are
+// taken as UTC.
+func parseTime(prop property) (time.Time, error) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s date %q", prop.name, value)
+		}
+		return parsed, nil
+	}
+
+	location := time.UTC
+	if strings.HasSuffix(value, "Z") {
+		value = strings.TrimSuffix(value, "Z")
+	} else if tzid := prop.params["TZID"]; tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+	parsed, err := time.ParseInLocation(dateTimeLayout, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s time %q", prop.name, prop.value)
+	}
+	return parsed.UTC(), nil
+}
+
+// readProperties unfolds content lines and splits them into name,
+// parameters and value.
+func readProperties(r io.Reader) ([]property, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var (
+		props   []property
+		pending strings.Builder
+		start   int
+	)
+	flush := func() error {
+		if pending.Len() == 0 {
+			return nil
+		}
+		prop, err := parseProperty(pending.String())
+		if err != nil {
+			return &ParseError{Line: start, Err: err}
+		}
+		prop.line = start
+		props = append(props, prop)
+		pending.Reset()
+		return nil
+	}
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			pending.WriteString(text[1:])
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		if text != "" {
+			pending.WriteString(text)
+			start = line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+func parseProperty(line string) (property, error) {
+	// The value starts at the first colon outside a quoted parameter.
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("malformed content line %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := property{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string, len(parts)-1),
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+	}
+	return prop, nil
+}
@@ -0,0 +1,111 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2026, 11, 2, 15, 30, 0, 0, time.UTC)
+	completedAt := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	todos := []Todo{
+		{UID: "todo-1@todoapp", Summary: "Call mom; then dad, maybe", Description: "line one\nline two \\ done", Due: &due, Priority: 1},
+		{UID: "todo-2@todoapp", Summary: strings.Repeat("長い件名", 20), Completed: true, CompletedAt: &completedAt, Due: &dueAt},
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf, "Todos", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))
+	for _, todo := range todos {
+		if err := writer.WriteTodo(todo); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Fatalf("line longer than %d octets: %q", maxLineOctets, line)
+		}
+	}
+	if !strings.Contains(buf.String(), "DUE;VALUE=DATE:20261101\r\n") || !strings.Contains(buf.String(), "DUE:20261102T153000Z\r\n") {
+		t.Fatalf("unexpected DUE encoding:\n%s", buf.String())
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, todos) {
+		t.Fatalf("round trip mismatch:\nwant %#v\ngot  %#v", todos, decoded)
+	}
+}
+
+func TestDecode_ForeignCalendar(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//Example//Tasks//EN",
+		"BEGIN:VTIMEZONE",
+		"TZID:Asia/Tokyo",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:event-1",
+		"SUMMARY:Not a todo",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:abc-123",
+		"SUMMARY:Renew pass",
+		" port",
+		"DUE;TZID=Asia/Tokyo:20261101T090000",
+		"STATUS:NEEDS-ACTION",
+		"X-APPLE-SORT-ORDER:5",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"END:VALARM",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	todos, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(todos) != 1 {
+		t.Fatalf("expected 1 todo, got %d", len(todos))
+	}
+	todo := todos[0]
+	if todo.UID != "abc-123" || todo.Summary != "Renew passport" || todo.Completed {
+		t.Fatalf("unexpected todo: %#v", todo)
+	}
+	if want := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC); !todo.Due.Equal(want) {
+		t.Fatalf("expected due %v, got %v", want, todo.Due)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{name: "not a calendar", input: "hello\r\n", line: 1},
+		{name: "missing uid", input: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:x\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", line: 4},
+		{name: "bad priority", input: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:a\r\nPRIORITY:high\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", line: 4},
+		{name: "unterminated", input: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:a\r\n", line: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line != tt.line {
+				t.Fatalf("expected a parse error on line %d, got %v", tt.line, err)
+			}
+		})
+	}
+}
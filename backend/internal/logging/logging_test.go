@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_JSONIncludesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "hello", "key", "value")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode log line: %v", err)
+	}
+	if record["msg"] != "hello" || record["key"] != "value" {
+		t.Fatalf("unexpected record: %#v", record)
+	}
+	if record["request_id"] != "req-1" {
+		t.Fatalf("expected request_id req-1, got %#v", record["request_id"])
+	}
+}
+
+func TestNew_TextRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", slog.LevelWarn)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.With("component", "test").Warn("kept")
+
+	out := buf.String()
+	if strings.Contains(out, "dropped") {
+		t.Fatalf("expected info record to be filtered, got %q", out)
+	}
+	if !strings.Contains(out, "msg=kept") || !strings.Contains(out, "component=test") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("debug")
+	if err != nil {
+		t.Fatalf("parse level: %v", err)
+	}
+	if level != slog.LevelDebug {
+		t.Fatalf("expected debug, got %v", level)
+	}
+
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Fatalf("expected error for unknown level")
+	}
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todoapp/backend/internal/respond"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := WithRequestID(r.Context(), requestID)
+			routed := r.WithContext(ctx)
+			recorder := respond.NewRecorder(w)
+
+			next.ServeHTTP(recorder, routed)
+
+			// Expose the pattern to any middleware wrapping this one.
+			r.Pattern = routed.Pattern
+
+			level := slog.LevelInfo
+			switch {
+			case recorder.Status() >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case recorder.Status() >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(ctx, level, "http request",
+				slog.String("method", r.Method),
+				slog.String("route", routePattern(r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", recorder.Status()),
+				slog.Int64("bytes", recorder.Bytes()),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
+
+// routePattern returns the ServeMux pattern that matched r, falling back to
+// a fixed value so unmatched paths do not explode log cardinality.
+func routePattern(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	return r.Pattern
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
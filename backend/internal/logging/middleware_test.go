@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestLogger(t *testing.T) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", slog.LevelDebug)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	return logger, &buf
+}
+
+func decodeRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode log line %q: %v", buf.String(), err)
+	}
+	return record
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	logger, buf := newTestLogger(t)
+
+	var seen string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/todos", func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+	handler := Middleware(logger)(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	requestID := rr.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Fatalf("expected request id header to be set")
+	}
+	if seen != requestID {
+		t.Fatalf("expected handler to see %q, got %q", requestID, seen)
+	}
+
+	record := decodeRecord(t, buf)
+	if record["request_id"] != requestID {
+		t.Fatalf("unexpected request_id: %#v", record["request_id"])
+	}
+	if record["method"] != "POST" || record["route"] != "POST /api/todos" {
+		t.Fatalf("unexpected method/route: %#v", record)
+	}
+	if record["status"] != float64(http.StatusCreated) || record["bytes"] != float64(len("created")) {
+		t.Fatalf("unexpected status/bytes: %#v", record)
+	}
+	if _, ok := record["duration"]; !ok {
+		t.Fatalf("expected duration to be logged")
+	}
+	if record["level"] != "INFO" {
+		t.Fatalf("expected INFO level, got %#v", record["level"])
+	}
+}
+
+func TestMiddleware_PropagatesRequestID(t *testing.T) {
+	logger, buf := newTestLogger(t)
+	handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set(RequestIDHeader, "upstream-123")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get(RequestIDHeader); got != "upstream-123" {
+		t.Fatalf("expected propagated request id, got %q", got)
+	}
+
+	record := decodeRecord(t, buf)
+	if record["request_id"] != "upstream-123" {
+		t.Fatalf("unexpected request_id: %#v", record["request_id"])
+	}
+	if record["route"] != "unmatched" {
+		t.Fatalf("unexpected route: %#v", record["route"])
+	}
+	if record["level"] != "ERROR" {
+		t.Fatalf("expected ERROR level, got %#v", record["level"])
+	}
+}
+
+func TestMiddleware_ReplacesInvalidRequestID(t *testing.T) {
+	logger, _ := newTestLogger(t)
+	handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+	req.Header.Set(RequestIDHeader, "has spaces\n")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	got := rr.Header().Get(RequestIDHeader)
+	if got == "" || got == "has spaces\n" {
+		t.Fatalf("expected invalid request id to be replaced, got %q", got)
+	}
+}
@@ -0,0 +1,29 @@
+// Package markdown renders user-written Markdown to HTML that is safe to
+// embed in a page.
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// policy allows the formatting Markdown produces and strips scripts,
+	// event handlers and unsafe URL schemes; links open without a referrer.
+	policy = bluemonday.UGCPolicy().
+		RequireNoReferrerOnLinks(true).
+		AddTargetBlankToFullyQualifiedLinks(true)
+)
+
+// Render converts GitHub-flavoured Markdown to sanitized HTML.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
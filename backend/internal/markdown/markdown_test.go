@@ -0,0 +1,37 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	html, err := Render("**Agenda**\n\n- [docs](https://example.com/docs)\n- ~~old~~")
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, want := range []string{"<strong>Agenda</strong>", `href="https://example.com/docs"`, "<del>old</del>"} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("expected %q in %q", want, html)
+		}
+	}
+}
+
+func TestRender_Sanitizes(t *testing.T) {
+	cases := map[string]string{
+		"raw script":     "<script>alert(1)</script>",
+		"event handler":  `<img src="x.png" onerror="alert(1)">`,
+		"javascript url": "[click](javascript:alert(1))",
+		"inline html":    `<a href="javascript:alert(1)">x</a>`,
+	}
+	for name, source := range cases {
+		html, err := Render(source)
+		if err != nil {
+			t.Fatalf("%s: render: %v", name, err)
+		}
+		lower := strings.ToLower(html)
+		if strings.Contains(lower, "<script") || strings.Contains(lower, "onerror") || strings.Contains(lower, "javascript:") {
+			t.Fatalf("%s: unsafe output %q", name, html)
+		}
+	}
+}
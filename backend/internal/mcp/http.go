@@ -0,0 +1,264 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"todoapp/backend/internal/auth"
+)
+
+const (
+	sessionHeader  = "Mcp-Session-Id"
+	versionHeader  = "Mcp-Protocol-Version"
+	eventStreamCT  = "text/event-stream"
+	jsonCT         = "application/json"
+	defaultIdleTTL = 30 * time.Minute
+)
+
+// Handler serves the streamable HTTP transport: clients POST each message
+// and get the response back as JSON, or as an event stream when the server
+// has to ask them something, such as a confirmation, before answering.
+// Sessions start with initialize and belong to the caller that started
+// them. Server-initiated streams on GET are not offered.
+type Handler struct {
+	srv          *Server
+	maxBodyBytes int64
+	idleTTL      time.Duration
+	now          func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+type httpSession struct {
+	*session
+	owner    string
+	lastUsed time.Time
+}
+
+func NewHandler(srv *Server, maxBodyBytes int64) *Handler {
+	return &Handler{
+		srv:          srv,
+		maxBodyBytes: maxBodyBytes,
+		idleTTL:      defaultIdleTTL,
+		now:          time.Now,
+		sessions:     make(map[string]*httpSession),
+	}
+}
+
+// Post receives one JSON-RPC message from the client.
+func (h *Handler) Post(w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(w, r) || !checkVersion(w, r) {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != jsonCT {
+		w.Header().Set("Accept-Post", jsonCT)
+		http.Error(w, "content type must be "+jsonCT, http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	msg, rpcErr := parseMessage(body)
+	if rpcErr != nil {
+		writeMessage(w, http.StatusBadRequest, message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: rpcErr})
+		return
+	}
+
+	ctx := r.Context()
+	var sess *httpSession
+	if msg.isRequest() && msg.Method == "initialize" {
+		var id string
+		id, sess, err = h.start(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to start mcp session", "error", err)
+			http.Error(w, "failed to start session", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(sessionHeader, id)
+	} else if sess = h.lookup(w, r); sess == nil {
+		return
+	}
+
+	switch {
+	case msg.isResponse():
+		sess.deliver(msg)
+		w.WriteHeader(http.StatusAccepted)
+	case msg.isNotification():
+		h.srv.handle(ctx, sess.session, noReplies, msg)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		stream := &responseStream{w: w}
+		response := h.srv.handle(ctx, sess.session, stream.send, msg)
+		stream.finish(ctx, *response)
+	}
+}
+
+// Delete ends the caller's session.
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(w, r) {
+		return
+	}
+	if h.lookup(w, r) == nil {
+		return
+	}
+	h.mu.Lock()
+	delete(h.sessions, r.Header.Get(sessionHeader))
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// start opens a session for the caller, dropping sessions that have been
+// idle too long.
+func (h *Handler) start(ctx context.Context) (string, *httpSession, error) {
+	id := rand.Text()
+	now := h.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, idle := range h.sessions {
+		if now.Sub(idle.lastUsed) > h.idleTTL {
+			delete(h.sessions, key)
+		}
+	}
+	if _, ok := h.sessions[id]; ok {
+		return "", nil, fmt.Errorf("session id %s already in use", id)
+	}
+	sess := &httpSession{session: newSession(), owner: owner(ctx), lastUsed: now}
+	h.sessions[id] = sess
+	return id, sess, nil
+}
+
+// lookup returns the session the request names, answering 400 when it names
+// none and 404 when the session has ended or belongs to someone else.
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request) *httpSession {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		http.Error(w, sessionHeader+" header is required", http.StatusBadRequest)
+		return nil
+	}
+	now := h.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sess, ok := h.sessions[id]
+	if !ok || sess.owner != owner(r.Context()) || now.Sub(sess.lastUsed) > h.idleTTL {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return nil
+	}
+	sess.lastUsed = now
+	return sess
+}
+
+// owner identifies the caller a session belongs to.
+func owner(ctx context.Context) string {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return principal.Key()
+}
+
+// checkOrigin rejects requests made by pages on other sites, which could
+// otherwise reach a server on localhost through DNS rebinding.
+func checkOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+	return false
+}
+
+// checkVersion rejects protocol revisions the server does not speak. The
+// header is absent before initialization and from older clients.
+func checkVersion(w http.ResponseWriter, r *http.Request) bool {
+	version := r.Header.Get(versionHeader)
+	if version == "" || slices.Contains(protocolVersions, version) {
+		return true
+	}
+	http.Error(w, "unsupported protocol version "+version, http.StatusBadRequest)
+	return false
+}
+
+// noReplies is the sender for notifications, which have no response to
+// carry requests to the client.
+func noReplies(message) error {
+	return errors.New("mcp: notifications cannot be answered")
+}
+
+// responseStream answers a request with plain JSON, switching to an event
+// stream the first time the server sends the client a request of its own.
+type responseStream struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	streaming bool
+	done      bool
+}
+
+func (s *responseStream) send(msg message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return errors.New("mcp: request already answered")
+	}
+	if !s.streaming {
+		s.w.Header().Set("Content-Type", eventStreamCT)
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.streaming = true
+	}
+	return s.event(msg)
+}
+
+func (s *responseStream) finish(ctx context.Context, response message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	if !s.streaming {
+		writeMessage(s.w, http.StatusOK, response)
+		return
+	}
+	if err := s.event(response); err != nil {
+		slog.ErrorContext(ctx, "failed to write response", "error", err)
+	}
+}
+
+func (s *responseStream) event(msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: message\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
+
+func writeMessage(w http.ResponseWriter, status int, msg message) {
+	w.Header().Set("Content-Type", jsonCT)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		slog.Error("failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,258 @@
+// Package mcp serves todos to AI agents over the Model Context Protocol,
+// https://modelcontextprotocol.io. Agents get tools to list, create,
+// complete and delete todos and can read lists as resources, over stdio
+// or the streamable HTTP transport.
+//
+// Tools run with the credentials of the connection, under the same scopes
+// and list roles as the REST API. Deleting asks the user to confirm, by
+// elicitation when the client supports it.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+
+	"todoapp/backend/internal/todo"
+)
+
+// protocolVersions are the protocol revisions the server speaks, newest
+// first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	// codeResourceNotFound is the code MCP uses for unknown resources.
+	codeResourceNotFound = -32002
+)
+
+type Server struct {
+	repo           todo.ReaderWriter
+	permissions    todo.Permissions
+	allowAnonymous bool
+	maxTitleLength int
+	version        string
+}
+
+type Option func(*Server)
+
+// WithPermissions enables shared lists. Without it only the default list
+// is reachable.
+func WithPermissions(p todo.Permissions) Option {
+	return func(s *Server) { s.permissions = p }
+}
+
+// WithAnonymous lets callers without credentials use the tools that change
+// todos, matching a server that does not require authentication.
+func WithAnonymous(allow bool) Option {
+	return func(s *Server) { s.allowAnonymous = allow }
+}
+
+func WithMaxTitleLength(n int) Option {
+	return func(s *Server) { s.maxTitleLength = n }
+}
+
+func NewServer(repo todo.ReaderWriter, opts ...Option) *Server {
+	s := &Server{
+		repo:           repo,
+		maxTitleLength: todo.DefaultMaxTitleLength,
+		version:        "1.0.0",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// message is a JSON-RPC 2.0 request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+func (m message) isRequest() bool      { return m.Method != "" && m.ID != nil }
+func (m message) isNotification() bool { return m.Method != "" && m.ID == nil }
+func (m message) isResponse() bool     { return m.Method == "" && m.ID != nil }
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("mcp: %s (%d)", e.Message, e.Code)
+}
+
+func errorf(code int, format string, args ...any) *rpcError {
+	return &rpcError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseMessage decodes one JSON-RPC message. Batches were dropped from the
+// protocol, so arrays are rejected.
+func parseMessage(data []byte) (message, *rpcError) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return message{}, errorf(codeParseError, "invalid JSON-RPC message")
+	}
+	if msg.JSONRPC != "2.0" || (msg.Method == "" && msg.ID == nil) {
+		return message{}, errorf(codeInvalidRequest, "invalid JSON-RPC message")
+	}
+	return msg, nil
+}
+
+// session is the state of one client connection: what the client can do,
+// and the requests the server has sent it that await a response.
+type session struct {
+	mu          sync.Mutex
+	version     string
+	elicitation bool
+	nextID      int64
+	pending     map[string]chan message
+}
+
+func newSession() *session {
+	return &session{version: protocolVersions[0], pending: make(map[string]chan message)}
+}
+
+// sender delivers a message from the server to the client, over whichever
+// connection the current request came in on.
+type sender func(message) error
+
+// call sends the client a request and waits for its response.
+func (s *session) call(ctx context.Context, send sender, method string, params any) (json.RawMessage, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.nextID++
+	id := json.RawMessage(strconv.Quote("server-" + strconv.FormatInt(s.nextID, 10)))
+	replies := make(chan message, 1)
+	s.pending[string(id)] = replies
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, string(id))
+		s.mu.Unlock()
+	}()
+
+	if err := send(message{JSONRPC: "2.0", ID: id, Method: method, Params: encoded}); err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-replies:
+		if reply.Error != nil {
+			return nil, reply.Error
+		}
+		return reply.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliver hands a client's response to the call waiting for it, reporting
+// whether there was one.
+func (s *session) deliver(reply message) bool {
+	s.mu.Lock()
+	replies, ok := s.pending[string(reply.ID)]
+	s.mu.Unlock()
+	if ok {
+		replies <- reply
+	}
+	return ok
+}
+
+// handle answers a request or notification from the client; the response
+// is nil for notifications. send carries requests the server makes of the
+// client while answering, such as elicitations.
+func (s *Server) handle(ctx context.Context, sess *session, send sender, msg message) *message {
+	result, err := s.dispatch(ctx, sess, send, msg)
+	if msg.isNotification() {
+		return nil
+	}
+	response := &message{JSONRPC: "2.0", ID: msg.ID}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			slog.ErrorContext(ctx, "mcp request failed", "method", msg.Method, "error", err)
+			rpcErr = errorf(codeInternalError, "internal error")
+		}
+		response.Error = rpcErr
+		return response
+	}
+	if response.Result, err = json.Marshal(result); err != nil {
+		response.Error = errorf(codeInternalError, "internal error")
+	}
+	return response
+}
+
+func (s *Server) dispatch(ctx context.Context, sess *session, send sender, msg message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(sess, msg.Params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		return s.callTool(ctx, sess, send, msg.Params)
+	case "resources/list":
+		return map[string]any{"resources": []resource{defaultListResource}}, nil
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": []resourceTemplate{sharedListTemplate}}, nil
+	case "resources/read":
+		return s.readResource(ctx, msg.Params)
+	}
+	if msg.isNotification() {
+		// notifications/initialized, notifications/cancelled and the like
+		// need no action.
+		return nil, nil
+	}
+	return nil, errorf(codeMethodNotFound, "method %q not found", msg.Method)
+}
+
+type initializeParams struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	Capabilities    struct {
+		Elicitation *struct{} `json:"elicitation"`
+	} `json:"capabilities"`
+}
+
+func (s *Server) initialize(sess *session, raw json.RawMessage) (any, error) {
+	var params initializeParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, errorf(codeInvalidParams, "invalid initialize params")
+	}
+	sess.mu.Lock()
+	if slices.Contains(protocolVersions, params.ProtocolVersion) {
+		sess.version = params.ProtocolVersion
+	}
+	sess.elicitation = params.Capabilities.Elicitation != nil
+	version := sess.version
+	sess.mu.Unlock()
+
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities": map[string]any{
+			"tools":     map[string]any{},
+			"resources": map[string]any{},
+		},
+		"serverInfo": map[string]any{"name": "todoapp", "version": s.version},
+		"instructions": "Manage the user's todos. Todos live on the default list unless a shared list_id is given. " +
+			"Deleting cannot be undone, so delete_todo asks the user to confirm.",
+	}, nil
+}
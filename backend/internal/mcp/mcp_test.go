@@ -0,0 +1,495 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+)
+
+const adminToken = "admin-token"
+
+type fixture struct {
+	server   *Server
+	repo     *todo.Repository
+	keys     *auth.KeyStore
+	sessions *auth.SessionStore
+	lists    *sharing.Store
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	f := &fixture{
+		repo:     todo.NewRepository(database),
+		keys:     auth.NewKeyStore(database),
+		sessions: auth.NewSessionStore(database, time.Hour),
+		lists:    sharing.NewStore(database),
+	}
+	f.server = NewServer(f.repo, WithPermissions(f.lists))
+	return f
+}
+
+// stdioClient drives ServeStdio over pipes.
+type stdioClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Scanner
+	nextID int
+}
+
+func (f *fixture) serveStdio(t *testing.T, ctx context.Context) *stdioClient {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- f.server.ServeStdio(ctx, inR, outW) }()
+	t.Cleanup(func() {
+		inW.Close()
+		cancel()
+		go io.Copy(io.Discard, outR)
+		if err := <-done; err != nil && err != context.Canceled {
+			t.Errorf("ServeStdio: %v", err)
+		}
+	})
+	return &stdioClient{t: t, in: inW, out: bufio.NewScanner(outR)}
+}
+
+func (c *stdioClient) send(v any) {
+	c.t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.in.Write(append(data, '\n')); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func (c *stdioClient) receive() message {
+	c.t.Helper()
+	if !c.out.Scan() {
+		c.t.Fatalf("read: %v", c.out.Err())
+	}
+	var msg message
+	if err := json.Unmarshal(c.out.Bytes(), &msg); err != nil {
+		c.t.Fatalf("decode %s: %v", c.out.Bytes(), err)
+	}
+	return msg
+}
+
+// call sends a request and returns the response to it.
+func (c *stdioClient) call(method string, params any) message {
+	c.t.Helper()
+	c.nextID++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	return c.receive()
+}
+
+func result[T any](t *testing.T, msg message) T {
+	t.Helper()
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+	var out T
+	if err := json.Unmarshal(msg.Result, &out); err != nil {
+		t.Fatalf("decode %s: %v", msg.Result, err)
+	}
+	return out
+}
+
+type callResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError"`
+}
+
+func initParams(elicitation bool) map[string]any {
+	capabilities := map[string]any{}
+	if elicitation {
+		capabilities["elicitation"] = map[string]any{}
+	}
+	return map[string]any{
+		"protocolVersion": "2025-06-18",
+		"capabilities":    capabilities,
+		"clientInfo":      map[string]any{"name": "test", "version": "1"},
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	f := newFixture(t)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Kind: auth.KindAdmin, Name: "admin", Scope: auth.ScopeReadWrite})
+	c := f.serveStdio(t, ctx)
+
+	initialized := result[struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}](t, c.call("initialize", initParams(true)))
+	if initialized.ProtocolVersion != "2025-06-18" || initialized.ServerInfo.Name != "todoapp" {
+		t.Fatalf("initialize = %+v", initialized)
+	}
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"})
+
+	listed := result[struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			InputSchema json.RawMessage `json:"inputSchema"`
+		} `json:"tools"`
+	}](t, c.call("tools/list", nil))
+	var names []string
+	for _, tool := range listed.Tools {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "list_todos,create_todo,complete_todo,delete_todo" {
+		t.Fatalf("tools = %s", got)
+	}
+
+	created := result[struct {
+		StructuredContent todo.Item `json:"structuredContent"`
+	}](t, c.call("tools/call", map[string]any{
+		"name":      "create_todo",
+		"arguments": map[string]any{"title": "Buy milk", "due": "2026-03-15", "priority": 2},
+	})).StructuredContent
+	if created.ID == 0 || created.Title != "Buy milk" || created.Due == nil || created.Priority != 2 {
+		t.Fatalf("created = %+v", created)
+	}
+
+	invalid := c.call("tools/call", map[string]any{"name": "create_todo", "arguments": map[string]any{"title": "x", "priority": 12}})
+	if invalid.Error == nil || invalid.Error.Code != codeInvalidParams {
+		t.Fatalf("priority 12: %+v", invalid)
+	}
+
+	completed := result[callResult](t, c.call("tools/call", map[string]any{"name": "complete_todo", "arguments": map[string]any{"id": created.ID}}))
+	if completed.IsError || !strings.Contains(completed.Content[0].Text, `"completed":true`) {
+		t.Fatalf("complete_todo = %+v", completed)
+	}
+
+	open := result[struct {
+		StructuredContent struct {
+			Todos []todo.Item `json:"todos"`
+		} `json:"structuredContent"`
+	}](t, c.call("tools/call", map[string]any{"name": "list_todos", "arguments": map[string]any{"completed": false}}))
+	if len(open.StructuredContent.Todos) != 0 {
+		t.Fatalf("open todos = %+v", open.StructuredContent.Todos)
+	}
+
+	read := result[struct {
+		Contents []resourceContents `json:"contents"`
+	}](t, c.call("resources/read", map[string]any{"uri": "todo://todos"}))
+	if len(read.Contents) != 1 || !strings.Contains(read.Contents[0].Text, "Buy milk") {
+		t.Fatalf("resources/read = %+v", read)
+	}
+	if missing := c.call("resources/read", map[string]any{"uri": "todo://lists/groceries/todos"}); missing.Error == nil || missing.Error.Code != codeResourceNotFound {
+		t.Fatalf("unknown resource: %+v", missing)
+	}
+
+	// Deleting asks the user first; declining keeps the todo.
+	c.nextID++
+	deleteID := c.nextID
+	deleteRequest := map[string]any{"jsonrpc": "2.0", "id": deleteID, "method": "tools/call",
+		"params": map[string]any{"name": "delete_todo", "arguments": map[string]any{"id": created.ID}}}
+	c.send(deleteRequest)
+	ask := c.receive()
+	if ask.Method != "elicitation/create" {
+		t.Fatalf("expected an elicitation, got %+v", ask)
+	}
+	c.send(map[string]any{"jsonrpc": "2.0", "id": ask.ID, "result": map[string]any{"action": "decline"}})
+	declined := result[callResult](t, c.receive())
+	if declined.IsError || !strings.Contains(declined.Content[0].Text, "not deleted") {
+		t.Fatalf("declined delete = %+v", declined)
+	}
+	if _, err := f.repo.Get(ctx, created.ID); err != nil {
+		t.Fatalf("todo gone after declining: %v", err)
+	}
+
+	c.nextID++
+	deleteRequest["id"] = c.nextID
+	c.send(deleteRequest)
+	ask = c.receive()
+	c.send(map[string]any{"jsonrpc": "2.0", "id": ask.ID, "result": map[string]any{"action": "accept", "content": map[string]any{"confirm": true}}})
+	deleted := result[callResult](t, c.receive())
+	if deleted.IsError || !strings.HasPrefix(deleted.Content[0].Text, "Deleted todo") {
+		t.Fatalf("delete = %+v", deleted)
+	}
+	if _, err := f.repo.Get(ctx, created.ID); err == nil {
+		t.Fatal("todo still exists")
+	}
+
+	if unknown := c.call("todos/purge", nil); unknown.Error == nil || unknown.Error.Code != codeMethodNotFound {
+		t.Fatalf("unknown method: %+v", unknown)
+	}
+}
+
+func TestServeStdio_WithoutElicitation(t *testing.T) {
+	f := newFixture(t)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Kind: auth.KindAdmin, Scope: auth.ScopeReadWrite})
+	c := f.serveStdio(t, ctx)
+	result[map[string]any](t, c.call("initialize", initParams(false)))
+
+	item, err := f.repo.Create(ctx, todo.Item{Title: "Old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := result[callResult](t, c.call("tools/call", map[string]any{"name": "delete_todo", "arguments": map[string]any{"id": item.ID}}))
+	if !refused.IsError || !strings.Contains(refused.Content[0].Text, "confirm: true") {
+		t.Fatalf("unconfirmed delete = %+v", refused)
+	}
+	confirmed := result[callResult](t, c.call("tools/call", map[string]any{"name": "delete_todo", "arguments": map[string]any{"id": item.ID, "confirm": true}}))
+	if confirmed.IsError {
+		t.Fatalf("confirmed delete = %+v", confirmed)
+	}
+}
+
+func TestTools_Access(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	alice, err := f.sessions.UpsertUser(ctx, "https://idp.example", "alice", "alice@example.com", "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := f.sessions.UpsertUser(ctx, "https://idp.example", "bob", "bob@example.com", "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := f.lists.CreateList(ctx, "Groceries", alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := f.lists.Invite(ctx, list.ID, "bob@example.com", sharing.RoleViewer, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.lists.Accept(ctx, token, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(principal *auth.Principal, name string, args map[string]any) callResult {
+		t.Helper()
+		ctx := context.Background()
+		if principal != nil {
+			ctx = auth.WithPrincipal(ctx, *principal)
+		}
+		params, _ := json.Marshal(map[string]any{"name": name, "arguments": args})
+		response := f.server.handle(ctx, newSession(), noReplies, message{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "tools/call", Params: params})
+		return result[callResult](t, *response)
+	}
+	asAlice := &auth.Principal{Kind: auth.KindUser, ID: alice.ID, Scope: auth.ScopeReadWrite}
+	asBob := &auth.Principal{Kind: auth.KindUser, ID: bob.ID, Scope: auth.ScopeReadWrite}
+	reader := &auth.Principal{Kind: auth.KindAPIKey, ID: 1, Scope: auth.ScopeRead}
+
+	if got := call(asAlice, "create_todo", map[string]any{"title": "Eggs", "list_id": list.ID}); got.IsError {
+		t.Fatalf("owner create = %+v", got)
+	}
+	if got := call(asBob, "list_todos", map[string]any{"list_id": list.ID}); got.IsError || !strings.Contains(got.Content[0].Text, "Eggs") {
+		t.Fatalf("viewer list = %+v", got)
+	}
+	if got := call(asBob, "create_todo", map[string]any{"title": "Ham", "list_id": list.ID}); !got.IsError || !strings.Contains(got.Content[0].Text, "viewers") {
+		t.Fatalf("viewer create = %+v", got)
+	}
+	if got := call(reader, "create_todo", map[string]any{"title": "Ham"}); !got.IsError || !strings.Contains(got.Content[0].Text, "scope") {
+		t.Fatalf("read-only key create = %+v", got)
+	}
+	if got := call(reader, "list_todos", map[string]any{"list_id": list.ID}); !got.IsError || !strings.Contains(got.Content[0].Text, "not found") {
+		t.Fatalf("key on shared list = %+v", got)
+	}
+	if got := call(nil, "create_todo", map[string]any{"title": "Ham"}); !got.IsError || !strings.Contains(got.Content[0].Text, "authentication") {
+		t.Fatalf("anonymous create = %+v", got)
+	}
+}
+
+// httpClient drives Handler behind the real authenticator.
+type httpClient struct {
+	t       *testing.T
+	url     string
+	token   string
+	session string
+}
+
+func (f *fixture) serveHTTP(t *testing.T) string {
+	t.Helper()
+	handler := NewHandler(f.server, 1<<20)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /mcp", handler.Post)
+	mux.HandleFunc("DELETE /mcp", handler.Delete)
+	server := httptest.NewServer(auth.NewAuthenticator(f.keys, f.sessions, adminToken).Middleware(mux))
+	t.Cleanup(server.Close)
+	return server.URL + "/mcp"
+}
+
+func (c *httpClient) do(method string, body any, header map[string]string) *http.Response {
+	c.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.url, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.session != "" {
+		req.Header.Set(sessionHeader, c.session)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	c.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (c *httpClient) initialize(elicitation bool) {
+	c.t.Helper()
+	resp := c.do(http.MethodPost, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": initParams(elicitation)}, nil)
+	if resp.StatusCode != http.StatusOK {
+		c.t.Fatalf("initialize status = %d", resp.StatusCode)
+	}
+	c.session = resp.Header.Get(sessionHeader)
+	if c.session == "" {
+		c.t.Fatal("initialize returned no session")
+	}
+}
+
+func decodeMessage(t *testing.T, r io.Reader) message {
+	t.Helper()
+	var msg message
+	if err := json.NewDecoder(r).Decode(&msg); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return msg
+}
+
+// nextEvent reads the data of the next server-sent event.
+func nextEvent(t *testing.T, r *bufio.Reader) message {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			return decodeMessage(t, strings.NewReader(data))
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	f := newFixture(t)
+	url := f.serveHTTP(t)
+	admin := &httpClient{t: t, url: url, token: adminToken}
+	admin.initialize(true)
+
+	resp := admin.do(http.MethodPost, map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"}, map[string]string{versionHeader: "2025-06-18"})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("notification status = %d", resp.StatusCode)
+	}
+
+	resp = admin.do(http.MethodPost, map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+		"params": map[string]any{"name": "create_todo", "arguments": map[string]any{"title": "Buy milk"}}}, nil)
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "application/json" {
+		t.Fatalf("create status = %d, content type %q", resp.StatusCode, ct)
+	}
+	created := result[struct {
+		StructuredContent todo.Item `json:"structuredContent"`
+	}](t, decodeMessage(t, resp.Body)).StructuredContent
+
+	// The confirmation is asked over an event stream on the tool call, and
+	// answered with a POST of its own.
+	resp = admin.do(http.MethodPost, map[string]any{"jsonrpc": "2.0", "id": 3, "method": "tools/call",
+		"params": map[string]any{"name": "delete_todo", "arguments": map[string]any{"id": created.ID}}}, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("delete content type = %q", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+	ask := nextEvent(t, events)
+	if ask.Method != "elicitation/create" {
+		t.Fatalf("expected an elicitation, got %+v", ask)
+	}
+	answer := admin.do(http.MethodPost, map[string]any{"jsonrpc": "2.0", "id": ask.ID,
+		"result": map[string]any{"action": "accept", "content": map[string]any{"confirm": true}}}, nil)
+	if answer.StatusCode != http.StatusAccepted {
+		t.Fatalf("answer status = %d", answer.StatusCode)
+	}
+	deleted := result[callResult](t, nextEvent(t, events))
+	if deleted.IsError || !strings.HasPrefix(deleted.Content[0].Text, "Deleted todo") {
+		t.Fatalf("delete = %+v", deleted)
+	}
+
+	// Sessions belong to whoever started them.
+	_, keyToken, err := f.keys.Create(context.Background(), "agent", auth.ScopeReadWrite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &httpClient{t: t, url: url, token: keyToken, session: admin.session}
+	if resp := other.do(http.MethodPost, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "ping"}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("foreign session status = %d", resp.StatusCode)
+	}
+
+	if resp := admin.do(http.MethodDelete, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete session status = %d", resp.StatusCode)
+	}
+	if resp := admin.do(http.MethodPost, map[string]any{"jsonrpc": "2.0", "id": 4, "method": "ping"}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("ended session status = %d", resp.StatusCode)
+	}
+}
+
+func TestHandler_Rejects(t *testing.T) {
+	f := newFixture(t)
+	url := f.serveHTTP(t)
+	admin := &httpClient{t: t, url: url, token: adminToken}
+	ping := map[string]any{"jsonrpc": "2.0", "id": 1, "method": "ping"}
+
+	tests := []struct {
+		name   string
+		body   any
+		header map[string]string
+		status int
+	}{
+		{name: "no session", body: ping, status: http.StatusBadRequest},
+		{name: "unknown session", body: ping, header: map[string]string{sessionHeader: "nope"}, status: http.StatusNotFound},
+		{name: "cross origin", body: ping, header: map[string]string{"Origin": "https://evil.example"}, status: http.StatusForbidden},
+		{name: "protocol version", body: ping, header: map[string]string{versionHeader: "2023-01-01"}, status: http.StatusBadRequest},
+		{name: "content type", body: ping, header: map[string]string{"Content-Type": "text/plain"}, status: http.StatusUnsupportedMediaType},
+		{name: "not JSON-RPC", body: map[string]any{"hello": "world"}, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin.t = t
+			if resp := admin.do(http.MethodPost, tt.body, tt.header); resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+type resourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+const (
+	defaultListURI   = "todo://todos"
+	sharedListPrefix = "todo://lists/"
+	sharedListSuffix = "/todos"
+)
+
+var defaultListResource = resource{
+	URI:         defaultListURI,
+	Name:        "todos",
+	Title:       "Todos",
+	Description: "The todos on the default list, as the JSON array GET /api/todos returns.",
+	MimeType:    "application/json",
+}
+
+var sharedListTemplate = resourceTemplate{
+	URITemplate: sharedListPrefix + "{list_id}" + sharedListSuffix,
+	Name:        "list-todos",
+	Title:       "Shared list todos",
+	Description: "The todos on a shared list, as the JSON array GET /api/todos?list= returns.",
+	MimeType:    "application/json",
+}
+
+type readParams struct {
+	URI string `json:"uri"`
+}
+
+type resourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+func (s *Server) readResource(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params readParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, errorf(codeInvalidParams, "invalid resources/read params")
+	}
+	listID, ok := parseListURI(params.URI)
+	if !ok {
+		return nil, errorf(codeResourceNotFound, "resource %q not found", params.URI)
+	}
+	items, err := s.listItems(ctx, listID)
+	if err != nil {
+		var denied errDenied
+		if errors.As(err, &denied) {
+			return nil, errorf(codeResourceNotFound, "resource %q: %s", params.URI, denied.message)
+		}
+		return nil, err
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"contents": []resourceContents{{URI: params.URI, MimeType: "application/json", Text: string(data)}}}, nil
+}
+
+// parseListURI returns the list a resource URI names, nil for the default
+// list.
+func parseListURI(uri string) (*int64, bool) {
+	if uri == defaultListURI {
+		return nil, true
+	}
+	raw, ok := strings.CutPrefix(uri, sharedListPrefix)
+	if !ok {
+		return nil, false
+	}
+	raw, ok = strings.CutSuffix(raw, sharedListSuffix)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, false
+	}
+	return &id, true
+}
@@ -0,0 +1,92 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ServeStdio speaks the stdio transport: newline-delimited JSON-RPC
+// messages on in and out, one client for the life of the process. Messages
+// are answered in order, except that tool calls run alongside the rest so
+// that one waiting for the user to confirm does not hold up the session.
+// It returns when in ends or ctx is done.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	var mu sync.Mutex
+	send := func(msg message) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, err = out.Write(append(data, '\n'))
+		return err
+	}
+
+	sess := newSession()
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+		for scanner.Scan() {
+			select {
+			case lines <- append([]byte(nil), scanner.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	for {
+		var line []byte
+		select {
+		case line = <-lines:
+		case err := <-readErr:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		msg, rpcErr := parseMessage(line)
+		switch {
+		case rpcErr != nil:
+			if err := send(message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: rpcErr}); err != nil {
+				return err
+			}
+		case msg.isResponse():
+			sess.deliver(msg)
+		case msg.Method != "tools/call":
+			if response := s.handle(ctx, sess, send, msg); response != nil {
+				if err := send(*response); err != nil {
+					return err
+				}
+			}
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if response := s.handle(ctx, sess, send, msg); response != nil {
+					if err := send(*response); err != nil && !errors.Is(err, context.Canceled) {
+						cancel()
+					}
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/openapi"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+)
+
+type tool struct {
+	Name        string          `json:"name"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	Annotations toolAnnotations `json:"annotations"`
+
+	schema *openapi.Schema
+	call   func(s *Server, ctx context.Context, sess *session, send sender, args json.RawMessage) (toolResult, error)
+}
+
+// toolAnnotations tell clients how careful to be with a tool, for example
+// whether to ask the user before running it.
+type toolAnnotations struct {
+	ReadOnlyHint    bool `json:"readOnlyHint"`
+	DestructiveHint bool `json:"destructiveHint"`
+	IdempotentHint  bool `json:"idempotentHint"`
+	OpenWorldHint   bool `json:"openWorldHint"`
+}
+
+type toolResult struct {
+	Content           []textContent `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func textResult(format string, args ...any) toolResult {
+	return toolResult{Content: []textContent{{Type: "text", Text: fmt.Sprintf(format, args...)}}}
+}
+
+// toolError is a failure the agent should see and can act on, such as a
+// missing todo, as opposed to a malformed call.
+func toolError(format string, args ...any) toolResult {
+	result := textResult(format, args...)
+	result.IsError = true
+	return result
+}
+
+// jsonResult returns v both as structured content and as its JSON text,
+// for clients that only read text.
+func jsonResult(v any) (toolResult, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return toolResult{}, err
+	}
+	return toolResult{Content: []textContent{{Type: "text", Text: string(data)}}, StructuredContent: v}, nil
+}
+
+var tools = []*tool{
+	{
+		Name:        "list_todos",
+		Title:       "List todos",
+		Description: "List the todos on the default list or a shared list, oldest first, optionally only open or completed ones or those matching a search.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"list_id": {"type": "integer", "description": "A shared list; the default list when left out."},
+				"completed": {"type": "boolean", "description": "Only completed todos when true, only open ones when false."},
+				"search": {"type": "string", "description": "Only todos whose title or notes contain this, ignoring case."}
+			}
+		}`),
+		Annotations: toolAnnotations{ReadOnlyHint: true, IdempotentHint: true},
+		call:        (*Server).listTodos,
+	},
+	{
+		Name:        "create_todo",
+		Title:       "Create a todo",
+		Description: "Add a todo to the default list or a shared list.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"additionalProperties": false,
+			"required": ["title"],
+			"properties": {
+				"title": {"type": "string", "minLength": 1},
+				"notes": {"type": "string", "description": "Markdown."},
+				"list_id": {"type": "integer", "description": "A shared list; the default list when left out."},
+				"due": {"type": "string", "description": "A date (2026-03-15) or an RFC 3339 time.", "anyOf": [{"format": "date"}, {"format": "date-time"}]},
+				"priority": {"type": "integer", "minimum": 0, "maximum": 9, "description": "1 is highest, 9 lowest, 0 none."}
+			}
+		}`),
+		call: (*Server).createTodo,
+	},
+	{
+		Name:        "complete_todo",
+		Title:       "Complete a todo",
+		Description: "Mark a todo as completed, or as open again with completed: false.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"additionalProperties": false,
+			"required": ["id"],
+			"properties": {
+				"id": {"type": "integer"},
+				"completed": {"type": "boolean", "default": true}
+			}
+		}`),
+		Annotations: toolAnnotations{IdempotentHint: true},
+		call:        (*Server).completeTodo,
+	},
+	{
+		Name:  "delete_todo",
+		Title: "Delete a todo",
+		Description: "Delete a todo for good. The user is asked to confirm; clients that cannot ask must get the user's " +
+			"consent themselves and pass confirm: true.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"additionalProperties": false,
+			"required": ["id"],
+			"properties": {
+				"id": {"type": "integer"},
+				"confirm": {"type": "boolean", "description": "The user has agreed to the deletion."}
+			}
+		}`),
+		Annotations: toolAnnotations{DestructiveHint: true, IdempotentHint: true},
+		call:        (*Server).deleteTodo,
+	},
+}
+
+func init() {
+	for _, t := range tools {
+		schema, err := openapi.CompileSchema(t.InputSchema)
+		if err != nil {
+			panic(fmt.Sprintf("mcp: input schema of %s: %v", t.Name, err))
+		}
+		t.schema = schema
+		// Compact the schema once so that tools/list does not repeat the
+		// indentation above.
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, t.InputSchema); err != nil {
+			panic(err)
+		}
+		t.InputSchema = compact.Bytes()
+	}
+}
+
+type callParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+func (s *Server) callTool(ctx context.Context, sess *session, send sender, raw json.RawMessage) (any, error) {
+	var params callParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, errorf(codeInvalidParams, "invalid tools/call params")
+	}
+	var t *tool
+	for _, candidate := range tools {
+		if candidate.Name == params.Name {
+			t = candidate
+		}
+	}
+	if t == nil {
+		return nil, errorf(codeInvalidParams, "unknown tool %q", params.Name)
+	}
+
+	args := params.Arguments
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage(`{}`)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(args))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errorf(codeInvalidParams, "arguments must be a JSON object")
+	}
+	if err := t.schema.Validate(value); err != nil {
+		return nil, errorf(codeInvalidParams, "invalid arguments for %s: %v", t.Name, err)
+	}
+	return t.call(s, ctx, sess, send, args)
+}
+
+// errDenied reports a tool call the caller's credentials do not allow.
+type errDenied struct{ message string }
+
+func (e errDenied) Error() string { return e.message }
+
+// deniedResult turns an access error into a tool error the agent can
+// explain to the user, and passes other errors on.
+func deniedResult(err error) (toolResult, error) {
+	var denied errDenied
+	if errors.As(err, &denied) {
+		return toolError("%s", denied.message), nil
+	}
+	return toolResult{}, err
+}
+
+type listTodosArgs struct {
+	ListID    *int64  `json:"list_id"`
+	Completed *bool   `json:"completed"`
+	Search    *string `json:"search"`
+}
+
+func (s *Server) listTodos(ctx context.Context, _ *session, _ sender, raw json.RawMessage) (toolResult, error) {
+	var args listTodosArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return toolResult{}, errorf(codeInvalidParams, "invalid arguments: %v", err)
+	}
+	items, err := s.listItems(ctx, args.ListID)
+	if err != nil {
+		return deniedResult(err)
+	}
+	matching := []todo.Item{}
+	for _, item := range items {
+		if args.Completed != nil && item.Completed != *args.Completed {
+			continue
+		}
+		if args.Search != nil {
+			search := strings.ToLower(*args.Search)
+			if !strings.Contains(strings.ToLower(item.Title), search) && !strings.Contains(strings.ToLower(item.Notes), search) {
+				continue
+			}
+		}
+		matching = append(matching, item)
+	}
+	return jsonResult(map[string]any{"todos": matching})
+}
+
+type createTodoArgs struct {
+	Title    string        `json:"title"`
+	Notes    string        `json:"notes"`
+	ListID   *int64        `json:"list_id"`
+	Due      *todo.DueTime `json:"due"`
+	Priority int           `json:"priority"`
+}
+
+func (s *Server) createTodo(ctx context.Context, _ *session, _ sender, raw json.RawMessage) (toolResult, error) {
+	var args createTodoArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return toolError("%v", err), nil
+	}
+	if err := s.canWrite(ctx); err != nil {
+		return deniedResult(err)
+	}
+	title := strings.TrimSpace(args.Title)
+	switch {
+	case title == "":
+		return toolError("title is required"), nil
+	case utf8.RuneCountInString(title) > s.maxTitleLength:
+		return toolError("title must be at most %d characters", s.maxTitleLength), nil
+	}
+	if err := todo.CheckNotes(args.Notes); err != nil {
+		return toolError("%v", err), nil
+	}
+	if err := todo.CheckPriority(args.Priority); err != nil {
+		return toolError("%v", err), nil
+	}
+	if err := s.listAccess(ctx, args.ListID, true, "todo list not found"); err != nil {
+		return deniedResult(err)
+	}
+
+	item := todo.Item{Title: title, Notes: args.Notes, ListID: args.ListID, Priority: args.Priority}
+	if args.Due != nil {
+		due := time.Time(*args.Due)
+		item.Due = &due
+	}
+	created, err := s.repo.Create(ctx, item)
+	if err != nil {
+		return toolResult{}, fmt.Errorf("create todo: %w", err)
+	}
+	return jsonResult(created)
+}
+
+type completeTodoArgs struct {
+	ID        int64 `json:"id"`
+	Completed *bool `json:"completed"`
+}
+
+func (s *Server) completeTodo(ctx context.Context, _ *session, _ sender, raw json.RawMessage) (toolResult, error) {
+	var args completeTodoArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return toolResult{}, errorf(codeInvalidParams, "invalid arguments: %v", err)
+	}
+	if err := s.canWrite(ctx); err != nil {
+		return deniedResult(err)
+	}
+	if _, err := s.loadTodo(ctx, args.ID, true); err != nil {
+		return deniedResult(err)
+	}
+	completed := args.Completed == nil || *args.Completed
+	item, err := s.repo.UpdateCompleted(ctx, args.ID, completed, auth.UserID(ctx))
+	if errors.Is(err, todo.ErrNotFound) {
+		return toolError("todo not found"), nil
+	}
+	if err != nil {
+		return toolResult{}, fmt.Errorf("complete todo: %w", err)
+	}
+	return jsonResult(item)
+}
+
+type deleteTodoArgs struct {
+	ID      int64 `json:"id"`
+	Confirm bool  `json:"confirm"`
+}
+
+// elicitResult is the client's answer to elicitation/create.
+type elicitResult struct {
+	Action  string `json:"action"`
+	Content struct {
+		Confirm bool `json:"confirm"`
+	} `json:"content"`
+}
+
+func (s *Server) deleteTodo(ctx context.Context, sess *session, send sender, raw json.RawMessage) (toolResult, error) {
+	var args deleteTodoArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return toolResult{}, errorf(codeInvalidParams, "invalid arguments: %v", err)
+	}
+	if err := s.canWrite(ctx); err != nil {
+		return deniedResult(err)
+	}
+	item, err := s.loadTodo(ctx, args.ID, true)
+	if err != nil {
+		return deniedResult(err)
+	}
+
+	sess.mu.Lock()
+	canAsk := sess.elicitation
+	sess.mu.Unlock()
+	switch {
+	case args.Confirm:
+	case canAsk:
+		reply, err := sess.call(ctx, send, "elicitation/create", map[string]any{
+			"message": fmt.Sprintf("Delete the todo %q? This cannot be undone.", item.Title),
+			"requestedSchema": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"confirm": map[string]any{"type": "boolean", "title": "Delete"}},
+				"required":   []string{"confirm"},
+			},
+		})
+		if err != nil {
+			return toolResult{}, fmt.Errorf("ask to confirm deletion: %w", err)
+		}
+		var answer elicitResult
+		if err := json.Unmarshal(reply, &answer); err != nil {
+			return toolResult{}, errorf(codeInvalidParams, "invalid elicitation result")
+		}
+		if answer.Action != "accept" || !answer.Content.Confirm {
+			return textResult("The user did not confirm; todo %d was not deleted.", item.ID), nil
+		}
+	default:
+		return toolError("Deleting todo %d (%q) cannot be undone. Ask the user to confirm, then call delete_todo again with confirm: true.", item.ID, item.Title), nil
+	}
+
+	if err := s.repo.Delete(ctx, item.ID); err != nil {
+		if errors.Is(err, todo.ErrNotFound) {
+			return toolError("todo not found"), nil
+		}
+		return toolResult{}, fmt.Errorf("delete todo: %w", err)
+	}
+	return textResult("Deleted todo %d (%q).", item.ID, item.Title), nil
+}
+
+// listItems returns the todos on listID if the caller may see them.
+func (s *Server) listItems(ctx context.Context, listID *int64) ([]todo.Item, error) {
+	if err := s.listAccess(ctx, listID, false, "todo list not found"); err != nil {
+		return nil, err
+	}
+	items, err := s.repo.List(ctx, listID)
+	if err != nil {
+		return nil, fmt.Errorf("list todos: %w", err)
+	}
+	return items, nil
+}
+
+// loadTodo returns the todo with id if the caller may see it, or modify it
+// when write is set.
+func (s *Server) loadTodo(ctx context.Context, id int64, write bool) (todo.Item, error) {
+	item, err := s.repo.Get(ctx, id)
+	if errors.Is(err, todo.ErrNotFound) {
+		return todo.Item{}, errDenied{"todo not found"}
+	}
+	if err != nil {
+		return todo.Item{}, fmt.Errorf("get todo: %w", err)
+	}
+	if err := s.listAccess(ctx, item.ListID, write, "todo not found"); err != nil {
+		return todo.Item{}, err
+	}
+	return item, nil
+}
+
+// listAccess enforces list roles as the REST handlers do: lists the caller
+// does not belong to are not found, and viewers may not modify them.
+func (s *Server) listAccess(ctx context.Context, listID *int64, write bool, notFound string) error {
+	if listID == nil {
+		return nil
+	}
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	switch {
+	case !ok:
+		return errDenied{"authentication required"}
+	case principal.Kind == auth.KindAdmin:
+		return nil
+	case principal.Kind != auth.KindUser || s.permissions == nil:
+		return errDenied{notFound}
+	}
+
+	role, err := s.permissions.Role(ctx, *listID, principal.ID)
+	if errors.Is(err, sharing.ErrNotFound) {
+		return errDenied{notFound}
+	}
+	if err != nil {
+		return fmt.Errorf("check list access: %w", err)
+	}
+	if write && !role.CanEdit() {
+		return errDenied{"viewers cannot modify this list"}
+	}
+	return nil
+}
+
+// canWrite turns auth.CheckWrite's errors into tool errors.
+func (s *Server) canWrite(ctx context.Context) error {
+	if err := auth.CheckWrite(ctx, s.allowAnonymous); err != nil {
+		return errDenied{err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/oidc"
+	"todoapp/backend/internal/oidc/oidctest"
+)
+
+func newProvider(idp *oidctest.Server) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Issuer:      idp.Issuer(),
+		ClientID:    idp.ClientID,
+		RedirectURL: "http://app.test/auth/callback",
+	})
+}
+
+// authorize follows the authorization request and returns the query the
+// provider redirected back with.
+func authorize(t *testing.T, idp *oidctest.Server, authURL string) url.Values {
+	t.Helper()
+
+	client := idp.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("authorize: expected redirect, got status %d", resp.StatusCode)
+	}
+	return location.Query()
+}
+
+func TestVerify(t *testing.T) {
+	idp := oidctest.NewServer(t, "todo-app")
+	provider := newProvider(idp)
+	ctx := context.Background()
+
+	claims, err := provider.Verify(ctx, idp.SignIDToken(map[string]any{"sub": "u1", "nonce": "n1", "email": "a@example.com"}), "n1")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.String("sub") != "u1" || claims.String("email") != "a@example.com" {
+		t.Fatalf("unexpected claims: %#v", claims)
+	}
+
+	cases := map[string]string{
+		"wrong nonce":    idp.SignIDToken(map[string]any{"sub": "u1", "nonce": "other"}),
+		"wrong audience": idp.SignIDToken(map[string]any{"sub": "u1", "nonce": "n1", "aud": "someone-else"}),
+		"wrong issuer":   idp.SignIDToken(map[string]any{"sub": "u1", "nonce": "n1", "iss": "https://evil.example.com"}),
+		"expired":        idp.SignIDToken(map[string]any{"sub": "u1", "nonce": "n1", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"missing sub":    idp.SignIDToken(map[string]any{"nonce": "n1"}),
+		"malformed":      "not-a-jwt",
+	}
+	for name, token := range cases {
+		if _, err := provider.Verify(ctx, token, "n1"); !errors.Is(err, oidc.ErrInvalidToken) {
+			t.Fatalf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestVerify_RejectsTamperedToken(t *testing.T) {
+	idp := oidctest.NewServer(t, "todo-app")
+	provider := newProvider(idp)
+
+	token := idp.SignIDToken(map[string]any{"sub": "u1", "nonce": "n1"})
+	forged := oidctest.NewServer(t, "todo-app").SignIDToken(map[string]any{"sub": "admin", "nonce": "n1", "iss": idp.Issuer()})
+	parts := strings.Split(token, ".")
+	forgedParts := strings.Split(forged, ".")
+
+	tampered := parts[0] + "." + forgedParts[1] + "." + parts[2]
+	if _, err := provider.Verify(context.Background(), tampered, "n1"); !errors.Is(err, oidc.ErrInvalidToken) {
+		t.Fatalf("expected tampered claims to be rejected, got %v", err)
+	}
+	if _, err := provider.Verify(context.Background(), forged, "n1"); !errors.Is(err, oidc.ErrInvalidToken) {
+		t.Fatalf("expected token signed by another key to be rejected, got %v", err)
+	}
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	idp := oidctest.NewServer(t, "todo-app")
+	provider := newProvider(idp)
+	ctx := context.Background()
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatalf("pkce: %v", err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("scope") != "openid profile email" {
+		t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+
+	callback := authorize(t, idp, authURL)
+	if callback.Get("state") != "state-1" || callback.Get("code") == "" {
+		t.Fatalf("unexpected callback: %v", callback)
+	}
+
+	if _, err := provider.Exchange(ctx, callback.Get("code"), "wrong-verifier"); err == nil {
+		t.Fatalf("expected exchange with wrong verifier to fail")
+	}
+
+	tokens, err := provider.Exchange(ctx, authorize(t, idp, authURL).Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	claims, err := provider.Verify(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.String("sub") != "user-1" {
+		t.Fatalf("unexpected subject %q", claims.String("sub"))
+	}
+
+	logoutURL, err := provider.EndSessionURL(ctx, tokens.IDToken, "http://app.test/")
+	if err != nil || !strings.HasPrefix(logoutURL, idp.URL+"/logout?") {
+		t.Fatalf("unexpected end session url %q: %v", logoutURL, err)
+	}
+}
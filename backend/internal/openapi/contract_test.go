@@ -0,0 +1,650 @@
+package openapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/blob"
+	"todoapp/backend/internal/caldav"
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/digest"
+	"todoapp/backend/internal/mcp"
+	"todoapp/backend/internal/oidc"
+	"todoapp/backend/internal/oidc/oidctest"
+	"todoapp/backend/internal/openapi"
+	"todoapp/backend/internal/reminder"
+	"todoapp/backend/internal/routes"
+	"todoapp/backend/internal/rpc"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+	"todoapp/backend/internal/webhook"
+)
+
+const adminToken = "admin-token"
+
+// contract serves the real handlers and checks every request sent to them,
+// and every response they give, against the document.
+type contract struct {
+	t        *testing.T
+	doc      *openapi.Document
+	app      *httptest.Server
+	idp      *oidctest.Server
+	sessions *auth.SessionStore
+	keys     *auth.KeyStore
+	fire     func(context.Context) ([]reminder.Notification, error)
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newContract(t *testing.T) *contract {
+	t.Helper()
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("load openapi document: %v", err)
+	}
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	c := &contract{t: t, doc: doc, idp: oidctest.NewServer(t, "todo-app"), seen: map[string]bool{}}
+	mux := http.NewServeMux()
+	c.keys = auth.NewKeyStore(database)
+	c.sessions = auth.NewSessionStore(database, time.Hour)
+	c.app = httptest.NewServer(auth.NewAuthenticator(c.keys, c.sessions, adminToken).Middleware(mux))
+	t.Cleanup(c.app.Close)
+
+	webhooks := webhook.NewStore(database)
+	repo := todo.NewRepository(database, todo.WithBlobStore(blob.NewMemory()), todo.WithEvents(webhooks))
+	lists := sharing.NewStore(database)
+	feedTokens := auth.NewFeedTokenStore(database)
+	reminders := reminder.NewStore(database)
+	c.fire = reminder.NewScheduler(reminders).FireDue
+	handler := todo.NewHandler(repo,
+		todo.WithPermissions(lists),
+		todo.WithComments(repo),
+		todo.WithAttachments(repo, 1<<20, 1<<22),
+		todo.WithReminders(repo),
+		todo.WithImportExport(repo),
+		todo.WithCalendar(repo, feedTokens),
+		todo.WithTaskImport(repo),
+		todo.WithSync(repo),
+		todo.WithGraphQL(lists, false),
+	)
+	// The same table as cmd/server, with every optional group enabled.
+	provider := oidc.NewProvider(oidc.Config{Issuer: c.idp.Issuer(), ClientID: "todo-app", RedirectURL: c.app.URL + "/auth/callback"})
+	routes.Register(mux, routes.Handlers{
+		Todos: handler,
+		Connect: rpc.NewConnectHandler(rpc.NewService(repo,
+			rpc.WithPermissions(lists),
+			rpc.WithWatch(repo),
+			rpc.WithPollInterval(10*time.Millisecond),
+		), 1<<20),
+		MCP:           mcp.NewHandler(mcp.NewServer(repo, mcp.WithPermissions(lists)), 1<<20),
+		CalDAV:        caldav.NewHandler(repo, caldav.WithLists(lists)),
+		Notifications: reminder.NewHandler(reminders),
+		Spec:          openapi.NewHandler(doc),
+		Keys:          auth.NewKeyHandler(c.keys),
+		Webhooks:      webhook.NewHandler(webhooks),
+		Login:         auth.NewLoginHandler(provider, c.sessions, auth.LoginOptions{Issuer: c.idp.Issuer()}),
+		Lists:         sharing.NewHandler(lists),
+		FeedTokens:    auth.NewFeedTokenHandler(feedTokens),
+		Digest:        digest.NewHandler(digest.NewStore(database), digest.NewBuilder(repo, lists)),
+	})
+	return c
+}
+
+// RoundTrip checks requests to the app, and the responses to them, against
+// the document. Requests elsewhere, to the identity provider, pass through.
+func (c *contract) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != strings.TrimPrefix(c.app.URL, "http://") {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	route, err := c.doc.Route(req)
+	if err != nil {
+		c.t.Errorf("%v", err)
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	c.mu.Lock()
+	c.seen[route] = true
+	c.mu.Unlock()
+	if req.Context().Value(invalidRequest{}) == nil {
+		if err := c.doc.ValidateRequest(req); err != nil {
+			c.t.Errorf("%v", err)
+		}
+	}
+
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if err := c.doc.ValidateResponse(req, res.StatusCode, res.Header, body); err != nil {
+		c.t.Errorf("%v", err)
+	}
+	return res, nil
+}
+
+// client returns a client with its own cookies that sends token, if any,
+// as a bearer token. It does not follow redirects, so each one is checked.
+func (c *contract) client(token string) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		c.t.Fatalf("cookie jar: %v", err)
+	}
+	var transport http.RoundTripper = c
+	if token != "" {
+		transport = bearer{token: token, next: c}
+	}
+	return &http.Client{
+		Transport:     transport,
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+type bearer struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (b bearer) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.next.RoundTrip(req)
+}
+
+// withHeader sends a header with every request of client, sharing its
+// credentials and cookies.
+func withHeader(client *http.Client, name, value string) *http.Client {
+	out := *client
+	out.Transport = headerTransport{name: name, value: value, next: client.Transport}
+	return &out
+}
+
+type headerTransport struct {
+	name, value string
+	next        http.RoundTripper
+}
+
+func (h headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(h.name, h.value)
+	return h.next.RoundTrip(req)
+}
+
+// invalidRequest marks a request that deliberately breaks the document,
+// to check the error response; the request itself is not validated.
+type invalidRequest struct{}
+
+// do sends body with contentType and fails the test unless the response
+// has status want. It returns the response body.
+func (c *contract) do(client *http.Client, method string, path string, want int, contentType string, body string) ([]byte, http.Header) {
+	c.t.Helper()
+	return c.send(context.Background(), client, method, path, want, contentType, body)
+}
+
+// reject is do for a request that the document does not allow.
+func (c *contract) reject(client *http.Client, method string, path string, want int, contentType string, body string) {
+	c.t.Helper()
+	c.send(context.WithValue(context.Background(), invalidRequest{}, true), client, method, path, want, contentType, body)
+}
+
+func (c *contract) send(ctx context.Context, client *http.Client, method string, path string, want int, contentType string, body string) ([]byte, http.Header) {
+	c.t.Helper()
+	target := path
+	if strings.HasPrefix(path, "/") {
+		target = c.app.URL + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != want {
+		c.t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, want, res.StatusCode, data)
+	}
+	return data, res.Header
+}
+
+func (c *contract) json(client *http.Client, method string, path string, want int, body any, target any) {
+	c.t.Helper()
+	var payload string
+	contentType := ""
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("encode body: %v", err)
+		}
+		payload, contentType = string(encoded), "application/json"
+	}
+	data, _ := c.do(client, method, path, want, contentType, payload)
+	if target != nil {
+		if err := json.Unmarshal(data, target); err != nil {
+			c.t.Fatalf("%s %s: decode %s: %v", method, path, data, err)
+		}
+	}
+}
+
+// login signs in through the identity provider and returns a client that
+// carries the session cookie.
+func (c *contract) login(subject string, email string, name string) *http.Client {
+	c.t.Helper()
+	c.idp.User = map[string]any{"sub": subject, "email": email, "name": name}
+	client := c.client("")
+	_, header := c.do(client, http.MethodGet, "/auth/login?return_to=/auth/me", http.StatusFound, "", "")
+	_, header = c.do(client, http.MethodGet, header.Get("Location"), http.StatusFound, "", "")
+	_, header = c.do(client, http.MethodGet, header.Get("Location"), http.StatusFound, "", "")
+	if location := header.Get("Location"); location != "/auth/me" {
+		c.t.Fatalf("expected login to return to /auth/me, got %q", location)
+	}
+	return client
+}
+
+func TestHandlersMatchOpenAPIDocument(t *testing.T) {
+	c := newContract(t)
+	ctx := context.Background()
+	anonymous := c.client("")
+	admin := c.client(adminToken)
+	alice := c.login("alice", "alice@example.com", "Alice")
+	path := func(format string, args ...any) string { return fmt.Sprintf(format, args...) }
+
+	c.do(anonymous, http.MethodGet, "/api/openapi.json", http.StatusOK, "", "")
+	c.do(anonymous, http.MethodGet, "/api/docs", http.StatusOK, "", "")
+	c.do(anonymous, http.MethodGet, "/.well-known/caldav", http.StatusMovedPermanently, "", "")
+	c.do(anonymous, http.MethodPost, "/api/todos", http.StatusUnauthorized, "application/json", `{"title":"x"}`)
+
+	var me auth.User
+	c.json(alice, http.MethodGet, "/auth/me", http.StatusOK, nil, &me)
+
+	// Todos.
+	var list sharing.List
+	c.json(alice, http.MethodPost, "/api/lists", http.StatusCreated, map[string]any{"name": "Household"}, &list)
+	var item, shared, overdue todo.Item
+	c.json(alice, http.MethodPost, "/api/todos", http.StatusCreated,
+		map[string]any{"title": "File taxes", "notes": "bring **receipts**", "due": "2026-03-15", "priority": 1}, &item)
+	c.json(alice, http.MethodPost, "/api/todos", http.StatusCreated, map[string]any{"title": "Buy milk", "listId": list.ID}, &shared)
+	yesterday := time.Now().UTC().Add(-24 * time.Hour).Format(time.RFC3339)
+	c.json(alice, http.MethodPost, "/api/todos", http.StatusCreated, map[string]any{"title": "Call the bank", "due": yesterday}, &overdue)
+	c.do(alice, http.MethodPost, "/api/todos", http.StatusBadRequest, "application/json", `{"title":" "}`)
+	c.do(alice, http.MethodGet, "/api/todos?render=html", http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, path("/api/todos?list=%d", list.ID), http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, path("/api/todos/%d?render=html", item.ID), http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, "/api/todos/999", http.StatusNotFound, "", "")
+	c.json(alice, http.MethodPatch, path("/api/todos/%d", item.ID), http.StatusOK, map[string]any{"completed": true}, nil)
+	c.json(alice, http.MethodPatch, path("/api/todos/%d", item.ID), http.StatusOK, map[string]any{"title": "File the taxes", "due": nil, "priority": 2}, nil)
+
+	// Comments.
+	var comment, reply todo.Comment
+	c.json(alice, http.MethodPost, path("/api/todos/%d/comments", item.ID), http.StatusCreated, map[string]any{"body": "Which year?"}, &comment)
+	c.json(alice, http.MethodPost, path("/api/todos/%d/comments", item.ID), http.StatusCreated, map[string]any{"body": "2025", "parentId": comment.ID}, &reply)
+	c.json(alice, http.MethodPatch, path("/api/todos/%d/comments/%d", item.ID, comment.ID), http.StatusOK, map[string]any{"body": "Which *tax* year?"}, nil)
+	c.do(alice, http.MethodGet, path("/api/todos/%d/comments", item.ID), http.StatusOK, "", "")
+	c.do(alice, http.MethodDelete, path("/api/todos/%d/comments/%d", item.ID, reply.ID), http.StatusNoContent, "", "")
+
+	// Reminders and the notifications they raise.
+	var fixed todo.Reminder
+	c.json(alice, http.MethodPost, path("/api/todos/%d/reminders", overdue.ID), http.StatusCreated, map[string]any{"minutesBefore": 0}, nil)
+	c.json(alice, http.MethodPost, path("/api/todos/%d/reminders", overdue.ID), http.StatusCreated,
+		map[string]any{"at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}, &fixed)
+	c.do(alice, http.MethodPost, path("/api/todos/%d/reminders", overdue.ID), http.StatusBadRequest, "application/json", `{}`)
+	if _, err := c.fire(ctx); err != nil {
+		t.Fatalf("fire reminders: %v", err)
+	}
+	c.do(alice, http.MethodGet, path("/api/todos/%d/reminders", overdue.ID), http.StatusOK, "", "")
+	c.do(alice, http.MethodDelete, path("/api/todos/%d/reminders/%d", overdue.ID, fixed.ID), http.StatusNoContent, "", "")
+	var inbox struct {
+		Notifications []reminder.Notification `json:"notifications"`
+	}
+	c.json(alice, http.MethodGet, "/api/notifications?unread=true", http.StatusOK, nil, &inbox)
+	if len(inbox.Notifications) != 1 {
+		t.Fatalf("expected one notification, got %d", len(inbox.Notifications))
+	}
+	c.json(alice, http.MethodPatch, path("/api/notifications/%d", inbox.Notifications[0].ID), http.StatusOK, map[string]any{"read": true}, nil)
+	c.do(alice, http.MethodPost, "/api/notifications/read", http.StatusNoContent, "", "")
+
+	// Attachments.
+	var upload bytes.Buffer
+	form := multipart.NewWriter(&upload)
+	part, _ := form.CreateFormFile("file", "receipt.txt")
+	io.WriteString(part, "42.00")
+	form.Close()
+	var attachment todo.Attachment
+	data, _ := c.do(alice, http.MethodPost, path("/api/todos/%d/attachments", item.ID), http.StatusCreated, form.FormDataContentType(), upload.String())
+	if err := json.Unmarshal(data, &attachment); err != nil {
+		t.Fatalf("decode attachment: %v", err)
+	}
+	c.do(alice, http.MethodGet, path("/api/todos/%d/attachments", item.ID), http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, path("/api/todos/%d/attachments/%d", item.ID, attachment.ID), http.StatusOK, "", "")
+	c.do(alice, http.MethodDelete, path("/api/todos/%d/attachments/%d", item.ID, attachment.ID), http.StatusNoContent, "", "")
+
+	// Import and export.
+	c.do(alice, http.MethodPost, "/api/import?dryRun=true", http.StatusOK, "application/json",
+		`[{"title":"Water plants","completed":false,"priority":"B","due":"2026-04-01","tags":["home"]}]`)
+	c.do(alice, http.MethodPost, "/api/import", http.StatusCreated, "text/plain", "(A) Call mom +family due:2026-04-01\nx 2026-01-02 Renew passport\n")
+	c.reject(alice, http.MethodPost, "/api/import?format=yaml", http.StatusBadRequest, "", "")
+	tasks := "tasks:\n  - name: fix-build\n    slug: fix-build\n    status: completed\n    content: Fix the build.\n"
+	c.do(alice, http.MethodPost, "/api/import/takt", http.StatusOK, "application/yaml", tasks)
+	c.do(alice, http.MethodPost, "/api/import/takt", http.StatusOK, "application/yaml", tasks)
+	c.do(alice, http.MethodPost, "/api/import/takt", http.StatusBadRequest, "application/yaml", "tasks:\n  - name: no slug\n")
+	c.do(alice, http.MethodGet, "/api/export", http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, "/api/export?format=csv", http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, path("/api/export?format=todotxt&list=%d", list.ID), http.StatusOK, "", "")
+
+	// Sync.
+	var pulled struct {
+		Token string `json:"token"`
+	}
+	c.json(alice, http.MethodGet, "/api/sync", http.StatusOK, nil, &pulled)
+	now := time.Now().UnixMilli()
+	c.json(alice, http.MethodPost, "/api/sync", http.StatusOK, map[string]any{
+		"clientId": "phone",
+		"mutations": []map[string]any{
+			{"id": "m1", "op": "upsert", "uid": "phone-1@example.com", "time": now, "fields": map[string]any{"title": "Fix bike", "due": "2026-05-01", "priority": nil}},
+			{"id": "m2", "op": "upsert", "uid": item.UID, "time": now, "fields": map[string]any{"completed": false, "due": nil}},
+			{"id": "m3", "op": "delete", "uid": overdue.UID, "time": now},
+		},
+	}, nil)
+	c.do(alice, http.MethodGet, "/api/sync?since="+pulled.Token, http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, "/api/sync?since=999999", http.StatusGone, "", "")
+
+	// Calendar.
+	var feed struct {
+		Token string `json:"token"`
+	}
+	c.json(alice, http.MethodPost, "/api/calendar/token", http.StatusCreated, nil, &feed)
+	c.do(anonymous, http.MethodGet, "/api/calendar.ics?token="+url.QueryEscape(feed.Token), http.StatusOK, "", "")
+	c.do(alice, http.MethodPost, "/api/calendar.ics", http.StatusOK, "text/calendar",
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VTODO\r\nUID:cal-1@example.com\r\nSUMMARY:Book flights\r\nDUE;VALUE=DATE:20260601\r\nEND:VTODO\r\nEND:VCALENDAR\r\n")
+	c.do(alice, http.MethodDelete, "/api/calendar/token", http.StatusNoContent, "", "")
+
+	// Sharing.
+	var invitation struct {
+		Token string `json:"token"`
+	}
+	c.json(alice, http.MethodPost, path("/api/lists/%d/invitations", list.ID), http.StatusCreated, map[string]any{"email": "bob@example.com", "role": "editor"}, &invitation)
+	bob := c.login("bob", "bob@example.com", "Bob")
+	var bobUser auth.User
+	c.json(bob, http.MethodGet, "/auth/me", http.StatusOK, nil, &bobUser)
+	c.json(bob, http.MethodPost, "/api/invitations/accept", http.StatusOK, map[string]any{"token": invitation.Token}, nil)
+	c.do(bob, http.MethodGet, "/api/lists", http.StatusOK, "", "")
+	c.do(bob, http.MethodGet, path("/api/lists/%d/members", list.ID), http.StatusOK, "", "")
+	c.json(bob, http.MethodPatch, path("/api/lists/%d/members/%d", list.ID, me.ID), http.StatusForbidden, map[string]any{"role": "viewer"}, nil)
+	c.json(alice, http.MethodPatch, path("/api/lists/%d/members/%d", list.ID, bobUser.ID), http.StatusNoContent, map[string]any{"role": "viewer"}, nil)
+	c.json(alice, http.MethodPatch, path("/api/lists/%d/members/%d", list.ID, me.ID), http.StatusConflict, map[string]any{"role": "editor"}, nil)
+	c.do(bob, http.MethodPatch, path("/api/todos/%d", shared.ID), http.StatusForbidden, "application/json", `{"completed":true}`)
+	c.do(alice, http.MethodDelete, path("/api/lists/%d/members/%d", list.ID, bobUser.ID), http.StatusNoContent, "", "")
+
+	// GraphQL.
+	c.json(alice, http.MethodPost, "/graphql", http.StatusOK, map[string]any{
+		"query":     `query($list: ID) { todos(list: $list, first: 1) { totalCount nodes { title list { name role } } pageInfo { endCursor } } stats { open overdue } }`,
+		"variables": map[string]any{"list": list.ID},
+	}, nil)
+	c.json(alice, http.MethodPost, "/graphql", http.StatusOK, map[string]any{
+		"query":     `mutation($id: ID!) { updateTodo(id: $id, input: {priority: 3, due: "2026-03-16"}) { priority due } }`,
+		"variables": map[string]any{"id": item.ID},
+	}, nil)
+	c.do(alice, http.MethodPost, "/graphql", http.StatusOK, "application/json", `{"query":"{ todos { nodes { nope } } }"}`)
+	c.reject(alice, http.MethodPost, "/graphql", http.StatusBadRequest, "application/json", `{"query":`)
+	c.do(anonymous, http.MethodGet, "/graphql?query="+url.QueryEscape(`{ todos(filter: {completed: false}) { totalCount } }`), http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, "/graphql?query="+url.QueryEscape(`mutation { deleteTodo(id: 1) }`), http.StatusMethodNotAllowed, "", "")
+	c.do(anonymous, http.MethodGet, "/graphql/schema", http.StatusOK, "", "")
+
+	// Connect.
+	var created struct {
+		ID string `json:"id"`
+	}
+	c.json(alice, http.MethodPost, "/todo.v1.TodoService/Create", http.StatusOK,
+		map[string]any{"title": "Over RPC", "listId": list.ID, "due": "2026-03-16T00:00:00Z", "priority": 2}, &created)
+	c.json(alice, http.MethodPost, "/todo.v1.TodoService/Create", http.StatusBadRequest, map[string]any{"title": " "}, nil)
+	c.json(anonymous, http.MethodPost, "/todo.v1.TodoService/Create", http.StatusUnauthorized, map[string]any{"title": "x"}, nil)
+	c.do(c.client("not-a-key"), http.MethodPost, "/todo.v1.TodoService/Create", http.StatusUnauthorized, "application/json", `{"title":"x"}`)
+	c.reject(alice, http.MethodPost, "/todo.v1.TodoService/Create", http.StatusUnsupportedMediaType, "text/plain", "x")
+	c.json(alice, http.MethodPost, "/todo.v1.TodoService/Update", http.StatusOK, map[string]any{"id": created.ID, "completed": true, "clearDue": true}, nil)
+	c.json(alice, http.MethodPost, "/todo.v1.TodoService/Update", http.StatusBadRequest, map[string]any{"id": created.ID}, nil)
+	c.json(alice, http.MethodPost, "/todo.v1.TodoService/Update", http.StatusNotFound, map[string]any{"id": 999999, "title": "x"}, nil)
+	c.json(alice, http.MethodPost, "/todo.v1.TodoService/Delete", http.StatusOK, map[string]any{"id": created.ID}, nil)
+	c.json(alice, http.MethodPost, "/todo.v1.TodoService/Delete", http.StatusNotFound, map[string]any{"id": created.ID}, nil)
+	envelope := func(message string) string {
+		return string([]byte{0, 0, 0, 0, byte(len(message))}) + message
+	}
+	stream, _ := c.do(alice, http.MethodPost, "/todo.v1.TodoService/List", http.StatusOK, "application/connect+json", envelope(fmt.Sprintf(`{"listId":%d}`, list.ID)))
+	if !bytes.Contains(stream, []byte(shared.Title)) || !bytes.HasSuffix(stream, []byte("\x02\x00\x00\x00\x02{}")) {
+		t.Fatalf("unexpected List stream %q", stream)
+	}
+	c.do(c.client("not-a-key"), http.MethodPost, "/todo.v1.TodoService/List", http.StatusUnauthorized, "application/connect+json", envelope("{}"))
+	c.reject(alice, http.MethodPost, "/todo.v1.TodoService/List", http.StatusUnsupportedMediaType, "application/json", "{}")
+	watch, err := http.NewRequest(http.MethodPost, c.app.URL+"/todo.v1.TodoService/Watch", strings.NewReader(envelope("{}")))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	watch.Header.Set("Content-Type", "application/connect+json")
+	watch.Header.Set("Connect-Timeout-Ms", "50")
+	res, err := alice.Do(watch)
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	stream, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !bytes.Contains(stream, []byte(`"code":"deadline_exceeded"`)) {
+		t.Fatalf("expected Watch to end at its deadline, got %d %q", res.StatusCode, stream)
+	}
+
+	// MCP.
+	_, header := c.do(alice, http.MethodPost, "/mcp", http.StatusOK, "application/json",
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{}}}`)
+	agent := withHeader(alice, "Mcp-Session-Id", header.Get("Mcp-Session-Id"))
+	c.do(agent, http.MethodPost, "/mcp", http.StatusAccepted, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	result, _ := c.do(withHeader(agent, "Mcp-Protocol-Version", "2025-06-18"), http.MethodPost, "/mcp", http.StatusOK, "application/json",
+		fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"list_todos","arguments":{"list_id":%d}}}`, list.ID))
+	if !bytes.Contains(result, []byte(shared.Title)) {
+		t.Fatalf("unexpected list_todos result %s", result)
+	}
+	c.do(withHeader(agent, "Mcp-Protocol-Version", "1999-01-01"), http.MethodPost, "/mcp", http.StatusBadRequest, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	c.do(withHeader(agent, "Origin", "https://evil.example"), http.MethodPost, "/mcp", http.StatusForbidden, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	c.reject(agent, http.MethodPost, "/mcp", http.StatusBadRequest, "application/json", `{"hello":"world"}`)
+	c.reject(agent, http.MethodPost, "/mcp", http.StatusUnsupportedMediaType, "text/plain", "ping")
+	c.do(alice, http.MethodPost, "/mcp", http.StatusBadRequest, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	c.do(withHeader(bob, "Mcp-Session-Id", header.Get("Mcp-Session-Id")), http.MethodPost, "/mcp", http.StatusNotFound, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	c.do(agent, http.MethodDelete, "/mcp", http.StatusNoContent, "", "")
+	c.do(agent, http.MethodDelete, "/mcp", http.StatusNotFound, "", "")
+
+	// Digests.
+	c.do(alice, http.MethodGet, "/api/digest/settings", http.StatusOK, "", "")
+	c.json(alice, http.MethodPut, "/api/digest/settings", http.StatusOK,
+		map[string]any{"frequency": "weekly", "timezone": "Europe/Berlin", "hour": 7, "weekday": "Friday"}, nil)
+	c.json(alice, http.MethodPut, "/api/digest/settings", http.StatusBadRequest, map[string]any{"timezone": "Mars/Olympus"}, nil)
+	c.do(alice, http.MethodGet, "/api/digest/preview", http.StatusOK, "", "")
+	c.do(alice, http.MethodGet, "/api/digest/preview?format=text&frequency=daily", http.StatusOK, "", "")
+
+	// API keys.
+	var key struct {
+		ID    int64  `json:"id"`
+		Token string `json:"token"`
+	}
+	c.json(admin, http.MethodPost, "/api/keys", http.StatusCreated, map[string]any{"name": "reader", "scope": "read"}, &key)
+	c.do(admin, http.MethodGet, "/api/keys", http.StatusOK, "", "")
+	reader := c.client(key.Token)
+	c.do(reader, http.MethodGet, "/api/todos", http.StatusOK, "", "")
+	c.do(reader, http.MethodDelete, path("/api/todos/%d", item.ID), http.StatusForbidden, "", "")
+	c.do(reader, http.MethodGet, "/api/keys", http.StatusForbidden, "", "")
+	c.do(reader, http.MethodPost, "/todo.v1.TodoService/Delete", http.StatusForbidden, "application/json", fmt.Sprintf(`{"id":"%d"}`, item.ID))
+	c.do(admin, http.MethodDelete, path("/api/keys/%d", key.ID), http.StatusNoContent, "", "")
+	c.do(reader, http.MethodGet, "/api/todos", http.StatusUnauthorized, "", "")
+
+	// Webhooks.
+	var hook webhook.Webhook
+	c.json(admin, http.MethodPost, "/api/webhooks", http.StatusCreated, map[string]any{"url": "https://hooks.example.com/todo", "events": []string{todo.EventCreated}}, &hook)
+	c.reject(admin, http.MethodPost, "/api/webhooks", http.StatusBadRequest, "application/json", `{"url":"https://hooks.example.com/todo","events":["todo.renamed"]}`)
+	c.json(alice, http.MethodPost, "/api/todos", http.StatusCreated, map[string]any{"title": "Trigger a webhook"}, nil)
+	var deliveries []webhook.Delivery
+	c.json(admin, http.MethodGet, path("/api/webhooks/%d/deliveries", hook.ID), http.StatusOK, nil, &deliveries)
+	if len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %d", len(deliveries))
+	}
+	c.do(admin, http.MethodGet, "/api/webhooks", http.StatusOK, "", "")
+	c.do(admin, http.MethodPost, path("/api/webhooks/%d/deliveries/%d/redeliver", hook.ID, deliveries[0].ID), http.StatusAccepted, "", "")
+	c.do(admin, http.MethodDelete, path("/api/webhooks/%d", hook.ID), http.StatusNoContent, "", "")
+
+	c.do(alice, http.MethodDelete, path("/api/todos/%d", item.ID), http.StatusNoContent, "", "")
+	c.do(alice, http.MethodPost, "/auth/logout", http.StatusSeeOther, "", "")
+	c.do(alice, http.MethodGet, "/auth/me", http.StatusUnauthorized, "", "")
+
+	// Every operation must be exercised, so a new route cannot be
+	// described without its handler being checked against the description.
+	for _, route := range c.doc.Routes() {
+		if strings.Contains(route, " ") && !c.seen[route] {
+			t.Errorf("%s is not exercised by this test", route)
+		}
+	}
+}
+
+func TestValidateRequest_RejectsUndescribedInput(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("load openapi document: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		method, target, body, want string
+	}{
+		"unknown path":       {http.MethodGet, "/api/nope", "", "path not described"},
+		"unknown method":     {http.MethodPut, "/api/todos", "", "method not described"},
+		"bad path parameter": {http.MethodGet, "/api/todos/abc", "", `path parameter "id"`},
+		"unknown query":      {http.MethodGet, "/api/todos?sort=title", "", `query parameter "sort" is not described`},
+		"bad enum":           {http.MethodGet, "/api/export?format=xml", "", `query parameter "format"`},
+		"missing property":   {http.MethodPost, "/api/todos", `{"notes":"x"}`, `missing required property "title"`},
+		"extra property":     {http.MethodPost, "/api/todos", `{"title":"x","tags":[]}`, `unexpected property "tags"`},
+		"wrong type":         {http.MethodPatch, "/api/todos/1", `{"completed":"yes"}`, "$.completed: expected boolean"},
+		"bad due":            {http.MethodPatch, "/api/todos/1", `{"due":"next week"}`, "$.due: matches none"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			err := doc.ValidateRequest(req)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/todos/1", strings.NewReader(`{"due":null,"title":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := doc.ValidateRequest(req); err != nil {
+		t.Fatalf("expected a valid request, got %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"due":null,"title":"x"}` {
+		t.Fatalf("expected the body to be readable again, got %q", body)
+	}
+}
+
+func TestValidateResponse_RejectsDrift(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("load openapi document: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/todos/1", nil)
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+	for name, tc := range map[string]struct {
+		status int
+		header http.Header
+		body   string
+		want   string
+	}{
+		"undescribed status": {http.StatusTeapot, jsonHeader, `{}`, "status is not described"},
+		"new field":          {http.StatusOK, jsonHeader, `{"id":1,"title":"x","completed":false,"color":"red"}`, `unexpected property "color"`},
+		"missing field":      {http.StatusOK, jsonHeader, `{"id":1,"completed":false}`, `missing required property "title"`},
+		"bad time":           {http.StatusOK, jsonHeader, `{"id":1,"title":"x","completed":false,"due":"tomorrow"}`, `not a valid date-time`},
+		"wrong content type": {http.StatusOK, http.Header{"Content-Type": {"text/html"}}, `<p>`, `content type "text/html" is not described`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := doc.ValidateResponse(req, tc.status, tc.header, []byte(tc.body))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, "/api/todos/1", nil)
+	if err := doc.ValidateResponse(del, http.StatusNoContent, http.Header{}, []byte("done")); err == nil {
+		t.Fatal("expected a body on 204 to be rejected")
+	}
+}
+
+func TestParse_RejectsUnsupportedKeywords(t *testing.T) {
+	spec := `
+openapi: 3.1.0
+info: {title: t, version: "1"}
+paths: {}
+components:
+  schemas:
+    Todo:
+      type: object
+      patternProperties:
+        "^x-": {type: string}
+`
+	if _, err := openapi.Parse([]byte(spec)); err == nil || !strings.Contains(err.Error(), `unsupported schema keyword "patternProperties"`) {
+		t.Fatalf("expected patternProperties to be rejected, got %v", err)
+	}
+}
+
+func TestHandler_ServesDocument(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("load openapi document: %v", err)
+	}
+	h := openapi.NewHandler(doc)
+
+	rr := httptest.NewRecorder()
+	h.Spec(rr, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	var served struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &served); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if served.OpenAPI != "3.1.0" || served.Paths["/api/todos/{id}"]["patch"] == nil {
+		t.Fatalf("unexpected document: %s", strconv.Quote(rr.Body.String()[:min(200, rr.Body.Len())]))
+	}
+
+	rr = httptest.NewRecorder()
+	h.Docs(rr, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	if rr.Header().Get("Content-Type") != "text/html; charset=utf-8" || !strings.Contains(rr.Body.String(), "/api/openapi.json") {
+		t.Fatalf("unexpected docs page: %q", rr.Header().Get("Content-Type"))
+	}
+}
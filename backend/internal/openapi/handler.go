@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+type Handler struct {
+	doc *Document
+}
+
+func NewHandler(doc *Document) *Handler {
+	return &Handler{doc: doc}
+}
+
+// Spec serves the document as JSON.
+func (h *Handler) Spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	write(w, r, h.doc.JSON())
+}
+
+// Docs serves a page that renders the document fetched from Spec.
+func (h *Handler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	write(w, r, docsPage)
+}
+
+func write(w http.ResponseWriter, r *http.Request, body []byte) {
+	if _, err := w.Write(body); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
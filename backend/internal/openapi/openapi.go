@@ -0,0 +1,364 @@
+// Package openapi serves the OpenAPI document that describes the HTTP API,
+// along with a page for browsing it, and checks requests and responses
+// against the document so tests can keep the two from drifting apart.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed openapi.yaml
+var source []byte
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is a parsed OpenAPI document.
+type Document struct {
+	raw        map[string]any
+	json       []byte
+	operations []*operation
+	paths      []string
+	schemas    map[string]*Schema
+}
+
+type operation struct {
+	method    string
+	path      string
+	segments  []string
+	params    []parameter
+	body      *requestBody
+	responses map[string]*response
+}
+
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	schema   *Schema
+}
+
+type requestBody struct {
+	required bool
+	content  map[string]*Schema
+}
+
+// response is nil-content when the document promises no body.
+type response struct {
+	content map[string]*Schema
+}
+
+// Load parses the document embedded in the binary.
+func Load() (*Document, error) {
+	return Parse(source)
+}
+
+// Parse reads a document in YAML or JSON. Schemas may only use the
+// keywords the validator understands, so that a document never claims
+// more than the tests check.
+func Parse(data []byte) (*Document, error) {
+	var decoded any
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("parse openapi document: %w", err)
+	}
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("encode openapi document: %w", err)
+	}
+	// Round-trip through JSON so the document is made of the same types
+	// that request and response bodies decode into.
+	var raw map[string]any
+	if err := json.Unmarshal(encoded, &raw); err != nil {
+		return nil, fmt.Errorf("decode openapi document: %w", err)
+	}
+	if version, _ := raw["openapi"].(string); !strings.HasPrefix(version, "3.1.") {
+		return nil, fmt.Errorf("openapi version %q is not supported", raw["openapi"])
+	}
+
+	doc := &Document{raw: raw, json: encoded, schemas: map[string]*Schema{}}
+	if err := doc.compileSchemas(); err != nil {
+		return nil, err
+	}
+	paths, _ := raw["paths"].(map[string]any)
+	for path, item := range paths {
+		if err := doc.addPath(path, item); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(doc.paths)
+	sort.Slice(doc.operations, func(i, j int) bool {
+		a, b := doc.operations[i], doc.operations[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.method < b.method
+	})
+	return doc, nil
+}
+
+// JSON returns the document encoded as JSON.
+func (d *Document) JSON() []byte {
+	return d.json
+}
+
+// Routes lists the document's operations as ServeMux patterns such as
+// "GET /api/todos/{id}". Paths described without operations, like a
+// prefix handed to another protocol, are listed as the bare path.
+func (d *Document) Routes() []string {
+	var routes []string
+	for _, path := range d.paths {
+		found := false
+		for _, op := range d.operations {
+			if op.path == path {
+				routes = append(routes, strings.ToUpper(op.method)+" "+path)
+				found = true
+			}
+		}
+		if !found {
+			routes = append(routes, path)
+		}
+	}
+	return routes
+}
+
+// Route returns the operation r is routed to, as a pattern like those
+// from Routes.
+func (d *Document) Route(r *http.Request) (string, error) {
+	op, _, err := d.find(r.Method, r.URL.Path)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(op.method) + " " + op.path, nil
+}
+
+func (d *Document) compileSchemas() error {
+	components, _ := d.raw["components"].(map[string]any)
+	schemas, _ := components["schemas"].(map[string]any)
+	// Register every component first so that references, including
+	// recursive ones, can be resolved while compiling.
+	for name := range schemas {
+		d.schemas["#/components/schemas/"+name] = &Schema{}
+	}
+	for name, raw := range schemas {
+		ref := "#/components/schemas/" + name
+		schema, err := d.compileSchema(raw, ref)
+		if err != nil {
+			return err
+		}
+		*d.schemas[ref] = *schema
+	}
+	return nil
+}
+
+func (d *Document) addPath(path string, raw any) error {
+	item, err := d.resolve(raw, "paths."+path)
+	if err != nil {
+		return err
+	}
+	d.paths = append(d.paths, path)
+	shared, err := d.parameters(item["parameters"], path)
+	if err != nil {
+		return err
+	}
+	for key, value := range item {
+		if !slices.Contains(methods, key) {
+			continue
+		}
+		where := strings.ToUpper(key) + " " + path
+		op, err := d.operation(value, where)
+		if err != nil {
+			return err
+		}
+		op.method, op.path, op.segments = key, path, strings.Split(path, "/")
+		// Operation parameters override path parameters of the same name.
+		for _, p := range shared {
+			if !slices.ContainsFunc(op.params, func(q parameter) bool { return q.name == p.name && q.in == p.in }) {
+				op.params = append(op.params, p)
+			}
+		}
+		for _, segment := range op.segments {
+			if name, ok := templateParam(segment); ok && !slices.ContainsFunc(op.params, func(p parameter) bool { return p.name == name && p.in == "path" }) {
+				return fmt.Errorf("%s: path parameter %q is not described", where, name)
+			}
+		}
+		d.operations = append(d.operations, op)
+	}
+	return nil
+}
+
+func (d *Document) operation(raw any, where string) (*operation, error) {
+	object, err := d.resolve(raw, where)
+	if err != nil {
+		return nil, err
+	}
+	op := &operation{responses: map[string]*response{}}
+	if op.params, err = d.parameters(object["parameters"], where); err != nil {
+		return nil, err
+	}
+	if raw, ok := object["requestBody"]; ok {
+		body, err := d.resolve(raw, where+" request body")
+		if err != nil {
+			return nil, err
+		}
+		op.body = &requestBody{required: body["required"] == true}
+		if op.body.content, err = d.content(body["content"], where+" request body"); err != nil {
+			return nil, err
+		}
+	}
+	responses, _ := object["responses"].(map[string]any)
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("%s: no responses", where)
+	}
+	for status, raw := range responses {
+		object, err := d.resolve(raw, where+" "+status)
+		if err != nil {
+			return nil, err
+		}
+		content, err := d.content(object["content"], where+" "+status)
+		if err != nil {
+			return nil, err
+		}
+		op.responses[status] = &response{content: content}
+	}
+	return op, nil
+}
+
+func (d *Document) parameters(raw any, where string) ([]parameter, error) {
+	list, _ := raw.([]any)
+	params := make([]parameter, 0, len(list))
+	for _, item := range list {
+		object, err := d.resolve(item, where)
+		if err != nil {
+			return nil, err
+		}
+		p := parameter{required: object["required"] == true}
+		p.name, _ = object["name"].(string)
+		p.in, _ = object["in"].(string)
+		switch p.in {
+		case "path", "query", "header", "cookie":
+		default:
+			return nil, fmt.Errorf("%s: parameter %q is in unknown location %q", where, p.name, p.in)
+		}
+		if p.schema, err = d.compileSchema(object["schema"], where+" parameter "+p.name); err != nil {
+			return nil, err
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+func (d *Document) content(raw any, where string) (map[string]*Schema, error) {
+	media, _ := raw.(map[string]any)
+	if len(media) == 0 {
+		return nil, nil
+	}
+	content := make(map[string]*Schema, len(media))
+	for mediaType, value := range media {
+		object, _ := value.(map[string]any)
+		schema, err := d.compileSchema(object["schema"], where+" "+mediaType)
+		if err != nil {
+			return nil, err
+		}
+		content[mediaType] = schema
+	}
+	return content, nil
+}
+
+// resolve follows a local $ref, if any, and returns the object it names.
+func (d *Document) resolve(raw any, where string) (map[string]any, error) {
+	object, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an object", where)
+	}
+	ref, ok := object["$ref"].(string)
+	if !ok {
+		return object, nil
+	}
+	target, err := d.pointer(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", where, err)
+	}
+	return d.resolve(target, ref)
+}
+
+func (d *Document) pointer(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("only local references are supported, got %q", ref)
+	}
+	var node any = d.raw
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("reference %q does not resolve", ref)
+		}
+		if node, ok = object[token]; !ok {
+			return nil, fmt.Errorf("reference %q does not resolve", ref)
+		}
+	}
+	return node, nil
+}
+
+// find returns the operation for method and the concrete path, preferring
+// literal segments over parameters, as ServeMux does.
+func (d *Document) find(method string, path string) (*operation, map[string]string, error) {
+	segments := strings.Split(path, "/")
+	var best *operation
+	var bestValues map[string]string
+	bestLiterals := -1
+	pathFound := false
+	for _, op := range d.operations {
+		values, literals, ok := match(op.segments, segments)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if op.method != strings.ToLower(method) || literals <= bestLiterals {
+			continue
+		}
+		best, bestValues, bestLiterals = op, values, literals
+	}
+	switch {
+	case best != nil:
+		return best, bestValues, nil
+	case pathFound:
+		return nil, nil, fmt.Errorf("%s %s: method not described", method, path)
+	}
+	return nil, nil, fmt.Errorf("%s %s: path not described", method, path)
+}
+
+func match(template []string, segments []string) (map[string]string, int, bool) {
+	if len(template) != len(segments) {
+		return nil, 0, false
+	}
+	values := map[string]string{}
+	literals := 0
+	for i, segment := range template {
+		if name, ok := templateParam(segment); ok {
+			if segments[i] == "" {
+				return nil, 0, false
+			}
+			values[name] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return values, literals, true
+}
+
+func templateParam(segment string) (string, bool) {
+	if len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
@@ -0,0 +1,340 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema the document uses. Parse rejects any
+// keyword outside it rather than silently not checking it.
+type Schema struct {
+	ref                  *Schema
+	types                []string
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	closed               bool
+	items                *Schema
+	enum                 []any
+	format               string
+	minimum, maximum     *float64
+	minLength, maxLength *int
+	minItems, maxItems   *int
+	anyOf, oneOf         []*Schema
+}
+
+// annotations describe a schema without constraining values.
+var annotations = []string{"description", "title", "default", "example", "examples", "deprecated", "readOnly", "writeOnly"}
+
+var formats = []string{"date", "date-time", "binary"}
+
+var schemaTypes = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+func (d *Document) compileSchema(raw any, where string) (*Schema, error) {
+	object, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object", where)
+	}
+	s := &Schema{}
+	if ref, ok := object["$ref"].(string); ok {
+		target, ok := d.schemas[ref]
+		if !ok {
+			return nil, fmt.Errorf("%s: reference %q does not resolve", where, ref)
+		}
+		s.ref = target
+	}
+
+	var err error
+	for _, keyword := range sortedKeys(object) {
+		value := object[keyword]
+		at := where + "." + keyword
+		switch keyword {
+		case "$ref":
+		case "type":
+			s.types, err = stringList(value, at)
+			for _, t := range s.types {
+				if !slices.Contains(schemaTypes, t) {
+					return nil, fmt.Errorf("%s: unknown type %q", at, t)
+				}
+			}
+		case "properties":
+			props, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be an object", at)
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for name, prop := range props {
+				if s.properties[name], err = d.compileSchema(prop, at+"."+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			s.required, err = stringList(value, at)
+		case "additionalProperties":
+			if allowed, ok := value.(bool); ok {
+				s.closed = !allowed
+			} else {
+				s.additionalProperties, err = d.compileSchema(value, at)
+			}
+		case "items":
+			s.items, err = d.compileSchema(value, at)
+		case "enum":
+			if s.enum, ok = value.([]any); !ok {
+				return nil, fmt.Errorf("%s: must be an array", at)
+			}
+		case "format":
+			s.format, _ = value.(string)
+			if !slices.Contains(formats, s.format) {
+				return nil, fmt.Errorf("%s: unknown format %q", at, value)
+			}
+		case "minimum":
+			s.minimum, err = number(value, at)
+		case "maximum":
+			s.maximum, err = number(value, at)
+		case "minLength":
+			s.minLength, err = count(value, at)
+		case "maxLength":
+			s.maxLength, err = count(value, at)
+		case "minItems":
+			s.minItems, err = count(value, at)
+		case "maxItems":
+			s.maxItems, err = count(value, at)
+		case "anyOf", "oneOf":
+			list, ok := value.([]any)
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("%s: must be a non-empty array", at)
+			}
+			schemas := make([]*Schema, len(list))
+			for i, item := range list {
+				if schemas[i], err = d.compileSchema(item, at+"["+strconv.Itoa(i)+"]"); err != nil {
+					return nil, err
+				}
+			}
+			if keyword == "anyOf" {
+				s.anyOf = schemas
+			} else {
+				s.oneOf = schemas
+			}
+		default:
+			if !slices.Contains(annotations, keyword) {
+				return nil, fmt.Errorf("%s: unsupported schema keyword %q", where, keyword)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Validate checks a value decoded by encoding/json with UseNumber.
+func (s *Schema) Validate(value any) error {
+	var errs []error
+	s.validate(value, "$", &errs)
+	return errors.Join(errs...)
+}
+
+func (s *Schema) validate(value any, at string, errs *[]error) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, fmt.Errorf("%s: %s", at, fmt.Sprintf(format, args...)))
+	}
+	if s.ref != nil {
+		s.ref.validate(value, at, errs)
+	}
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(value, t) }) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(value))
+		return
+	}
+	if len(s.enum) > 0 && !slices.ContainsFunc(s.enum, func(e any) bool { return equal(e, value) }) {
+		fail("%v is not one of %v", value, s.enum)
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := len([]rune(value))
+		if s.minLength != nil && length < *s.minLength {
+			fail("shorter than %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("longer than %d characters", *s.maxLength)
+		}
+		if err := checkFormat(s.format, value); err != nil {
+			fail("%v", err)
+		}
+	case json.Number:
+		n, _ := value.Float64()
+		if s.minimum != nil && n < *s.minimum {
+			fail("%s is less than %v", value, *s.minimum)
+		}
+		if s.maximum != nil && n > *s.maximum {
+			fail("%s is greater than %v", value, *s.maximum)
+		}
+	case []any:
+		if s.minItems != nil && len(value) < *s.minItems {
+			fail("fewer than %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(value) > *s.maxItems {
+			fail("more than %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range value {
+				s.items.validate(item, at+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := value[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for _, name := range sortedKeys(value) {
+			prop, ok := s.properties[name]
+			switch {
+			case ok:
+				prop.validate(value[name], at+"."+name, errs)
+			case s.additionalProperties != nil:
+				s.additionalProperties.validate(value[name], at+"."+name, errs)
+			case s.closed:
+				fail("unexpected property %q", name)
+			}
+		}
+	}
+
+	if len(s.anyOf) > 0 && matching(s.anyOf, value) == 0 {
+		fail("matches none of the anyOf schemas")
+	}
+	if len(s.oneOf) > 0 {
+		if n := matching(s.oneOf, value); n != 1 {
+			fail("matches %d of the oneOf schemas, want exactly 1", n)
+		}
+	}
+}
+
+func matching(schemas []*Schema, value any) int {
+	n := 0
+	for _, schema := range schemas {
+		if schema.Validate(value) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func checkFormat(format string, value string) error {
+	var err error
+	switch format {
+	case "date":
+		_, err = time.Parse(time.DateOnly, value)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339Nano, value)
+	}
+	if err != nil {
+		return fmt.Errorf("%q is not a valid %s", value, format)
+	}
+	return nil
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return typeOf(value) == t
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// equal compares an enum entry from the document with a value from a body,
+// whose numbers are json.Number rather than float64.
+func equal(want any, got any) bool {
+	if n, ok := got.(json.Number); ok {
+		f, err := n.Float64()
+		return err == nil && want == f
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+func stringList(value any, at string) ([]string, error) {
+	switch value := value.(type) {
+	case string:
+		return []string{value}, nil
+	case []any:
+		list := make([]string, len(value))
+		for i, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a list of strings", at)
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("%s: must be a string or a list of strings", at)
+}
+
+func number(value any, at string) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", at)
+	}
+	return &n, nil
+}
+
+func count(value any, at string) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != float64(int(n)) {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", at)
+	}
+	c := int(n)
+	return &c, nil
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CompileSchema compiles a standalone JSON Schema, in the same subset and
+// without references, for other APIs described by JSON Schema.
+func CompileSchema(data []byte) (*Schema, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	return (&Document{schemas: map[string]*Schema{}}).compileSchema(raw, "$")
+}
@@ -0,0 +1,159 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ValidateRequest checks r against the operation the document describes
+// for its method and path: path and query parameters, and the body for
+// its content type. The body is read and replaced, so r can still be
+// served afterwards.
+func (d *Document) ValidateRequest(r *http.Request) error {
+	op, values, err := d.find(r.Method, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	where := r.Method + " " + op.path
+	var errs []error
+	for _, p := range op.params {
+		switch p.in {
+		case "path":
+			errs = append(errs, p.check(values[p.name]))
+		case "query":
+			if raw, ok := r.URL.Query()[p.name]; ok {
+				errs = append(errs, p.check(raw[0]))
+			} else if p.required {
+				errs = append(errs, fmt.Errorf("missing required query parameter %q", p.name))
+			}
+		}
+	}
+	for name := range r.URL.Query() {
+		if !op.hasParam(name, "query") {
+			errs = append(errs, fmt.Errorf("query parameter %q is not described", name))
+		}
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return fmt.Errorf("%s: read request body: %w", where, err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	switch {
+	case op.body == nil && len(body) > 0:
+		errs = append(errs, errors.New("request body is not described"))
+	case op.body != nil && len(body) == 0 && op.body.required:
+		errs = append(errs, errors.New("request body is required"))
+	case op.body != nil && len(body) > 0:
+		errs = append(errs, checkContent(op.body.content, r.Header.Get("Content-Type"), body))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s request: %w", where, err)
+	}
+	return nil
+}
+
+// ValidateResponse checks that the document describes status for the
+// operation r was routed to, and that the body matches its content.
+func (d *Document) ValidateResponse(r *http.Request, status int, header http.Header, body []byte) error {
+	op, _, err := d.find(r.Method, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	where := fmt.Sprintf("%s %s response %d", r.Method, op.path, status)
+	res, ok := op.responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("%s: status is not described", where)
+	}
+	switch {
+	case res.content != nil:
+		err = checkContent(res.content, header.Get("Content-Type"), body)
+	case status/100 != 3 && len(body) > 0:
+		// Redirects carry a short note for browsers that is not part of
+		// the API; anything else without described content must be empty.
+		err = errors.New("body is not described")
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", where, err)
+	}
+	return nil
+}
+
+func (p parameter) check(raw string) error {
+	value, err := coerce(raw, p.schema)
+	if err == nil {
+		err = p.schema.Validate(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s parameter %q: %w", p.in, p.name, err)
+	}
+	return nil
+}
+
+func (op *operation) hasParam(name string, in string) bool {
+	for _, p := range op.params {
+		if p.name == name && p.in == in {
+			return true
+		}
+	}
+	return false
+}
+
+// coerce turns a parameter string into the JSON value its schema expects.
+func coerce(raw string, schema *Schema) (any, error) {
+	for s := schema; s != nil; s = s.ref {
+		for _, t := range s.types {
+			switch t {
+			case "integer", "number":
+				if _, err := strconv.ParseFloat(raw, 64); err != nil {
+					return nil, fmt.Errorf("%q is not a number", raw)
+				}
+				return json.Number(raw), nil
+			case "boolean":
+				b, err := strconv.ParseBool(raw)
+				if err != nil {
+					return nil, fmt.Errorf("%q is not a boolean", raw)
+				}
+				return b, nil
+			}
+		}
+	}
+	return raw, nil
+}
+
+func checkContent(content map[string]*Schema, contentType string, body []byte) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("content type %q is invalid", contentType)
+	}
+	schema, ok := content[mediaType]
+	if !ok {
+		if wildcard, found := content[strings.Split(mediaType, "/")[0]+"/*"]; found {
+			schema, ok = wildcard, true
+		} else {
+			schema, ok = content["*/*"]
+		}
+	}
+	if !ok {
+		return fmt.Errorf("content type %q is not described", mediaType)
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("body is not valid JSON: %w", err)
+	}
+	return schema.Validate(value)
+}
@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/backend/internal/auth"
+)
+
+const sweepInterval = time.Minute
+
+// Rule is a token bucket refilled at Rate tokens per second holding at most
+// Burst tokens. A zero Rate disables limiting.
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+type Options struct {
+	Enabled bool
+	Default Rule
+	// Routes overrides Default for ServeMux patterns such as "POST /api/todos".
+	Routes map[string]Rule
+	// TrustProxy takes the client IP from X-Forwarded-For. Each proxy
+	// appends the address it received the request from, so the entry
+	// ProxyHops from the right is the one the outermost trusted proxy
+	// recorded; anything left of it was sent by the client. ProxyHops
+	// below 1 counts as 1.
+	TrustProxy bool
+	ProxyHops  int
+}
+
+type Limiter struct {
+	opts Options
+	now  func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	route  string
+	client string
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rule    Rule
+}
+
+func New(opts Options) *Limiter {
+	return &Limiter{
+		opts:    opts,
+		now:     time.Now,
+		buckets: make(map[bucketKey]*bucket),
+	}
+}
+
+// Limit throttles next per client using the rule configured for pattern.
+func (l *Limiter) Limit(pattern string, next http.Handler) http.Handler {
+	rule, ok := l.opts.Routes[pattern]
+	if !ok {
+		rule = l.opts.Default
+	}
+	if !l.opts.Enabled || rule.Rate <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision := l.take(bucketKey{route: pattern, client: l.clientKey(r)}, rule)
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(rule.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.reset)))
+		if !decision.allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.retryAfter)))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type decision struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+func (l *Limiter) take(key bucketKey, rule Rule) decision {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), updated: now, rule: rule}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	var d decision
+	if b.tokens >= 1 {
+		b.tokens--
+		d.allowed = true
+	} else {
+		d.retryAfter = secondsToDuration((1 - b.tokens) / rule.Rate)
+	}
+	d.remaining = int(math.Floor(b.tokens))
+	d.reset = secondsToDuration((float64(rule.Burst) - b.tokens) / rule.Rate)
+	return d
+}
+
+// sweep drops buckets that have refilled completely, since they carry no
+// state that a fresh bucket would not.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rule.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(b.rule.Burst), b.tokens+elapsed*b.rule.Rate)
+	b.updated = now
+}
+
+// clientKey identifies the caller by credential when authenticated, so a
+// key shared across hosts gets one budget, and by IP address otherwise.
+func (l *Limiter) clientKey(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return principal.Key()
+	}
+	if l.opts.TrustProxy {
+		if ip := forwardedClient(r.Header.Values("X-Forwarded-For"), l.opts.ProxyHops); ip != "" {
+			return "ip:" + ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// forwardedClient returns the X-Forwarded-For entry hops from the right,
+// or the leftmost one when the request passed through fewer proxies.
+func forwardedClient(headers []string, hops int) string {
+	var entries []string
+	for _, header := range headers {
+		entries = append(entries, strings.Split(header, ",")...)
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(entries[max(len(entries)-max(hops, 1), 0)])
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
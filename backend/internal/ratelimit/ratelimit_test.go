@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(opts Options) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := New(opts)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func doRequest(handler http.Handler, remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusCreated)
+})
+
+func TestLimit_RejectsAfterBurst(t *testing.T) {
+	limiter, clock := newTestLimiter(Options{Enabled: true, Default: Rule{Rate: 1, Burst: 2}})
+	handler := limiter.Limit("POST /api/todos", okHandler)
+
+	for i := 0; i < 2; i++ {
+		rr := doRequest(handler, "10.0.0.1:1234", "")
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("request %d: expected status 201, got %d", i, rr.Code)
+		}
+	}
+
+	rr := doRequest(handler, "10.0.0.1:1234", "")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "1" {
+		t.Fatalf("unexpected Retry-After: %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Fatalf("unexpected RateLimit-Limit: %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Fatalf("unexpected RateLimit-Remaining: %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Reset"); got != "2" {
+		t.Fatalf("unexpected RateLimit-Reset: %q", got)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if rr := doRequest(handler, "10.0.0.1:1234", ""); rr.Code != http.StatusCreated {
+		t.Fatalf("expected token to be refilled, got %d", rr.Code)
+	}
+}
+
+func TestLimit_SeparatesClientsAndRoutes(t *testing.T) {
+	limiter, _ := newTestLimiter(Options{
+		Enabled: true,
+		Default: Rule{Rate: 1, Burst: 5},
+		Routes:  map[string]Rule{"POST /api/todos": {Rate: 1, Burst: 1}},
+	})
+	create := limiter.Limit("POST /api/todos", okHandler)
+	list := limiter.Limit("GET /api/todos", okHandler)
+
+	doRequest(create, "10.0.0.1:1", "")
+	if rr := doRequest(create, "10.0.0.1:2", ""); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected same IP on another port to share a bucket, got %d", rr.Code)
+	}
+	if rr := doRequest(create, "10.0.0.2:1", ""); rr.Code != http.StatusCreated {
+		t.Fatalf("expected other client to be allowed, got %d", rr.Code)
+	}
+	if rr := doRequest(list, "10.0.0.1:1", ""); rr.Code != http.StatusCreated {
+		t.Fatalf("expected other route to be allowed, got %d", rr.Code)
+	}
+	if got := doRequest(list, "10.0.0.1:1", "").Header().Get("RateLimit-Limit"); got != "5" {
+		t.Fatalf("expected default rule on other route, got limit %q", got)
+	}
+}
+
+func TestLimit_TrustProxy(t *testing.T) {
+	limiter, _ := newTestLimiter(Options{Enabled: true, Default: Rule{Rate: 1, Burst: 1}, TrustProxy: true})
+	handler := limiter.Limit("POST /api/todos", okHandler)
+
+	doRequest(handler, "127.0.0.1:1", "203.0.113.1")
+	if rr := doRequest(handler, "127.0.0.1:1", "203.0.113.2"); rr.Code != http.StatusCreated {
+		t.Fatalf("expected forwarded clients to be limited separately, got %d", rr.Code)
+	}
+	if rr := doRequest(handler, "127.0.0.1:1", "203.0.113.1"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected forwarded client to be limited, got %d", rr.Code)
+	}
+}
+
+func TestLimit_TrustProxyIgnoresSpoofedEntries(t *testing.T) {
+	limiter, _ := newTestLimiter(Options{Enabled: true, Default: Rule{Rate: 1, Burst: 1}, TrustProxy: true})
+	handler := limiter.Limit("POST /api/todos", okHandler)
+
+	doRequest(handler, "127.0.0.1:1", "203.0.113.1")
+	if rr := doRequest(handler, "127.0.0.1:1", "198.51.100.7, 203.0.113.1"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a spoofed leftmost entry not to get a fresh bucket, got %d", rr.Code)
+	}
+
+	limiter, _ = newTestLimiter(Options{Enabled: true, Default: Rule{Rate: 1, Burst: 1}, TrustProxy: true, ProxyHops: 2})
+	handler = limiter.Limit("POST /api/todos", okHandler)
+	doRequest(handler, "127.0.0.1:1", "203.0.113.1, 10.0.0.1")
+	if rr := doRequest(handler, "127.0.0.1:1", "198.51.100.7, 203.0.113.1, 10.0.0.2"); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the entry two hops from the right to identify the client, got %d", rr.Code)
+	}
+	if rr := doRequest(handler, "127.0.0.1:1", "203.0.113.2, 10.0.0.1"); rr.Code != http.StatusCreated {
+		t.Fatalf("expected another client behind the same proxies to be limited separately, got %d", rr.Code)
+	}
+}
+
+func TestLimit_KeysByPrincipal(t *testing.T) {
+	limiter, _ := newTestLimiter(Options{Enabled: true, Default: Rule{Rate: 1, Burst: 1}})
+	handler := limiter.Limit("POST /api/todos", okHandler)
+
+	request := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/todos", nil)
+		req.RemoteAddr = remoteAddr
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Kind: auth.KindAPIKey, ID: 9}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	request("10.0.0.1:1")
+	if code := request("10.0.0.2:1"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected key to share one budget across hosts, got %d", code)
+	}
+	if rr := doRequest(handler, "10.0.0.1:1", ""); rr.Code != http.StatusCreated {
+		t.Fatalf("expected anonymous client to be limited separately, got %d", rr.Code)
+	}
+}
+
+func TestLimit_Disabled(t *testing.T) {
+	limiter, _ := newTestLimiter(Options{Enabled: false, Default: Rule{Rate: 1, Burst: 1}})
+	handler := limiter.Limit("POST /api/todos", okHandler)
+
+	for i := 0; i < 3; i++ {
+		if rr := doRequest(handler, "10.0.0.1:1", ""); rr.Code != http.StatusCreated {
+			t.Fatalf("expected disabled limiter to allow requests, got %d", rr.Code)
+		}
+	}
+}
+
+func TestSweep_DropsRefilledBuckets(t *testing.T) {
+	limiter, clock := newTestLimiter(Options{Enabled: true, Default: Rule{Rate: 1, Burst: 1}})
+	handler := limiter.Limit("POST /api/todos", okHandler)
+
+	doRequest(handler, "10.0.0.1:1", "")
+	clock.now = clock.now.Add(2 * sweepInterval)
+	doRequest(handler, "10.0.0.2:1", "")
+
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("expected idle bucket to be swept, got %d buckets", len(limiter.buckets))
+	}
+}
@@ -0,0 +1,108 @@
+package reminder
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/respond"
+)
+
+const (
+	maxRequestBytes   = 1 << 16
+	notificationLimit = 100
+)
+
+type Handler struct {
+	store *Store
+}
+
+func NewHandler(store *Store) *Handler {
+	return &Handler{store: store}
+}
+
+type notificationsResponse struct {
+	Unread        int            `json:"unread"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// ListNotifications returns the caller's latest notifications, only the
+// unread ones with ?unread=true, along with the number unread.
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := h.store.Notifications(r.Context(), principal.Key(), unreadOnly, notificationLimit)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch notifications", err)
+		return
+	}
+	unread, err := h.store.UnreadCount(r.Context(), principal.Key())
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch notifications", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, notificationsResponse{Unread: unread, Notifications: notifications})
+}
+
+type updateNotificationRequest struct {
+	Read *bool `json:"read"`
+}
+
+func (h *Handler) UpdateNotification(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid notification id", http.StatusBadRequest)
+		return
+	}
+	var req updateNotificationRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || req.Read == nil {
+		http.Error(w, "read is required", http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.store.MarkRead(r.Context(), principal.Key(), id, *req.Read)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "notification not found", http.StatusNotFound)
+			return
+		}
+		respond.ServerError(w, r, "failed to update notification", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, n)
+}
+
+// ReadAllNotifications marks every notification of the caller read.
+func (h *Handler) ReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.store.MarkAllRead(r.Context(), principal.Key()); err != nil {
+		respond.ServerError(w, r, "failed to update notifications", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func requirePrincipal(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return auth.Principal{}, false
+	}
+	return principal, true
+}
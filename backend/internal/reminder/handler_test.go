@@ -0,0 +1,80 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/todo"
+)
+
+func serve(h http.HandlerFunc, method string, target string, body string, principal *auth.Principal, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func TestHandler_Notifications(t *testing.T) {
+	s, repo, now := setupScheduler(t)
+	h := NewHandler(s.store)
+	ctx := context.Background()
+	alice := &auth.Principal{Kind: auth.KindUser, ID: 1, Scope: auth.ScopeReadWrite}
+	bob := &auth.Principal{Kind: auth.KindUser, ID: 2, Scope: auth.ScopeReadWrite}
+
+	item, _ := repo.Create(ctx, todo.Item{Title: "Call mom"})
+	remind(t, repo, todo.Reminder{TodoID: item.ID, At: ptr(*now)})
+	remind(t, repo, todo.Reminder{TodoID: item.ID, At: ptr(*now)})
+	s.FireDue(ctx)
+
+	list := func(principal *auth.Principal, query string) notificationsResponse {
+		t.Helper()
+		rr := serve(h.ListNotifications, http.MethodGet, "/api/notifications"+query, "", principal, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rr.Code)
+		}
+		var response notificationsResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response
+	}
+
+	if inbox := list(alice, ""); inbox.Unread != 2 || len(inbox.Notifications) != 2 || inbox.Notifications[0].Title != "Call mom" {
+		t.Fatalf("unexpected inbox %#v", inbox)
+	}
+	if inbox := list(bob, ""); inbox.Unread != 0 || len(inbox.Notifications) != 0 {
+		t.Fatalf("expected other users' notifications to be hidden, got %#v", inbox)
+	}
+
+	if rr := serve(h.UpdateNotification, http.MethodPatch, "/api/notifications/1", `{"read":true}`, bob, "1"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for another user's notification, got %d", rr.Code)
+	}
+	rr := serve(h.UpdateNotification, http.MethodPatch, "/api/notifications/1", `{"read":true}`, alice, "1")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"readAt"`) {
+		t.Fatalf("expected the notification to be read, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if inbox := list(alice, "?unread=true"); inbox.Unread != 1 || len(inbox.Notifications) != 1 || inbox.Notifications[0].ID != 2 {
+		t.Fatalf("unexpected unread inbox %#v", inbox)
+	}
+
+	if rr := serve(h.ReadAllNotifications, http.MethodPost, "/api/notifications/read", "", alice, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+	if inbox := list(alice, ""); inbox.Unread != 0 || len(inbox.Notifications) != 2 {
+		t.Fatalf("expected everything read, got %#v", inbox)
+	}
+
+	if rr := serve(h.UpdateNotification, http.MethodPatch, "/api/notifications/1", `{}`, alice, "1"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 without read, got %d", rr.Code)
+	}
+	if rr := serve(h.ListNotifications, http.MethodGet, "/api/notifications", "", nil, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 when anonymous, got %d", rr.Code)
+	}
+}
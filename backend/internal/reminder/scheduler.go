@@ -0,0 +1,121 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"todoapp/backend/internal/email"
+	"todoapp/backend/internal/todo"
+)
+
+// Notifier delivers a fired reminder outside the in-app inbox. Notifiers
+// run after the reminder is recorded as fired, so a failure is logged and
+// not retried.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type Scheduler struct {
+	store     *Store
+	notifiers []Notifier
+	now       func() time.Time
+}
+
+func NewScheduler(store *Store, notifiers ...Notifier) *Scheduler {
+	return &Scheduler{store: store, notifiers: notifiers, now: time.Now}
+}
+
+// Run fires due reminders every interval until ctx is done. Reminders that
+// fell due while the server was down fire on the first run.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.FireDue(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to fire reminders", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FireDue fires every due reminder and returns the notifications created.
+func (s *Scheduler) FireDue(ctx context.Context) ([]Notification, error) {
+	fired, err := s.store.fireDue(ctx, s.now())
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range fired {
+		for _, notifier := range s.notifiers {
+			if err := notifier.Notify(ctx, n); err != nil {
+				slog.WarnContext(ctx, "failed to send reminder", "notification", n.ID, "todo", n.TodoID, "error", err)
+			}
+		}
+	}
+	return fired, nil
+}
+
+type EventQueue interface {
+	Enqueue(ctx context.Context, event todo.Event) error
+}
+
+type TodoGetter interface {
+	Get(ctx context.Context, id int64) (todo.Item, error)
+}
+
+// WebhookNotifier raises a todo.reminder event, delivered to webhooks
+// subscribed to it with their usual retries.
+type WebhookNotifier struct {
+	events EventQueue
+	todos  TodoGetter
+}
+
+func NewWebhookNotifier(events EventQueue, todos TodoGetter) *WebhookNotifier {
+	return &WebhookNotifier{events: events, todos: todos}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	item, err := w.todos.Get(ctx, n.TodoID)
+	if errors.Is(err, todo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return w.events.Enqueue(ctx, todo.Event{Type: todo.EventReminder, Time: n.CreatedAt, Item: item})
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg email.Message) error
+}
+
+// EmailNotifier mails reminders to signed-in users. Reminders set with an
+// API key have no address and are skipped.
+type EmailNotifier struct {
+	mailer Mailer
+}
+
+func NewEmailNotifier(mailer Mailer) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer}
+}
+
+func (e *EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Email == "" {
+		return nil
+	}
+	text := "Reminder: " + n.Title + "\n"
+	if n.Due != nil {
+		text += fmt.Sprintf("Due: %s\n", n.Due.UTC().Format("Mon, 02 Jan 2006 15:04 MST"))
+	}
+	return e.mailer.Send(ctx, email.Message{
+		To:      []string{n.Email},
+		Subject: "Reminder: " + n.Title,
+		Text:    text,
+	})
+}
@@ -0,0 +1,155 @@
+package reminder
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/email"
+	"todoapp/backend/internal/email/emailtest"
+	"todoapp/backend/internal/todo"
+)
+
+func setupScheduler(t *testing.T, notifiers ...Notifier) (*Scheduler, *todo.Repository, *time.Time) {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	if _, err := database.Exec(`
+		INSERT INTO users (id, issuer, subject, email, name, created_at, updated_at)
+		VALUES (1, 'https://idp.example.com', 'alice', 'alice@example.com', 'Alice', ?, ?)`, now, now); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	store := NewStore(database)
+	store.now = func() time.Time { return now }
+	scheduler := NewScheduler(store, notifiers...)
+	scheduler.now = func() time.Time { return now }
+	return scheduler, todo.NewRepository(database), &now
+}
+
+func remind(t *testing.T, repo *todo.Repository, reminder todo.Reminder) todo.Reminder {
+	t.Helper()
+	if reminder.OwnerKey == "" {
+		userID := int64(1)
+		reminder.OwnerKey, reminder.UserID = "user:1", &userID
+	}
+	created, err := repo.CreateReminder(context.Background(), reminder)
+	if err != nil {
+		t.Fatalf("create reminder: %v", err)
+	}
+	return created
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestScheduler_FiresDueRemindersOnce(t *testing.T) {
+	s, repo, now := setupScheduler(t)
+	ctx := context.Background()
+
+	due := now.Add(2 * time.Hour)
+	item, _ := repo.Create(ctx, todo.Item{Title: "Dentist", Due: &due})
+	undated, _ := repo.Create(ctx, todo.Item{Title: "Someday"})
+	absolute := remind(t, repo, todo.Reminder{TodoID: item.ID, At: ptr(now.Add(30 * time.Minute))})
+	relative := remind(t, repo, todo.Reminder{TodoID: item.ID, MinutesBefore: ptr(60)})
+	remind(t, repo, todo.Reminder{TodoID: undated.ID, MinutesBefore: ptr(0)})
+
+	if fired, err := s.FireDue(ctx); err != nil || len(fired) != 0 {
+		t.Fatalf("expected nothing to be due yet, got %#v: %v", fired, err)
+	}
+
+	*now = now.Add(time.Hour)
+	fired, err := s.FireDue(ctx)
+	if err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if len(fired) != 2 || fired[0].ReminderID != absolute.ID || fired[1].ReminderID != relative.ID {
+		t.Fatalf("expected both reminders on the dentist to fire, got %#v", fired)
+	}
+	if fired[0].Title != "Dentist" || fired[0].Email != "alice@example.com" || fired[0].Recipient != "user:1" || !fired[0].Due.Equal(due) {
+		t.Fatalf("unexpected notification %#v", fired[0])
+	}
+
+	if fired, _ := s.FireDue(ctx); len(fired) != 0 {
+		t.Fatalf("expected reminders to fire once, got %#v", fired)
+	}
+	inbox, _ := s.store.Notifications(ctx, "user:1", true, 10)
+	if len(inbox) != 2 {
+		t.Fatalf("expected two unread notifications, got %#v", inbox)
+	}
+	reminders, _ := repo.ListReminders(ctx, item.ID, "user:1")
+	if reminders[0].FiredAt == nil || reminders[1].FiredAt == nil {
+		t.Fatalf("expected the reminders to be marked fired, got %#v", reminders)
+	}
+}
+
+func TestScheduler_FollowsDueDateAndSkipsCompletedTodos(t *testing.T) {
+	s, repo, now := setupScheduler(t)
+	ctx := context.Background()
+
+	item, _ := repo.Create(ctx, todo.Item{Title: "Report"})
+	remind(t, repo, todo.Reminder{TodoID: item.ID, MinutesBefore: ptr(15)})
+
+	due := now.Add(10 * time.Minute)
+	if _, err := repo.Update(ctx, item.ID, todo.Changes{SetDue: true, Due: &due}, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	repo.UpdateCompleted(ctx, item.ID, true, nil)
+	if fired, _ := s.FireDue(ctx); len(fired) != 0 {
+		t.Fatalf("expected completed todos not to remind, got %#v", fired)
+	}
+
+	repo.UpdateCompleted(ctx, item.ID, false, nil)
+	if fired, _ := s.FireDue(ctx); len(fired) != 1 {
+		t.Fatalf("expected the reminder to fire once the todo reopened, got %#v", fired)
+	}
+}
+
+type fakeQueue struct {
+	events []todo.Event
+}
+
+func (f *fakeQueue) Enqueue(_ context.Context, event todo.Event) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func TestScheduler_Notifiers(t *testing.T) {
+	server := emailtest.NewServer(t)
+	queue := &fakeQueue{}
+	s, repo, now := setupScheduler(t)
+	s.notifiers = []Notifier{
+		NewEmailNotifier(email.NewSender(email.Config{Addr: server.Addr, From: "todo@example.com"})),
+		NewWebhookNotifier(queue, repo),
+	}
+	ctx := context.Background()
+
+	item, _ := repo.Create(ctx, todo.Item{Title: "Water plants"})
+	remind(t, repo, todo.Reminder{TodoID: item.ID, At: ptr(*now)})
+	remind(t, repo, todo.Reminder{TodoID: item.ID, At: ptr(*now), OwnerKey: "api_key:3"})
+
+	if fired, err := s.FireDue(ctx); err != nil || len(fired) != 2 {
+		t.Fatalf("expected two reminders to fire, got %#v: %v", fired, err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "alice@example.com" || !strings.Contains(messages[0].Data, "Subject: Reminder: Water plants") {
+		t.Fatalf("expected one email to the signed-in user, got %#v", messages)
+	}
+	if len(queue.events) != 2 || queue.events[0].Type != todo.EventReminder || queue.events[0].Item.Title != "Water plants" {
+		t.Fatalf("unexpected events %#v", queue.events)
+	}
+}
@@ -0,0 +1,231 @@
+// Package reminder fires todo reminders and keeps the in-app notification
+// inbox. Reminders are stored by the todo package; the Scheduler finds
+// the ones that are due, marks them fired and hands the resulting
+// notifications to its notifiers.
+package reminder
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todoapp/backend/internal/todo"
+)
+
+var ErrNotFound = errors.New("notification not found")
+
+// Notification is a fired reminder in its recipient's inbox.
+type Notification struct {
+	ID         int64      `json:"id"`
+	ReminderID int64      `json:"reminderId"`
+	TodoID     int64      `json:"todoId"`
+	Title      string     `json:"title"`
+	Due        *time.Time `json:"due,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ReadAt     *time.Time `json:"readAt,omitempty"`
+	// Recipient is the principal key the reminder belongs to and Email
+	// their address, empty unless they are a signed-in user.
+	Recipient string `json:"-"`
+	Email     string `json:"-"`
+}
+
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+const notificationColumns = `id, reminder_id, todo_id, title, due, created_at, read_at, recipient_key FROM notifications`
+
+// Notifications returns recipient's latest notifications, newest first.
+func (s *Store) Notifications(ctx context.Context, recipient string, unreadOnly bool, limit int) ([]Notification, error) {
+	query := `SELECT ` + notificationColumns + ` WHERE recipient_key = ?`
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY id DESC LIMIT ?`, recipient, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]Notification, 0)
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (s *Store) UnreadCount(ctx context.Context, recipient string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notifications WHERE recipient_key = ? AND read_at IS NULL`, recipient).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks one of recipient's notifications read or unread.
+func (s *Store) MarkRead(ctx context.Context, recipient string, id int64, read bool) (Notification, error) {
+	var readAt sql.NullTime
+	if read {
+		readAt = sql.NullTime{Time: s.now().UTC(), Valid: true}
+	}
+	// Marking a read notification read again keeps the original time.
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE notifications SET read_at = CASE WHEN ? AND read_at IS NOT NULL THEN read_at ELSE ? END
+		WHERE id = ? AND recipient_key = ?`, read, readAt, id, recipient)
+	if err != nil {
+		return Notification{}, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return Notification{}, err
+	}
+	if updated == 0 {
+		return Notification{}, ErrNotFound
+	}
+	return scanNotification(s.db.QueryRowContext(ctx, `SELECT `+notificationColumns+` WHERE id = ?`, id))
+}
+
+// MarkAllRead marks every unread notification of recipient read and
+// returns how many there were.
+func (s *Store) MarkAllRead(ctx context.Context, recipient string) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE notifications SET read_at = ? WHERE recipient_key = ? AND read_at IS NULL`, s.now().UTC(), recipient)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// fireDue marks every reminder due at now fired and adds it to its owner's
+// inbox, in one transaction, so each reminder fires exactly once even
+// across restarts. Reminders on completed todos wait until they reopen.
+func (s *Store) fireDue(ctx context.Context, now time.Time) ([]Notification, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Relative reminders can only be due if their todo is due within the
+	// longest allowed offset; the exact time is checked below.
+	horizon := now.Add(todo.MaxReminderMinutesBefore * time.Minute)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT reminders.id, reminders.todo_id, reminders.owner_key, reminders.remind_at, reminders.minutes_before,
+			todos.title, todos.due, COALESCE(users.email, '')
+		FROM reminders
+		JOIN todos ON todos.id = reminders.todo_id
+		LEFT JOIN users ON users.id = reminders.user_id
+		WHERE reminders.fired_at IS NULL AND todos.completed = 0
+			AND (reminders.remind_at <= ? OR (reminders.remind_at IS NULL AND todos.due <= ?))
+		ORDER BY reminders.id ASC`, now.UTC(), horizon.UTC())
+	if err != nil {
+		return nil, err
+	}
+	var due []Notification
+	for rows.Next() {
+		var (
+			reminder      todo.Reminder
+			n             Notification
+			remindAt      sql.NullTime
+			minutesBefore sql.NullInt64
+			todoDue       sql.NullTime
+		)
+		if err := rows.Scan(&reminder.ID, &n.TodoID, &n.Recipient, &remindAt, &minutesBefore, &n.Title, &todoDue, &n.Email); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if remindAt.Valid {
+			reminder.At = &remindAt.Time
+		}
+		if minutesBefore.Valid {
+			minutes := int(minutesBefore.Int64)
+			reminder.MinutesBefore = &minutes
+		}
+		if todoDue.Valid {
+			n.Due = &todoDue.Time
+		}
+		if fireAt := reminder.FireTime(n.Due); fireAt == nil || fireAt.After(now) {
+			continue
+		}
+		n.ReminderID = reminder.ID
+		due = append(due, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fired := make([]Notification, 0, len(due))
+	for _, n := range due {
+		n.CreatedAt = now.UTC()
+		result, err := tx.ExecContext(ctx, `UPDATE reminders SET fired_at = ? WHERE id = ? AND fired_at IS NULL`, n.CreatedAt, n.ReminderID)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if updated == 0 {
+			// Fired by a concurrent run.
+			continue
+		}
+		result, err = tx.ExecContext(ctx, `
+			INSERT INTO notifications (recipient_key, reminder_id, todo_id, title, due, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)`, n.Recipient, n.ReminderID, n.TodoID, n.Title, nullTime(n.Due), n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if n.ID, err = result.LastInsertId(); err != nil {
+			return nil, err
+		}
+		fired = append(fired, n)
+	}
+	return fired, tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanNotification(row rowScanner) (Notification, error) {
+	var (
+		n           Notification
+		reminderID  sql.NullInt64
+		due, readAt sql.NullTime
+	)
+	err := row.Scan(&n.ID, &reminderID, &n.TodoID, &n.Title, &due, &n.CreatedAt, &readAt, &n.Recipient)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Notification{}, ErrNotFound
+	}
+	if err != nil {
+		return Notification{}, err
+	}
+	n.ReminderID = reminderID.Int64
+	if due.Valid {
+		n.Due = &due.Time
+	}
+	if readAt.Valid {
+		n.ReadAt = &readAt.Time
+	}
+	return n, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
@@ -0,0 +1,56 @@
+package respond
+
+import "net/http"
+
+// Recorder remembers the status and size of the response written through
+// it, for middleware that reports on a request once the handler returns.
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Status is the status written, or 200 when the handler wrote nothing.
+func (r *Recorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Bytes is how much of the body has been written.
+func (r *Recorder) Bytes() int64 {
+	return r.bytes
+}
+
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush lets streamed responses, such as gRPC calls, through the recorder.
+func (r *Recorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
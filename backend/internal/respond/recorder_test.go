@@ -0,0 +1,27 @@
+package respond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	rr := httptest.NewRecorder()
+	recorder := NewRecorder(rr)
+	if recorder.Status() != http.StatusOK {
+		t.Fatalf("expected 200 before anything is written, got %d", recorder.Status())
+	}
+
+	recorder.WriteHeader(http.StatusNotFound)
+	recorder.WriteHeader(http.StatusInternalServerError)
+	recorder.Write([]byte("not found"))
+	recorder.Flush()
+
+	if recorder.Status() != http.StatusNotFound || recorder.Bytes() != 9 {
+		t.Fatalf("expected the first status and the body size, got %d and %d", recorder.Status(), recorder.Bytes())
+	}
+	if !rr.Flushed || rr.Body.String() != "not found" {
+		t.Fatalf("expected the response to pass through, got %q flushed=%v", rr.Body.String(), rr.Flushed)
+	}
+}
@@ -0,0 +1,25 @@
+// Package respond writes the JSON and error responses shared by the API
+// handlers, and records responses for the middleware that reports on them.
+package respond
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// JSON writes body as the JSON response with status.
+func JSON(w http.ResponseWriter, r *http.Request, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.ErrorContext(r.Context(), "failed to encode response", "error", err)
+	}
+}
+
+// ServerError logs err and answers 500 with message, keeping the cause out
+// of the response.
+func ServerError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, "error", err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
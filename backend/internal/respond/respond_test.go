@@ -0,0 +1,36 @@
+package respond
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	rr := httptest.NewRecorder()
+	JSON(rr, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusCreated, map[string]int{"id": 1})
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	if got := rr.Body.String(); got != "{\"id\":1}\n" {
+		t.Fatalf("unexpected body: %q", got)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ServerError(rr, httptest.NewRequest(http.MethodGet, "/", nil), "failed to fetch todos", errors.New("disk on fire"))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "failed to fetch todos") || strings.Contains(body, "disk on fire") {
+		t.Fatalf("unexpected body: %q", body)
+	}
+}
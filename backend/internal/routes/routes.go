@@ -0,0 +1,170 @@
+// Package routes registers the server's HTTP routes, so that cmd/server and
+// the tests that exercise the real handlers serve the same table.
+package routes
+
+import (
+	"net/http"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/caldav"
+	"todoapp/backend/internal/digest"
+	"todoapp/backend/internal/mcp"
+	"todoapp/backend/internal/openapi"
+	"todoapp/backend/internal/ratelimit"
+	"todoapp/backend/internal/reminder"
+	"todoapp/backend/internal/rpc"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+	"todoapp/backend/internal/webhook"
+)
+
+// Mux is where routes are registered; *http.ServeMux is one.
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// Handlers holds what the routes serve. The admin handlers, Keys and
+// Webhooks, and the handlers for signed-in users, Login, Lists, FeedTokens
+// and Digest, are optional: their routes are registered only when they are
+// set.
+type Handlers struct {
+	Todos         *todo.Handler
+	Connect       *rpc.ConnectHandler
+	MCP           *mcp.Handler
+	CalDAV        http.Handler
+	Notifications *reminder.Handler
+	Spec          *openapi.Handler
+
+	Keys     *auth.KeyHandler
+	Webhooks *webhook.Handler
+
+	Login      *auth.LoginHandler
+	Lists      *sharing.Handler
+	FeedTokens *auth.FeedTokenHandler
+	Digest     *digest.Handler
+}
+
+type registrar struct {
+	mux             Mux
+	limiter         *ratelimit.Limiter
+	anonymousWrites bool
+}
+
+type Option func(*registrar)
+
+// WithRateLimit throttles each route with the rule limiter has for its
+// pattern.
+func WithRateLimit(limiter *ratelimit.Limiter) Option {
+	return func(r *registrar) { r.limiter = limiter }
+}
+
+// WithAnonymousWrites lets requests without credentials reach the routes
+// that write, as when authentication is not required.
+func WithAnonymousWrites(allow bool) Option {
+	return func(r *registrar) { r.anonymousWrites = allow }
+}
+
+func (r *registrar) route(pattern string, h http.Handler) {
+	if r.limiter != nil {
+		h = r.limiter.Limit(pattern, h)
+	}
+	r.mux.Handle(pattern, h)
+}
+
+func (r *registrar) write(h http.HandlerFunc) http.Handler {
+	return auth.RequireScope(auth.ScopeReadWrite, r.anonymousWrites, h)
+}
+
+func admin(h http.HandlerFunc) http.Handler {
+	return auth.RequireAdmin(h)
+}
+
+// Register adds the routes for h to mux.
+func Register(mux Mux, h Handlers, opts ...Option) {
+	r := &registrar{mux: mux}
+	for _, opt := range opts {
+		opt(r)
+	}
+	route, write := r.route, r.write
+
+	todos := h.Todos
+	route("GET /api/todos", http.HandlerFunc(todos.ListTodos))
+	route("POST /api/todos", write(todos.CreateTodo))
+	route("GET /api/todos/{id}", http.HandlerFunc(todos.GetTodo))
+	route("PATCH /api/todos/{id}", write(todos.UpdateTodo))
+	route("DELETE /api/todos/{id}", write(todos.DeleteTodo))
+	route("GET /api/export", http.HandlerFunc(todos.ExportTodos))
+	route("POST /api/import", write(todos.ImportTodos))
+	route("POST /api/import/takt", write(todos.ImportTasks))
+	route("GET /api/sync", http.HandlerFunc(todos.PullChanges))
+	route("POST /api/sync", write(todos.PushChanges))
+	route("GET /api/calendar.ics", http.HandlerFunc(todos.CalendarFeed))
+	route("POST /api/calendar.ics", write(todos.ImportCalendar))
+	route("POST /graphql", http.HandlerFunc(todos.GraphQL))
+	route("GET /graphql", http.HandlerFunc(todos.GraphQLQuery))
+	route("GET /graphql/schema", http.HandlerFunc(todos.GraphQLSchema))
+	route("GET /api/todos/{id}/comments", http.HandlerFunc(todos.ListComments))
+	route("POST /api/todos/{id}/comments", write(todos.CreateComment))
+	route("PATCH /api/todos/{id}/comments/{commentId}", write(todos.UpdateComment))
+	route("DELETE /api/todos/{id}/comments/{commentId}", write(todos.DeleteComment))
+	route("GET /api/todos/{id}/reminders", http.HandlerFunc(todos.ListReminders))
+	route("POST /api/todos/{id}/reminders", write(todos.CreateReminder))
+	route("DELETE /api/todos/{id}/reminders/{reminderId}", write(todos.DeleteReminder))
+	route("GET /api/todos/{id}/attachments", http.HandlerFunc(todos.ListAttachments))
+	route("POST /api/todos/{id}/attachments", write(todos.UploadAttachment))
+	route("GET /api/todos/{id}/attachments/{attachmentId}", http.HandlerFunc(todos.DownloadAttachment))
+	route("DELETE /api/todos/{id}/attachments/{attachmentId}", write(todos.DeleteAttachment))
+
+	route("POST /todo.v1.TodoService/List", http.HandlerFunc(h.Connect.List))
+	route("POST /todo.v1.TodoService/Create", http.HandlerFunc(h.Connect.Create))
+	route("POST /todo.v1.TodoService/Update", http.HandlerFunc(h.Connect.Update))
+	route("POST /todo.v1.TodoService/Delete", http.HandlerFunc(h.Connect.Delete))
+	route("POST /todo.v1.TodoService/Watch", http.HandlerFunc(h.Connect.Watch))
+	route("POST /mcp", http.HandlerFunc(h.MCP.Post))
+	route("DELETE /mcp", http.HandlerFunc(h.MCP.Delete))
+	route(caldav.Prefix, h.CalDAV)
+	route("/.well-known/caldav", http.RedirectHandler(caldav.Prefix, http.StatusMovedPermanently))
+	route("GET /api/notifications", http.HandlerFunc(h.Notifications.ListNotifications))
+	route("PATCH /api/notifications/{id}", write(h.Notifications.UpdateNotification))
+	route("POST /api/notifications/read", write(h.Notifications.ReadAllNotifications))
+	route("GET /api/openapi.json", http.HandlerFunc(h.Spec.Spec))
+	route("GET /api/docs", http.HandlerFunc(h.Spec.Docs))
+
+	if h.Keys != nil {
+		route("GET /api/keys", admin(h.Keys.ListKeys))
+		route("POST /api/keys", admin(h.Keys.CreateKey))
+		route("DELETE /api/keys/{id}", admin(h.Keys.RevokeKey))
+	}
+	if h.Webhooks != nil {
+		route("GET /api/webhooks", admin(h.Webhooks.ListWebhooks))
+		route("POST /api/webhooks", admin(h.Webhooks.CreateWebhook))
+		route("DELETE /api/webhooks/{id}", admin(h.Webhooks.DeleteWebhook))
+		route("GET /api/webhooks/{id}/deliveries", admin(h.Webhooks.ListDeliveries))
+		route("POST /api/webhooks/{id}/deliveries/{deliveryId}/redeliver", admin(h.Webhooks.Redeliver))
+	}
+
+	if h.Login != nil {
+		route("GET /auth/login", http.HandlerFunc(h.Login.Login))
+		route("GET /auth/callback", http.HandlerFunc(h.Login.Callback))
+		route("POST /auth/logout", http.HandlerFunc(h.Login.Logout))
+		route("GET /auth/me", http.HandlerFunc(h.Login.Me))
+	}
+	if h.Lists != nil {
+		route("GET /api/lists", http.HandlerFunc(h.Lists.ListLists))
+		route("POST /api/lists", http.HandlerFunc(h.Lists.CreateList))
+		route("GET /api/lists/{id}/members", http.HandlerFunc(h.Lists.ListMembers))
+		route("PATCH /api/lists/{id}/members/{userId}", http.HandlerFunc(h.Lists.UpdateMember))
+		route("DELETE /api/lists/{id}/members/{userId}", http.HandlerFunc(h.Lists.RemoveMember))
+		route("POST /api/lists/{id}/invitations", http.HandlerFunc(h.Lists.Invite))
+		route("POST /api/invitations/accept", http.HandlerFunc(h.Lists.AcceptInvitation))
+	}
+	if h.FeedTokens != nil {
+		route("POST /api/calendar/token", http.HandlerFunc(h.FeedTokens.IssueFeedToken))
+		route("DELETE /api/calendar/token", http.HandlerFunc(h.FeedTokens.RevokeFeedToken))
+	}
+	if h.Digest != nil {
+		route("GET /api/digest/settings", http.HandlerFunc(h.Digest.GetSettings))
+		route("PUT /api/digest/settings", http.HandlerFunc(h.Digest.UpdateSettings))
+		route("GET /api/digest/preview", http.HandlerFunc(h.Digest.Preview))
+	}
+}
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/digest"
+	"todoapp/backend/internal/mcp"
+	"todoapp/backend/internal/openapi"
+	"todoapp/backend/internal/reminder"
+	"todoapp/backend/internal/rpc"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+	"todoapp/backend/internal/webhook"
+)
+
+type recorder []string
+
+func (r *recorder) Handle(pattern string, handler http.Handler) {
+	*r = append(*r, pattern)
+}
+
+// registeredRoutes returns every pattern Register adds when all the
+// optional handlers are set. The handlers are never called.
+func registeredRoutes() []string {
+	var patterns recorder
+	Register(&patterns, Handlers{
+		Todos:         &todo.Handler{},
+		Connect:       &rpc.ConnectHandler{},
+		MCP:           &mcp.Handler{},
+		CalDAV:        http.NotFoundHandler(),
+		Notifications: &reminder.Handler{},
+		Spec:          &openapi.Handler{},
+		Keys:          &auth.KeyHandler{},
+		Webhooks:      &webhook.Handler{},
+		Login:         &auth.LoginHandler{},
+		Lists:         &sharing.Handler{},
+		FeedTokens:    &auth.FeedTokenHandler{},
+		Digest:        &digest.Handler{},
+	})
+	return patterns
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("load openapi document: %v", err)
+	}
+	documented := spec.Routes()
+	registered := registeredRoutes()
+
+	// A pattern without a method serves every method on its path, so any
+	// operation on that path is registered.
+	path := func(pattern string) string {
+		if _, p, ok := strings.Cut(pattern, " "); ok {
+			return p
+		}
+		return pattern
+	}
+	for _, pattern := range registered {
+		if strings.Contains(pattern, " ") {
+			if !slices.Contains(documented, pattern) {
+				t.Errorf("route %q is not described in openapi.yaml", pattern)
+			}
+		} else if !slices.ContainsFunc(documented, func(route string) bool { return path(route) == pattern }) {
+			t.Errorf("path %q is not described in openapi.yaml", pattern)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(registered, route) && !slices.Contains(registered, path(route)) {
+			t.Errorf("openapi.yaml describes %q, which Register does not register", route)
+		}
+	}
+}
+
+func TestRegister_SkipsUnsetOptionalHandlers(t *testing.T) {
+	var patterns recorder
+	Register(&patterns, Handlers{
+		Todos:         &todo.Handler{},
+		Connect:       &rpc.ConnectHandler{},
+		MCP:           &mcp.Handler{},
+		CalDAV:        http.NotFoundHandler(),
+		Notifications: &reminder.Handler{},
+		Spec:          &openapi.Handler{},
+	})
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "/api/keys") || strings.Contains(pattern, "/api/webhooks") || strings.Contains(pattern, "/auth/") || strings.Contains(pattern, "/api/lists") || strings.Contains(pattern, "/api/digest") {
+			t.Errorf("expected %q to need its optional handler", pattern)
+		}
+	}
+	if len(patterns) >= len(registeredRoutes()) {
+		t.Fatalf("expected fewer routes without the optional handlers, got %d", len(patterns))
+	}
+}
@@ -0,0 +1,292 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"todoapp/backend/pkg/todov1"
+)
+
+// The Connect protocol, https://connectrpc.com/docs/protocol, sends unary
+// calls as plain POSTs and streams as enveloped messages.
+const (
+	unaryContentType  = "application/json"
+	streamContentType = "application/connect+json"
+
+	// flagEndStream marks the envelope that ends a stream, which carries
+	// the call's error, if any.
+	flagEndStream = 0x02
+	// flagCompressed marks a compressed envelope. Compression is not
+	// negotiated, so clients never have reason to send one.
+	flagCompressed = 0x01
+)
+
+// ConnectHandler serves TodoService to Connect clients with the JSON codec,
+// so that the API is reachable with nothing more than curl and HTTP/1.1.
+type ConnectHandler struct {
+	svc          *Service
+	maxBodyBytes int64
+}
+
+func NewConnectHandler(svc *Service, maxBodyBytes int64) *ConnectHandler {
+	return &ConnectHandler{svc: svc, maxBodyBytes: maxBodyBytes}
+}
+
+func (h *ConnectHandler) List(w http.ResponseWriter, r *http.Request) {
+	serveStream(w, r, h.maxBodyBytes, &todov1.ListRequest{}, h.svc.List)
+}
+
+func (h *ConnectHandler) Create(w http.ResponseWriter, r *http.Request) {
+	serveUnary(w, r, h.maxBodyBytes, &todov1.CreateRequest{}, h.svc.Create)
+}
+
+func (h *ConnectHandler) Update(w http.ResponseWriter, r *http.Request) {
+	serveUnary(w, r, h.maxBodyBytes, &todov1.UpdateRequest{}, h.svc.Update)
+}
+
+func (h *ConnectHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	serveUnary(w, r, h.maxBodyBytes, &todov1.DeleteRequest{}, h.svc.Delete)
+}
+
+func (h *ConnectHandler) Watch(w http.ResponseWriter, r *http.Request) {
+	serveStream(w, r, h.maxBodyBytes, &todov1.WatchRequest{}, h.svc.Watch)
+}
+
+var unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+func serveUnary[Req, Res proto.Message](w http.ResponseWriter, r *http.Request, maxBodyBytes int64, req Req, call func(context.Context, Req) (Res, error)) {
+	if !hasContentType(w, r, unaryContentType) {
+		return
+	}
+	ctx, cancel, err := callContext(r)
+	if err != nil {
+		writeUnaryError(w, r, err)
+		return
+	}
+	defer cancel()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeUnaryError(w, r, readError(err))
+		return
+	}
+	if len(body) > 0 {
+		if err := unmarshalOptions.Unmarshal(body, req); err != nil {
+			writeUnaryError(w, r, status.Error(codes.InvalidArgument, "invalid request body"))
+			return
+		}
+	}
+
+	res, err := call(ctx, req)
+	if err != nil {
+		writeUnaryError(w, r, err)
+		return
+	}
+	data, err := protojson.Marshal(res)
+	if err != nil {
+		writeUnaryError(w, r, internal(ctx, "failed to encode response", err))
+		return
+	}
+	w.Header().Set("Content-Type", unaryContentType)
+	if _, err := w.Write(data); err != nil {
+		slog.ErrorContext(ctx, "failed to write response", "error", err)
+	}
+}
+
+func serveStream[Req proto.Message, Res any](w http.ResponseWriter, r *http.Request, maxBodyBytes int64, req Req, call func(Req, grpc.ServerStreamingServer[Res]) error) {
+	if !hasContentType(w, r, streamContentType) {
+		return
+	}
+	w.Header().Set("Content-Type", streamContentType)
+	stream := &connectStream[Res]{ctx: r.Context(), w: w}
+
+	ctx, cancel, err := callContext(r)
+	if err != nil {
+		stream.end(err)
+		return
+	}
+	defer cancel()
+	stream.ctx = ctx
+
+	if err := readEnvelope(r.Body, maxBodyBytes, req); err != nil {
+		stream.end(err)
+		return
+	}
+	stream.end(call(req, stream))
+}
+
+// hasContentType answers 415 unless the request body is of contentType.
+func hasContentType(w http.ResponseWriter, r *http.Request, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != contentType {
+		w.Header().Set("Accept-Post", contentType)
+		http.Error(w, "content type must be "+contentType, http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// callContext applies the deadline a client sets with Connect-Timeout-Ms.
+func callContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	raw := r.Header.Get("Connect-Timeout-Ms")
+	if raw == "" {
+		ctx, cancel := context.WithCancel(r.Context())
+		return ctx, cancel, nil
+	}
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || ms <= 0 || len(raw) > 10 {
+		return nil, nil, status.Error(codes.InvalidArgument, "invalid Connect-Timeout-Ms header")
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(ms)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
+func readError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return status.Error(codes.InvalidArgument, "request body too large")
+	}
+	return status.Error(codes.InvalidArgument, "invalid request body")
+}
+
+// readEnvelope reads the single message of a server-streaming call.
+func readEnvelope(body io.Reader, maxBytes int64, req proto.Message) error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return readError(err)
+	}
+	if prefix[0]&flagCompressed != 0 {
+		return status.Error(codes.Unimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if int64(size) > maxBytes {
+		return status.Error(codes.InvalidArgument, "request body too large")
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return readError(err)
+	}
+	if err := unmarshalOptions.Unmarshal(data, req); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid request body")
+	}
+	return nil
+}
+
+// connectStream adapts a Connect response stream to the gRPC stream the
+// service writes to. Headers and trailers are not used by the service.
+type connectStream[Res any] struct {
+	ctx context.Context
+	w   http.ResponseWriter
+}
+
+func (s *connectStream[Res]) Send(m *Res) error {
+	return s.SendMsg(m)
+}
+
+func (s *connectStream[Res]) SendMsg(m any) error {
+	data, err := protojson.Marshal(m.(proto.Message))
+	if err != nil {
+		return internal(s.ctx, "failed to encode response", err)
+	}
+	return s.writeEnvelope(0, data)
+}
+
+func (s *connectStream[Res]) RecvMsg(any) error            { return io.EOF }
+func (s *connectStream[Res]) SetHeader(metadata.MD) error  { return nil }
+func (s *connectStream[Res]) SendHeader(metadata.MD) error { return nil }
+func (s *connectStream[Res]) SetTrailer(metadata.MD)       {}
+func (s *connectStream[Res]) Context() context.Context     { return s.ctx }
+
+func (s *connectStream[Res]) writeEnvelope(flags byte, data []byte) error {
+	envelope := make([]byte, 5, 5+len(data))
+	envelope[0] = flags
+	binary.BigEndian.PutUint32(envelope[1:], uint32(len(data)))
+	if _, err := s.w.Write(append(envelope, data...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
+
+// end writes the end-of-stream message, which reports err.
+func (s *connectStream[Res]) end(err error) {
+	var message struct {
+		Error *connectError `json:"error,omitempty"`
+	}
+	if err != nil {
+		message.Error, _ = toConnectError(err)
+	}
+	data, _ := json.Marshal(message)
+	if err := s.writeEnvelope(flagEndStream, data); err != nil {
+		slog.DebugContext(s.ctx, "failed to end stream", "error", err)
+	}
+}
+
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// connectCodes names the gRPC codes the way Connect does, with the HTTP
+// status of a unary call that fails with each.
+var connectCodes = map[codes.Code]struct {
+	name   string
+	status int
+}{
+	codes.Canceled:           {"canceled", 499},
+	codes.Unknown:            {"unknown", http.StatusInternalServerError},
+	codes.InvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	codes.DeadlineExceeded:   {"deadline_exceeded", http.StatusGatewayTimeout},
+	codes.NotFound:           {"not_found", http.StatusNotFound},
+	codes.AlreadyExists:      {"already_exists", http.StatusConflict},
+	codes.PermissionDenied:   {"permission_denied", http.StatusForbidden},
+	codes.ResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	codes.FailedPrecondition: {"failed_precondition", http.StatusBadRequest},
+	codes.Aborted:            {"aborted", http.StatusConflict},
+	codes.OutOfRange:         {"out_of_range", http.StatusBadRequest},
+	codes.Unimplemented:      {"unimplemented", http.StatusNotImplemented},
+	codes.Internal:           {"internal", http.StatusInternalServerError},
+	codes.Unavailable:        {"unavailable", http.StatusServiceUnavailable},
+	codes.DataLoss:           {"data_loss", http.StatusInternalServerError},
+	codes.Unauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+// toConnectError describes err to the client, with the HTTP status a unary
+// call answers with.
+func toConnectError(err error) (*connectError, int) {
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.FromContextError(err)
+	}
+	code, ok := connectCodes[st.Code()]
+	if !ok {
+		code = connectCodes[codes.Unknown]
+	}
+	return &connectError{Code: code.name, Message: st.Message()}, code.status
+}
+
+func writeUnaryError(w http.ResponseWriter, r *http.Request, err error) {
+	connectErr, httpStatus := toConnectError(err)
+	if httpStatus == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	w.Header().Set("Content-Type", unaryContentType)
+	w.WriteHeader(httpStatus)
+	if err := json.NewEncoder(w).Encode(connectErr); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write error", "error", err)
+	}
+}
@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+
+	"todoapp/backend/pkg/todov1"
+)
+
+// NewServer returns a gRPC server for svc with reflection enabled, so that
+// tools such as grpcurl can discover the API. It is served through
+// Multiplex rather than on a listener of its own.
+func NewServer(svc *Service) *grpc.Server {
+	server := grpc.NewServer()
+	todov1.RegisterTodoServiceServer(server, svc)
+	reflection.Register(server)
+	return server
+}
+
+// Multiplex sends gRPC calls to grpcHandler and every other request,
+// Connect calls included, to next. gRPC needs HTTP/2, which the server
+// must accept without TLS as well for plaintext clients.
+func Multiplex(grpcHandler http.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,354 @@
+// Package rpc serves the todo.v1.TodoService defined in
+// proto/todo/v1/todo.proto, over gRPC and over the Connect protocol's JSON
+// encoding. Both share the HTTP server, and so the authentication, logging,
+// tracing and rate limiting, of the REST API.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+	"todoapp/backend/pkg/todov1"
+)
+
+// defaultPollInterval is how often Watch looks for changes.
+const defaultPollInterval = time.Second
+
+// Service implements TodoService on the repository the REST API uses,
+// enforcing the same scopes and list roles.
+type Service struct {
+	todov1.UnimplementedTodoServiceServer
+
+	repo           todo.ReaderWriter
+	permissions    todo.Permissions
+	sync           todo.SyncStore
+	allowAnonymous bool
+	maxTitleLength int
+	pollInterval   time.Duration
+}
+
+type Option func(*Service)
+
+// WithPermissions enables shared lists. Without it only the default list
+// is reachable.
+func WithPermissions(p todo.Permissions) Option {
+	return func(s *Service) { s.permissions = p }
+}
+
+// WithWatch enables Watch, which polls s for changes.
+func WithWatch(s todo.SyncStore) Option {
+	return func(svc *Service) { svc.sync = s }
+}
+
+// WithPollInterval sets how often Watch looks for changes.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Service) { s.pollInterval = d }
+}
+
+// WithAnonymous lets callers without credentials create, update and delete
+// todos, matching a server that does not require authentication.
+func WithAnonymous(allow bool) Option {
+	return func(s *Service) { s.allowAnonymous = allow }
+}
+
+func WithMaxTitleLength(n int) Option {
+	return func(s *Service) { s.maxTitleLength = n }
+}
+
+func NewService(repo todo.ReaderWriter, opts ...Option) *Service {
+	s := &Service{
+		repo:           repo,
+		maxTitleLength: todo.DefaultMaxTitleLength,
+		pollInterval:   defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Service) List(req *todov1.ListRequest, stream todov1.TodoService_ListServer) error {
+	ctx := stream.Context()
+	if err := s.listAccess(ctx, req.ListId, false, "todo list not found"); err != nil {
+		return err
+	}
+	items, err := s.repo.List(ctx, req.ListId)
+	if err != nil {
+		return internal(ctx, "failed to fetch todos", err)
+	}
+	for _, item := range items {
+		if err := stream.Send(toProto(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) Create(ctx context.Context, req *todov1.CreateRequest) (*todov1.Todo, error) {
+	if err := s.canWrite(ctx); err != nil {
+		return nil, err
+	}
+	title, err := s.checkTitle(req.Title, "title is required")
+	if err != nil {
+		return nil, err
+	}
+	if err := todo.CheckNotes(req.Notes); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := todo.CheckPriority(int(req.Priority)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	due, err := dueTime(req.Due)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.listAccess(ctx, req.ListId, true, "todo list not found"); err != nil {
+		return nil, err
+	}
+
+	item, err := s.repo.Create(ctx, todo.Item{
+		Title:    title,
+		Notes:    req.Notes,
+		ListID:   req.ListId,
+		Due:      due,
+		Priority: int(req.Priority),
+	})
+	if err != nil {
+		return nil, internal(ctx, "failed to create todo", err)
+	}
+	return toProto(item), nil
+}
+
+func (s *Service) Update(ctx context.Context, req *todov1.UpdateRequest) (*todov1.Todo, error) {
+	if err := s.canWrite(ctx); err != nil {
+		return nil, err
+	}
+	if req.Title == nil && req.Completed == nil && req.Notes == nil && req.Priority == nil && req.Due == nil && !req.ClearDue {
+		return nil, status.Error(codes.InvalidArgument, "title, completed, notes, due, clear_due or priority is required")
+	}
+	if req.Due != nil && req.ClearDue {
+		return nil, status.Error(codes.InvalidArgument, "due and clear_due cannot be combined")
+	}
+
+	changes := todo.Changes{Completed: req.Completed, Notes: req.Notes, SetDue: req.Due != nil || req.ClearDue}
+	if req.Title != nil {
+		title, err := s.checkTitle(*req.Title, "title must not be empty")
+		if err != nil {
+			return nil, err
+		}
+		changes.Title = &title
+	}
+	if req.Notes != nil {
+		if err := todo.CheckNotes(*req.Notes); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if req.Priority != nil {
+		priority := int(*req.Priority)
+		if err := todo.CheckPriority(priority); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		changes.Priority = &priority
+	}
+	due, err := dueTime(req.Due)
+	if err != nil {
+		return nil, err
+	}
+	changes.Due = due
+
+	if _, err := s.loadTodo(ctx, req.Id, true); err != nil {
+		return nil, err
+	}
+
+	item, err := s.repo.Update(ctx, req.Id, changes, auth.UserID(ctx))
+	if err != nil {
+		if errors.Is(err, todo.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "todo not found")
+		}
+		return nil, internal(ctx, "failed to update todo", err)
+	}
+	return toProto(item), nil
+}
+
+func (s *Service) Delete(ctx context.Context, req *todov1.DeleteRequest) (*todov1.DeleteResponse, error) {
+	if err := s.canWrite(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := s.loadTodo(ctx, req.Id, true); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, req.Id); err != nil {
+		if errors.Is(err, todo.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "todo not found")
+		}
+		return nil, internal(ctx, "failed to delete todo", err)
+	}
+	return &todov1.DeleteResponse{}, nil
+}
+
+// Watch polls the sync log the way GraphQL subscriptions do, and ends
+// when the caller loses access to the list. Headers are sent as soon as
+// the watch has started, so that clients can tell when later changes are
+// sure to be seen.
+func (s *Service) Watch(req *todov1.WatchRequest, stream todov1.TodoService_WatchServer) error {
+	ctx := stream.Context()
+	if s.sync == nil {
+		return status.Error(codes.Unimplemented, "watching is not enabled")
+	}
+	if err := s.listAccess(ctx, req.ListId, false, "todo list not found"); err != nil {
+		return err
+	}
+	watcher, err := todo.NewWatcher(ctx, s.sync, req.ListId)
+	if err != nil {
+		return internal(ctx, "failed to watch todos", err)
+	}
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+		if err := s.listAccess(ctx, req.ListId, false, "todo list not found"); err != nil {
+			return err
+		}
+		changes, err := watcher.Poll(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to poll todo changes", "error", err)
+			continue
+		}
+		for _, change := range changes {
+			event := &todov1.WatchResponse{Id: change.ID, Uid: change.UID, Deleted: change.Item == nil}
+			if change.Item != nil {
+				event.Todo = toProto(*change.Item)
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Service) checkTitle(title string, empty string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", status.Error(codes.InvalidArgument, empty)
+	}
+	if utf8.RuneCountInString(title) > s.maxTitleLength {
+		return "", status.Errorf(codes.InvalidArgument, "title must be at most %d characters", s.maxTitleLength)
+	}
+	return title, nil
+}
+
+// loadTodo returns the todo with id if the caller may see it, or modify it
+// when write is set.
+func (s *Service) loadTodo(ctx context.Context, id int64, write bool) (todo.Item, error) {
+	item, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, todo.ErrNotFound) {
+			return todo.Item{}, status.Error(codes.NotFound, "todo not found")
+		}
+		return todo.Item{}, internal(ctx, "failed to fetch todo", err)
+	}
+	if err := s.listAccess(ctx, item.ListID, write, "todo not found"); err != nil {
+		return todo.Item{}, err
+	}
+	return item, nil
+}
+
+// listAccess enforces list roles as the REST handlers do: lists the caller
+// does not belong to are not found, and viewers may not modify them.
+func (s *Service) listAccess(ctx context.Context, listID *int64, write bool, notFound string) error {
+	if listID == nil {
+		return nil
+	}
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	switch {
+	case !ok:
+		return status.Error(codes.Unauthenticated, "authentication required")
+	case principal.Kind == auth.KindAdmin:
+		return nil
+	case principal.Kind != auth.KindUser || s.permissions == nil:
+		return status.Error(codes.NotFound, notFound)
+	}
+
+	role, err := s.permissions.Role(ctx, *listID, principal.ID)
+	if err != nil {
+		if errors.Is(err, sharing.ErrNotFound) {
+			return status.Error(codes.NotFound, notFound)
+		}
+		return internal(ctx, "failed to check list access", err)
+	}
+	if write && !role.CanEdit() {
+		return status.Error(codes.PermissionDenied, "viewers cannot modify this list")
+	}
+	return nil
+}
+
+// canWrite maps auth.CheckWrite to gRPC status codes.
+func (s *Service) canWrite(ctx context.Context) error {
+	switch err := auth.CheckWrite(ctx, s.allowAnonymous); err {
+	case auth.ErrUnauthenticated:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case auth.ErrInsufficientScope:
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// internal logs err and hides it from the client behind message.
+func internal(ctx context.Context, message string, err error) error {
+	slog.ErrorContext(ctx, message, "error", err)
+	return status.Error(codes.Internal, message)
+}
+
+func dueTime(due *timestamppb.Timestamp) (*time.Time, error) {
+	if due == nil {
+		return nil, nil
+	}
+	if err := due.CheckValid(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid due time: %v", err))
+	}
+	t := due.AsTime()
+	return &t, nil
+}
+
+func toProto(item todo.Item) *todov1.Todo {
+	out := &todov1.Todo{
+		Id:        item.ID,
+		Uid:       item.UID,
+		Title:     item.Title,
+		Completed: item.Completed,
+		Notes:     item.Notes,
+		Priority:  int32(item.Priority),
+		ListId:    item.ListID,
+	}
+	if item.Due != nil {
+		out.Due = timestamppb.New(*item.Due)
+	}
+	if item.CompletedAt != nil {
+		out.CompletedAt = timestamppb.New(*item.CompletedAt)
+	}
+	if item.CompletedBy != nil {
+		out.CompletedBy = &todov1.User{Id: item.CompletedBy.ID, Name: item.CompletedBy.Name}
+	}
+	return out
+}
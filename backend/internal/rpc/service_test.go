@@ -0,0 +1,340 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/todo"
+	"todoapp/backend/pkg/todov1"
+)
+
+const adminToken = "admin-token"
+
+type testServer struct {
+	client   todov1.TodoServiceClient
+	conn     *grpc.ClientConn
+	keys     *auth.KeyStore
+	sessions *auth.SessionStore
+	lists    *sharing.Store
+}
+
+// newTestServer serves the service the way cmd/server does, multiplexed
+// with plain HTTP behind the real authenticator, over an in-process
+// listener.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	repo := todo.NewRepository(database)
+	s := &testServer{
+		keys:     auth.NewKeyStore(database),
+		sessions: auth.NewSessionStore(database, time.Hour),
+		lists:    sharing.NewStore(database),
+	}
+	svc := NewService(repo, WithPermissions(s.lists), WithWatch(repo), WithPollInterval(10*time.Millisecond))
+	authenticator := auth.NewAuthenticator(s.keys, s.sessions, adminToken)
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{
+		Handler:   authenticator.Middleware(Multiplex(NewServer(svc), http.NotFoundHandler())),
+		Protocols: protocols,
+	}
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	s.conn, err = grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { s.conn.Close() })
+	s.client = todov1.NewTodoServiceClient(s.conn)
+	return s
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// signIn creates a user with a session and returns a context that carries
+// its cookie, along with the user.
+func (s *testServer) signIn(t *testing.T, name string) (context.Context, auth.User) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := s.sessions.UpsertUser(ctx, "https://idp.example", name, name+"@example.com", name)
+	if err != nil {
+		t.Fatalf("upsert user: %v", err)
+	}
+	token, _, err := s.sessions.CreateSession(ctx, user.ID, "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "cookie", auth.SessionCookie+"="+token), user
+}
+
+func collect[T any](t *testing.T, stream grpc.ServerStreamingClient[T]) []*T {
+	t.Helper()
+	var messages []*T
+	for {
+		message, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return messages
+		}
+		if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		messages = append(messages, message)
+	}
+}
+
+func TestService_CRUD(t *testing.T) {
+	s := newTestServer(t)
+	ctx := withToken(context.Background(), adminToken)
+
+	due := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	created, err := s.client.Create(ctx, &todov1.CreateRequest{Title: "  File taxes ", Notes: "receipts", Due: timestamppb.New(due), Priority: 1})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.Id == 0 || created.Title != "File taxes" || !created.Due.AsTime().Equal(due) || created.Priority != 1 || created.Uid == "" {
+		t.Fatalf("unexpected todo %v", created)
+	}
+	if _, err := s.client.Create(ctx, &todov1.CreateRequest{Title: "Second"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	stream, err := s.client.List(ctx, &todov1.ListRequest{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if todos := collect(t, stream); len(todos) != 2 || !proto.Equal(todos[0], created) {
+		t.Fatalf("expected both todos, the first as created, got %v", todos)
+	}
+
+	updated, err := s.client.Update(ctx, &todov1.UpdateRequest{Id: created.Id, Title: proto.String("Taxes"), Completed: proto.Bool(true), ClearDue: true})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Title != "Taxes" || !updated.Completed || updated.Due != nil || updated.CompletedAt == nil || updated.Notes != "receipts" {
+		t.Fatalf("unexpected update %v", updated)
+	}
+
+	if _, err := s.client.Delete(ctx, &todov1.DeleteRequest{Id: created.Id}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.client.Delete(ctx, &todov1.DeleteRequest{Id: created.Id}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for a deleted todo, got %v", err)
+	}
+}
+
+func TestService_Errors(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	admin := withToken(ctx, adminToken)
+	_, readKey, err := s.keys.Create(ctx, "reader", auth.ScopeRead, nil)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"empty title", func() error {
+			_, err := s.client.Create(admin, &todov1.CreateRequest{Title: " "})
+			return err
+		}, codes.InvalidArgument},
+		{"bad priority", func() error {
+			_, err := s.client.Create(admin, &todov1.CreateRequest{Title: "x", Priority: 10})
+			return err
+		}, codes.InvalidArgument},
+		{"empty update", func() error {
+			_, err := s.client.Update(admin, &todov1.UpdateRequest{Id: 1})
+			return err
+		}, codes.InvalidArgument},
+		{"due and clear_due", func() error {
+			_, err := s.client.Update(admin, &todov1.UpdateRequest{Id: 1, Due: timestamppb.Now(), ClearDue: true})
+			return err
+		}, codes.InvalidArgument},
+		{"anonymous write", func() error {
+			_, err := s.client.Create(ctx, &todov1.CreateRequest{Title: "x"})
+			return err
+		}, codes.Unauthenticated},
+		{"invalid key", func() error {
+			_, err := s.client.Create(withToken(ctx, "nope"), &todov1.CreateRequest{Title: "x"})
+			return err
+		}, codes.Unauthenticated},
+		{"read-only key", func() error {
+			_, err := s.client.Create(withToken(ctx, readKey), &todov1.CreateRequest{Title: "x"})
+			return err
+		}, codes.PermissionDenied},
+		{"missing todo", func() error {
+			_, err := s.client.Update(admin, &todov1.UpdateRequest{Id: 42, Title: proto.String("x")})
+			return err
+		}, codes.NotFound},
+	}
+	for _, tc := range cases {
+		if err := tc.call(); status.Code(err) != tc.code {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.code, err)
+		}
+	}
+}
+
+func TestService_ListAccess(t *testing.T) {
+	s := newTestServer(t)
+	ownerCtx, owner := s.signIn(t, "owner")
+	viewerCtx, viewer := s.signIn(t, "viewer")
+	strangerCtx, _ := s.signIn(t, "stranger")
+
+	list, err := s.lists.CreateList(context.Background(), "Household", owner.ID)
+	if err != nil {
+		t.Fatalf("create list: %v", err)
+	}
+	_, invitation, err := s.lists.Invite(context.Background(), list.ID, "viewer@example.com", sharing.RoleViewer, owner.ID)
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+	if _, err := s.lists.Accept(context.Background(), invitation, viewer.ID); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+
+	created, err := s.client.Create(ownerCtx, &todov1.CreateRequest{Title: "Dishes", ListId: &list.ID})
+	if err != nil {
+		t.Fatalf("create on shared list: %v", err)
+	}
+	if created.GetListId() != list.ID {
+		t.Fatalf("expected the todo on list %d, got %v", list.ID, created)
+	}
+
+	stream, err := s.client.List(viewerCtx, &todov1.ListRequest{ListId: &list.ID})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if todos := collect(t, stream); len(todos) != 1 || todos[0].Title != "Dishes" {
+		t.Fatalf("expected the viewer to see the todo, got %v", todos)
+	}
+	if _, err := s.client.Update(viewerCtx, &todov1.UpdateRequest{Id: created.Id, Completed: proto.Bool(true)}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected viewers to be denied, got %v", err)
+	}
+	if _, err := s.client.Delete(strangerCtx, &todov1.DeleteRequest{Id: created.Id}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected the todo to be hidden from non-members, got %v", err)
+	}
+	stream, err = s.client.List(strangerCtx, &todov1.ListRequest{ListId: &list.ID})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected the list to be hidden from non-members, got %v", err)
+	}
+
+	completed, err := s.client.Update(ownerCtx, &todov1.UpdateRequest{Id: created.Id, Completed: proto.Bool(true)})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if completed.CompletedBy.GetId() != owner.ID || completed.CompletedBy.GetName() != "owner" {
+		t.Fatalf("expected the owner to be recorded as completing it, got %v", completed.CompletedBy)
+	}
+}
+
+func TestService_Watch(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithTimeout(withToken(context.Background(), adminToken), 5*time.Second)
+	defer cancel()
+
+	before, err := s.client.Create(ctx, &todov1.CreateRequest{Title: "Before"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stream, err := s.client.Watch(ctx, &todov1.WatchRequest{})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	// Nothing is sent for todos that exist when the watch starts, so the
+	// first event is the next change.
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	created, err := s.client.Create(ctx, &todov1.CreateRequest{Title: "Fresh"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	if event.Id != created.Id || event.Deleted || event.Todo.GetTitle() != "Fresh" {
+		t.Fatalf("expected the created todo, got %v", event)
+	}
+
+	if _, err := s.client.Delete(ctx, &todov1.DeleteRequest{Id: before.Id}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	event, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	if event.Id != before.Id || !event.Deleted || event.Todo != nil || event.Uid != before.Uid {
+		t.Fatalf("expected the deletion, got %v", event)
+	}
+}
+
+func TestServer_Reflection(t *testing.T) {
+	s := newTestServer(t)
+	stream, err := reflectionpb.NewServerReflectionClient(s.conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("reflection: %v", err)
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	var names []string
+	for _, service := range res.GetListServicesResponse().GetService() {
+		names = append(names, service.Name)
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == todov1.TodoService_ServiceDesc.ServiceName
+	}
+	if !found {
+		t.Fatalf("expected reflection to list %s, got %v", todov1.TodoService_ServiceDesc.ServiceName, names)
+	}
+}
@@ -0,0 +1,283 @@
+package sharing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/respond"
+)
+
+const maxRequestBytes = 1 << 16
+
+type Handler struct {
+	store *Store
+}
+
+func NewHandler(store *Store) *Handler {
+	return &Handler{store: store}
+}
+
+func (h *Handler) ListLists(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	lists, err := h.store.Lists(r.Context(), userID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch lists", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, lists)
+}
+
+type createListRequest struct {
+	Name string `json:"name"`
+}
+
+func (h *Handler) CreateList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req createListRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.store.CreateList(r.Context(), name, userID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to create list", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusCreated, list)
+}
+
+func (h *Handler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	listID, _, ok := h.authorize(w, r, false)
+	if !ok {
+		return
+	}
+
+	members, err := h.store.Members(r.Context(), listID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch members", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, members)
+}
+
+type inviteRequest struct {
+	Email string `json:"email"`
+	Role  Role   `json:"role"`
+}
+
+type inviteResponse struct {
+	Invitation
+	Token string `json:"token"`
+}
+
+func (h *Handler) Invite(w http.ResponseWriter, r *http.Request) {
+	listID, userID, ok := h.authorize(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req inviteRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if req.Role == "" {
+		req.Role = RoleViewer
+	}
+	if !req.Role.Valid() {
+		http.Error(w, "role must be owner, editor or viewer", http.StatusBadRequest)
+		return
+	}
+
+	invitation, token, err := h.store.Invite(r.Context(), listID, req.Email, req.Role, userID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to create invitation", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusCreated, inviteResponse{Invitation: invitation, Token: token})
+}
+
+type acceptRequest struct {
+	Token string `json:"token"`
+}
+
+// AcceptInvitation takes the token in the body rather than the URL so it
+// does not end up in access logs.
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req acceptRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.store.Accept(r.Context(), req.Token, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidInvitation):
+			http.Error(w, "invitation is invalid or expired", http.StatusNotFound)
+		case errors.Is(err, ErrEmailMismatch):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			respond.ServerError(w, r, "failed to accept invitation", err)
+		}
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, list)
+}
+
+type updateMemberRequest struct {
+	Role Role `json:"role"`
+}
+
+func (h *Handler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	listID, _, ok := h.authorize(w, r, true)
+	if !ok {
+		return
+	}
+	memberID, err := parseID(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var req updateMemberRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if !req.Role.Valid() {
+		http.Error(w, "role must be owner, editor or viewer", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SetRole(r.Context(), listID, memberID, req.Role); err != nil {
+		memberError(w, r, "failed to update member", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember lets owners remove anyone and every member leave on their own.
+func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	memberID, err := parseID(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	listID, _, ok := h.authorize(w, r, memberID != userID)
+	if !ok {
+		return
+	}
+
+	if err := h.store.RemoveMember(r.Context(), listID, memberID); err != nil {
+		memberError(w, r, "failed to remove member", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorize resolves the {id} list for the signed-in user, writing the error
+// response and returning false when they are not a member or, if manage is
+// set, not an owner.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, manage bool) (int64, int64, bool) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+	listID, err := parseID(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid list id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	role, err := h.store.Role(r.Context(), listID, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "list not found", http.StatusNotFound)
+			return 0, 0, false
+		}
+		respond.ServerError(w, r, "failed to check list access", err)
+		return 0, 0, false
+	}
+	if manage && !role.CanManage() {
+		http.Error(w, "only owners can manage this list", http.StatusForbidden)
+		return 0, 0, false
+	}
+	return listID, userID, true
+}
+
+// currentUser returns the signed-in user. Lists belong to people, so API
+// keys and the admin token cannot use these endpoints.
+func currentUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return 0, false
+	}
+	if principal.Kind != auth.KindUser {
+		http.Error(w, "shared lists require a signed-in user", http.StatusForbidden)
+		return 0, false
+	}
+	return principal.ID, true
+}
+
+func memberError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	switch {
+	case errors.Is(err, ErrMemberNotFound):
+		http.Error(w, "member not found", http.StatusNotFound)
+	case errors.Is(err, ErrLastOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		respond.ServerError(w, r, message, err)
+	}
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, target any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func parseID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if id <= 0 {
+		return 0, errors.New("id must be positive")
+	}
+	return id, nil
+}
@@ -0,0 +1,89 @@
+package sharing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/auth"
+)
+
+func serve(h http.HandlerFunc, method string, target string, body string, principal *auth.Principal, pathValues map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	for name, value := range pathValues {
+		req.SetPathValue(name, value)
+	}
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func user(id int64) *auth.Principal {
+	return &auth.Principal{Kind: auth.KindUser, ID: id, Scope: auth.ScopeReadWrite}
+}
+
+func TestHandler_InvitationFlow(t *testing.T) {
+	store, _ := setupStore(t)
+	h := NewHandler(store)
+	list, err := store.CreateList(context.Background(), "Household", 1)
+	if err != nil {
+		t.Fatalf("create list: %v", err)
+	}
+	listPath := map[string]string{"id": "1"}
+
+	rr := serve(h.Invite, http.MethodPost, "/api/lists/1/invitations", `{"role":"viewer"}`, user(1), listPath)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var invitation inviteResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &invitation); err != nil || invitation.Token == "" || invitation.ListID != list.ID {
+		t.Fatalf("unexpected invitation %s: %v", rr.Body.String(), err)
+	}
+
+	rr = serve(h.AcceptInvitation, http.MethodPost, "/api/invitations/accept", `{"token":"`+invitation.Token+`"}`, user(2), nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Viewers can see members but cannot invite or change roles.
+	if rr := serve(h.ListMembers, http.MethodGet, "/api/lists/1/members", "", user(2), listPath); rr.Code != http.StatusOK {
+		t.Fatalf("expected viewer to list members, got %d", rr.Code)
+	}
+	if rr := serve(h.Invite, http.MethodPost, "/api/lists/1/invitations", `{}`, user(2), listPath); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected viewer invite to be forbidden, got %d", rr.Code)
+	}
+	promote := map[string]string{"id": "1", "userId": "2"}
+	if rr := serve(h.UpdateMember, http.MethodPatch, "/api/lists/1/members/2", `{"role":"owner"}`, user(2), promote); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected viewer to be unable to promote themselves, got %d", rr.Code)
+	}
+	if rr := serve(h.UpdateMember, http.MethodPatch, "/api/lists/1/members/2", `{"role":"editor"}`, user(1), promote); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected owner to change roles, got %d", rr.Code)
+	}
+
+	// Members may leave; outsiders cannot tell the list exists.
+	if rr := serve(h.RemoveMember, http.MethodDelete, "/api/lists/1/members/2", "", user(2), promote); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected member to leave, got %d", rr.Code)
+	}
+	if rr := serve(h.ListMembers, http.MethodGet, "/api/lists/1/members", "", user(2), listPath); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected former member to get 404, got %d", rr.Code)
+	}
+}
+
+func TestHandler_RequiresSignedInUser(t *testing.T) {
+	store, _ := setupStore(t)
+	h := NewHandler(store)
+
+	if rr := serve(h.ListLists, http.MethodGet, "/api/lists", "", nil, nil); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected anonymous caller to get 401, got %d", rr.Code)
+	}
+	apiKey := &auth.Principal{Kind: auth.KindAPIKey, ID: 1, Scope: auth.ScopeReadWrite}
+	if rr := serve(h.CreateList, http.MethodPost, "/api/lists", `{"name":"x"}`, apiKey, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected api key to get 403, got %d", rr.Code)
+	}
+}
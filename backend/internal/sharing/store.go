@@ -0,0 +1,327 @@
+// Package sharing manages shared todo lists: memberships with roles and
+// the invitations that create them.
+package sharing
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/db"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrNotFound          = errors.New("list not found")
+	ErrMemberNotFound    = errors.New("member not found")
+	ErrInvalidInvitation = errors.New("invalid invitation")
+	ErrEmailMismatch     = errors.New("invitation was issued for another email address")
+	ErrLastOwner         = errors.New("a list must keep at least one owner")
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// CanEdit reports whether the role may add, complete and delete todos.
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// CanManage reports whether the role may invite people and change roles.
+func (r Role) CanManage() bool {
+	return r == RoleOwner
+}
+
+type List struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Member struct {
+	UserID   int64     `json:"userId"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     Role      `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+type Invitation struct {
+	ID        int64     `json:"id"`
+	ListID    int64     `json:"listId"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	InvitedBy int64     `json:"invitedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// CreateList creates a list owned by ownerID.
+func (s *Store) CreateList(ctx context.Context, name string, ownerID int64) (List, error) {
+	list := List{Name: name, Role: RoleOwner, CreatedAt: s.now().UTC()}
+	err := db.InTx(ctx, s.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `INSERT INTO lists (name, created_at) VALUES (?, ?)`, list.Name, list.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if list.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO list_members (list_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`,
+			list.ID, ownerID, string(RoleOwner), list.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return list, nil
+}
+
+// Lists returns the lists userID belongs to along with their role in each.
+func (s *Store) Lists(ctx context.Context, userID int64) ([]List, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT lists.id, lists.name, list_members.role, lists.created_at
+		FROM lists JOIN list_members ON list_members.list_id = lists.id
+		WHERE list_members.user_id = ?
+		ORDER BY lists.id ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := make([]List, 0)
+	for rows.Next() {
+		var list List
+		if err := rows.Scan(&list.ID, &list.Name, &list.Role, &list.CreatedAt); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// Role returns userID's role in listID, or ErrNotFound when they are not a
+// member, so that lists a user cannot see are indistinguishable from lists
+// that do not exist.
+func (s *Store) Role(ctx context.Context, listID int64, userID int64) (Role, error) {
+	var role Role
+	err := s.db.QueryRowContext(ctx,
+		`SELECT role FROM list_members WHERE list_id = ? AND user_id = ?`, listID, userID,
+	).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+func (s *Store) Members(ctx context.Context, listID int64) ([]Member, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT users.id, users.name, users.email, list_members.role, list_members.created_at
+		FROM list_members JOIN users ON users.id = list_members.user_id
+		WHERE list_members.list_id = ?
+		ORDER BY list_members.created_at ASC, users.id ASC`, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]Member, 0)
+	for rows.Next() {
+		var member Member
+		if err := rows.Scan(&member.UserID, &member.Name, &member.Email, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Invite creates an invitation to listID and returns it with its plaintext
+// token. An empty email lets anyone holding the token join.
+func (s *Store) Invite(ctx context.Context, listID int64, email string, role Role, invitedBy int64) (Invitation, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return Invitation{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := s.now().UTC()
+	invitation := Invitation{
+		ListID:    listID,
+		Email:     strings.TrimSpace(email),
+		Role:      role,
+		InvitedBy: invitedBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(invitationTTL),
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO list_invitations (list_id, token_hash, email, role, invited_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		invitation.ListID, auth.HashToken(token), invitation.Email, string(invitation.Role), invitation.InvitedBy,
+		invitation.CreatedAt, invitation.ExpiresAt)
+	if err != nil {
+		return Invitation{}, "", err
+	}
+	if invitation.ID, err = result.LastInsertId(); err != nil {
+		return Invitation{}, "", err
+	}
+	return invitation, token, nil
+}
+
+// Accept redeems an invitation token for userID. Accepting a list the user
+// already belongs to leaves their current role untouched.
+func (s *Store) Accept(ctx context.Context, token string, userID int64) (List, error) {
+	var list List
+	err := db.InTx(ctx, s.db, func(tx *sql.Tx) error {
+		var (
+			invitationID int64
+			email        string
+			role         Role
+			expiresAt    time.Time
+			acceptedAt   sql.NullTime
+		)
+		err := tx.QueryRowContext(ctx,
+			`SELECT id, list_id, email, role, expires_at, accepted_at FROM list_invitations WHERE token_hash = ?`,
+			auth.HashToken(token),
+		).Scan(&invitationID, &list.ID, &email, &role, &expiresAt, &acceptedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidInvitation
+			}
+			return err
+		}
+		now := s.now().UTC()
+		if acceptedAt.Valid || !now.Before(expiresAt) {
+			return ErrInvalidInvitation
+		}
+
+		if email != "" {
+			var userEmail string
+			if err := tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id = ?`, userID).Scan(&userEmail); err != nil {
+				return err
+			}
+			if !strings.EqualFold(email, userEmail) {
+				return ErrEmailMismatch
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO list_members (list_id, user_id, role, created_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (list_id, user_id) DO NOTHING`,
+			list.ID, userID, string(role), now); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE list_invitations SET accepted_by = ?, accepted_at = ? WHERE id = ?`, userID, now, invitationID); err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx, `
+			SELECT lists.name, list_members.role, lists.created_at
+			FROM lists JOIN list_members ON list_members.list_id = lists.id
+			WHERE lists.id = ? AND list_members.user_id = ?`, list.ID, userID,
+		).Scan(&list.Name, &list.Role, &list.CreatedAt)
+	})
+	if err != nil {
+		return List{}, err
+	}
+	return list, nil
+}
+
+// SetRole changes a member's role, refusing to demote the last owner.
+func (s *Store) SetRole(ctx context.Context, listID int64, userID int64, role Role) error {
+	return db.InTx(ctx, s.db, func(tx *sql.Tx) error {
+		if role != RoleOwner {
+			if err := ensureOtherOwner(ctx, tx, listID, userID); err != nil {
+				return err
+			}
+		}
+		result, err := tx.ExecContext(ctx,
+			`UPDATE list_members SET role = ? WHERE list_id = ? AND user_id = ?`, string(role), listID, userID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrMemberNotFound)
+	})
+}
+
+// RemoveMember takes userID off listID, refusing to remove the last owner.
+func (s *Store) RemoveMember(ctx context.Context, listID int64, userID int64) error {
+	return db.InTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := ensureOtherOwner(ctx, tx, listID, userID); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM list_members WHERE list_id = ? AND user_id = ?`, listID, userID)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, ErrMemberNotFound)
+	})
+}
+
+// ensureOtherOwner fails with ErrLastOwner when userID is the only owner of
+// listID.
+func ensureOtherOwner(ctx context.Context, tx *sql.Tx, listID int64, userID int64) error {
+	var isOwner, otherOwners int
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			COUNT(CASE WHEN user_id = ? THEN 1 END),
+			COUNT(CASE WHEN user_id != ? THEN 1 END)
+		FROM list_members WHERE list_id = ? AND role = ?`,
+		userID, userID, listID, string(RoleOwner),
+	).Scan(&isOwner, &otherOwners)
+	if err != nil {
+		return err
+	}
+	if isOwner > 0 && otherOwners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func requireAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package sharing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+)
+
+func setupStore(t *testing.T) (*Store, *time.Time) {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for id, email := range map[int64]string{1: "alice@example.com", 2: "bob@example.com", 3: "carol@example.com"} {
+		if _, err := database.Exec(`
+			INSERT INTO users (id, issuer, subject, email, name, created_at, updated_at)
+			VALUES (?, 'https://idp.example.com', ?, ?, '', ?, ?)`, id, email, email, now, now); err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+
+	store := NewStore(database)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestStore_InviteAndAccept(t *testing.T) {
+	store, _ := setupStore(t)
+	ctx := context.Background()
+
+	list, err := store.CreateList(ctx, "Household", 1)
+	if err != nil {
+		t.Fatalf("create list: %v", err)
+	}
+	_, token, err := store.Invite(ctx, list.ID, "BOB@example.com", RoleEditor, 1)
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+
+	if _, err := store.Accept(ctx, token, 3); !errors.Is(err, ErrEmailMismatch) {
+		t.Fatalf("expected email mismatch, got %v", err)
+	}
+	accepted, err := store.Accept(ctx, token, 2)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if accepted.ID != list.ID || accepted.Role != RoleEditor {
+		t.Fatalf("unexpected accepted list: %#v", accepted)
+	}
+	if _, err := store.Accept(ctx, token, 2); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected invitation to be single use, got %v", err)
+	}
+
+	role, err := store.Role(ctx, list.ID, 2)
+	if err != nil || role != RoleEditor {
+		t.Fatalf("expected editor role, got %q (%v)", role, err)
+	}
+	if _, err := store.Role(ctx, list.ID, 3); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected non-member to get ErrNotFound, got %v", err)
+	}
+
+	lists, err := store.Lists(ctx, 2)
+	if err != nil || len(lists) != 1 || lists[0].Name != "Household" {
+		t.Fatalf("unexpected lists %#v: %v", lists, err)
+	}
+}
+
+func TestStore_InvitationExpires(t *testing.T) {
+	store, now := setupStore(t)
+	ctx := context.Background()
+
+	list, err := store.CreateList(ctx, "Team", 1)
+	if err != nil {
+		t.Fatalf("create list: %v", err)
+	}
+	_, token, err := store.Invite(ctx, list.ID, "", RoleViewer, 1)
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+
+	*now = now.Add(invitationTTL)
+	if _, err := store.Accept(ctx, token, 3); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected expired invitation to be rejected, got %v", err)
+	}
+}
+
+func TestStore_KeepsLastOwner(t *testing.T) {
+	store, _ := setupStore(t)
+	ctx := context.Background()
+
+	list, err := store.CreateList(ctx, "Team", 1)
+	if err != nil {
+		t.Fatalf("create list: %v", err)
+	}
+	if err := store.SetRole(ctx, list.ID, 1, RoleEditor); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("expected demoting the last owner to fail, got %v", err)
+	}
+	if err := store.RemoveMember(ctx, list.ID, 1); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("expected removing the last owner to fail, got %v", err)
+	}
+
+	_, token, err := store.Invite(ctx, list.ID, "", RoleOwner, 1)
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+	if _, err := store.Accept(ctx, token, 2); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if err := store.RemoveMember(ctx, list.ID, 1); err != nil {
+		t.Fatalf("expected owner to leave once another owner exists: %v", err)
+	}
+	if err := store.RemoveMember(ctx, list.ID, 1); !errors.Is(err, ErrMemberNotFound) {
+		t.Fatalf("expected ErrMemberNotFound, got %v", err)
+	}
+}
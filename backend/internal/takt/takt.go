@@ -0,0 +1,59 @@
+// Package takt reads the task queue that the takt agent workflow keeps in
+// .takt/tasks.yaml.
+package takt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StatusCompleted is the status of a task the agents have finished.
+const StatusCompleted = "completed"
+
+// Task is one entry of tasks.yaml. Fields the todo app has no use for, such
+// as the worktree and branch, are left out.
+type Task struct {
+	Name        string     `yaml:"name"`
+	Slug        string     `yaml:"slug"`
+	Status      string     `yaml:"status"`
+	Content     string     `yaml:"content"`
+	CreatedAt   *time.Time `yaml:"created_at"`
+	StartedAt   *time.Time `yaml:"started_at"`
+	CompletedAt *time.Time `yaml:"completed_at"`
+}
+
+// Completed reports whether the agents have finished the task.
+func (t Task) Completed() bool {
+	return t.Status == StatusCompleted
+}
+
+type file struct {
+	Tasks []Task `yaml:"tasks"`
+}
+
+// Decode reads a tasks.yaml file. Every task needs a slug, which identifies
+// it across edits of the file, and no two tasks may share one.
+func Decode(r io.Reader) ([]Task, error) {
+	var f file
+	if err := yaml.NewDecoder(r).Decode(&f); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid tasks file: %w", err)
+	}
+	seen := make(map[string]bool, len(f.Tasks))
+	for i, task := range f.Tasks {
+		switch {
+		case task.Slug == "":
+			return nil, fmt.Errorf("task %d: slug is required", i+1)
+		case seen[task.Slug]:
+			return nil, fmt.Errorf("task %d: duplicate slug %q", i+1, task.Slug)
+		}
+		seen[task.Slug] = true
+	}
+	return f.Tasks, nil
+}
@@ -0,0 +1,79 @@
+package takt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	tasks, err := Decode(strings.NewReader(`tasks:
+  - worktree: true
+    branch: takt/20260219T1427-build
+    name: build
+    status: completed
+    slug: fix-build
+    content: |-
+      Fix the frontend build.
+
+      1. npm run build
+    created_at: 2026-02-19T14:27:51.922Z
+    started_at: 2026-02-19T14:27:51.933Z
+    completed_at: 2026-02-19T15:36:19.478Z
+    owner_pid: null
+  - name: crud
+    status: pending
+    slug: crud
+    content: >-
+      Implement
+      editing.
+    created_at: 2026-02-21T14:01:00Z
+`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+
+	first := tasks[0]
+	if first.Name != "build" || first.Slug != "fix-build" || !first.Completed() {
+		t.Fatalf("unexpected first task: %+v", first)
+	}
+	if first.Content != "Fix the frontend build.\n\n1. npm run build" {
+		t.Fatalf("unexpected content %q", first.Content)
+	}
+	if first.CompletedAt == nil || !first.CompletedAt.Equal(time.Date(2026, 2, 19, 15, 36, 19, 478e6, time.UTC)) {
+		t.Fatalf("unexpected completed_at %v", first.CompletedAt)
+	}
+
+	second := tasks[1]
+	if second.Completed() || second.Content != "Implement editing." || second.CompletedAt != nil || second.CreatedAt == nil {
+		t.Fatalf("unexpected second task: %+v", second)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "not yaml", input: "tasks: [", want: "invalid tasks file"},
+		{name: "no slug", input: "tasks:\n  - name: a\n", want: "task 1: slug is required"},
+		{name: "duplicate slug", input: "tasks:\n  - slug: a\n  - slug: b\n  - slug: a\n", want: `task 3: duplicate slug "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	tasks, err := Decode(strings.NewReader(""))
+	if err != nil || len(tasks) != 0 {
+		t.Fatalf("expected an empty file to hold no tasks, got %v %v", tasks, err)
+	}
+}
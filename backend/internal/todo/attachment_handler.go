@@ -0,0 +1,287 @@
+package todo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"todoapp/backend/internal/respond"
+)
+
+const (
+	DefaultMaxAttachmentBytes = 10 << 20
+	DefaultAttachmentQuota    = 100 << 20
+
+	// multipartOverhead allows for the multipart framing around the file.
+	multipartOverhead  = 64 << 10
+	maxFilenameLength  = 255
+	contentSniffLength = 512
+)
+
+type AttachmentStore interface {
+	ListAttachments(ctx context.Context, todoID int64) ([]Attachment, error)
+	GetAttachment(ctx context.Context, id int64) (Attachment, error)
+	AttachmentUsage(ctx context.Context, uploaderKey string) (int64, error)
+	AddAttachment(ctx context.Context, attachment Attachment, content io.Reader, maxBytes int64) (Attachment, error)
+	OpenAttachment(ctx context.Context, attachment Attachment) (io.ReadSeekCloser, error)
+	DeleteAttachment(ctx context.Context, id int64) error
+}
+
+// inlineTypes are shown in the browser; everything else is downloaded so
+// that uploaded HTML or SVG never runs in the app's origin.
+var inlineTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+func (h *Handler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := h.attachmentTodo(w, r, false)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachments.ListAttachments(r.Context(), todoID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch attachments", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, attachments)
+}
+
+// UploadAttachment stores the "file" part of a multipart/form-data body. The
+// content is streamed to the blob store, never buffered whole in memory.
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	todoID, ok := h.attachmentTodo(w, r, true)
+	if !ok {
+		return
+	}
+
+	used, err := h.attachments.AttachmentUsage(r.Context(), principal.Key())
+	if err != nil {
+		respond.ServerError(w, r, "failed to check attachment quota", err)
+		return
+	}
+	limit := min(h.maxAttachmentBytes, h.attachmentQuota-used)
+	if limit <= 0 {
+		http.Error(w, "attachment quota exceeded", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+	var (
+		body     *uploadReader
+		filename string
+	)
+	for body == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, "file is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			body = &uploadReader{r: bufio.NewReaderSize(part, contentSniffLength)}
+			filename = cleanFilename(part.FileName())
+			defer part.Close()
+		}
+	}
+
+	head, err := body.r.Peek(contentSniffLength)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		uploadError(w, err)
+		return
+	}
+
+	attachment, err := h.attachments.AddAttachment(r.Context(), Attachment{
+		TodoID:      todoID,
+		Filename:    filename,
+		ContentType: detectContentType(head, filename),
+		UploaderKey: principal.Key(),
+	}, body, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrAttachmentTooLarge) && limit < h.maxAttachmentBytes:
+			http.Error(w, "attachment quota exceeded", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, ErrAttachmentTooLarge):
+			http.Error(w, fmt.Sprintf("file must be at most %d bytes", h.maxAttachmentBytes), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "todo not found", http.StatusNotFound)
+		case body.err != nil:
+			uploadError(w, body.err)
+		default:
+			respond.ServerError(w, r, "failed to store attachment", err)
+		}
+		return
+	}
+	respond.JSON(w, r, http.StatusCreated, attachment)
+}
+
+// DownloadAttachment serves the file with its detected Content-Type.
+// http.ServeContent handles Range, If-Range and conditional requests.
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := h.attachment(w, r, false)
+	if !ok {
+		return
+	}
+
+	content, err := h.attachments.OpenAttachment(r.Context(), attachment)
+	if err != nil {
+		respond.ServerError(w, r, "failed to open attachment", err)
+		return
+	}
+	defer content.Close()
+
+	disposition := "attachment"
+	if mediaType, _, _ := mime.ParseMediaType(attachment.ContentType); inlineTypes[mediaType] {
+		disposition = "inline"
+	}
+	header := w.Header()
+	header.Set("Content-Type", attachment.ContentType)
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "sandbox")
+	header.Set("ETag", `"`+attachment.BlobKey+`"`)
+	header.Set("Cache-Control", "private, max-age=0, must-revalidate")
+	http.ServeContent(w, r, attachment.Filename, attachment.CreatedAt, content)
+}
+
+// DeleteAttachment lets anyone who may edit the todo remove its files.
+func (h *Handler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := h.attachment(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := h.attachments.DeleteAttachment(r.Context(), attachment.ID); err != nil {
+		if errors.Is(err, ErrAttachmentNotFound) {
+			http.Error(w, "attachment not found", http.StatusNotFound)
+			return
+		}
+		respond.ServerError(w, r, "failed to delete attachment", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attachmentTodo resolves the {id} todo and checks the caller may read it,
+// or modify it when write is set.
+func (h *Handler) attachmentTodo(w http.ResponseWriter, r *http.Request, write bool) (int64, bool) {
+	if h.attachments == nil {
+		http.NotFound(w, r)
+		return 0, false
+	}
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid todo id", http.StatusBadRequest)
+		return 0, false
+	}
+	if _, ok := h.authorizeItem(w, r, id, write); !ok {
+		return 0, false
+	}
+	return id, true
+}
+
+// attachment resolves the {attachmentId} attachment on the {id} todo.
+func (h *Handler) attachment(w http.ResponseWriter, r *http.Request, write bool) (Attachment, bool) {
+	todoID, ok := h.attachmentTodo(w, r, write)
+	if !ok {
+		return Attachment{}, false
+	}
+	attachmentID, err := parseID(r.PathValue("attachmentId"))
+	if err != nil {
+		http.Error(w, "invalid attachment id", http.StatusBadRequest)
+		return Attachment{}, false
+	}
+
+	attachment, err := h.attachments.GetAttachment(r.Context(), attachmentID)
+	if err != nil && !errors.Is(err, ErrAttachmentNotFound) {
+		respond.ServerError(w, r, "failed to fetch attachment", err)
+		return Attachment{}, false
+	}
+	if err != nil || attachment.TodoID != todoID {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return Attachment{}, false
+	}
+	return attachment, true
+}
+
+// uploadError reports a failure to read the request body.
+func uploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "invalid multipart body", http.StatusBadRequest)
+}
+
+// uploadReader remembers the last read error so that a broken upload can be
+// told apart from a failing blob store.
+type uploadReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	if err != nil && err != io.EOF {
+		u.err = err
+	}
+	return n, err
+}
+
+// detectContentType sniffs the content, falling back to the file extension
+// when sniffing finds nothing more specific than binary or plain text.
+func detectContentType(head []byte, filename string) string {
+	sniffed := http.DetectContentType(head)
+	if sniffed != "application/octet-stream" && !strings.HasPrefix(sniffed, "text/plain") {
+		return sniffed
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); byExt != "" {
+		return byExt
+	}
+	return sniffed
+}
+
+// cleanFilename keeps only the base name, drops control characters and
+// caps the length, falling back to a generic name.
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
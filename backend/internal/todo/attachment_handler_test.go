@@ -0,0 +1,181 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/blob"
+	"todoapp/backend/internal/sharing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func uploadRequest(t *testing.T, filename string, content []byte, principal *auth.Principal) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos/1/attachments", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetPathValue("id", "1")
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	return req
+}
+
+func attachmentRequest(method string, attachmentID int64) *http.Request {
+	req := httptest.NewRequest(method, "/api/todos/1/attachments/"+strconv.FormatInt(attachmentID, 10), nil)
+	req.SetPathValue("id", "1")
+	req.SetPathValue("attachmentId", strconv.FormatInt(attachmentID, 10))
+	return req
+}
+
+func TestAttachments_UploadAndDownload(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	blobs := blob.NewMemory()
+	repo := NewRepository(db, WithBlobStore(blobs))
+	h := NewHandler(repo, WithAttachments(repo, DefaultMaxAttachmentBytes, DefaultAttachmentQuota))
+	alice := &auth.Principal{Kind: auth.KindAPIKey, ID: 1, Name: "alice"}
+
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte("x"), 100)...)
+	rr := httptest.NewRecorder()
+	h.UploadAttachment(rr, uploadRequest(t, `C:\Users\alice\screen shot.png`, content, alice))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var attachment Attachment
+	if err := json.Unmarshal(rr.Body.Bytes(), &attachment); err != nil {
+		t.Fatalf("decode attachment: %v", err)
+	}
+	if attachment.Filename != "screen shot.png" || attachment.ContentType != "image/png" || attachment.Size != int64(len(content)) {
+		t.Fatalf("unexpected attachment: %#v", attachment)
+	}
+
+	rr = httptest.NewRecorder()
+	h.DownloadAttachment(rr, attachmentRequest(http.MethodGet, attachment.ID))
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), content) {
+		t.Fatalf("unexpected download: %d, %d bytes", rr.Code, rr.Body.Len())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `inline; filename="screen shot.png"` {
+		t.Fatalf("unexpected content disposition %q", got)
+	}
+
+	req := attachmentRequest(http.MethodGet, attachment.ID)
+	req.Header.Set("Range", "bytes=0-7")
+	rr = httptest.NewRecorder()
+	h.DownloadAttachment(rr, req)
+	if rr.Code != http.StatusPartialContent || !bytes.Equal(rr.Body.Bytes(), pngHeader[:8]) {
+		t.Fatalf("unexpected range response: %d %q", rr.Code, rr.Body.Bytes())
+	}
+	if got := rr.Header().Get("Content-Range"); got != "bytes 0-7/"+strconv.Itoa(len(content)) {
+		t.Fatalf("unexpected content range %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.DeleteAttachment(rr, attachmentRequest(http.MethodDelete, attachment.ID))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+	if blobs.Len() != 0 {
+		t.Fatalf("expected the blob to be removed, %d left", blobs.Len())
+	}
+}
+
+func TestAttachments_HTMLIsNeverInline(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db, WithBlobStore(blob.NewMemory()))
+	h := NewHandler(repo, WithAttachments(repo, DefaultMaxAttachmentBytes, DefaultAttachmentQuota))
+
+	rr := httptest.NewRecorder()
+	h.UploadAttachment(rr, uploadRequest(t, "page.png", []byte("<html><script>alert(1)</script></html>"), &auth.Principal{Kind: auth.KindAPIKey, ID: 1}))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var attachment Attachment
+	json.Unmarshal(rr.Body.Bytes(), &attachment)
+
+	rr = httptest.NewRecorder()
+	h.DownloadAttachment(rr, attachmentRequest(http.MethodGet, attachment.ID))
+	if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Fatalf("expected sniffed text/html, got %q", got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment") {
+		t.Fatalf("expected HTML to be served as a download, got %q", got)
+	}
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" || rr.Header().Get("Content-Security-Policy") != "sandbox" {
+		t.Fatalf("missing protective headers: %v", rr.Header())
+	}
+}
+
+func TestAttachments_Limits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	blobs := blob.NewMemory()
+	repo := NewRepository(db, WithBlobStore(blobs))
+	h := NewHandler(repo, WithAttachments(repo, 100, 150))
+	alice := &auth.Principal{Kind: auth.KindAPIKey, ID: 1}
+	bob := &auth.Principal{Kind: auth.KindAPIKey, ID: 2}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		size      int
+		status    int
+		message   string
+	}{
+		{name: "anonymous", status: http.StatusUnauthorized},
+		{name: "file too large", principal: alice, size: 101, status: http.StatusRequestEntityTooLarge, message: "at most 100 bytes"},
+		{name: "within limits", principal: alice, size: 100, status: http.StatusCreated},
+		{name: "over quota", principal: alice, size: 60, status: http.StatusRequestEntityTooLarge, message: "quota exceeded"},
+		{name: "within remaining quota", principal: alice, size: 50, status: http.StatusCreated},
+		{name: "quota used up", principal: alice, size: 1, status: http.StatusRequestEntityTooLarge, message: "quota exceeded"},
+		{name: "quota is per user", principal: bob, size: 100, status: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.UploadAttachment(rr, uploadRequest(t, "data.bin", bytes.Repeat([]byte{byte(tt.size)}, tt.size), tt.principal))
+			if rr.Code != tt.status || !strings.Contains(rr.Body.String(), tt.message) {
+				t.Fatalf("expected %d %q, got %d: %s", tt.status, tt.message, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	// Bob's upload matches Alice's first one, so they share a blob.
+	if blobs.Len() != 2 {
+		t.Fatalf("expected rejected uploads to leave no blobs, have %d", blobs.Len())
+	}
+}
+
+func TestAttachments_RequireWriteAccess(t *testing.T) {
+	listID := int64(5)
+	repo := &fakeRepo{getItems: map[int64]Item{1: {ID: 1, Title: "shared", ListID: &listID}}}
+	h := NewHandler(repo, WithPermissions(fakePermissions{3: sharing.RoleViewer}), WithAttachments(unusedAttachments{}, 100, 100))
+
+	rr := httptest.NewRecorder()
+	h.UploadAttachment(rr, uploadRequest(t, "notes.txt", []byte("hello"), &auth.Principal{Kind: auth.KindUser, ID: 3}))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected viewers to be refused, got %d", rr.Code)
+	}
+}
+
+// unusedAttachments panics if the handler gets as far as the store.
+type unusedAttachments struct{ AttachmentStore }
@@ -0,0 +1,247 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+
+	"todoapp/backend/internal/db"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const attachmentColumns = `id, todo_id, filename, content_type, size, blob_key, uploader_key, created_at FROM attachments`
+
+func (r *Repository) ListAttachments(ctx context.Context, todoID int64) (attachments []Attachment, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.ListAttachments", trace.WithAttributes(attribute.Int64("todo.id", todoID)))
+	defer func() { endSpan(span, err) }()
+
+	const query = `SELECT ` + attachmentColumns + ` WHERE todo_id = ? ORDER BY id ASC`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "attachments", query)
+	rows, err := r.db.QueryContext(ctx, query, todoID)
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments = make([]Attachment, 0)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *Repository) GetAttachment(ctx context.Context, id int64) (attachment Attachment, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.GetAttachment", trace.WithAttributes(attribute.Int64("attachment.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	const query = `SELECT ` + attachmentColumns + ` WHERE id = ?`
+	queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "attachments", query)
+	attachment, err = scanAttachment(r.db.QueryRowContext(queryCtx, query, id))
+	endSpan(stmtSpan, err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Attachment{}, ErrAttachmentNotFound
+		}
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// AttachmentUsage returns the bytes uploaded by uploaderKey across all todos.
+// Every attachment counts in full, even when its blob is shared.
+func (r *Repository) AttachmentUsage(ctx context.Context, uploaderKey string) (used int64, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.AttachmentUsage")
+	defer func() { endSpan(span, err) }()
+
+	const query = `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE uploader_key = ?`
+	queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "attachments", query)
+	err = r.db.QueryRowContext(queryCtx, query, uploaderKey).Scan(&used)
+	endSpan(stmtSpan, err)
+	return used, err
+}
+
+// AddAttachment stores content in the blob store and records it on the todo.
+// Reading more than maxBytes fails with ErrAttachmentTooLarge without
+// recording anything.
+func (r *Repository) AddAttachment(ctx context.Context, attachment Attachment, content io.Reader, maxBytes int64) (created Attachment, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.AddAttachment", trace.WithAttributes(attribute.Int64("todo.id", attachment.TodoID)))
+	defer func() { endSpan(span, err) }()
+
+	if r.blobs == nil {
+		return Attachment{}, errors.New("attachments require a blob store")
+	}
+
+	attachment, err = r.storeAttachment(ctx, attachment, content, maxBytes)
+	if err != nil {
+		if attachment.BlobKey != "" {
+			r.releaseBlobs(ctx, attachment.BlobKey)
+		}
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// storeAttachment puts content in the blob store and inserts the row that
+// refers to it. It holds blobMu for reading throughout, so releaseBlobs
+// cannot delete a blob that Put found already stored before the row is
+// there to keep it. On failure the returned attachment carries the key of
+// any blob that was stored.
+func (r *Repository) storeAttachment(ctx context.Context, attachment Attachment, content io.Reader, maxBytes int64) (Attachment, error) {
+	r.blobMu.RLock()
+	defer r.blobMu.RUnlock()
+
+	key, size, err := r.blobs.Put(ctx, &limitedReader{r: content, remaining: maxBytes})
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	attachment.BlobKey = key
+	attachment.Size = size
+	attachment.CreatedAt = r.now().UTC()
+	result, err := r.exec(ctx, "INSERT", "attachments", `
+		INSERT INTO attachments (todo_id, filename, content_type, size, blob_key, uploader_key, created_at)
+		SELECT id, ?, ?, ?, ?, ?, ? FROM todos WHERE id = ?`,
+		attachment.Filename, attachment.ContentType, attachment.Size, attachment.BlobKey,
+		attachment.UploaderKey, attachment.CreatedAt, attachment.TodoID)
+	if err == nil {
+		err = requireUpdated(result, ErrNotFound)
+	}
+	if err == nil {
+		attachment.ID, err = result.LastInsertId()
+	}
+	return attachment, err
+}
+
+// OpenAttachment returns the content of attachment for reading.
+func (r *Repository) OpenAttachment(ctx context.Context, attachment Attachment) (content io.ReadSeekCloser, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.OpenAttachment", trace.WithAttributes(attribute.Int64("attachment.id", attachment.ID)))
+	defer func() { endSpan(span, err) }()
+
+	if r.blobs == nil {
+		return nil, errors.New("attachments require a blob store")
+	}
+	return r.blobs.Open(ctx, attachment.BlobKey)
+}
+
+// DeleteAttachment removes the attachment and, when no other attachment
+// shares it, its blob.
+func (r *Repository) DeleteAttachment(ctx context.Context, id int64) (err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.DeleteAttachment", trace.WithAttributes(attribute.Int64("attachment.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	var key string
+	err = db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		const query = `SELECT blob_key FROM attachments WHERE id = ?`
+		queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "attachments", query)
+		err := tx.QueryRowContext(queryCtx, query, id).Scan(&key)
+		endSpan(stmtSpan, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAttachmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = execStatement(ctx, tx, "DELETE", "attachments", `DELETE FROM attachments WHERE id = ?`, id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	r.releaseBlobs(ctx, key)
+	return nil
+}
+
+func attachmentBlobKeys(ctx context.Context, tx *sql.Tx, todoID int64) ([]string, error) {
+	const query = `SELECT DISTINCT blob_key FROM attachments WHERE todo_id = ?`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "attachments", query)
+	rows, err := tx.QueryContext(ctx, query, todoID)
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	return keys, err
+}
+
+// releaseBlobs deletes the blobs among keys that no attachment refers to any
+// more. Failures are logged rather than returned: the rows are already gone
+// and a leftover blob only costs disk space. It holds blobMu while it counts
+// and deletes, so an upload of the same content either finishes recording
+// its row first or stores the blob again afterwards.
+func (r *Repository) releaseBlobs(ctx context.Context, keys ...string) {
+	if r.blobs == nil {
+		return
+	}
+	r.blobMu.Lock()
+	defer r.blobMu.Unlock()
+	for _, key := range keys {
+		const query = `SELECT COUNT(*) FROM attachments WHERE blob_key = ?`
+		queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "attachments", query)
+		var refs int
+		err := r.db.QueryRowContext(queryCtx, query, key).Scan(&refs)
+		endSpan(stmtSpan, err)
+		if err == nil && refs == 0 {
+			err = r.blobs.Delete(ctx, key)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "failed to delete orphaned blob", "blob", key, "error", err)
+		}
+	}
+}
+
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var attachment Attachment
+	err := row.Scan(&attachment.ID, &attachment.TodoID, &attachment.Filename, &attachment.ContentType,
+		&attachment.Size, &attachment.BlobKey, &attachment.UploaderKey, &attachment.CreatedAt)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// limitedReader fails with ErrAttachmentTooLarge once more than remaining
+// bytes have been read, so the blob store aborts the upload.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, ErrAttachmentTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,114 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"todoapp/backend/internal/db"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var nativeUIDPattern = regexp.MustCompile(`^todo-(\d+)@todoapp$`)
+
+// nativeUID is the calendar UID of a todo created through this server.
+func nativeUID(id int64) string {
+	return fmt.Sprintf("todo-%d@todoapp", id)
+}
+
+// GetByUID returns the todo on listID whose calendar UID is uid.
+func (r *Repository) GetByUID(ctx context.Context, listID *int64, uid string) (item Item, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.GetByUID")
+	defer func() { endSpan(span, err) }()
+
+	id, err := findByUID(ctx, r.db, listID, uid)
+	if err != nil {
+		return Item{}, err
+	}
+	return r.get(ctx, id)
+}
+
+// UpsertByUID applies calendar items to listID in one transaction. An item
+// whose UID names a todo on the list, either one created here or one
+// imported earlier, updates it; any other item is created and keeps its UID.
+func (r *Repository) UpsertByUID(ctx context.Context, listID *int64, items []Item) (outcomes []ImportOutcome, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.UpsertByUID", trace.WithAttributes(attribute.Int("todo.import.items", len(items))))
+	defer func() { endSpan(span, err) }()
+
+	err = db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		outcomes = make([]ImportOutcome, len(items))
+		for i, item := range items {
+			id, err := findByUID(ctx, tx, listID, item.UID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			if err == nil {
+				outcomes[i] = ImportOutcome{ID: id, Updated: true}
+				before, err := getItem(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				if err := r.replace(ctx, tx, id, item); err != nil {
+					return err
+				}
+				if err := recordChange(ctx, tx, id, r.serverClock(), syncedFields...); err != nil {
+					return err
+				}
+				if err := r.publishCompletion(ctx, tx, before); err != nil {
+					return err
+				}
+				continue
+			}
+
+			item.ListID = listID
+			if outcomes[i].ID, err = r.insertTodo(ctx, tx, item, Clock{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// replace overwrites the calendar-visible fields of todo id. A todo that
+// stays completed keeps its original completion time and attribution.
+func (r *Repository) replace(ctx context.Context, tx *sql.Tx, id int64, item Item) error {
+	_, err := execStatement(ctx, tx, "UPDATE", "todos", `
+		UPDATE todos SET
+			title = ?, notes = ?, due = ?, priority = ?,
+			completed_at = CASE WHEN ? THEN COALESCE(?, CASE WHEN completed THEN completed_at END, ?) END,
+			completed_by = CASE WHEN ? AND completed THEN completed_by END,
+			completed = ?
+		WHERE id = ?`,
+		item.Title, item.Notes, nullTime(item.Due), item.Priority,
+		item.Completed, nullTime(item.CompletedAt), r.now().UTC(),
+		item.Completed,
+		item.Completed, id)
+	return err
+}
+
+// findByUID returns the id of the todo on listID with uid.
+func findByUID(ctx context.Context, conn queryRower, listID *int64, uid string) (int64, error) {
+	var nativeID int64
+	if match := nativeUIDPattern.FindStringSubmatch(uid); match != nil {
+		nativeID, _ = strconv.ParseInt(match[1], 10, 64)
+	}
+
+	const query = `SELECT id FROM todos WHERE list_id IS ? AND (uid = ? OR (uid IS NULL AND id = ?))`
+	queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "todos", query)
+	var id int64
+	err := conn.QueryRowContext(queryCtx, query, nullInt64(listID), uid, nativeID).Scan(&id)
+	endSpan(stmtSpan, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
@@ -0,0 +1,186 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/ical"
+	"todoapp/backend/internal/respond"
+)
+
+type CalendarStore interface {
+	UpsertByUID(ctx context.Context, listID *int64, items []Item) ([]ImportOutcome, error)
+}
+
+// FeedTokens resolves the secret token of a calendar subscription URL to
+// the user it was issued to, failing with auth.ErrInvalidFeedToken.
+type FeedTokens interface {
+	Authenticate(ctx context.Context, token string) (int64, error)
+}
+
+// WithCalendar enables the iCalendar feed and upload. Without tokens only
+// the default list can be subscribed to.
+func WithCalendar(store CalendarStore, tokens FeedTokens) Option {
+	return func(h *Handler) {
+		h.calendar = store
+		h.feedTokens = tokens
+	}
+}
+
+// CalendarFeed serves the default list, or the shared list given by ?list=,
+// as VTODOs. Calendar apps cannot log in, so ?token= stands in for the
+// user's credentials with read-only access.
+func (h *Handler) CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if h.calendar == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		if h.feedTokens == nil {
+			http.Error(w, "calendar not found", http.StatusNotFound)
+			return
+		}
+		userID, err := h.feedTokens.Authenticate(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidFeedToken) {
+				http.Error(w, "calendar not found", http.StatusNotFound)
+				return
+			}
+			respond.ServerError(w, r, "failed to check feed token", err)
+			return
+		}
+		principal := auth.Principal{Kind: auth.KindUser, ID: userID, Scope: auth.ScopeRead}
+		r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+	}
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, false, "calendar not found") {
+		return
+	}
+
+	items, err := h.repo.List(r.Context(), listID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch todos", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	writer := ical.NewWriter(w, "Todos", time.Now())
+	for _, item := range items {
+		if err := writer.WriteTodo(VTODO(item)); err != nil {
+			break
+		}
+	}
+	if err := writer.Close(); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write calendar", "error", err)
+	}
+}
+
+type calendarImportResult struct {
+	Created int                  `json:"created"`
+	Updated int                  `json:"updated"`
+	Items   []calendarImportItem `json:"items"`
+}
+
+type calendarImportItem struct {
+	UID    string `json:"uid"`
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+// ImportCalendar applies the VTODOs of an uploaded .ics file to the default
+// list, or the list given by ?list=. VTODOs whose UID matches a todo on the
+// list update it; the rest are created. Either every VTODO is applied or,
+// when one is invalid, none is.
+func (h *Handler) ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	if h.calendar == nil {
+		http.NotFound(w, r)
+		return
+	}
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, true, "todo list not found") {
+		return
+	}
+
+	todos, err := ical.Decode(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items := make([]Item, len(todos))
+	for i, todo := range todos {
+		item, err := ItemFromVTODO(todo, h.maxTitleLength)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("VTODO %q: %v", todo.UID, err), http.StatusBadRequest)
+			return
+		}
+		items[i] = item
+	}
+
+	outcomes, err := h.calendar.UpsertByUID(r.Context(), listID, items)
+	if err != nil {
+		respond.ServerError(w, r, "failed to import calendar", err)
+		return
+	}
+
+	result := calendarImportResult{Items: make([]calendarImportItem, len(outcomes))}
+	for i, outcome := range outcomes {
+		result.Items[i] = calendarImportItem{UID: items[i].UID, ID: outcome.ID, Status: "created"}
+		if outcome.Updated {
+			result.Items[i].Status = "updated"
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+	respond.JSON(w, r, http.StatusOK, result)
+}
+
+// VTODO returns the calendar form of item.
+func VTODO(item Item) ical.Todo {
+	return ical.Todo{
+		UID:         item.UID,
+		Summary:     item.Title,
+		Description: item.Notes,
+		Completed:   item.Completed,
+		CompletedAt: item.CompletedAt,
+		Due:         item.Due,
+		Priority:    item.Priority,
+	}
+}
+
+// ItemFromVTODO validates todo with the same rules as CreateTodo and
+// converts it into an item that keeps the VTODO's UID.
+func ItemFromVTODO(todo ical.Todo, maxTitleLength int) (Item, error) {
+	title := strings.TrimSpace(todo.Summary)
+	if title == "" {
+		return Item{}, errors.New("SUMMARY is required")
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return Item{}, fmt.Errorf("SUMMARY must be at most %d characters", maxTitleLength)
+	}
+	if err := CheckNotes(todo.Description); err != nil {
+		return Item{}, fmt.Errorf("DESCRIPTION: %w", err)
+	}
+	return Item{
+		UID:         todo.UID,
+		Title:       title,
+		Notes:       todo.Description,
+		Completed:   todo.Completed,
+		CompletedAt: todo.CompletedAt,
+		Due:         todo.Due,
+		Priority:    todo.Priority,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/sharing"
+)
+
+type fakeFeedTokens map[string]int64
+
+func (f fakeFeedTokens) Authenticate(_ context.Context, token string) (int64, error) {
+	userID, ok := f[token]
+	if !ok {
+		return 0, auth.ErrInvalidFeedToken
+	}
+	return userID, nil
+}
+
+func TestCalendarFeed(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO lists (id, name, created_at) VALUES (5, 'Shared', ?)`, time.Now()); err != nil {
+		t.Fatalf("insert list: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO todos (title, list_id, priority, due) VALUES ('Shared; secret', 5, 1, '2026-11-01 00:00:00+00:00')`); err != nil {
+		t.Fatalf("insert shared todo: %v", err)
+	}
+	repo := NewRepository(db)
+	h := NewHandler(repo,
+		WithPermissions(fakePermissions{3: sharing.RoleViewer}),
+		WithCalendar(repo, fakeFeedTokens{"viewer-token": 3, "outsider-token": 4}))
+
+	rr := httptest.NewRecorder()
+	h.CalendarFeed(rr, httptest.NewRequest(http.MethodGet, "/api/calendar.ics", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/calendar; charset=utf-8" {
+		t.Fatalf("unexpected response: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"UID:todo-1@todoapp\r\n", "SUMMARY:First\r\n", "STATUS:NEEDS-ACTION\r\n", "UID:todo-2@todoapp\r\n", "STATUS:COMPLETED\r\n"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in feed:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "secret") {
+		t.Fatalf("expected the default feed to leave out shared lists:\n%s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	h.CalendarFeed(rr, httptest.NewRequest(http.MethodGet, "/api/calendar.ics?list=5&token=viewer-token", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected a member's token to open the shared feed, got %d", rr.Code)
+	}
+	body = rr.Body.String()
+	for _, want := range []string{`SUMMARY:Shared\; secret`, "PRIORITY:1\r\n", "DUE;VALUE=DATE:20261101\r\n"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in feed:\n%s", want, body)
+		}
+	}
+
+	for _, token := range []string{"outsider-token", "wrong"} {
+		rr = httptest.NewRecorder()
+		h.CalendarFeed(rr, httptest.NewRequest(http.MethodGet, "/api/calendar.ics?list=5&token="+token, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected status 404, got %d", token, rr.Code)
+		}
+	}
+}
+
+func TestImportCalendar_CreatesThenUpdatesByUID(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithCalendar(repo, nil))
+
+	upload := func(vtodos ...string) calendarImportResult {
+		t.Helper()
+		body := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(vtodos, "") + "END:VCALENDAR\r\n"
+		rr := httptest.NewRecorder()
+		h.ImportCalendar(rr, httptest.NewRequest(http.MethodPost, "/api/calendar.ics", strings.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var result calendarImportResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode result: %v", err)
+		}
+		return result
+	}
+	vtodo := func(uid string, props string) string {
+		return "BEGIN:VTODO\r\nUID:" + uid + "\r\n" + props + "END:VTODO\r\n"
+	}
+
+	result := upload(
+		vtodo("phone-1", "SUMMARY:Renew passport\r\nDUE;VALUE=DATE:20261201\r\nPRIORITY:5\r\n"),
+		vtodo("todo-1@todoapp", "SUMMARY:First, renamed\r\nSTATUS:COMPLETED\r\n"),
+	)
+	if result.Created != 1 || result.Updated != 1 || result.Items[1].ID != 1 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+
+	first, err := repo.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if first.Title != "First, renamed" || !first.Completed || first.CompletedAt == nil {
+		t.Fatalf("expected the native todo to be updated: %#v", first)
+	}
+
+	result = upload(vtodo("phone-1", "SUMMARY:Renew passport\r\nSTATUS:COMPLETED\r\n"))
+	if result.Created != 0 || result.Updated != 1 {
+		t.Fatalf("expected the imported UID to be matched: %#v", result)
+	}
+	passport, err := repo.Get(context.Background(), result.Items[0].ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if passport.UID != "phone-1" || !passport.Completed || passport.Due != nil || passport.Priority != 0 {
+		t.Fatalf("unexpected imported todo: %#v", passport)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ImportCalendar(rr, httptest.NewRequest(http.MethodPost, "/api/calendar.ics",
+		strings.NewReader("BEGIN:VCALENDAR\r\n"+vtodo("a", "SUMMARY:ok\r\n")+vtodo("b", "")+"END:VCALENDAR\r\n")))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a VTODO without SUMMARY to be rejected, got %d", rr.Code)
+	}
+	if items, _ := repo.List(context.Background(), nil); len(items) != 3 {
+		t.Fatalf("expected nothing from the rejected upload, have %d todos", len(items))
+	}
+}
@@ -0,0 +1,243 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/markdown"
+	"todoapp/backend/internal/respond"
+)
+
+const maxCommentLength = 5000
+
+type CommentStore interface {
+	ListComments(ctx context.Context, todoID int64) ([]Comment, error)
+	GetComment(ctx context.Context, id int64) (Comment, error)
+	CreateComment(ctx context.Context, comment Comment) (Comment, error)
+	UpdateComment(ctx context.Context, id int64, body string) (Comment, error)
+	DeleteComment(ctx context.Context, id int64) error
+}
+
+// ListComments returns the comments on a todo as threads.
+func (h *Handler) ListComments(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := h.commentTodo(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := h.comments.ListComments(r.Context(), todoID)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch comments", err)
+		return
+	}
+	for i := range comments {
+		if err := renderComment(r, &comments[i]); err != nil {
+			respond.ServerError(w, r, "failed to render comment", err)
+			return
+		}
+	}
+	respond.JSON(w, r, http.StatusOK, threadComments(comments))
+}
+
+type createCommentRequest struct {
+	Body     string `json:"body"`
+	ParentID *int64 `json:"parentId"`
+}
+
+// CreateComment adds a comment or, with parentId, a reply. Anyone who can
+// read the todo may comment, including viewers of a shared list.
+func (h *Handler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	todoID, ok := h.commentTodo(w, r)
+	if !ok {
+		return
+	}
+
+	var req createCommentRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	body, ok := validCommentBody(w, req.Body)
+	if !ok {
+		return
+	}
+	if req.ParentID != nil {
+		parent, err := h.comments.GetComment(r.Context(), *req.ParentID)
+		if err != nil && !errors.Is(err, ErrCommentNotFound) {
+			respond.ServerError(w, r, "failed to fetch comment", err)
+			return
+		}
+		if err != nil || parent.TodoID != todoID {
+			http.Error(w, "parent comment not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	name := principal.Name
+	if name == "" {
+		name = principal.Kind
+	}
+	comment, err := h.comments.CreateComment(r.Context(), Comment{
+		TodoID:     todoID,
+		ParentID:   req.ParentID,
+		AuthorKey:  principal.Key(),
+		AuthorName: name,
+		Body:       body,
+	})
+	if err != nil {
+		respond.ServerError(w, r, "failed to create comment", err)
+		return
+	}
+	if err := renderComment(r, &comment); err != nil {
+		respond.ServerError(w, r, "failed to render comment", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusCreated, comment)
+}
+
+type updateCommentRequest struct {
+	Body string `json:"body"`
+}
+
+func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.ownComment(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateCommentRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	body, ok := validCommentBody(w, req.Body)
+	if !ok {
+		return
+	}
+
+	updated, err := h.comments.UpdateComment(r.Context(), comment.ID, body)
+	if err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			http.Error(w, "comment not found", http.StatusNotFound)
+			return
+		}
+		respond.ServerError(w, r, "failed to update comment", err)
+		return
+	}
+	if err := renderComment(r, &updated); err != nil {
+		respond.ServerError(w, r, "failed to render comment", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, updated)
+}
+
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.ownComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.comments.DeleteComment(r.Context(), comment.ID); err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			http.Error(w, "comment not found", http.StatusNotFound)
+			return
+		}
+		respond.ServerError(w, r, "failed to delete comment", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// commentTodo resolves the {id} todo and checks the caller may read it.
+func (h *Handler) commentTodo(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if h.comments == nil {
+		http.NotFound(w, r)
+		return 0, false
+	}
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid todo id", http.StatusBadRequest)
+		return 0, false
+	}
+	if _, ok := h.authorizeItem(w, r, id, false); !ok {
+		return 0, false
+	}
+	return id, true
+}
+
+// ownComment resolves the {commentId} comment on the {id} todo and checks
+// that the caller wrote it. The admin token may moderate any comment.
+func (h *Handler) ownComment(w http.ResponseWriter, r *http.Request) (Comment, bool) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return Comment{}, false
+	}
+	todoID, ok := h.commentTodo(w, r)
+	if !ok {
+		return Comment{}, false
+	}
+	commentID, err := parseID(r.PathValue("commentId"))
+	if err != nil {
+		http.Error(w, "invalid comment id", http.StatusBadRequest)
+		return Comment{}, false
+	}
+
+	comment, err := h.comments.GetComment(r.Context(), commentID)
+	if err != nil && !errors.Is(err, ErrCommentNotFound) {
+		respond.ServerError(w, r, "failed to fetch comment", err)
+		return Comment{}, false
+	}
+	if err != nil || comment.TodoID != todoID || comment.Deleted {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return Comment{}, false
+	}
+	if comment.AuthorKey != principal.Key() && principal.Kind != auth.KindAdmin {
+		http.Error(w, "only the author can change this comment", http.StatusForbidden)
+		return Comment{}, false
+	}
+	return comment, true
+}
+
+// requirePrincipal returns the caller, since comments and attachments must
+// be attributable.
+func requirePrincipal(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return auth.Principal{}, false
+	}
+	return principal, true
+}
+
+func validCommentBody(w http.ResponseWriter, raw string) (string, bool) {
+	body := strings.TrimSpace(raw)
+	if body == "" {
+		http.Error(w, "body is required", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		http.Error(w, fmt.Sprintf("body must be at most %d characters", maxCommentLength), http.StatusBadRequest)
+		return "", false
+	}
+	return body, true
+}
+
+func renderComment(r *http.Request, comment *Comment) error {
+	if !wantsHTML(r) || comment.Body == "" {
+		return nil
+	}
+	html, err := markdown.Render(comment.Body)
+	if err != nil {
+		return err
+	}
+	comment.BodyHTML = html
+	return nil
+}
@@ -0,0 +1,127 @@
+package todo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/auth"
+)
+
+func commentRequest(method string, target string, body string, principal *auth.Principal, commentID int64) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.SetPathValue("id", "1")
+	if commentID != 0 {
+		req.SetPathValue("commentId", strconv.FormatInt(commentID, 10))
+	}
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	return req
+}
+
+func TestComments_Thread(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithComments(repo))
+	alice := &auth.Principal{Kind: auth.KindAPIKey, ID: 1, Name: "alice"}
+	bob := &auth.Principal{Kind: auth.KindAPIKey, ID: 2, Name: "bob"}
+
+	rr := httptest.NewRecorder()
+	h.CreateComment(rr, commentRequest(http.MethodPost, "/api/todos/1/comments", `{"body":"See [notes](https://example.com)"}`, alice, 0))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var root Comment
+	if err := json.Unmarshal(rr.Body.Bytes(), &root); err != nil {
+		t.Fatalf("decode comment: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	h.CreateComment(rr, commentRequest(http.MethodPost, "/api/todos/1/comments", `{"body":"thanks","parentId":`+strconv.FormatInt(root.ID, 10)+`}`, bob, 0))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected reply to be created, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ListComments(rr, commentRequest(http.MethodGet, "/api/todos/1/comments?render=html", "", nil, 0))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var thread []Comment
+	if err := json.Unmarshal(rr.Body.Bytes(), &thread); err != nil {
+		t.Fatalf("decode thread: %v", err)
+	}
+	if len(thread) != 1 || len(thread[0].Replies) != 1 || thread[0].Replies[0].AuthorName != "bob" {
+		t.Fatalf("unexpected thread: %#v", thread)
+	}
+	if !strings.Contains(thread[0].BodyHTML, `href="https://example.com"`) {
+		t.Fatalf("expected rendered html, got %q", thread[0].BodyHTML)
+	}
+
+	// Only the author may edit or delete.
+	rr = httptest.NewRecorder()
+	h.UpdateComment(rr, commentRequest(http.MethodPatch, "/api/todos/1/comments/1", `{"body":"hijacked"}`, bob, root.ID))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for another author, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	h.UpdateComment(rr, commentRequest(http.MethodPatch, "/api/todos/1/comments/1", `{"body":"edited"}`, alice, root.ID))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"body":"edited"`) {
+		t.Fatalf("expected author edit to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.DeleteComment(rr, commentRequest(http.MethodDelete, "/api/todos/1/comments/1", "", alice, root.ID))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+
+	// The deleted comment stays as a placeholder so the reply keeps its thread.
+	comments, err := repo.ListComments(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("list comments: %v", err)
+	}
+	thread = threadComments(comments)
+	if len(thread) != 1 || !thread[0].Deleted || thread[0].Body != "" || len(thread[0].Replies) != 1 {
+		t.Fatalf("unexpected thread after delete: %#v", thread)
+	}
+}
+
+func TestComments_Validation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithComments(repo))
+	alice := &auth.Principal{Kind: auth.KindAPIKey, ID: 1, Name: "alice"}
+
+	cases := []struct {
+		name      string
+		body      string
+		principal *auth.Principal
+		want      int
+	}{
+		{name: "anonymous", body: `{"body":"hi"}`, want: http.StatusUnauthorized},
+		{name: "empty body", body: `{"body":"  "}`, principal: alice, want: http.StatusBadRequest},
+		{name: "unknown parent", body: `{"body":"hi","parentId":99}`, principal: alice, want: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		h.CreateComment(rr, commentRequest(http.MethodPost, "/api/todos/1/comments", tc.body, tc.principal, 0))
+		if rr.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.want, rr.Code)
+		}
+	}
+
+	req := commentRequest(http.MethodGet, "/api/todos/99/comments", "", nil, 0)
+	req.SetPathValue("id", "99")
+	rr := httptest.NewRecorder()
+	h.ListComments(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for missing todo, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,168 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const commentColumns = `id, todo_id, parent_id, author_key, author_name, body, created_at, updated_at, deleted_at FROM comments`
+
+// ListComments returns every comment on todoID, oldest first, as a flat
+// slice; the handler assembles threads.
+func (r *Repository) ListComments(ctx context.Context, todoID int64) (comments []Comment, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.ListComments", trace.WithAttributes(attribute.Int64("todo.id", todoID)))
+	defer func() { endSpan(span, err) }()
+
+	const query = `SELECT ` + commentColumns + ` WHERE todo_id = ? ORDER BY id ASC`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "comments", query)
+	rows, err := r.db.QueryContext(ctx, query, todoID)
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments = make([]Comment, 0)
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *Repository) GetComment(ctx context.Context, id int64) (comment Comment, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.GetComment", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return r.getComment(ctx, id)
+}
+
+func (r *Repository) CreateComment(ctx context.Context, comment Comment) (created Comment, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.CreateComment", trace.WithAttributes(attribute.Int64("todo.id", comment.TodoID)))
+	defer func() { endSpan(span, err) }()
+
+	now := r.now().UTC()
+	result, err := r.exec(ctx, "INSERT", "comments", `
+		INSERT INTO comments (todo_id, parent_id, author_key, author_name, body, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		comment.TodoID, nullInt64(comment.ParentID), comment.AuthorKey, comment.AuthorName, comment.Body, now, now)
+	if err != nil {
+		return Comment{}, err
+	}
+	if comment.ID, err = result.LastInsertId(); err != nil {
+		return Comment{}, err
+	}
+	comment.CreatedAt = now
+	comment.UpdatedAt = now
+	return comment, nil
+}
+
+func (r *Repository) UpdateComment(ctx context.Context, id int64, body string) (comment Comment, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.UpdateComment", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	result, err := r.exec(ctx, "UPDATE", "comments",
+		`UPDATE comments SET body = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`, body, r.now().UTC(), id)
+	if err != nil {
+		return Comment{}, err
+	}
+	if err := requireUpdated(result, ErrCommentNotFound); err != nil {
+		return Comment{}, err
+	}
+	return r.getComment(ctx, id)
+}
+
+// DeleteComment blanks the comment instead of removing the row so that its
+// replies keep their place in the thread.
+func (r *Repository) DeleteComment(ctx context.Context, id int64) (err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.DeleteComment", trace.WithAttributes(attribute.Int64("comment.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	now := r.now().UTC()
+	result, err := r.exec(ctx, "UPDATE", "comments",
+		`UPDATE comments SET body = '', updated_at = ?, deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, now, now, id)
+	if err != nil {
+		return err
+	}
+	return requireUpdated(result, ErrCommentNotFound)
+}
+
+func (r *Repository) getComment(ctx context.Context, id int64) (Comment, error) {
+	const query = `SELECT ` + commentColumns + ` WHERE id = ?`
+	queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "comments", query)
+	comment, err := scanComment(r.db.QueryRowContext(queryCtx, query, id))
+	endSpan(stmtSpan, err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Comment{}, ErrCommentNotFound
+		}
+		return Comment{}, err
+	}
+	return comment, nil
+}
+
+func scanComment(row rowScanner) (Comment, error) {
+	var (
+		comment   Comment
+		parentID  sql.NullInt64
+		deletedAt sql.NullTime
+	)
+	err := row.Scan(&comment.ID, &comment.TodoID, &parentID, &comment.AuthorKey, &comment.AuthorName,
+		&comment.Body, &comment.CreatedAt, &comment.UpdatedAt, &deletedAt)
+	if err != nil {
+		return Comment{}, err
+	}
+	if parentID.Valid {
+		comment.ParentID = &parentID.Int64
+	}
+	comment.Deleted = deletedAt.Valid
+	return comment, nil
+}
+
+func requireUpdated(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// threadComments nests replies under their parents, preserving order.
+func threadComments(flat []Comment) []Comment {
+	children := make(map[int64][]Comment)
+	roots := make([]Comment, 0)
+	for _, comment := range flat {
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+			continue
+		}
+		children[*comment.ParentID] = append(children[*comment.ParentID], comment)
+	}
+
+	var attach func(comments []Comment) []Comment
+	attach = func(comments []Comment) []Comment {
+		for i := range comments {
+			if replies, ok := children[comments[i].ID]; ok {
+				comments[i].Replies = attach(replies)
+			}
+		}
+		return comments
+	}
+	return attach(roots)
+}
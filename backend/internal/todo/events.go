@@ -0,0 +1,69 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Event types. All but EventReminder are raised by the repository;
+// reminders are raised when they fire.
+const (
+	EventCreated   = "todo.created"
+	EventCompleted = "todo.completed"
+	EventReopened  = "todo.reopened"
+	EventDeleted   = "todo.deleted"
+	EventReminder  = "todo.reminder"
+)
+
+var EventTypes = []string{EventCreated, EventCompleted, EventReopened, EventDeleted, EventReminder}
+
+// Event describes a change to a todo. Item is the todo after the change, or
+// as it was before a deletion.
+type Event struct {
+	Type string    `json:"event"`
+	Time time.Time `json:"occurredAt"`
+	Item Item      `json:"todo"`
+}
+
+// EventSink records events. Publish runs inside the transaction that made
+// the change, so an event is kept exactly when the change is committed.
+type EventSink interface {
+	Publish(ctx context.Context, tx *sql.Tx, event Event) error
+}
+
+// WithEvents raises events for every write that creates, completes,
+// reopens or deletes a todo, whichever endpoint it came through.
+func WithEvents(sink EventSink) RepositoryOption {
+	return func(r *Repository) { r.events = sink }
+}
+
+// publish reads todo id through tx and hands it to the event sink, if any.
+func (r *Repository) publish(ctx context.Context, tx *sql.Tx, eventType string, id int64) error {
+	if r.events == nil {
+		return nil
+	}
+	item, err := getItem(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	return r.events.Publish(ctx, tx, Event{Type: eventType, Time: r.now().UTC(), Item: item})
+}
+
+// publishCompletion raises EventCompleted or EventReopened when a write
+// through tx completed or reopened the todo that was before. Every write
+// that can change a todo's completion calls it.
+func (r *Repository) publishCompletion(ctx context.Context, tx *sql.Tx, before Item) error {
+	if r.events == nil {
+		return nil
+	}
+	item, err := getItem(ctx, tx, before.ID)
+	if err != nil || item.Completed == before.Completed {
+		return err
+	}
+	eventType := EventReopened
+	if item.Completed {
+		eventType = EventCompleted
+	}
+	return r.events.Publish(ctx, tx, Event{Type: eventType, Time: r.now().UTC(), Item: item})
+}
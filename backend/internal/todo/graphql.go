@@ -0,0 +1,929 @@
+package todo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/graphql"
+	"todoapp/backend/internal/markdown"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/pkg/api"
+)
+
+// ListDirectory names the shared lists a user belongs to, with their role
+// on each.
+type ListDirectory interface {
+	Lists(ctx context.Context, userID int64) ([]sharing.List, error)
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+
+	// defaultPollInterval is how often a todoChanged subscription looks
+	// for changes.
+	defaultPollInterval = time.Second
+)
+
+// Error codes reported as extensions.code, besides those of the graphql
+// package.
+const (
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeForbidden       = "FORBIDDEN"
+	codeNotFound        = "NOT_FOUND"
+)
+
+// WithGraphQL enables the GraphQL endpoint. lists resolves the shared lists
+// todos belong to, and allowAnonymous lets callers without credentials run
+// mutations, matching a server that does not require authentication.
+// Subscriptions need WithSync as well.
+func WithGraphQL(lists ListDirectory, allowAnonymous bool) Option {
+	return func(h *Handler) {
+		h.lists = lists
+		h.allowAnonymous = allowAnonymous
+		h.pollInterval = defaultPollInterval
+		h.graphql = h.graphQLSchema()
+	}
+}
+
+// todoConnection is a page of todos.
+type todoConnection struct {
+	nodes   []Item
+	total   int
+	hasNext bool
+}
+
+type todoStats struct {
+	total, completed, open, overdue int
+	byPriority                      []countOf
+	tags                            []countOf
+}
+
+type countOf struct {
+	key   any
+	count int
+}
+
+// graphQLLoaders batch and cache the reads of one GraphQL execution.
+type graphQLLoaders struct {
+	items *graphql.Loader[int64, []Item]
+	todos *graphql.Loader[int64, Item]
+	lists *graphql.Loader[int64, sharing.List]
+}
+
+type loadersKey struct{}
+
+// graphQLContext gives each execution its own loaders, so that nothing is
+// cached across requests or subscription events.
+func (h *Handler) graphQLContext(ctx context.Context) context.Context {
+	loaders := &graphQLLoaders{
+		// Items are keyed by list ID, with 0 for the default list.
+		items: graphql.NewLoader(func(ctx context.Context, keys []int64) (map[int64][]Item, error) {
+			result := make(map[int64][]Item, len(keys))
+			for _, key := range keys {
+				items, err := h.repo.List(ctx, listKeyID(key))
+				if err != nil {
+					return nil, err
+				}
+				result[key] = items
+			}
+			return result, nil
+		}),
+		todos: graphql.NewLoader(func(ctx context.Context, ids []int64) (map[int64]Item, error) {
+			result := make(map[int64]Item, len(ids))
+			for _, id := range ids {
+				item, err := h.repo.Get(ctx, id)
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				result[id] = item
+			}
+			return result, nil
+		}),
+		// One call names every list the caller belongs to, however many
+		// lists the query touches.
+		lists: graphql.NewLoader(func(ctx context.Context, _ []int64) (map[int64]sharing.List, error) {
+			lists, err := h.memberLists(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[int64]sharing.List, len(lists))
+			for _, list := range lists {
+				result[list.ID] = list
+			}
+			return result, nil
+		}),
+	}
+	return context.WithValue(ctx, loadersKey{}, loaders)
+}
+
+func (h *Handler) loaders(ctx context.Context) *graphQLLoaders {
+	if loaders, ok := ctx.Value(loadersKey{}).(*graphQLLoaders); ok {
+		return loaders
+	}
+	return h.graphQLContext(ctx).Value(loadersKey{}).(*graphQLLoaders)
+}
+
+// memberLists returns the shared lists of the signed-in user, or none for
+// other callers.
+func (h *Handler) memberLists(ctx context.Context) ([]sharing.List, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || principal.Kind != auth.KindUser || h.lists == nil {
+		return []sharing.List{}, nil
+	}
+	return h.lists.Lists(ctx, principal.ID)
+}
+
+func listKey(listID *int64) int64 {
+	if listID == nil {
+		return 0
+	}
+	return *listID
+}
+
+func listKeyID(key int64) *int64 {
+	if key == 0 {
+		return nil
+	}
+	return &key
+}
+
+// listAccess is authorizeList for GraphQL: it returns an error for the
+// client instead of writing a response.
+func (h *Handler) listAccess(ctx context.Context, listID *int64, write bool, notFound string) error {
+	if listID == nil {
+		return nil
+	}
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	switch {
+	case !ok:
+		return graphql.Errorf(codeUnauthenticated, "authentication required")
+	case principal.Kind == auth.KindAdmin:
+		return nil
+	case principal.Kind != auth.KindUser:
+		return graphql.Errorf(codeNotFound, "%s", notFound)
+	}
+
+	list, found, err := h.loaders(ctx).lists.Load(ctx, *listID)()
+	if err != nil {
+		return fmt.Errorf("check list access: %w", err)
+	}
+	if !found {
+		return graphql.Errorf(codeNotFound, "%s", notFound)
+	}
+	if write && !list.Role.CanEdit() {
+		return graphql.Errorf(codeForbidden, "viewers cannot modify this list")
+	}
+	return nil
+}
+
+// canWrite maps auth.CheckWrite to GraphQL error codes for mutations.
+func (h *Handler) canWrite(ctx context.Context) error {
+	switch err := auth.CheckWrite(ctx, h.allowAnonymous); err {
+	case auth.ErrUnauthenticated:
+		return graphql.Errorf(codeUnauthenticated, "%s", err)
+	case auth.ErrInsufficientScope:
+		return graphql.Errorf(codeForbidden, "%s", err)
+	}
+	return nil
+}
+
+// loadTodo returns the todo with id if the caller may see it, or modify it
+// when write is set.
+func (h *Handler) loadTodo(ctx context.Context, id int64, write bool) (Item, error) {
+	item, found, err := h.loaders(ctx).todos.Load(ctx, id)()
+	if err != nil {
+		return Item{}, err
+	}
+	if !found {
+		return Item{}, graphql.Errorf(codeNotFound, "todo not found")
+	}
+	if err := h.listAccess(ctx, item.ListID, write, "todo not found"); err != nil {
+		return Item{}, err
+	}
+	return item, nil
+}
+
+func badInput(format string, args ...any) error {
+	return graphql.Errorf(graphql.CodeBadUserInput, format, args...)
+}
+
+func parseIDArg(value any, what string) (int64, error) {
+	id, err := strconv.ParseInt(fmt.Sprint(value), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, badInput("invalid %s id", what)
+	}
+	return id, nil
+}
+
+// listArg reads an optional list argument; null means the default list.
+func listArg(value any) (*int64, error) {
+	if value == nil {
+		return nil, nil
+	}
+	id, err := parseIDArg(value, "list")
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// todoFilter selects todos. Due bounds form a half-open range: dueFrom is
+// inclusive, dueBefore exclusive, and either leaves out todos with no due
+// date.
+type todoFilter struct {
+	completed *bool
+	search    string
+	tag       string
+	priority  *int
+	dueFrom   *time.Time
+	dueBefore *time.Time
+}
+
+func parseFilter(value any) todoFilter {
+	var f todoFilter
+	fields, _ := value.(map[string]any)
+	if v, ok := fields["completed"].(bool); ok {
+		f.completed = &v
+	}
+	if v, ok := fields["search"].(string); ok {
+		f.search = strings.ToLower(v)
+	}
+	if v, ok := fields["tag"].(string); ok {
+		f.tag = strings.ToLower(strings.TrimPrefix(v, "#"))
+	}
+	if v, ok := fields["priority"].(int); ok {
+		f.priority = &v
+	}
+	if v, ok := fields["dueFrom"].(time.Time); ok {
+		f.dueFrom = &v
+	}
+	if v, ok := fields["dueBefore"].(time.Time); ok {
+		f.dueBefore = &v
+	}
+	return f
+}
+
+func (f todoFilter) matches(item Item) bool {
+	switch {
+	case f.completed != nil && item.Completed != *f.completed:
+		return false
+	case f.priority != nil && item.Priority != *f.priority:
+		return false
+	case f.search != "" && !strings.Contains(strings.ToLower(item.Title), f.search) && !strings.Contains(strings.ToLower(item.Notes), f.search):
+		return false
+	case f.tag != "" && !slices.Contains(titleTags(item.Title), f.tag):
+		return false
+	case f.dueFrom != nil && (item.Due == nil || item.Due.Before(*f.dueFrom)):
+		return false
+	case f.dueBefore != nil && (item.Due == nil || !item.Due.Before(*f.dueBefore)):
+		return false
+	}
+	return true
+}
+
+// titleTags returns the #hashtags in a title, lowercased and without the
+// hash, in order of first appearance.
+func titleTags(title string) []string {
+	var tags []string
+	for _, word := range strings.Fields(title) {
+		tag, ok := strings.CutPrefix(word, "#")
+		tag = strings.ToLower(strings.TrimRightFunc(tag, unicode.IsPunct))
+		if ok && tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("todo:" + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if id, ok := strings.CutPrefix(string(raw), "todo:"); ok {
+			if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+				return n, nil
+			}
+		}
+	}
+	return 0, badInput("invalid cursor")
+}
+
+// resolveTodos pages through the todos on listID that match the filter.
+// Todos come in ID order and the cursor is the last ID seen, so pages stay
+// stable while todos are added or deleted.
+func (h *Handler) resolveTodos(ctx context.Context, listID *int64, args map[string]any) (any, error) {
+	if err := h.listAccess(ctx, listID, false, "todo list not found"); err != nil {
+		return nil, err
+	}
+	first := args["first"].(int)
+	if first < 0 || first > maxPageSize {
+		return nil, badInput("first must be between 0 and %d", maxPageSize)
+	}
+	var after int64
+	if cursor, ok := args["after"].(string); ok {
+		var err error
+		if after, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	filter := parseFilter(args["filter"])
+
+	load := h.loaders(ctx).items.Load(ctx, listKey(listID))
+	return graphql.Thunk(func() (any, error) {
+		items, _, err := load()
+		if err != nil {
+			return nil, err
+		}
+		var conn todoConnection
+		for _, item := range items {
+			if !filter.matches(item) {
+				continue
+			}
+			conn.total++
+			if item.ID <= after {
+				continue
+			}
+			if len(conn.nodes) == first {
+				conn.hasNext = true
+				continue
+			}
+			conn.nodes = append(conn.nodes, item)
+		}
+		return conn, nil
+	}), nil
+}
+
+// resolveStats counts the todos on listID. Priorities and tags are counted
+// over open todos only.
+func (h *Handler) resolveStats(ctx context.Context, listID *int64) (any, error) {
+	if err := h.listAccess(ctx, listID, false, "todo list not found"); err != nil {
+		return nil, err
+	}
+	load := h.loaders(ctx).items.Load(ctx, listKey(listID))
+	return graphql.Thunk(func() (any, error) {
+		items, _, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return countTodos(items, time.Now()), nil
+	}), nil
+}
+
+func countTodos(items []Item, now time.Time) todoStats {
+	stats := todoStats{total: len(items), byPriority: []countOf{}, tags: []countOf{}}
+	priorities := make(map[int]int)
+	tags := make(map[string]int)
+	for _, item := range items {
+		if item.Completed {
+			stats.completed++
+			continue
+		}
+		stats.open++
+		if item.Due != nil && item.Due.Before(now) {
+			stats.overdue++
+		}
+		priorities[item.Priority]++
+		for _, tag := range titleTags(item.Title) {
+			tags[tag]++
+		}
+	}
+	for priority, count := range priorities {
+		stats.byPriority = append(stats.byPriority, countOf{key: priority, count: count})
+	}
+	slices.SortFunc(stats.byPriority, func(a, b countOf) int { return a.key.(int) - b.key.(int) })
+	for tag, count := range tags {
+		stats.tags = append(stats.tags, countOf{key: tag, count: count})
+	}
+	slices.SortFunc(stats.tags, func(a, b countOf) int {
+		if a.count != b.count {
+			return b.count - a.count
+		}
+		return strings.Compare(a.key.(string), b.key.(string))
+	})
+	return stats
+}
+
+// todoInput validates the fields shared by CreateTodoInput and
+// UpdateTodoInput, trimming the title in place.
+func (h *Handler) todoInput(input map[string]any) error {
+	if title, ok := input["title"].(string); ok {
+		title = strings.TrimSpace(title)
+		if title == "" {
+			return badInput("title must not be empty")
+		}
+		if utf8.RuneCountInString(title) > h.maxTitleLength {
+			return badInput("title must be at most %d characters", h.maxTitleLength)
+		}
+		input["title"] = title
+	}
+	if notes, ok := input["notes"].(string); ok {
+		if err := CheckNotes(notes); err != nil {
+			return badInput("%s", err)
+		}
+	}
+	if priority, ok := input["priority"].(int); ok {
+		if err := CheckPriority(priority); err != nil {
+			return badInput("%s", err)
+		}
+	}
+	return nil
+}
+
+func (h *Handler) createTodo(ctx context.Context, p graphql.ResolveParams) (any, error) {
+	if err := h.canWrite(ctx); err != nil {
+		return nil, err
+	}
+	input := p.Args["input"].(map[string]any)
+	if err := h.todoInput(input); err != nil {
+		return nil, err
+	}
+	listID, err := listArg(input["list"])
+	if err != nil {
+		return nil, err
+	}
+	if err := h.listAccess(ctx, listID, true, "todo list not found"); err != nil {
+		return nil, err
+	}
+
+	item := Item{ListID: listID}
+	item.Title = input["title"].(string)
+	item.Notes, _ = input["notes"].(string)
+	item.Priority, _ = input["priority"].(int)
+	if due, ok := input["due"].(time.Time); ok {
+		item.Due = &due
+	}
+	return h.repo.Create(ctx, item)
+}
+
+func (h *Handler) updateTodo(ctx context.Context, p graphql.ResolveParams) (any, error) {
+	if err := h.canWrite(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseIDArg(p.Args["id"], "todo")
+	if err != nil {
+		return nil, err
+	}
+	input := p.Args["input"].(map[string]any)
+	if len(input) == 0 {
+		return nil, badInput("title, completed, notes, due or priority is required")
+	}
+	if err := h.todoInput(input); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"title", "completed", "notes", "priority"} {
+		if value, ok := input[name]; ok && value == nil {
+			return nil, badInput("%s must not be null", name)
+		}
+	}
+	if _, err := h.loadTodo(ctx, id, true); err != nil {
+		return nil, err
+	}
+
+	var changes Changes
+	if title, ok := input["title"].(string); ok {
+		changes.Title = &title
+	}
+	if notes, ok := input["notes"].(string); ok {
+		changes.Notes = &notes
+	}
+	if priority, ok := input["priority"].(int); ok {
+		changes.Priority = &priority
+	}
+	if due, ok := input["due"]; ok {
+		changes.SetDue = true
+		if t, ok := due.(time.Time); ok {
+			changes.Due = &t
+		}
+	}
+
+	if completed, ok := input["completed"].(bool); ok {
+		changes.Completed = &completed
+	}
+
+	item, err := h.repo.Update(ctx, id, changes, auth.UserID(ctx))
+	if errors.Is(err, ErrNotFound) {
+		return nil, graphql.Errorf(codeNotFound, "todo not found")
+	}
+	return item, err
+}
+
+func (h *Handler) deleteTodo(ctx context.Context, p graphql.ResolveParams) (any, error) {
+	if err := h.canWrite(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseIDArg(p.Args["id"], "todo")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.loadTodo(ctx, id, true); err != nil {
+		return nil, err
+	}
+	if err := h.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, graphql.Errorf(codeNotFound, "todo not found")
+		}
+		return nil, err
+	}
+	return id, nil
+}
+
+// subscribeTodoChanges polls the sync store for changes to a list, the way
+// webhook deliveries are polled for, and sends one event per changed or
+// deleted todo. The stream ends when the caller loses access to the list.
+func (h *Handler) subscribeTodoChanges(ctx context.Context, p graphql.ResolveParams) (<-chan any, error) {
+	if h.sync == nil {
+		return nil, badInput("subscriptions are not enabled")
+	}
+	listID, err := listArg(p.Args["list"])
+	if err != nil {
+		return nil, err
+	}
+	if err := h.listAccess(ctx, listID, false, "todo list not found"); err != nil {
+		return nil, err
+	}
+	watcher, err := NewWatcher(ctx, h.sync, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan any)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(h.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := h.listAccess(h.graphQLContext(ctx), listID, false, "todo list not found"); err != nil {
+				return
+			}
+			batch, err := watcher.Poll(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to poll todo changes", "error", err)
+				continue
+			}
+			for _, change := range batch {
+				select {
+				case events <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// resolve adapts a function of the source to a field resolver.
+func resolve[S any](fn func(source S) any) func(context.Context, graphql.ResolveParams) (any, error) {
+	return func(_ context.Context, p graphql.ResolveParams) (any, error) {
+		return fn(p.Source.(S)), nil
+	}
+}
+
+func nonNull(t graphql.Type) graphql.Type {
+	return graphql.NewNonNull(t)
+}
+
+func listOf(t graphql.Type) graphql.Type {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+var (
+	dateTimeScalar = &graphql.Scalar{
+		Name:        "DateTime",
+		Description: "An RFC 3339 timestamp in UTC.",
+		Serialize: func(value any) (any, error) {
+			switch t := value.(type) {
+			case time.Time:
+				return t.UTC().Format(time.RFC3339), nil
+			case *time.Time:
+				return t.UTC().Format(time.RFC3339), nil
+			}
+			return nil, fmt.Errorf("DateTime cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			if s, ok := value.(string); ok {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					return t.UTC(), nil
+				}
+			}
+			return nil, fmt.Errorf("DateTime must be an RFC 3339 timestamp")
+		},
+	}
+	dueScalar = &graphql.Scalar{
+		Name:        "Due",
+		Description: "A due date: a YYYY-MM-DD date, meaning midnight UTC, or an RFC 3339 timestamp.",
+		Serialize: func(value any) (any, error) {
+			switch t := value.(type) {
+			case time.Time:
+				return DueTime(t).String(), nil
+			case *time.Time:
+				return DueTime(*t).String(), nil
+			}
+			return nil, fmt.Errorf("Due cannot represent %v", value)
+		},
+		Parse: func(value any) (any, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("Due must be a string")
+			}
+			return api.ParseDue(s)
+		},
+	}
+	roleEnum = &graphql.Enum{
+		Name:        "Role",
+		Description: "What a member may do with a shared list.",
+		Values: []graphql.EnumValue{
+			{Name: "OWNER", Description: "Edits the list and manages its members.", Value: sharing.RoleOwner},
+			{Name: "EDITOR", Description: "Adds, edits and deletes todos.", Value: sharing.RoleEditor},
+			{Name: "VIEWER", Description: "Reads the list.", Value: sharing.RoleViewer},
+		},
+	}
+	userType = &graphql.Object{
+		Name:        "User",
+		Description: "A signed-in user.",
+		Fields: []*graphql.Field{
+			{Name: "id", Type: nonNull(graphql.ID), Resolve: resolve(func(u *UserRef) any { return u.ID })},
+			{Name: "name", Type: nonNull(graphql.String), Resolve: resolve(func(u *UserRef) any { return u.Name })},
+		},
+	}
+	pageInfoType = &graphql.Object{
+		Name:        "PageInfo",
+		Description: "Where a page ends. Pass endCursor as after to get the next page.",
+		Fields: []*graphql.Field{
+			{Name: "hasNextPage", Type: nonNull(graphql.Boolean), Resolve: resolve(func(c todoConnection) any { return c.hasNext })},
+			{Name: "endCursor", Type: graphql.String, Resolve: resolve(func(c todoConnection) any {
+				if len(c.nodes) == 0 {
+					return nil
+				}
+				return encodeCursor(c.nodes[len(c.nodes)-1].ID)
+			})},
+		},
+	}
+	priorityCountType = &graphql.Object{
+		Name: "PriorityCount",
+		Fields: []*graphql.Field{
+			{Name: "priority", Type: nonNull(graphql.Int), Resolve: resolve(func(c countOf) any { return c.key })},
+			{Name: "count", Type: nonNull(graphql.Int), Resolve: resolve(func(c countOf) any { return c.count })},
+		},
+	}
+	tagCountType = &graphql.Object{
+		Name: "TagCount",
+		Fields: []*graphql.Field{
+			{Name: "tag", Type: nonNull(graphql.String), Resolve: resolve(func(c countOf) any { return c.key })},
+			{Name: "count", Type: nonNull(graphql.Int), Resolve: resolve(func(c countOf) any { return c.count })},
+		},
+	}
+	statsType = &graphql.Object{
+		Name:        "Stats",
+		Description: "Counts of the todos on a list.",
+		Fields: []*graphql.Field{
+			{Name: "total", Type: nonNull(graphql.Int), Resolve: resolve(func(s todoStats) any { return s.total })},
+			{Name: "completed", Type: nonNull(graphql.Int), Resolve: resolve(func(s todoStats) any { return s.completed })},
+			{Name: "open", Type: nonNull(graphql.Int), Resolve: resolve(func(s todoStats) any { return s.open })},
+			{Name: "overdue", Type: nonNull(graphql.Int), Description: "Open todos whose due time has passed.",
+				Resolve: resolve(func(s todoStats) any { return s.overdue })},
+			{Name: "byPriority", Type: listOf(priorityCountType), Description: "Open todos per priority, 0 meaning none.",
+				Resolve: resolve(func(s todoStats) any { return s.byPriority })},
+			{Name: "tags", Type: listOf(tagCountType), Description: "Open todos per tag, most used first.",
+				Resolve: resolve(func(s todoStats) any { return s.tags })},
+		},
+	}
+	todoFilterType = &graphql.InputObject{
+		Name:        "TodoFilter",
+		Description: "Selects todos. Every field that is set must match.",
+		Fields: []*graphql.Argument{
+			{Name: "completed", Type: graphql.Boolean},
+			{Name: "search", Type: graphql.String, Description: "Text the title or notes contain, ignoring case."},
+			{Name: "tag", Type: graphql.String, Description: "A #hashtag in the title, with or without the hash."},
+			{Name: "priority", Type: graphql.Int},
+			{Name: "dueFrom", Type: dueScalar, Description: "Due at or after this time."},
+			{Name: "dueBefore", Type: dueScalar, Description: "Due before this time."},
+		},
+	}
+	createTodoInputType = &graphql.InputObject{
+		Name: "CreateTodoInput",
+		Fields: []*graphql.Argument{
+			{Name: "title", Type: nonNull(graphql.String)},
+			{Name: "notes", Type: graphql.String, Description: "Markdown."},
+			{Name: "list", Type: graphql.ID, Description: "The shared list to add to; the default list when left out."},
+			{Name: "due", Type: dueScalar},
+			{Name: "priority", Type: graphql.Int, Description: "1 is highest, 9 lowest, 0 none."},
+		},
+	}
+	updateTodoInputType = &graphql.InputObject{
+		Name:        "UpdateTodoInput",
+		Description: "Fields to change; those left out are kept. A null due clears the due date.",
+		Fields: []*graphql.Argument{
+			{Name: "title", Type: graphql.String},
+			{Name: "completed", Type: graphql.Boolean},
+			{Name: "notes", Type: graphql.String},
+			{Name: "due", Type: dueScalar},
+			{Name: "priority", Type: graphql.Int},
+		},
+	}
+	pageArgs = []*graphql.Argument{
+		{Name: "filter", Type: todoFilterType},
+		{Name: "first", Type: graphql.Int, Default: defaultPageSize, HasDefault: true,
+			Description: fmt.Sprintf("How many todos to return, at most %d.", maxPageSize)},
+		{Name: "after", Type: graphql.String, Description: "The endCursor of the previous page."},
+	}
+)
+
+// graphQLSchema builds the schema served at /graphql. The schema is fixed,
+// so an error building it is a programming mistake.
+func (h *Handler) graphQLSchema() *graphql.Schema {
+	listType := &graphql.Object{
+		Name:        "List",
+		Description: "A shared list the caller belongs to.",
+	}
+	todoType := &graphql.Object{
+		Name: "Todo",
+		Fields: []*graphql.Field{
+			{Name: "id", Type: nonNull(graphql.ID), Resolve: resolve(func(i Item) any { return i.ID })},
+			{Name: "uid", Type: nonNull(graphql.String), Description: "The iCalendar UID.",
+				Resolve: resolve(func(i Item) any { return i.UID })},
+			{Name: "title", Type: nonNull(graphql.String), Resolve: resolve(func(i Item) any { return i.Title })},
+			{Name: "notes", Type: nonNull(graphql.String), Description: "Markdown, empty when there are none.",
+				Resolve: resolve(func(i Item) any { return i.Notes })},
+			{Name: "notesHtml", Type: graphql.String, Description: "The notes rendered to sanitized HTML.",
+				Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+					item := p.Source.(Item)
+					if item.Notes == "" {
+						return nil, nil
+					}
+					return markdown.Render(item.Notes)
+				}},
+			{Name: "completed", Type: nonNull(graphql.Boolean), Resolve: resolve(func(i Item) any { return i.Completed })},
+			{Name: "completedAt", Type: dateTimeScalar, Resolve: resolve(func(i Item) any { return i.CompletedAt })},
+			{Name: "completedBy", Type: userType, Resolve: resolve(func(i Item) any { return i.CompletedBy })},
+			{Name: "due", Type: dueScalar, Resolve: resolve(func(i Item) any { return i.Due })},
+			{Name: "priority", Type: nonNull(graphql.Int), Description: "1 is highest, 9 lowest, 0 none.",
+				Resolve: resolve(func(i Item) any { return i.Priority })},
+			{Name: "tags", Type: listOf(graphql.String), Description: "The #hashtags in the title, lowercased and without the hash.",
+				Resolve: resolve(func(i Item) any { return nonNilTags(titleTags(i.Title)) })},
+			{Name: "list", Type: listType, Description: "The shared list, or null on the default list.",
+				Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+					item := p.Source.(Item)
+					if item.ListID == nil {
+						return nil, nil
+					}
+					load := h.loaders(ctx).lists.Load(ctx, *item.ListID)
+					return graphql.Thunk(func() (any, error) {
+						list, found, err := load()
+						if err != nil || !found {
+							return nil, err
+						}
+						return list, nil
+					}), nil
+				}},
+		},
+	}
+	connectionType := &graphql.Object{
+		Name: "TodoConnection",
+		Fields: []*graphql.Field{
+			{Name: "nodes", Type: listOf(todoType), Resolve: resolve(func(c todoConnection) any { return c.nodes })},
+			{Name: "totalCount", Type: nonNull(graphql.Int), Description: "How many todos match, on every page.",
+				Resolve: resolve(func(c todoConnection) any { return c.total })},
+			{Name: "pageInfo", Type: nonNull(pageInfoType), Resolve: resolve(func(c todoConnection) any { return c })},
+		},
+	}
+	listType.Fields = []*graphql.Field{
+		{Name: "id", Type: nonNull(graphql.ID), Resolve: resolve(func(l sharing.List) any { return l.ID })},
+		{Name: "name", Type: nonNull(graphql.String), Resolve: resolve(func(l sharing.List) any { return l.Name })},
+		{Name: "role", Type: nonNull(roleEnum), Description: "The caller's role.",
+			Resolve: resolve(func(l sharing.List) any { return l.Role })},
+		{Name: "todos", Type: nonNull(connectionType), Args: pageArgs,
+			Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+				list := p.Source.(sharing.List)
+				return h.resolveTodos(ctx, &list.ID, p.Args)
+			}},
+		{Name: "stats", Type: nonNull(statsType),
+			Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+				list := p.Source.(sharing.List)
+				return h.resolveStats(ctx, &list.ID)
+			}},
+	}
+	changeType := &graphql.Object{
+		Name:        "TodoChange",
+		Description: "A todo that was created, changed or deleted.",
+		Fields: []*graphql.Field{
+			{Name: "id", Type: nonNull(graphql.ID), Resolve: resolve(func(c Change) any { return c.ID })},
+			{Name: "uid", Type: nonNull(graphql.String), Resolve: resolve(func(c Change) any { return c.UID })},
+			{Name: "deleted", Type: nonNull(graphql.Boolean), Resolve: resolve(func(c Change) any { return c.Item == nil })},
+			{Name: "todo", Type: todoType, Description: "The todo as it is now, or null when it was deleted.",
+				Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+					if change := p.Source.(Change); change.Item != nil {
+						return *change.Item, nil
+					}
+					return nil, nil
+				}},
+		},
+	}
+	listArgs := []*graphql.Argument{{Name: "list", Type: graphql.ID, Description: "A shared list; the default list when left out."}}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: &graphql.Object{
+			Name: "Query",
+			Fields: []*graphql.Field{
+				{Name: "todos", Type: nonNull(connectionType), Args: append(slices.Clone(listArgs), pageArgs...),
+					Description: "A page of the todos on a list, in the order they were created.",
+					Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+						listID, err := listArg(p.Args["list"])
+						if err != nil {
+							return nil, err
+						}
+						return h.resolveTodos(ctx, listID, p.Args)
+					}},
+				{Name: "todo", Type: todoType, Args: []*graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}},
+					Description: "A todo, or null when there is none the caller may see.",
+					Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+						id, err := parseIDArg(p.Args["id"], "todo")
+						if err != nil {
+							return nil, err
+						}
+						h.loaders(ctx).todos.Load(ctx, id)
+						return graphql.Thunk(func() (any, error) {
+							item, err := h.loadTodo(ctx, id, false)
+							if graphql.ErrorCode(err) == codeNotFound {
+								return nil, nil
+							}
+							return item, err
+						}), nil
+					}},
+				{Name: "stats", Type: nonNull(statsType), Args: listArgs,
+					Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+						listID, err := listArg(p.Args["list"])
+						if err != nil {
+							return nil, err
+						}
+						return h.resolveStats(ctx, listID)
+					}},
+				{Name: "lists", Type: listOf(listType), Description: "The shared lists the signed-in user belongs to.",
+					Resolve: func(ctx context.Context, _ graphql.ResolveParams) (any, error) {
+						lists, err := h.memberLists(ctx)
+						if err != nil {
+							return nil, err
+						}
+						loader := h.loaders(ctx).lists
+						for _, list := range lists {
+							loader.Prime(list.ID, list)
+						}
+						return lists, nil
+					}},
+			},
+		},
+		Mutation: &graphql.Object{
+			Name: "Mutation",
+			Fields: []*graphql.Field{
+				{Name: "createTodo", Type: nonNull(todoType), Resolve: h.createTodo,
+					Args: []*graphql.Argument{{Name: "input", Type: nonNull(createTodoInputType)}}},
+				{Name: "updateTodo", Type: nonNull(todoType), Resolve: h.updateTodo,
+					Args: []*graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}, {Name: "input", Type: nonNull(updateTodoInputType)}}},
+				{Name: "deleteTodo", Type: nonNull(graphql.ID), Description: "Deletes a todo and returns its ID.", Resolve: h.deleteTodo,
+					Args: []*graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}}},
+			},
+		},
+		Subscription: &graphql.Object{
+			Name: "Subscription",
+			Fields: []*graphql.Field{
+				{Name: "todoChanged", Type: nonNull(changeType), Args: listArgs, Subscribe: h.subscribeTodoChanges,
+					Description: "Sends an event each time a todo on the list is created, changed or deleted."},
+			},
+		},
+		RequestContext: h.graphQLContext,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
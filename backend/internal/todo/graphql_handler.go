@@ -0,0 +1,248 @@
+package todo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"todoapp/backend/internal/graphql"
+	"todoapp/backend/internal/respond"
+	"todoapp/backend/internal/websocket"
+)
+
+// graphQLWSProtocol is the WebSocket subprotocol for subscriptions, see
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md.
+const graphQLWSProtocol = "graphql-transport-ws"
+
+// connectionInitTimeout is how long a WebSocket client has to send
+// connection_init.
+const connectionInitTimeout = 10 * time.Second
+
+// Close codes of graphql-transport-ws.
+const (
+	closeBadMessage         = 4400
+	closeUnauthorized       = 4401
+	closeInitTimeout        = 4408
+	closeSubscriberExists   = 4409
+	closeTooManyInitRequest = 4429
+)
+
+// GraphQL runs a query or mutation posted as JSON.
+func (h *Handler) GraphQL(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	var req graphql.Request
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	h.writeGraphQL(w, r, h.graphql.Execute(r.Context(), req))
+}
+
+// GraphQLQuery runs a query given by the query, operationName and variables
+// parameters, or upgrades to WebSocket for subscriptions. Mutations must be
+// posted so that links and prefetches cannot change anything.
+func (h *Handler) GraphQLQuery(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsUpgrade(r) {
+		h.serveGraphQLWS(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	req := graphql.Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+	if raw := query.Get("variables"); raw != "" {
+		decoder := json.NewDecoder(strings.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&req.Variables); err != nil {
+			http.Error(w, "variables must be a JSON object", http.StatusBadRequest)
+			return
+		}
+	}
+	if graphql.OperationKind(req) == "mutation" {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "mutations must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeGraphQL(w, r, h.graphql.Execute(r.Context(), req))
+}
+
+// GraphQLSchema serves the schema in the GraphQL schema language.
+func (h *Handler) GraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(h.graphql.SDL())); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write graphql schema", "error", err)
+	}
+}
+
+func (h *Handler) writeGraphQL(w http.ResponseWriter, r *http.Request, resp *graphql.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		respond.ServerError(w, r, "failed to encode response", err)
+	}
+}
+
+// wsMessage is a graphql-transport-ws message.
+type wsMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// serveGraphQLWS speaks graphql-transport-ws. Subscriptions run in their own
+// goroutines and end when the client completes them, the stream ends or
+// the connection closes. Queries and mutations are answered once.
+func (h *Handler) serveGraphQLWS(w http.ResponseWriter, r *http.Request) {
+	// Browsers send cookies with cross-site WebSocket handshakes and CORS
+	// does not apply, so only same-origin pages may connect.
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			http.Error(w, "cross-origin WebSocket connections are not allowed", http.StatusForbidden)
+			return
+		}
+	}
+	conn, err := websocket.Upgrade(w, r, []string{graphQLWSProtocol})
+	if err != nil {
+		return
+	}
+	conn.SetMaxMessageLen(h.maxBodyBytes)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	var (
+		mu            sync.Mutex
+		subscriptions = make(map[string]context.CancelFunc)
+		wg            sync.WaitGroup
+	)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	send := func(message wsMessage) {
+		data, err := json.Marshal(message)
+		if err == nil {
+			err = conn.WriteMessage(data)
+		}
+		if err != nil {
+			cancel()
+		}
+	}
+	payload := func(v any) json.RawMessage {
+		data, _ := json.Marshal(v)
+		return data
+	}
+	// finish sends complete unless the client already completed id.
+	finish := func(id string) {
+		mu.Lock()
+		_, running := subscriptions[id]
+		delete(subscriptions, id)
+		mu.Unlock()
+		if running {
+			send(wsMessage{Type: "complete", ID: id})
+		}
+	}
+
+	initTimer := time.AfterFunc(connectionInitTimeout, func() {
+		conn.Close(closeInitTimeout, "Connection initialisation timeout")
+	})
+	defer initTimer.Stop()
+	initialised := false
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close(websocket.CloseNormal, "")
+			return
+		}
+		var message wsMessage
+		if err := json.Unmarshal(data, &message); err != nil || message.Type == "" {
+			conn.Close(closeBadMessage, "Invalid message received")
+			return
+		}
+
+		switch message.Type {
+		case "connection_init":
+			if initialised {
+				conn.Close(closeTooManyInitRequest, "Too many initialisation requests")
+				return
+			}
+			initialised = true
+			initTimer.Stop()
+			send(wsMessage{Type: "connection_ack"})
+		case "ping":
+			send(wsMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !initialised {
+				conn.Close(closeUnauthorized, "Unauthorized")
+				return
+			}
+			var req graphql.Request
+			decoder := json.NewDecoder(bytes.NewReader(message.Payload))
+			decoder.UseNumber()
+			if message.ID == "" || decoder.Decode(&req) != nil {
+				conn.Close(closeBadMessage, "Invalid message received")
+				return
+			}
+			mu.Lock()
+			_, exists := subscriptions[message.ID]
+			subCtx, subCancel := context.WithCancel(ctx)
+			if !exists {
+				subscriptions[message.ID] = subCancel
+			}
+			mu.Unlock()
+			if exists {
+				subCancel()
+				conn.Close(closeSubscriberExists, "Subscriber for "+message.ID+" already exists")
+				return
+			}
+
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				defer subCancel()
+				if graphql.OperationKind(req) != "subscription" {
+					send(wsMessage{Type: "next", ID: id, Payload: payload(h.graphql.Execute(subCtx, req))})
+					finish(id)
+					return
+				}
+				responses, errResp := h.graphql.Subscribe(subCtx, req)
+				if errResp != nil {
+					mu.Lock()
+					delete(subscriptions, id)
+					mu.Unlock()
+					send(wsMessage{Type: "error", ID: id, Payload: payload(errResp.Errors)})
+					return
+				}
+				for resp := range responses {
+					send(wsMessage{Type: "next", ID: id, Payload: payload(resp)})
+				}
+				if ctx.Err() == nil {
+					finish(id)
+				}
+			}(message.ID)
+		case "complete":
+			mu.Lock()
+			if stop, ok := subscriptions[message.ID]; ok {
+				stop()
+				delete(subscriptions, message.ID)
+			}
+			mu.Unlock()
+		default:
+			conn.Close(closeBadMessage, "Invalid message received")
+			return
+		}
+	}
+}
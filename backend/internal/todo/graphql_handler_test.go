@@ -0,0 +1,400 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/internal/websocket"
+)
+
+type fakeDirectory struct {
+	lists map[int64][]sharing.List
+	calls int
+}
+
+func (f *fakeDirectory) Lists(_ context.Context, userID int64) ([]sharing.List, error) {
+	f.calls++
+	return f.lists[userID], nil
+}
+
+// countingRepo counts List calls to show that resolvers share them.
+type countingRepo struct {
+	*Repository
+	lists int
+}
+
+func (c *countingRepo) List(ctx context.Context, listID *int64) ([]Item, error) {
+	c.lists++
+	return c.Repository.List(ctx, listID)
+}
+
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func (r graphQLResult) code() string {
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	code, _ := r.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+func postGraphQL(t *testing.T, h *Handler, principal *auth.Principal, query string, variables map[string]any) graphQLResult {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": variables})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	rr := httptest.NewRecorder()
+	h.GraphQL(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result graphQLResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return result
+}
+
+func newGraphQLHandler(t *testing.T) (*Handler, *countingRepo, *fakeDirectory) {
+	t.Helper()
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`
+		INSERT INTO users (id, issuer, subject, email, name, created_at, updated_at)
+		VALUES (1, 'https://idp.example.com', 'u1', 'owner@example.com', 'Owner', ?, ?)`, time.Now(), time.Now()); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO lists (id, name, created_at) VALUES (5, 'Groceries', ?)`, time.Now()); err != nil {
+		t.Fatalf("insert list: %v", err)
+	}
+	repo := &countingRepo{Repository: NewRepository(db)}
+	directory := &fakeDirectory{lists: map[int64][]sharing.List{
+		1: {{ID: 5, Name: "Groceries", Role: sharing.RoleOwner}},
+		3: {{ID: 5, Name: "Groceries", Role: sharing.RoleViewer}},
+	}}
+	h := NewHandler(repo, WithSync(repo.Repository), WithGraphQL(directory, false))
+	return h, repo, directory
+}
+
+var owner = &auth.Principal{Kind: auth.KindUser, ID: 1, Name: "Owner", Scope: auth.ScopeReadWrite}
+
+func TestGraphQL_QueriesShareLoads(t *testing.T) {
+	h, repo, directory := newGraphQLHandler(t)
+	ctx := context.Background()
+	listID := int64(5)
+	due := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	for _, item := range []Item{
+		{Title: "Buy milk #errand", ListID: &listID, Priority: 1, Due: &due},
+		{Title: "Buy eggs #Errand #food", ListID: &listID, Priority: 1},
+		{Title: "Bake bread #food", ListID: &listID},
+	} {
+		if _, err := repo.Create(ctx, item); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	repo.lists = 0
+
+	result := postGraphQL(t, h, owner, `query($list: ID) {
+		page: todos(list: $list, first: 2, filter: {completed: false}) {
+			totalCount
+			nodes { title tags due list { name role } }
+			pageInfo { hasNextPage endCursor }
+		}
+		stats(list: $list) { total open overdue byPriority { priority count } tags { tag count } }
+		lists { id stats { open } }
+	}`, map[string]any{"list": 5})
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", result.Errors)
+	}
+	var data struct {
+		Page struct {
+			TotalCount int
+			Nodes      []struct {
+				Title string
+				Tags  []string
+				Due   *string
+				List  struct{ Name, Role string }
+			}
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   string
+			}
+		}
+		Stats struct {
+			Total, Open, Overdue int
+			ByPriority           []struct{ Priority, Count int }
+			Tags                 []struct {
+				Tag   string
+				Count int
+			}
+		}
+		Lists []struct {
+			ID    string
+			Stats struct{ Open int }
+		}
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		t.Fatalf("decode data: %v", err)
+	}
+
+	if data.Page.TotalCount != 3 || len(data.Page.Nodes) != 2 || !data.Page.PageInfo.HasNextPage {
+		t.Fatalf("expected the first 2 of 3 todos, got %+v", data.Page)
+	}
+	first := data.Page.Nodes[0]
+	if first.Due == nil || *first.Due != "2000-01-02" || first.List.Name != "Groceries" || first.List.Role != "OWNER" {
+		t.Fatalf("unexpected first todo: %+v", first)
+	}
+	if tags := data.Page.Nodes[1].Tags; len(tags) != 2 || tags[0] != "errand" || tags[1] != "food" {
+		t.Fatalf("expected lowercased tags, got %v", tags)
+	}
+	if data.Stats.Total != 3 || data.Stats.Open != 3 || data.Stats.Overdue != 1 {
+		t.Fatalf("unexpected stats: %+v", data.Stats)
+	}
+	if len(data.Stats.ByPriority) != 2 || data.Stats.ByPriority[0].Priority != 0 || data.Stats.ByPriority[1].Count != 2 {
+		t.Fatalf("unexpected priority counts: %+v", data.Stats.ByPriority)
+	}
+	if len(data.Stats.Tags) != 2 || data.Stats.Tags[0].Tag != "errand" || data.Stats.Tags[0].Count != 2 {
+		t.Fatalf("unexpected tag counts: %+v", data.Stats.Tags)
+	}
+	if len(data.Lists) != 1 || data.Lists[0].ID != "5" || data.Lists[0].Stats.Open != 3 {
+		t.Fatalf("unexpected lists: %+v", data.Lists)
+	}
+	if repo.lists != 1 || directory.calls != 2 {
+		t.Fatalf("expected one List call and two directory calls, got %d and %d", repo.lists, directory.calls)
+	}
+
+	next := postGraphQL(t, h, owner, `query($after: String) {
+		todos(list: 5, after: $after) { totalCount nodes { title } pageInfo { hasNextPage } }
+	}`, map[string]any{"after": data.Page.PageInfo.EndCursor})
+	if !strings.Contains(string(next.Data), `"nodes":[{"title":"Bake bread #food"}],"pageInfo":{"hasNextPage":false}`) {
+		t.Fatalf("expected the last todo on the second page, got %s", next.Data)
+	}
+
+	filtered := postGraphQL(t, h, owner, `{
+		byTag: todos(list: 5, filter: {tag: "#food"}) { totalCount }
+		bySearch: todos(list: 5, filter: {search: "MILK"}) { totalCount }
+		byDue: todos(list: 5, filter: {dueFrom: "2000-01-02", dueBefore: "2000-01-03"}) { totalCount }
+		defaultList: todos { totalCount }
+	}`, nil)
+	if string(filtered.Data) != `{"byTag":{"totalCount":2},"bySearch":{"totalCount":1},"byDue":{"totalCount":1},"defaultList":{"totalCount":2}}` {
+		t.Fatalf("unexpected filtered counts: %s", filtered.Data)
+	}
+}
+
+func TestGraphQL_ListAccess(t *testing.T) {
+	h, repo, _ := newGraphQLHandler(t)
+	listID := int64(5)
+	shared, err := repo.Create(context.Background(), Item{Title: "Shared", ListID: &listID})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stranger := &auth.Principal{Kind: auth.KindUser, ID: 4, Scope: auth.ScopeReadWrite}
+
+	if result := postGraphQL(t, h, nil, `{ todos(list: 5) { totalCount } }`, nil); result.code() != codeUnauthenticated {
+		t.Fatalf("expected anonymous callers to be unauthenticated, got %+v", result)
+	}
+	if result := postGraphQL(t, h, stranger, `{ todos(list: 5) { totalCount } }`, nil); result.code() != codeNotFound {
+		t.Fatalf("expected non-members to get not found, got %+v", result)
+	}
+	result := postGraphQL(t, h, stranger, `query($id: ID!) { todo(id: $id) { title } }`, map[string]any{"id": shared.ID})
+	if len(result.Errors) > 0 || string(result.Data) != `{"todo":null}` {
+		t.Fatalf("expected a hidden todo to be null, got %s %+v", result.Data, result.Errors)
+	}
+	result = postGraphQL(t, h, owner, `query($id: ID!) { todo(id: $id) { title } }`, map[string]any{"id": shared.ID})
+	if string(result.Data) != `{"todo":{"title":"Shared"}}` {
+		t.Fatalf("expected the owner to see the todo, got %s", result.Data)
+	}
+}
+
+func TestGraphQL_Mutations(t *testing.T) {
+	h, repo, _ := newGraphQLHandler(t)
+
+	created := postGraphQL(t, h, owner, `mutation {
+		createTodo(input: {title: "  Plan trip  ", notes: "**soon**", list: 5, due: "2030-05-01", priority: 2}) {
+			id title notesHtml due priority completed list { id }
+		}
+	}`, nil)
+	var data struct {
+		CreateTodo struct {
+			ID, Title, NotesHTML, Due string
+			Priority                  int
+		}
+	}
+	if err := json.Unmarshal(created.Data, &data); err != nil || len(created.Errors) > 0 {
+		t.Fatalf("create: %v %+v", err, created.Errors)
+	}
+	if data.CreateTodo.Title != "Plan trip" || data.CreateTodo.Due != "2030-05-01" || !strings.Contains(data.CreateTodo.NotesHTML, "<strong>soon</strong>") {
+		t.Fatalf("unexpected created todo: %+v", data.CreateTodo)
+	}
+	id := data.CreateTodo.ID
+
+	updated := postGraphQL(t, h, owner, `mutation($id: ID!) {
+		updateTodo(id: $id, input: {completed: true, due: null, priority: 0}) { completed due priority completedBy { name } }
+	}`, map[string]any{"id": id})
+	if string(updated.Data) != `{"updateTodo":{"completed":true,"due":null,"priority":0,"completedBy":{"name":"Owner"}}}` {
+		t.Fatalf("unexpected update: %s %+v", updated.Data, updated.Errors)
+	}
+
+	viewer := &auth.Principal{Kind: auth.KindUser, ID: 3, Scope: auth.ScopeReadWrite}
+	readOnly := &auth.Principal{Kind: auth.KindAPIKey, ID: 8, Scope: auth.ScopeRead}
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		query     string
+		code      string
+		message   string
+	}{
+		{"anonymous", nil, `mutation { createTodo(input: {title: "x"}) { id } }`, codeUnauthenticated, "authentication required"},
+		{"read-only key", readOnly, `mutation { createTodo(input: {title: "x"}) { id } }`, codeForbidden, "insufficient scope"},
+		{"viewer", viewer, `mutation { deleteTodo(id: ` + id + `) }`, codeForbidden, "viewers cannot modify this list"},
+		{"empty title", owner, `mutation { createTodo(input: {title: " "}) { id } }`, "BAD_USER_INPUT", "title must not be empty"},
+		{"priority", owner, `mutation { updateTodo(id: ` + id + `, input: {priority: 10}) { id } }`, "BAD_USER_INPUT", "priority must be between 0 and 9"},
+		{"no fields", owner, `mutation { updateTodo(id: ` + id + `, input: {}) { id } }`, "BAD_USER_INPUT", "title, completed, notes, due or priority is required"},
+		{"null title", owner, `mutation { updateTodo(id: ` + id + `, input: {title: null}) { id } }`, "BAD_USER_INPUT", "title must not be null"},
+		{"missing", owner, `mutation { deleteTodo(id: 999) }`, codeNotFound, "todo not found"},
+	}
+	for _, tc := range cases {
+		result := postGraphQL(t, h, tc.principal, tc.query, nil)
+		if result.code() != tc.code || result.Errors[0].Message != tc.message {
+			t.Fatalf("%s: expected %s %q, got %+v", tc.name, tc.code, tc.message, result.Errors)
+		}
+	}
+
+	deleted := postGraphQL(t, h, owner, `mutation($id: ID!) { deleteTodo(id: $id) }`, map[string]any{"id": id})
+	if string(deleted.Data) != `{"deleteTodo":"`+id+`"}` {
+		t.Fatalf("unexpected delete: %s %+v", deleted.Data, deleted.Errors)
+	}
+	if _, err := repo.Get(context.Background(), mustParseID(t, id)); err == nil {
+		t.Fatal("expected the todo to be gone")
+	}
+
+	anonymous := NewHandler(repo, WithGraphQL(&fakeDirectory{}, true))
+	if result := postGraphQL(t, anonymous, nil, `mutation { createTodo(input: {title: "x"}) { title } }`, nil); len(result.Errors) > 0 {
+		t.Fatalf("expected anonymous writes to be allowed, got %+v", result.Errors)
+	}
+}
+
+func mustParseID(t *testing.T, raw string) int64 {
+	t.Helper()
+	id, err := parseID(raw)
+	if err != nil {
+		t.Fatalf("parse id %q: %v", raw, err)
+	}
+	return id
+}
+
+func TestGraphQLQuery_GET(t *testing.T) {
+	h, _, _ := newGraphQLHandler(t)
+
+	query := url.Values{"query": {`query($done: Boolean) { todos(filter: {completed: $done}) { totalCount } }`}, "variables": {`{"done":true}`}}
+	rr := httptest.NewRecorder()
+	h.GraphQLQuery(rr, httptest.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"data":{"todos":{"totalCount":1}}}` {
+		t.Fatalf("unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	mutation := url.Values{"query": {`mutation { deleteTodo(id: 1) }`}}
+	rr = httptest.NewRecorder()
+	h.GraphQLQuery(rr, httptest.NewRequest(http.MethodGet, "/graphql?"+mutation.Encode(), nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected mutations over GET to be refused, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GraphQLSchema(rr, httptest.NewRequest(http.MethodGet, "/graphql/schema", nil))
+	if !strings.Contains(rr.Body.String(), "todoChanged(list: ID): TodoChange!") {
+		t.Fatalf("expected the schema to list the subscription, got:\n%s", rr.Body.String())
+	}
+}
+
+func TestGraphQL_Subscription(t *testing.T) {
+	h, repo, _ := newGraphQLHandler(t)
+	h.pollInterval = 10 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.GraphQLQuery(w, r.WithContext(auth.WithPrincipal(r.Context(), *owner)))
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := websocket.Dial(ctx, wsURL, graphQLWSProtocol, http.Header{"Origin": {"https://evil.example"}}); err == nil {
+		t.Fatal("expected a cross-origin handshake to be refused")
+	}
+
+	conn, err := websocket.Dial(ctx, wsURL, graphQLWSProtocol, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+	send := func(message string) {
+		if err := conn.WriteMessage([]byte(message)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	receive := func() wsMessage {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var message wsMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("decode %s: %v", data, err)
+		}
+		return message
+	}
+
+	send(`{"type":"connection_init"}`)
+	if message := receive(); message.Type != "connection_ack" {
+		t.Fatalf("expected connection_ack, got %+v", message)
+	}
+	send(`{"type":"subscribe","id":"1","payload":{"query":"subscription { todoChanged(list: 5) { deleted todo { title } } }"}}`)
+	send(`{"type":"ping"}`)
+	if message := receive(); message.Type != "pong" {
+		t.Fatalf("expected pong, got %+v", message)
+	}
+
+	listID := int64(5)
+	item, err := repo.Create(context.Background(), Item{Title: "Fresh", ListID: &listID})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if message := receive(); message.Type != "next" || message.ID != "1" || string(message.Payload) != `{"data":{"todoChanged":{"deleted":false,"todo":{"title":"Fresh"}}}}` {
+		t.Fatalf("expected the created todo, got %s %s", message.Type, message.Payload)
+	}
+	if err := repo.Delete(context.Background(), item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if message := receive(); string(message.Payload) != `{"data":{"todoChanged":{"deleted":true,"todo":null}}}` {
+		t.Fatalf("expected the deletion, got %s", message.Payload)
+	}
+
+	send(`{"type":"subscribe","id":"2","payload":{"query":"{ stats(list: 5) { total } }"}}`)
+	if message := receive(); message.Type != "next" || message.ID != "2" || string(message.Payload) != `{"data":{"stats":{"total":0}}}` {
+		t.Fatalf("expected a query result, got %s %s", message.Type, message.Payload)
+	}
+	if message := receive(); message.Type != "complete" || message.ID != "2" {
+		t.Fatalf("expected the query to complete, got %+v", message)
+	}
+
+	send(`{"type":"subscribe","id":"1","payload":{"query":"{ stats { total } }"}}`)
+	if _, err := conn.ReadMessage(); err == nil || !strings.Contains(err.Error(), "4409") {
+		t.Fatalf("expected a duplicate id to close the connection with 4409, got %v", err)
+	}
+}
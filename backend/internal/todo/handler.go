@@ -1,51 +1,169 @@
 package todo
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/graphql"
+	"todoapp/backend/internal/markdown"
+	"todoapp/backend/internal/respond"
+	"todoapp/backend/internal/sharing"
 )
 
 type ReaderWriter interface {
-	List() ([]Item, error)
-	Create(title string) (Item, error)
-	UpdateCompleted(id int64, completed bool) (Item, error)
-	Delete(id int64) error
+	List(ctx context.Context, listID *int64) ([]Item, error)
+	Get(ctx context.Context, id int64) (Item, error)
+	Create(ctx context.Context, item Item) (Item, error)
+	UpdateCompleted(ctx context.Context, id int64, completed bool, completedBy *int64) (Item, error)
+	Update(ctx context.Context, id int64, changes Changes, completedBy *int64) (Item, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+// Permissions reports a user's role on a shared list, returning
+// sharing.ErrNotFound when they are not a member.
+type Permissions interface {
+	Role(ctx context.Context, listID int64, userID int64) (sharing.Role, error)
 }
 
+const (
+	DefaultMaxBodyBytes   = 1 << 20
+	DefaultMaxTitleLength = 200
+
+	maxNotesLength = 10000
+	maxPriority    = 9
+)
+
 type Handler struct {
-	repo ReaderWriter
+	repo           ReaderWriter
+	comments       CommentStore
+	attachments    AttachmentStore
+	reminders      ReminderStore
+	transfers      ImportExporter
+	calendar       CalendarStore
+	tasks          TaskStore
+	feedTokens     FeedTokens
+	sync           SyncStore
+	permissions    Permissions
+	lists          ListDirectory
+	graphql        *graphql.Schema
+	allowAnonymous bool
+	pollInterval   time.Duration
+	maxBodyBytes   int64
+	maxTitleLength int
+
+	maxAttachmentBytes int64
+	attachmentQuota    int64
+}
+
+type Option func(*Handler)
+
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *Handler) { h.maxBodyBytes = n }
+}
+
+func WithMaxTitleLength(n int) Option {
+	return func(h *Handler) { h.maxTitleLength = n }
 }
 
-func NewHandler(repo ReaderWriter) *Handler {
-	return &Handler{repo: repo}
+// WithComments enables the comments endpoints.
+func WithComments(c CommentStore) Option {
+	return func(h *Handler) { h.comments = c }
 }
 
-func (h *Handler) ListTodos(w http.ResponseWriter, _ *http.Request) {
-	items, err := h.repo.List()
+// WithAttachments enables the attachment endpoints. Each upload may be at
+// most maxFileBytes and each uploader may store at most quotaBytes in total.
+func WithAttachments(a AttachmentStore, maxFileBytes int64, quotaBytes int64) Option {
+	return func(h *Handler) {
+		h.attachments = a
+		h.maxAttachmentBytes = maxFileBytes
+		h.attachmentQuota = quotaBytes
+	}
+}
+
+// WithImportExport enables the import and export endpoints.
+func WithImportExport(t ImportExporter) Option {
+	return func(h *Handler) { h.transfers = t }
+}
+
+// WithPermissions enables shared lists. Without it only the default list
+// is reachable.
+func WithPermissions(p Permissions) Option {
+	return func(h *Handler) { h.permissions = p }
+}
+
+func NewHandler(repo ReaderWriter, opts ...Option) *Handler {
+	h := &Handler{
+		repo:           repo,
+		maxBodyBytes:   DefaultMaxBodyBytes,
+		maxTitleLength: DefaultMaxTitleLength,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ListTodos lists the default list, or the shared list given by ?list=.
+func (h *Handler) ListTodos(w http.ResponseWriter, r *http.Request) {
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, false, "todo list not found") {
+		return
+	}
+
+	items, err := h.repo.List(r.Context(), listID)
 	if err != nil {
-		http.Error(w, "failed to fetch todos", http.StatusInternalServerError)
+		respond.ServerError(w, r, "failed to fetch todos", err)
 		return
 	}
+	for i := range items {
+		if err := renderNotes(r, &items[i]); err != nil {
+			respond.ServerError(w, r, "failed to render notes", err)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(items); err != nil {
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		respond.ServerError(w, r, "failed to encode response", err)
 		return
 	}
 }
 
-type createTodoRequest struct {
-	Title string `json:"title"`
+func (h *Handler) GetTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid todo id", http.StatusBadRequest)
+		return
+	}
+
+	item, ok := h.authorizeItem(w, r, id, false)
+	if !ok {
+		return
+	}
+	if err := renderNotes(r, &item); err != nil {
+		respond.ServerError(w, r, "failed to render notes", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		respond.ServerError(w, r, "failed to encode response", err)
+		return
+	}
 }
 
 func (h *Handler) CreateTodo(w http.ResponseWriter, r *http.Request) {
-	var req createTodoRequest
-	if err := decodeJSON(r, &req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	var req CreateTodoRequest
+	if !h.decodeRequest(w, r, &req) {
 		return
 	}
 
@@ -54,25 +172,45 @@ func (h *Handler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "title is required", http.StatusBadRequest)
 		return
 	}
+	if utf8.RuneCountInString(title) > h.maxTitleLength {
+		http.Error(w, fmt.Sprintf("title must be at most %d characters", h.maxTitleLength), http.StatusBadRequest)
+		return
+	}
+	if !validNotes(w, req.Notes) {
+		return
+	}
+	if !validPriority(w, req.Priority) {
+		return
+	}
 
-	item, err := h.repo.Create(title)
+	if !h.authorizeList(w, r, req.ListID, true, "todo list not found") {
+		return
+	}
+
+	item, err := h.repo.Create(r.Context(), Item{
+		Title:    title,
+		Notes:    req.Notes,
+		ListID:   req.ListID,
+		Due:      req.Due.Time(),
+		Priority: req.Priority,
+	})
 	if err != nil {
-		http.Error(w, "failed to create todo", http.StatusInternalServerError)
+		respond.ServerError(w, r, "failed to create todo", err)
+		return
+	}
+	if err := renderNotes(r, &item); err != nil {
+		respond.ServerError(w, r, "failed to render notes", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(item); err != nil {
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		respond.ServerError(w, r, "failed to encode response", err)
 		return
 	}
 }
 
-type updateTodoRequest struct {
-	Completed *bool `json:"completed"`
-}
-
 func (h *Handler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	id, err := parseID(r.PathValue("id"))
 	if err != nil {
@@ -80,29 +218,61 @@ func (h *Handler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req updateTodoRequest
-	if err := decodeJSON(r, &req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	var req UpdateTodoRequest
+	if !h.decodeRequest(w, r, &req) {
 		return
 	}
-	if req.Completed == nil {
-		http.Error(w, "completed is required", http.StatusBadRequest)
+	if req.Title == nil && req.Completed == nil && req.Notes == nil && req.Priority == nil && !req.Due.Set {
+		http.Error(w, "title, completed, notes, due or priority is required", http.StatusBadRequest)
+		return
+	}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			http.Error(w, "title must not be empty", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(title) > h.maxTitleLength {
+			http.Error(w, fmt.Sprintf("title must be at most %d characters", h.maxTitleLength), http.StatusBadRequest)
+			return
+		}
+		req.Title = &title
+	}
+	if req.Notes != nil && !validNotes(w, *req.Notes) {
+		return
+	}
+	if req.Priority != nil && !validPriority(w, *req.Priority) {
+		return
+	}
+
+	if _, ok := h.authorizeItem(w, r, id, true); !ok {
 		return
 	}
 
-	item, err := h.repo.UpdateCompleted(id, *req.Completed)
+	item, err := h.repo.Update(r.Context(), id, Changes{
+		Title:     req.Title,
+		Completed: req.Completed,
+		Notes:     req.Notes,
+		Priority:  req.Priority,
+		SetDue:    req.Due.Set,
+		Due:       req.Due.Value.Time(),
+	}, auth.UserID(r.Context()))
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			http.Error(w, "todo not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, "failed to update todo", http.StatusInternalServerError)
+		respond.ServerError(w, r, "failed to update todo", err)
+		return
+	}
+	if err := renderNotes(r, &item); err != nil {
+		respond.ServerError(w, r, "failed to render notes", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(item); err != nil {
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		respond.ServerError(w, r, "failed to encode response", err)
 		return
 	}
 }
@@ -114,19 +284,145 @@ func (h *Handler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if _, ok := h.authorizeItem(w, r, id, true); !ok {
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), id); err != nil {
 		if errors.Is(err, ErrNotFound) {
 			http.Error(w, "todo not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, "failed to delete todo", http.StatusInternalServerError)
+		respond.ServerError(w, r, "failed to delete todo", err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func decodeJSON(r *http.Request, target any) error {
+// authorizeItem loads the todo with id and checks that the caller may read
+// it, or modify it when write is set, writing the error response and
+// returning false when not.
+func (h *Handler) authorizeItem(w http.ResponseWriter, r *http.Request, id int64, write bool) (Item, bool) {
+	item, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "todo not found", http.StatusNotFound)
+			return Item{}, false
+		}
+		respond.ServerError(w, r, "failed to fetch todo", err)
+		return Item{}, false
+	}
+	return item, h.authorizeList(w, r, item.ListID, write, "todo not found")
+}
+
+// authorizeList enforces list roles before the repository is touched. The
+// default list (nil listID) is governed by route-level scopes alone. Lists
+// the caller does not belong to answer with notFound so their existence is
+// not revealed; viewers get 403 on writes.
+func (h *Handler) authorizeList(w http.ResponseWriter, r *http.Request, listID *int64, write bool, notFound string) bool {
+	if listID == nil {
+		return true
+	}
+
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	switch {
+	case !ok:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return false
+	case principal.Kind == auth.KindAdmin:
+		return true
+	case principal.Kind != auth.KindUser || h.permissions == nil:
+		http.Error(w, notFound, http.StatusNotFound)
+		return false
+	}
+
+	role, err := h.permissions.Role(r.Context(), *listID, principal.ID)
+	if err != nil {
+		if errors.Is(err, sharing.ErrNotFound) {
+			http.Error(w, notFound, http.StatusNotFound)
+			return false
+		}
+		respond.ServerError(w, r, "failed to check list access", err)
+		return false
+	}
+	if write && !role.CanEdit() {
+		http.Error(w, "viewers cannot modify this list", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func validNotes(w http.ResponseWriter, notes string) bool {
+	if err := CheckNotes(notes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func validPriority(w http.ResponseWriter, priority int) bool {
+	if err := CheckPriority(priority); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// CheckNotes reports notes that are too long, in the words the API uses.
+func CheckNotes(notes string) error {
+	if utf8.RuneCountInString(notes) > maxNotesLength {
+		return fmt.Errorf("notes must be at most %d characters", maxNotesLength)
+	}
+	return nil
+}
+
+// CheckPriority reports a priority outside the iCalendar range.
+func CheckPriority(priority int) error {
+	if priority < 0 || priority > maxPriority {
+		return fmt.Errorf("priority must be between 0 and %d", maxPriority)
+	}
+	return nil
+}
+
+// wantsHTML reports whether the client asked for server-rendered Markdown.
+func wantsHTML(r *http.Request) bool {
+	return r.URL.Query().Get("render") == "html"
+}
+
+func renderNotes(r *http.Request, item *Item) error {
+	if !wantsHTML(r) || item.Notes == "" {
+		return nil
+	}
+	html, err := markdown.Render(item.Notes)
+	if err != nil {
+		return err
+	}
+	item.NotesHTML = html
+	return nil
+}
+
+// decodeRequest decodes the JSON body into target, writing a 400 or 413
+// response and returning false when the body is unusable.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, target any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	if err := decodeJSON(r, target); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func decodeJSON(r *http.Request, target any) (err error) {
+	_, span := tracer.Start(r.Context(), "decode request body")
+	defer func() { endSpan(span, err) }()
+
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(target); err != nil {
@@ -134,6 +430,10 @@ func decodeJSON(r *http.Request, target any) error {
 	}
 
 	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
 		return errors.New("request body must contain only a single JSON object")
 	}
 	return nil
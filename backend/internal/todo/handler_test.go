@@ -1,56 +1,112 @@
 package todo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/logging"
+	"todoapp/backend/internal/sharing"
+	"todoapp/backend/pkg/api"
 )
 
 type fakeRepo struct {
-	listItems []Item
-	listErr   error
+	listItems  []Item
+	listErr    error
+	listListID *int64
+
+	getItems map[int64]Item
+	getErr   error
 
-	createItem  Item
-	createErr   error
-	createTitle string
+	createItem     Item
+	createErr      error
+	createTitle    string
+	createNotes    string
+	createList     *int64
+	createDue      *time.Time
+	createPriority int
 
 	updateItem      Item
 	updateErr       error
 	updateID        int64
 	updateCompleted bool
+	updateBy        *int64
+	updateNotes     *string
+	updateChanges   Changes
 
 	deleteErr error
 	deleteID  int64
 }
 
-func (f *fakeRepo) List() ([]Item, error) {
+func (f *fakeRepo) List(_ context.Context, listID *int64) ([]Item, error) {
+	f.listListID = listID
 	if f.listErr != nil {
 		return nil, f.listErr
 	}
 	return f.listItems, nil
 }
 
-func (f *fakeRepo) Create(title string) (Item, error) {
-	f.createTitle = title
+// Get returns the configured item, or an item on the default list when none
+// is configured for id.
+func (f *fakeRepo) Get(_ context.Context, id int64) (Item, error) {
+	if f.getErr != nil {
+		return Item{}, f.getErr
+	}
+	if item, ok := f.getItems[id]; ok {
+		return item, nil
+	}
+	return Item{ID: id}, nil
+}
+
+func (f *fakeRepo) Create(_ context.Context, item Item) (Item, error) {
+	f.createTitle = item.Title
+	f.createNotes = item.Notes
+	f.createList = item.ListID
+	f.createDue = item.Due
+	f.createPriority = item.Priority
 	if f.createErr != nil {
 		return Item{}, f.createErr
 	}
 	return f.createItem, nil
 }
 
-func (f *fakeRepo) UpdateCompleted(id int64, completed bool) (Item, error) {
+func (f *fakeRepo) UpdateCompleted(_ context.Context, id int64, completed bool, completedBy *int64) (Item, error) {
 	f.updateID = id
 	f.updateCompleted = completed
+	f.updateBy = completedBy
 	if f.updateErr != nil {
 		return Item{}, f.updateErr
 	}
 	return f.updateItem, nil
 }
 
-func (f *fakeRepo) Delete(id int64) error {
+func (f *fakeRepo) Update(_ context.Context, id int64, changes Changes, completedBy *int64) (Item, error) {
+	f.updateID = id
+	f.updateNotes = changes.Notes
+	f.updateChanges = changes
+	if changes.Completed != nil {
+		f.updateCompleted = *changes.Completed
+		f.updateBy = completedBy
+	}
+	if f.updateErr != nil {
+		return Item{}, f.updateErr
+	}
+	item := f.updateItem
+	if changes.Notes != nil {
+		item.Notes = *changes.Notes
+	}
+	return item, nil
+}
+
+func (f *fakeRepo) Delete(_ context.Context, id int64) error {
 	f.deleteID = id
 	return f.deleteErr
 }
@@ -89,6 +145,34 @@ func TestListTodos_Error(t *testing.T) {
 	}
 }
 
+func TestListTodos_ErrorLogsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	repo := &fakeRepo{listErr: errors.New("boom")}
+	h := NewHandler(repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/todos", nil)
+	req = req.WithContext(logging.WithRequestID(req.Context(), "req-42"))
+	rr := httptest.NewRecorder()
+
+	h.ListTodos(rr, req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode log line %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "req-42" || record["error"] != "boom" {
+		t.Fatalf("unexpected log record: %#v", record)
+	}
+}
+
 func TestCreateTodo_Success(t *testing.T) {
 	repo := &fakeRepo{
 		createItem: Item{ID: 3, Title: "created", Completed: false},
@@ -144,6 +228,69 @@ func TestCreateTodo_EmptyTitle(t *testing.T) {
 	}
 }
 
+func TestCreateTodo_TitleTooLong(t *testing.T) {
+	repo := &fakeRepo{}
+	h := NewHandler(repo, WithMaxTitleLength(5))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"title":"あいうえおか"}`))
+	rr := httptest.NewRecorder()
+
+	h.CreateTodo(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rr.Code)
+	}
+	if repo.createTitle != "" {
+		t.Fatalf("expected repo not to be called, got %q", repo.createTitle)
+	}
+}
+
+func TestCreateTodo_NotesTooLong(t *testing.T) {
+	repo := &fakeRepo{}
+	h := NewHandler(repo)
+
+	body, _ := json.Marshal(map[string]string{"title": "Buy milk", "notes": strings.Repeat("a", maxNotesLength+1)})
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	h.CreateTodo(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rr.Code)
+	}
+	if repo.createTitle != "" {
+		t.Fatalf("expected repo not to be called, got %q", repo.createTitle)
+	}
+}
+
+func TestCreateTodo_TitleAtLimitCountsCharacters(t *testing.T) {
+	repo := &fakeRepo{createItem: Item{ID: 1, Title: "あいうえお"}}
+	h := NewHandler(repo, WithMaxTitleLength(5))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"title":"あいうえお"}`))
+	rr := httptest.NewRecorder()
+
+	h.CreateTodo(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rr.Code)
+	}
+}
+
+func TestCreateTodo_BodyTooLarge(t *testing.T) {
+	repo := &fakeRepo{}
+	h := NewHandler(repo, WithMaxBodyBytes(16))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"title":"`+strings.Repeat("a", 64)+`"}`))
+	rr := httptest.NewRecorder()
+
+	h.CreateTodo(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", rr.Code)
+	}
+}
+
 func TestCreateTodo_Error(t *testing.T) {
 	repo := &fakeRepo{createErr: errors.New("boom")}
 	h := NewHandler(repo)
@@ -220,7 +367,7 @@ func TestUpdateTodo_MissingCompleted(t *testing.T) {
 	repo := &fakeRepo{}
 	h := NewHandler(repo)
 
-	req := httptest.NewRequest(http.MethodPatch, "/api/todos/1", strings.NewReader(`{"title":"nope"}`))
+	req := httptest.NewRequest(http.MethodPatch, "/api/todos/1", strings.NewReader(`{}`))
 	req.SetPathValue("id", "1")
 	rr := httptest.NewRecorder()
 
@@ -323,3 +470,283 @@ func TestDeleteTodo_Error(t *testing.T) {
 		t.Fatalf("expected status 500, got %d", rr.Code)
 	}
 }
+
+type fakePermissions map[int64]sharing.Role
+
+func (f fakePermissions) Role(_ context.Context, listID int64, userID int64) (sharing.Role, error) {
+	role, ok := f[userID]
+	if !ok || listID != 5 {
+		return "", sharing.ErrNotFound
+	}
+	return role, nil
+}
+
+func newSharedListHandler(repo *fakeRepo) *Handler {
+	listID := int64(5)
+	repo.getItems = map[int64]Item{9: {ID: 9, Title: "shared", ListID: &listID}}
+	return NewHandler(repo, WithPermissions(fakePermissions{1: sharing.RoleOwner, 2: sharing.RoleEditor, 3: sharing.RoleViewer}))
+}
+
+func asUser(req *http.Request, userID int64) *http.Request {
+	principal := auth.Principal{Kind: auth.KindUser, ID: userID, Scope: auth.ScopeReadWrite}
+	return req.WithContext(auth.WithPrincipal(req.Context(), principal))
+}
+
+func TestSharedList_RolesAreEnforced(t *testing.T) {
+	cases := []struct {
+		name   string
+		userID int64
+		want   map[string]int
+	}{
+		{name: "owner", userID: 1, want: map[string]int{"list": 200, "create": 201, "update": 200, "delete": 204}},
+		{name: "editor", userID: 2, want: map[string]int{"list": 200, "create": 201, "update": 200, "delete": 204}},
+		{name: "viewer", userID: 3, want: map[string]int{"list": 200, "create": 403, "update": 403, "delete": 403}},
+		{name: "non-member", userID: 4, want: map[string]int{"list": 404, "create": 404, "update": 404, "delete": 404}},
+	}
+	for _, tc := range cases {
+		repo := &fakeRepo{createItem: Item{ID: 10}, updateItem: Item{ID: 9}}
+		h := newSharedListHandler(repo)
+
+		requests := map[string]func() int{
+			"list": func() int {
+				rr := httptest.NewRecorder()
+				h.ListTodos(rr, asUser(httptest.NewRequest(http.MethodGet, "/api/todos?list=5", nil), tc.userID))
+				return rr.Code
+			},
+			"create": func() int {
+				rr := httptest.NewRecorder()
+				h.CreateTodo(rr, asUser(httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"title":"milk","listId":5}`)), tc.userID))
+				return rr.Code
+			},
+			"update": func() int {
+				req := asUser(httptest.NewRequest(http.MethodPatch, "/api/todos/9", strings.NewReader(`{"completed":true}`)), tc.userID)
+				req.SetPathValue("id", "9")
+				rr := httptest.NewRecorder()
+				h.UpdateTodo(rr, req)
+				return rr.Code
+			},
+			"delete": func() int {
+				req := asUser(httptest.NewRequest(http.MethodDelete, "/api/todos/9", nil), tc.userID)
+				req.SetPathValue("id", "9")
+				rr := httptest.NewRecorder()
+				h.DeleteTodo(rr, req)
+				return rr.Code
+			},
+		}
+		for action, want := range tc.want {
+			if got := requests[action](); got != want {
+				t.Fatalf("%s %s: expected status %d, got %d", tc.name, action, want, got)
+			}
+		}
+
+		if tc.want["update"] != http.StatusOK && (repo.updateID != 0 || repo.deleteID != 0 || repo.createTitle != "") {
+			t.Fatalf("%s: repository was called despite being denied", tc.name)
+		}
+	}
+}
+
+func TestSharedList_AnonymousIsUnauthorized(t *testing.T) {
+	h := newSharedListHandler(&fakeRepo{})
+
+	rr := httptest.NewRecorder()
+	h.ListTodos(rr, httptest.NewRequest(http.MethodGet, "/api/todos?list=5", nil))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rr.Code)
+	}
+}
+
+func TestUpdateTodo_RecordsCompletingUser(t *testing.T) {
+	repo := &fakeRepo{updateItem: Item{ID: 9}}
+	h := newSharedListHandler(repo)
+
+	req := asUser(httptest.NewRequest(http.MethodPatch, "/api/todos/9", strings.NewReader(`{"completed":true}`)), 2)
+	req.SetPathValue("id", "9")
+	rr := httptest.NewRecorder()
+	h.UpdateTodo(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if repo.updateBy == nil || *repo.updateBy != 2 {
+		t.Fatalf("expected completion to be attributed to user 2, got %v", repo.updateBy)
+	}
+}
+
+func TestUpdateTodo_Notes(t *testing.T) {
+	repo := &fakeRepo{updateItem: Item{ID: 2, Title: "meeting"}}
+	h := NewHandler(repo)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/todos/2?render=html", strings.NewReader(`{"notes":"**agenda** <script>x</script>"}`))
+	req.SetPathValue("id", "2")
+	rr := httptest.NewRecorder()
+
+	h.UpdateTodo(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if repo.updateNotes == nil || *repo.updateNotes != "**agenda** <script>x</script>" {
+		t.Fatalf("unexpected notes update: %v", repo.updateNotes)
+	}
+	var body Item
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !strings.Contains(body.NotesHTML, "<strong>agenda</strong>") || strings.Contains(body.NotesHTML, "<script") {
+		t.Fatalf("unexpected rendered notes: %q", body.NotesHTML)
+	}
+}
+
+func TestUpdateTodo_NotesTooLong(t *testing.T) {
+	repo := &fakeRepo{}
+	h := NewHandler(repo)
+
+	body, _ := json.Marshal(map[string]string{"notes": strings.Repeat("a", maxNotesLength+1)})
+	req := httptest.NewRequest(http.MethodPatch, "/api/todos/2", bytes.NewReader(body))
+	req.SetPathValue("id", "2")
+	rr := httptest.NewRecorder()
+
+	h.UpdateTodo(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rr.Code)
+	}
+	if repo.updateNotes != nil {
+		t.Fatalf("expected repository not to be called")
+	}
+}
+
+func TestCreateTodo_DueAndPriority(t *testing.T) {
+	repo := &fakeRepo{createItem: Item{ID: 3, Title: "taxes"}}
+	h := NewHandler(repo)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"title":"taxes","due":"2026-03-15","priority":1}`))
+	rr := httptest.NewRecorder()
+	h.CreateTodo(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if repo.createDue == nil || !repo.createDue.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)) || repo.createPriority != 1 {
+		t.Fatalf("unexpected due %v and priority %d", repo.createDue, repo.createPriority)
+	}
+
+	for _, body := range []string{`{"title":"x","priority":10}`, `{"title":"x","due":"next week"}`} {
+		rr := httptest.NewRecorder()
+		h.CreateTodo(rr, httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestUpdateTodo_Due(t *testing.T) {
+	tests := []struct {
+		body   string
+		setDue bool
+		due    *time.Time
+	}{
+		{body: `{"priority":2}`},
+		{body: `{"due":null}`, setDue: true},
+		{body: `{"due":"2026-03-15T09:30:00+09:00"}`, setDue: true, due: ptrTime(time.Date(2026, 3, 15, 0, 30, 0, 0, time.UTC))},
+	}
+	for _, tt := range tests {
+		repo := &fakeRepo{updateItem: Item{ID: 2}}
+		h := NewHandler(repo)
+		req := httptest.NewRequest(http.MethodPatch, "/api/todos/2", strings.NewReader(tt.body))
+		req.SetPathValue("id", "2")
+		rr := httptest.NewRecorder()
+		h.UpdateTodo(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.body, rr.Code)
+		}
+		changes := repo.updateChanges
+		if changes.SetDue != tt.setDue || (changes.Due == nil) != (tt.due == nil) || (tt.due != nil && !changes.Due.Equal(*tt.due)) {
+			t.Fatalf("%s: unexpected changes %#v", tt.body, changes)
+		}
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func TestUpdateTodo_Title(t *testing.T) {
+	repo := &fakeRepo{updateItem: Item{ID: 2}}
+	h := NewHandler(repo, WithMaxTitleLength(10))
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/todos/2", strings.NewReader(`{"title":"  renamed  "}`))
+	req.SetPathValue("id", "2")
+	rr := httptest.NewRecorder()
+	h.UpdateTodo(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if title := repo.updateChanges.Title; title == nil || *title != "renamed" {
+		t.Fatalf("unexpected title update %v", title)
+	}
+
+	for _, body := range []string{`{"title":"   "}`, `{"title":"far too long"}`} {
+		req := httptest.NewRequest(http.MethodPatch, "/api/todos/2", strings.NewReader(body))
+		req.SetPathValue("id", "2")
+		rr := httptest.NewRecorder()
+		h.UpdateTodo(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestUpdateTodoRequest_MarshalJSON(t *testing.T) {
+	title := "x"
+	tests := []struct {
+		req  UpdateTodoRequest
+		want string
+	}{
+		{UpdateTodoRequest{Title: &title}, `{"title":"x"}`},
+		{UpdateTodoRequest{Due: api.SetDue(nil)}, `{"due":null}`},
+		{UpdateTodoRequest{Due: api.SetDue(ptrTime(time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)))}, `{"due":"2026-03-15"}`},
+		{UpdateTodoRequest{Due: api.SetDue(ptrTime(time.Date(2026, 3, 15, 9, 30, 0, 0, time.UTC)))}, `{"due":"2026-03-15T09:30:00Z"}`},
+	}
+	for _, tt := range tests {
+		body, err := json.Marshal(tt.req)
+		if err != nil || string(body) != tt.want {
+			t.Fatalf("expected %s, got %s: %v", tt.want, body, err)
+		}
+		var decoded UpdateTodoRequest
+		if err := json.Unmarshal(body, &decoded); err != nil || decoded.Due.Set != tt.req.Due.Set {
+			t.Fatalf("%s did not round-trip: %#v %v", body, decoded, err)
+		}
+	}
+}
+
+func TestGetTodo(t *testing.T) {
+	repo := &fakeRepo{getItems: map[int64]Item{2: {ID: 2, Title: "meeting", Notes: "**agenda**"}}}
+	h := NewHandler(repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/todos/2?render=html", nil)
+	req.SetPathValue("id", "2")
+	rr := httptest.NewRecorder()
+	h.GetTodo(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var body Item
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Title != "meeting" || !strings.Contains(body.NotesHTML, "<strong>agenda</strong>") {
+		t.Fatalf("unexpected todo %#v", body)
+	}
+
+	repo.getErr = ErrNotFound
+	rr = httptest.NewRecorder()
+	h.GetTodo(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rr.Code)
+	}
+}
@@ -1,11 +1,161 @@
 package todo
 
-import "errors"
+import (
+	"errors"
+	"time"
 
-var ErrNotFound = errors.New("todo not found")
+	"todoapp/backend/pkg/api"
+)
 
-type Item struct {
-	ID        int64  `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
+var (
+	ErrNotFound        = api.ErrNotFound
+	ErrCommentNotFound = errors.New("comment not found")
+
+	ErrReminderNotFound   = errors.New("reminder not found")
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+)
+
+// The types exchanged over the REST API live in pkg/api, which the Go
+// client imports without pulling in the server.
+type (
+	Item              = api.Item
+	UserRef           = api.UserRef
+	Changes           = api.Changes
+	CreateTodoRequest = api.CreateTodoRequest
+	UpdateTodoRequest = api.UpdateTodoRequest
+	DueTime           = api.DueTime
+	OptionalDue       = api.OptionalDue
+	TaskImportResult  = api.TaskImportResult
+	TaskImportItem    = api.TaskImportItem
+)
+
+// Reminder notifies its owner about a todo, either at a fixed time or a
+// number of minutes before the todo is due. Reminders fire once; FireAt is
+// when the next firing is due, nil for a relative reminder on a todo
+// without a due date.
+type Reminder struct {
+	ID            int64      `json:"id"`
+	TodoID        int64      `json:"todoId"`
+	At            *time.Time `json:"at,omitempty"`
+	MinutesBefore *int       `json:"minutesBefore,omitempty"`
+	FireAt        *time.Time `json:"fireAt,omitempty"`
+	FiredAt       *time.Time `json:"firedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	// OwnerKey is the principal the reminder belongs to; UserID is set
+	// when that principal is a signed-in user, who can be emailed.
+	OwnerKey string `json:"-"`
+	UserID   *int64 `json:"-"`
+}
+
+// FireTime returns when the reminder fires for a todo due at due.
+func (r Reminder) FireTime(due *time.Time) *time.Time {
+	switch {
+	case r.At != nil:
+		return r.At
+	case r.MinutesBefore != nil && due != nil:
+		at := due.Add(-time.Duration(*r.MinutesBefore) * time.Minute)
+		return &at
+	}
+	return nil
+}
+
+// Comment is a remark on a todo. Replies hang off their parent, forming a
+// thread; deleted comments keep their place with an empty body so replies
+// stay attached.
+type Comment struct {
+	ID         int64     `json:"id"`
+	TodoID     int64     `json:"todoId"`
+	ParentID   *int64    `json:"parentId,omitempty"`
+	AuthorName string    `json:"authorName"`
+	Body       string    `json:"body"`
+	BodyHTML   string    `json:"bodyHtml,omitempty"`
+	Deleted    bool      `json:"deleted,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Replies    []Comment `json:"replies,omitempty"`
+
+	// AuthorKey identifies the author's credential, see auth.Principal.Key.
+	AuthorKey string `json:"-"`
+}
+
+// Attachment is a file uploaded to a todo. Its content lives in a blob store
+// under BlobKey, the SHA-256 of the bytes, so identical files share storage.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	TodoID      int64     `json:"todoId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	BlobKey     string    `json:"sha256"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	// UploaderKey identifies who uploaded the file and is charged for it
+	// against their quota, see auth.Principal.Key.
+	UploaderKey string `json:"-"`
+}
+
+// ImportOutcome reports what importing one item did: ID is set when it was
+// created or updated, Duplicate when it was skipped.
+type ImportOutcome struct {
+	ID        int64
+	Duplicate bool
+	Updated   bool
+}
+
+// Clock orders writes to a field for last-writer-wins merging: the later
+// Time wins and ClientID breaks ties. Writes made through the server's own
+// endpoints carry the server time and an empty ClientID.
+type Clock struct {
+	Time     int64 // milliseconds since the Unix epoch
+	ClientID string
+}
+
+// Before reports whether c is older than other.
+func (c Clock) Before(other Clock) bool {
+	if c.Time != other.Time {
+		return c.Time < other.Time
+	}
+	return c.ClientID < other.ClientID
+}
+
+// Mutation is a change a client made, possibly offline, to the todo with
+// UID. Nil fields were not changed; Changes.Due applies only with SetDue.
+type Mutation struct {
+	UID    string
+	Delete bool
+	Clock  Clock
+	Title  *string
+	Changes
+}
+
+// Statuses of an applied Mutation.
+const (
+	MutationApplied = "applied"  // every field was written
+	MutationMerged  = "merged"   // some fields lost to newer writes
+	MutationIgnored = "ignored"  // every field lost to newer writes
+	MutationDeleted = "deleted"  // the todo is gone, deletions win
+	MutationInvalid = "rejected" // the todo does not exist and has no title
+)
+
+// MutationResult is the outcome of one Mutation and, unless the todo was
+// deleted, the todo as it now stands.
+type MutationResult struct {
+	Status string
+	Item   *Item
+}
+
+// ChangeSet holds the todos changed and deleted since a sync token, along
+// with the token that covers them.
+type ChangeSet struct {
+	Token   int64
+	Full    bool
+	Items   []Item
+	Deleted []Tombstone
+}
+
+// Tombstone records a deleted todo so that clients can drop their copy.
+type Tombstone struct {
+	ID  int64  `json:"id"`
+	UID string `json:"uid"`
 }
@@ -0,0 +1,148 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/respond"
+)
+
+const (
+	maxRemindersPerTodo = 10
+	// MaxReminderMinutesBefore bounds relative reminders to four weeks
+	// before the due date.
+	MaxReminderMinutesBefore = 4 * 7 * 24 * 60
+)
+
+type ReminderStore interface {
+	ListReminders(ctx context.Context, todoID int64, ownerKey string) ([]Reminder, error)
+	CreateReminder(ctx context.Context, reminder Reminder) (Reminder, error)
+	DeleteReminder(ctx context.Context, todoID int64, id int64, ownerKey string) error
+}
+
+// WithReminders enables the reminder endpoints.
+func WithReminders(s ReminderStore) Option {
+	return func(h *Handler) { h.reminders = s }
+}
+
+// ListReminders returns the caller's reminders on a todo. Reminders are
+// personal: members of a shared list only see their own.
+func (h *Handler) ListReminders(w http.ResponseWriter, r *http.Request) {
+	principal, item, ok := h.reminderTodo(w, r)
+	if !ok {
+		return
+	}
+
+	reminders, err := h.reminders.ListReminders(r.Context(), item.ID, principal.Key())
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch reminders", err)
+		return
+	}
+	for i := range reminders {
+		reminders[i].FireAt = reminders[i].FireTime(item.Due)
+	}
+	respond.JSON(w, r, http.StatusOK, reminders)
+}
+
+type createReminderRequest struct {
+	At            *time.Time `json:"at"`
+	MinutesBefore *int       `json:"minutesBefore"`
+}
+
+// CreateReminder sets a reminder at an absolute time or, with
+// minutesBefore, relative to the todo's due date. Relative reminders follow
+// later changes to the due date and wait while the todo has none.
+func (h *Handler) CreateReminder(w http.ResponseWriter, r *http.Request) {
+	principal, item, ok := h.reminderTodo(w, r)
+	if !ok {
+		return
+	}
+
+	var req createReminderRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	switch {
+	case (req.At == nil) == (req.MinutesBefore == nil):
+		http.Error(w, "exactly one of at and minutesBefore is required", http.StatusBadRequest)
+		return
+	case req.At != nil && !req.At.After(time.Now()):
+		http.Error(w, "at must be in the future", http.StatusBadRequest)
+		return
+	case req.MinutesBefore != nil && (*req.MinutesBefore < 0 || *req.MinutesBefore > MaxReminderMinutesBefore):
+		http.Error(w, fmt.Sprintf("minutesBefore must be between 0 and %d", MaxReminderMinutesBefore), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.reminders.ListReminders(r.Context(), item.ID, principal.Key())
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch reminders", err)
+		return
+	}
+	if len(existing) >= maxRemindersPerTodo {
+		http.Error(w, fmt.Sprintf("at most %d reminders may be set on a todo", maxRemindersPerTodo), http.StatusConflict)
+		return
+	}
+
+	reminder := Reminder{TodoID: item.ID, MinutesBefore: req.MinutesBefore, OwnerKey: principal.Key()}
+	if req.At != nil {
+		at := req.At.UTC()
+		reminder.At = &at
+	}
+	if principal.Kind == auth.KindUser {
+		reminder.UserID = &principal.ID
+	}
+	created, err := h.reminders.CreateReminder(r.Context(), reminder)
+	if err != nil {
+		respond.ServerError(w, r, "failed to create reminder", err)
+		return
+	}
+	created.FireAt = created.FireTime(item.Due)
+	respond.JSON(w, r, http.StatusCreated, created)
+}
+
+func (h *Handler) DeleteReminder(w http.ResponseWriter, r *http.Request) {
+	principal, item, ok := h.reminderTodo(w, r)
+	if !ok {
+		return
+	}
+	reminderID, err := parseID(r.PathValue("reminderId"))
+	if err != nil {
+		http.Error(w, "invalid reminder id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.reminders.DeleteReminder(r.Context(), item.ID, reminderID, principal.Key()); err != nil {
+		if errors.Is(err, ErrReminderNotFound) {
+			http.Error(w, "reminder not found", http.StatusNotFound)
+			return
+		}
+		respond.ServerError(w, r, "failed to delete reminder", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reminderTodo resolves the caller and the {id} todo, which they must be
+// able to read.
+func (h *Handler) reminderTodo(w http.ResponseWriter, r *http.Request) (auth.Principal, Item, bool) {
+	if h.reminders == nil {
+		http.NotFound(w, r)
+		return auth.Principal{}, Item{}, false
+	}
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return auth.Principal{}, Item{}, false
+	}
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid todo id", http.StatusBadRequest)
+		return auth.Principal{}, Item{}, false
+	}
+	item, ok := h.authorizeItem(w, r, id, false)
+	return principal, item, ok
+}
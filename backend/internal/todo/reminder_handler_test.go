@@ -0,0 +1,121 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+)
+
+func reminderRequest(method string, target string, body string, principal *auth.Principal, reminderID string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.SetPathValue("id", "1")
+	req.SetPathValue("reminderId", reminderID)
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), *principal))
+	}
+	return req
+}
+
+func TestReminders_CreateListDelete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithReminders(repo))
+	alice := &auth.Principal{Kind: auth.KindUser, ID: 1, Scope: auth.ScopeReadWrite}
+	insertUser(t, db, alice.ID)
+	ci := &auth.Principal{Kind: auth.KindAPIKey, ID: 7, Scope: auth.ScopeReadWrite}
+
+	due := time.Date(2030, 1, 10, 9, 0, 0, 0, time.UTC)
+	repo.Update(context.Background(), 1, Changes{SetDue: true, Due: &due}, nil)
+
+	rr := httptest.NewRecorder()
+	h.CreateReminder(rr, reminderRequest(http.MethodPost, "/api/todos/1/reminders", `{"minutesBefore":30}`, alice, ""))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created Reminder
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.FireAt == nil || !created.FireAt.Equal(due.Add(-30*time.Minute)) || created.At != nil {
+		t.Fatalf("unexpected reminder %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.CreateReminder(rr, reminderRequest(http.MethodPost, "/api/todos/1/reminders", `{"at":"2030-01-01T08:00:00+01:00"}`, ci, ""))
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), `"fireAt":"2030-01-01T07:00:00Z"`) {
+		t.Fatalf("expected an absolute reminder, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Reminders are personal.
+	rr = httptest.NewRecorder()
+	h.ListReminders(rr, reminderRequest(http.MethodGet, "/api/todos/1/reminders", "", alice, ""))
+	var reminders []Reminder
+	json.Unmarshal(rr.Body.Bytes(), &reminders)
+	if len(reminders) != 1 || reminders[0].ID != created.ID {
+		t.Fatalf("expected only alice's reminder, got %s", rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	h.DeleteReminder(rr, reminderRequest(http.MethodDelete, "/api/todos/1/reminders/1", "", ci, "1"))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for someone else's reminder, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	h.DeleteReminder(rr, reminderRequest(http.MethodDelete, "/api/todos/1/reminders/1", "", alice, "1"))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+}
+
+func TestReminders_Validation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithReminders(repo))
+	alice := &auth.Principal{Kind: auth.KindUser, ID: 1, Scope: auth.ScopeReadWrite}
+	insertUser(t, db, alice.ID)
+
+	for _, body := range []string{
+		`{}`,
+		`{"at":"2030-01-01T08:00:00Z","minutesBefore":5}`,
+		`{"at":"2001-01-01T08:00:00Z"}`,
+		`{"minutesBefore":-1}`,
+		`{"minutesBefore":40321}`,
+	} {
+		rr := httptest.NewRecorder()
+		h.CreateReminder(rr, reminderRequest(http.MethodPost, "/api/todos/1/reminders", body, alice, ""))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+
+	for i := 0; i < maxRemindersPerTodo; i++ {
+		rr := httptest.NewRecorder()
+		h.CreateReminder(rr, reminderRequest(http.MethodPost, "/api/todos/1/reminders", `{"minutesBefore":5}`, alice, ""))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d", rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	h.CreateReminder(rr, reminderRequest(http.MethodPost, "/api/todos/1/reminders", `{"minutesBefore":5}`, alice, ""))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 past the limit, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.CreateReminder(rr, reminderRequest(http.MethodPost, "/api/todos/1/reminders", `{"minutesBefore":5}`, nil, ""))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 when anonymous, got %d", rr.Code)
+	}
+
+	if err := repo.Delete(context.Background(), 1); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if reminders, _ := repo.ListReminders(context.Background(), 1, "user:1"); len(reminders) != 0 {
+		t.Fatalf("expected reminders to be deleted with their todo, got %#v", reminders)
+	}
+}
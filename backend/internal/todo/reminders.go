@@ -0,0 +1,105 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const reminderColumns = `id, todo_id, owner_key, user_id, remind_at, minutes_before, created_at, fired_at FROM reminders`
+
+// ListReminders returns the reminders ownerKey set on todoID, oldest first.
+func (r *Repository) ListReminders(ctx context.Context, todoID int64, ownerKey string) (reminders []Reminder, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.ListReminders", trace.WithAttributes(attribute.Int64("todo.id", todoID)))
+	defer func() { endSpan(span, err) }()
+
+	const query = `SELECT ` + reminderColumns + ` WHERE todo_id = ? AND owner_key = ? ORDER BY id ASC`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "reminders", query)
+	rows, err := r.db.QueryContext(ctx, query, todoID, ownerKey)
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders = make([]Reminder, 0)
+	for rows.Next() {
+		reminder, err := scanReminder(rows)
+		if err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (r *Repository) CreateReminder(ctx context.Context, reminder Reminder) (created Reminder, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.CreateReminder", trace.WithAttributes(attribute.Int64("todo.id", reminder.TodoID)))
+	defer func() { endSpan(span, err) }()
+
+	var minutesBefore sql.NullInt64
+	if reminder.MinutesBefore != nil {
+		minutesBefore = sql.NullInt64{Int64: int64(*reminder.MinutesBefore), Valid: true}
+	}
+	reminder.CreatedAt = r.now().UTC()
+	result, err := r.exec(ctx, "INSERT", "reminders", `
+		INSERT INTO reminders (todo_id, owner_key, user_id, remind_at, minutes_before, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		reminder.TodoID, reminder.OwnerKey, nullInt64(reminder.UserID), nullTime(reminder.At), minutesBefore, reminder.CreatedAt)
+	if err != nil {
+		return Reminder{}, err
+	}
+	if reminder.ID, err = result.LastInsertId(); err != nil {
+		return Reminder{}, err
+	}
+	return reminder, nil
+}
+
+// DeleteReminder removes reminder id from todoID if ownerKey set it.
+func (r *Repository) DeleteReminder(ctx context.Context, todoID int64, id int64, ownerKey string) (err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.DeleteReminder", trace.WithAttributes(attribute.Int64("reminder.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	result, err := r.exec(ctx, "DELETE", "reminders",
+		`DELETE FROM reminders WHERE id = ? AND todo_id = ? AND owner_key = ?`, id, todoID, ownerKey)
+	if err != nil {
+		return err
+	}
+	return requireUpdated(result, ErrReminderNotFound)
+}
+
+func scanReminder(row rowScanner) (Reminder, error) {
+	var (
+		reminder          Reminder
+		userID            sql.NullInt64
+		minutesBefore     sql.NullInt64
+		remindAt, firedAt sql.NullTime
+	)
+	err := row.Scan(&reminder.ID, &reminder.TodoID, &reminder.OwnerKey, &userID, &remindAt, &minutesBefore,
+		&reminder.CreatedAt, &firedAt)
+	if err != nil {
+		return Reminder{}, err
+	}
+	if userID.Valid {
+		reminder.UserID = &userID.Int64
+	}
+	if remindAt.Valid {
+		reminder.At = &remindAt.Time
+	}
+	if minutesBefore.Valid {
+		minutes := int(minutesBefore.Int64)
+		reminder.MinutesBefore = &minutes
+	}
+	if firedAt.Valid {
+		reminder.FiredAt = &firedAt.Time
+	}
+	return reminder, nil
+}
@@ -1,73 +1,302 @@
 package todo
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"todoapp/backend/internal/blob"
+	"todoapp/backend/internal/db"
 )
 
+var tracer = otel.Tracer("todoapp/backend/internal/todo")
+
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	blobs  blob.Store
+	events EventSink
+	now    func() time.Time
+
+	// blobMu keeps releaseBlobs from deleting a blob between an upload
+	// storing it and recording the attachment that refers to it.
+	blobMu sync.RWMutex
+}
+
+type RepositoryOption func(*Repository)
+
+// WithBlobStore stores attachment content in blobs. Without it attachments
+// cannot be added.
+func WithBlobStore(blobs blob.Store) RepositoryOption {
+	return func(r *Repository) { r.blobs = blobs }
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, opts ...RepositoryOption) *Repository {
+	r := &Repository{db: db, now: time.Now}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-func (r *Repository) List() ([]Item, error) {
-	rows, err := r.db.Query(`SELECT id, title, completed FROM todos ORDER BY id ASC`)
+// itemColumns selects an Item along with the user who completed it.
+const itemColumns = `todos.id, todos.title, todos.completed, todos.notes, todos.list_id, todos.completed_at,
+	todos.due, todos.priority, todos.uid, users.id, COALESCE(NULLIF(users.name, ''), users.email)
+	FROM todos LEFT JOIN users ON users.id = todos.completed_by`
+
+// List returns the todos on listID, or on the default list when listID is nil.
+func (r *Repository) List(ctx context.Context, listID *int64) (items []Item, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.List")
+	defer func() { endSpan(span, err) }()
+
+	const query = `SELECT ` + itemColumns + ` WHERE todos.list_id IS ? ORDER BY todos.id ASC`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "todos", query)
+	rows, err := r.db.QueryContext(ctx, query, nullInt64(listID))
 	if err != nil {
+		endSpan(stmtSpan, err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	items := make([]Item, 0)
+	items = make([]Item, 0)
 	for rows.Next() {
-		var item Item
-		if err := rows.Scan(&item.ID, &item.Title, &item.Completed); err != nil {
+		item, err := scanItem(rows)
+		if err != nil {
+			endSpan(stmtSpan, err)
 			return nil, err
 		}
 		items = append(items, item)
 	}
-	if err := rows.Err(); err != nil {
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	if err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
-func (r *Repository) Create(title string) (Item, error) {
-	result, err := r.db.Exec(`INSERT INTO todos (title, completed) VALUES (?, ?)`, title, false)
-	if err != nil {
-		return Item{}, err
-	}
+func (r *Repository) Get(ctx context.Context, id int64) (item Item, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.Get", trace.WithAttributes(attribute.Int64("todo.id", id)))
+	defer func() { endSpan(span, err) }()
 
-	id, err := result.LastInsertId()
+	return r.get(ctx, id)
+}
+
+// Create adds an open todo with the title, notes, list, due date and
+// priority of item.
+func (r *Repository) Create(ctx context.Context, item Item) (created Item, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.Create")
+	defer func() { endSpan(span, err) }()
+
+	item = Item{Title: item.Title, Notes: item.Notes, ListID: item.ListID, Due: item.Due, Priority: item.Priority}
+	var id int64
+	err = db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		id, err = r.insertTodo(ctx, tx, item, Clock{})
+		return err
+	})
 	if err != nil {
 		return Item{}, err
 	}
 
 	return Item{
-		ID:        id,
-		Title:     title,
-		Completed: false,
+		ID:       id,
+		Title:    item.Title,
+		Notes:    item.Notes,
+		ListID:   item.ListID,
+		Due:      item.Due,
+		Priority: item.Priority,
+		UID:      nativeUID(id),
 	}, nil
 }
 
-func (r *Repository) UpdateCompleted(id int64, completed bool) (Item, error) {
-	result, err := r.db.Exec(`UPDATE todos SET completed = ? WHERE id = ?`, completed, id)
+// UpdateCompleted sets the completed flag, attributing completion to
+// completedBy when given. Reopening an item clears the attribution.
+func (r *Repository) UpdateCompleted(ctx context.Context, id int64, completed bool, completedBy *int64) (item Item, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.UpdateCompleted", trace.WithAttributes(attribute.Int64("todo.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return r.update(ctx, id, Changes{Completed: &completed}, completedBy)
+}
+
+// Update edits the fields set in changes in one transaction. Completing the
+// item attributes it to completedBy when given; reopening it clears the
+// attribution.
+func (r *Repository) Update(ctx context.Context, id int64, changes Changes, completedBy *int64) (item Item, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.Update", trace.WithAttributes(attribute.Int64("todo.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return r.update(ctx, id, changes, completedBy)
+}
+
+func (r *Repository) update(ctx context.Context, id int64, changes Changes, completedBy *int64) (Item, error) {
+	set, args, fields := assignments(changes, r.now().UTC(), completedBy)
+	if len(set) == 0 {
+		return r.get(ctx, id)
+	}
+
+	err := db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		before, err := getItem(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := execStatement(ctx, tx, "UPDATE", "todos", `UPDATE todos SET `+strings.Join(set, ", ")+` WHERE id = ?`, append(args, id)...); err != nil {
+			return err
+		}
+		if err := recordChange(ctx, tx, id, r.serverClock(), fields...); err != nil {
+			return err
+		}
+		return r.publishCompletion(ctx, tx, before)
+	})
 	if err != nil {
 		return Item{}, err
 	}
 
-	updatedRows, err := result.RowsAffected()
+	return r.get(ctx, id)
+}
+
+// assignments returns the SET clauses and arguments for c, along with the
+// names of the fields they write. Completing stamps now and completedBy.
+func assignments(c Changes, now time.Time, completedBy *int64) (set []string, args []any, fields []string) {
+	if c.Title != nil {
+		set = append(set, "title = ?")
+		args = append(args, *c.Title)
+		fields = append(fields, fieldTitle)
+	}
+	if c.Completed != nil {
+		var (
+			completedAt sql.NullTime
+			by          sql.NullInt64
+		)
+		if *c.Completed {
+			completedAt, by = sql.NullTime{Time: now, Valid: true}, nullInt64(completedBy)
+		}
+		set = append(set, "completed = ?, completed_by = ?, completed_at = ?")
+		args = append(args, *c.Completed, by, completedAt)
+		fields = append(fields, fieldCompleted)
+	}
+	if c.Notes != nil {
+		set = append(set, "notes = ?")
+		args = append(args, *c.Notes)
+		fields = append(fields, fieldNotes)
+	}
+	if c.Priority != nil {
+		set = append(set, "priority = ?")
+		args = append(args, *c.Priority)
+		fields = append(fields, fieldPriority)
+	}
+	if c.SetDue {
+		set = append(set, "due = ?")
+		args = append(args, nullTime(c.Due))
+		fields = append(fields, fieldDue)
+	}
+	return set, args, fields
+}
+
+// Delete removes the todo along with its attachments, then deletes any
+// blobs no other attachment still refers to.
+func (r *Repository) Delete(ctx context.Context, id int64) (err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.Delete", trace.WithAttributes(attribute.Int64("todo.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	var blobKeys []string
+	err = db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		blobKeys, err = r.deleteTodo(ctx, tx, id)
+		return err
+	})
 	if err != nil {
-		return Item{}, err
+		return err
 	}
-	if updatedRows == 0 {
-		return Item{}, ErrNotFound
+
+	r.releaseBlobs(ctx, blobKeys...)
+	return nil
+}
+
+// insertTodo adds item, records the change with clock for fields and
+// publishes EventCreated. Every write that creates a todo goes through it.
+// A completed item keeps its CompletedAt, or is completed now, and its
+// CompletedBy; an empty UID leaves the todo with the one derived from its
+// ID.
+func (r *Repository) insertTodo(ctx context.Context, tx *sql.Tx, item Item, clock Clock, fields ...string) (int64, error) {
+	var (
+		completedAt sql.NullTime
+		completedBy sql.NullInt64
+		uid         sql.NullString
+	)
+	if item.Completed {
+		completedAt = sql.NullTime{Time: r.now().UTC(), Valid: true}
+		if item.CompletedAt != nil {
+			completedAt.Time = item.CompletedAt.UTC()
+		}
+		if item.CompletedBy != nil {
+			completedBy = sql.NullInt64{Int64: item.CompletedBy.ID, Valid: true}
+		}
+	}
+	if item.UID != "" {
+		uid = sql.NullString{String: item.UID, Valid: true}
+	}
+	result, err := execStatement(ctx, tx, "INSERT", "todos", `
+		INSERT INTO todos (title, completed, notes, list_id, completed_at, completed_by, due, priority, uid)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.Title, item.Completed, item.Notes, nullInt64(item.ListID), completedAt, completedBy, nullTime(item.Due), item.Priority, uid)
+	if err != nil {
+		return 0, err
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := recordChange(ctx, tx, id, clock, fields...); err != nil {
+		return 0, err
+	}
+	return id, r.publish(ctx, tx, EventCreated, id)
+}
+
+// deleteTodo publishes EventDeleted and removes todo id, leaving a
+// tombstone for sync clients. Its comments, attachments and reminders go
+// with it by foreign key; it returns the blob keys the attachments used.
+// Every write that deletes a todo goes through it.
+func (r *Repository) deleteTodo(ctx context.Context, tx *sql.Tx, id int64) ([]string, error) {
+	if err := r.publish(ctx, tx, EventDeleted, id); err != nil {
+		return nil, err
+	}
+	keys, err := attachmentBlobKeys(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordDeletion(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	// The field clocks have no foreign key, so they are deleted here.
+	if _, err := execStatement(ctx, tx, "DELETE", "todo_clocks", `DELETE FROM todo_clocks WHERE todo_id = ?`, id); err != nil {
+		return nil, err
+	}
+	result, err := execStatement(ctx, tx, "DELETE", "todos", `DELETE FROM todos WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireUpdated(result, ErrNotFound); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *Repository) get(ctx context.Context, id int64) (Item, error) {
+	return getItem(ctx, r.db, id)
+}
 
-	var item Item
-	if err := r.db.QueryRow(`SELECT id, title, completed FROM todos WHERE id = ?`, id).Scan(&item.ID, &item.Title, &item.Completed); err != nil {
+func getItem(ctx context.Context, conn queryRower, id int64) (Item, error) {
+	const query = `SELECT ` + itemColumns + ` WHERE todos.id = ?`
+	queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "todos", query)
+	item, err := scanItem(conn.QueryRowContext(queryCtx, query, id))
+	endSpan(stmtSpan, err)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Item{}, ErrNotFound
 		}
@@ -76,19 +305,94 @@ func (r *Repository) UpdateCompleted(id int64, completed bool) (Item, error) {
 	return item, nil
 }
 
-func (r *Repository) Delete(id int64) error {
-	result, err := r.db.Exec(`DELETE FROM todos WHERE id = ?`, id)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanItem(row rowScanner) (Item, error) {
+	var (
+		item          Item
+		listID        sql.NullInt64
+		completedAt   sql.NullTime
+		due           sql.NullTime
+		uid           sql.NullString
+		completedByID sql.NullInt64
+		completedBy   sql.NullString
+	)
+	err := row.Scan(&item.ID, &item.Title, &item.Completed, &item.Notes, &listID, &completedAt,
+		&due, &item.Priority, &uid, &completedByID, &completedBy)
 	if err != nil {
-		return err
+		return Item{}, err
+	}
+	item.UID = uid.String
+	if !uid.Valid {
+		item.UID = nativeUID(item.ID)
+	}
+	if due.Valid {
+		item.Due = &due.Time
+	}
+	if listID.Valid {
+		item.ListID = &listID.Int64
+	}
+	if completedAt.Valid {
+		item.CompletedAt = &completedAt.Time
+	}
+	if completedByID.Valid {
+		item.CompletedBy = &UserRef{ID: completedByID.Int64, Name: completedBy.String}
 	}
+	return item, nil
+}
 
-	deletedRows, err := result.RowsAffected()
-	if err != nil {
-		return err
+func nullInt64(v *int64) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
 	}
-	if deletedRows == 0 {
-		return ErrNotFound
+	return sql.NullInt64{Int64: *v, Valid: true}
+}
+
+func nullTime(v *time.Time) sql.NullTime {
+	if v == nil {
+		return sql.NullTime{}
 	}
+	return sql.NullTime{Time: v.UTC(), Valid: true}
+}
 
-	return nil
+func (r *Repository) exec(ctx context.Context, operation string, table string, query string, args ...any) (sql.Result, error) {
+	return execStatement(ctx, r.db, operation, table, query, args...)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func execStatement(ctx context.Context, conn execer, operation string, table string, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatementSpan(ctx, operation, table, query)
+	result, err := conn.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return result, err
+}
+
+func startStatementSpan(ctx context.Context, operation string, table string, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation+" "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", query),
+		),
+	)
+}
+
+// endSpan ends span, marking it failed unless err is nil or a not-found
+// error, which is an expected outcome rather than a fault.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrCommentNotFound) && !errors.Is(err, ErrAttachmentNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
 }
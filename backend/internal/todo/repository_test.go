@@ -1,33 +1,42 @@
 package todo
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
-	_ "modernc.org/sqlite"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"todoapp/backend/internal/blob"
+	"todoapp/backend/internal/db"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
 	t.Helper()
-	db, err := sql.Open("sqlite", ":memory:")
+	database, err := db.Open(":memory:")
 	if err != nil {
 		t.Fatalf("open sqlite: %v", err)
 	}
+	database.SetMaxOpenConns(1)
 
-	_, err = db.Exec(`
-		CREATE TABLE todos (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT NOT NULL,
-			completed INTEGER NOT NULL DEFAULT 0
-		);
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	_, err = database.Exec(`
 		INSERT INTO todos (title, completed) VALUES ('First', 0), ('Second', 1);
 	`)
 	if err != nil {
 		t.Fatalf("setup schema: %v", err)
 	}
 
-	return db
+	return database
 }
 
 func TestRepositoryList(t *testing.T) {
@@ -35,7 +44,7 @@ func TestRepositoryList(t *testing.T) {
 	defer db.Close()
 
 	repo := NewRepository(db)
-	items, err := repo.List()
+	items, err := repo.List(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("list todos: %v", err)
 	}
@@ -56,7 +65,7 @@ func TestRepositoryCreate(t *testing.T) {
 	defer db.Close()
 
 	repo := NewRepository(db)
-	item, err := repo.Create("Created")
+	item, err := repo.Create(context.Background(), Item{Title: "Created"})
 	if err != nil {
 		t.Fatalf("create todo: %v", err)
 	}
@@ -74,7 +83,7 @@ func TestRepositoryUpdateCompleted(t *testing.T) {
 	defer db.Close()
 
 	repo := NewRepository(db)
-	item, err := repo.UpdateCompleted(1, true)
+	item, err := repo.UpdateCompleted(context.Background(), 1, true, nil)
 	if err != nil {
 		t.Fatalf("update completed: %v", err)
 	}
@@ -84,12 +93,130 @@ func TestRepositoryUpdateCompleted(t *testing.T) {
 	}
 }
 
+// insertUser adds the user that a test's principal or attribution names,
+// which foreign keys require to exist.
+func insertUser(t *testing.T, database *sql.DB, id int64) {
+	t.Helper()
+	if _, err := database.Exec(`
+		INSERT INTO users (id, issuer, subject, email, name, created_at, updated_at)
+		VALUES (?, 'https://idp.example.com', ?, '', '', ?, ?)`, id, fmt.Sprintf("u%d", id), time.Now(), time.Now()); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+}
+
+func TestRepositoryListsAreSeparate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO lists (id, name, created_at) VALUES (1, 'Household', ?)`, time.Now()); err != nil {
+		t.Fatalf("insert list: %v", err)
+	}
+
+	repo := NewRepository(db)
+	listID := int64(1)
+	created, err := repo.Create(context.Background(), Item{Title: "Shared", ListID: &listID})
+	if err != nil {
+		t.Fatalf("create todo: %v", err)
+	}
+
+	shared, err := repo.List(context.Background(), &listID)
+	if err != nil {
+		t.Fatalf("list shared todos: %v", err)
+	}
+	if len(shared) != 1 || shared[0].ID != created.ID || shared[0].ListID == nil || *shared[0].ListID != 1 {
+		t.Fatalf("unexpected shared todos: %#v", shared)
+	}
+
+	defaults, err := repo.List(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("list default todos: %v", err)
+	}
+	if len(defaults) != 2 {
+		t.Fatalf("expected shared todo to stay off the default list, got %#v", defaults)
+	}
+}
+
+func TestRepositoryUpdateCompleted_RecordsAttribution(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	if _, err := db.Exec(`
+		INSERT INTO users (id, issuer, subject, email, name, created_at, updated_at)
+		VALUES (7, 'https://idp.example.com', 'u7', 'bob@example.com', '', ?, ?)`, time.Now(), time.Now()); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	completedAt := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	repo := NewRepository(db)
+	repo.now = func() time.Time { return completedAt }
+	userID := int64(7)
+
+	item, err := repo.UpdateCompleted(context.Background(), 1, true, &userID)
+	if err != nil {
+		t.Fatalf("update completed: %v", err)
+	}
+	if item.CompletedBy == nil || item.CompletedBy.ID != 7 || item.CompletedBy.Name != "bob@example.com" {
+		t.Fatalf("unexpected attribution: %#v", item.CompletedBy)
+	}
+	if item.CompletedAt == nil || !item.CompletedAt.Equal(completedAt) {
+		t.Fatalf("unexpected completedAt: %v", item.CompletedAt)
+	}
+
+	item, err = repo.UpdateCompleted(context.Background(), 1, false, &userID)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if item.CompletedBy != nil || item.CompletedAt != nil {
+		t.Fatalf("expected reopening to clear attribution, got %#v", item)
+	}
+}
+
+func TestRepositoryUpdateCompleted_RecordsStatementSpans(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	repo := NewRepository(db)
+	if _, err := repo.UpdateCompleted(context.Background(), 1, true, nil); err != nil {
+		t.Fatalf("update completed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	parent := spans[len(spans)-1]
+	if parent.Name() != "todo.Repository.UpdateCompleted" {
+		t.Fatalf("unexpected parent span: %q", parent.Name())
+	}
+
+	statements := map[string]string{}
+	for _, child := range spans[:len(spans)-1] {
+		if child.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("expected %q to be a child of the repository span", child.Name())
+		}
+		for _, attr := range child.Attributes() {
+			if attr.Key == "db.statement" {
+				statements[child.Name()] = attr.Value.AsString()
+			}
+		}
+	}
+	if len(statements) != 4 || statements["REPLACE todo_changes"] == "" || statements["INSERT todo_clocks"] == "" {
+		t.Fatalf("expected the update, clock, change and select statements, got %#v", statements)
+	}
+	if statements["UPDATE todos"] != `UPDATE todos SET completed = ?, completed_by = ?, completed_at = ? WHERE id = ?` {
+		t.Fatalf("unexpected UPDATE statement attribute: %#v", statements)
+	}
+	if !strings.HasPrefix(statements["SELECT todos"], `SELECT todos.id, todos.title, todos.completed`) {
+		t.Fatalf("unexpected SELECT statement attribute: %#v", statements)
+	}
+}
+
 func TestRepositoryUpdateCompleted_NotFound(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
 	repo := NewRepository(db)
-	_, err := repo.UpdateCompleted(999, true)
+	_, err := repo.UpdateCompleted(context.Background(), 999, true, nil)
 	if !errors.Is(err, ErrNotFound) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
@@ -100,11 +227,11 @@ func TestRepositoryDelete(t *testing.T) {
 	defer db.Close()
 
 	repo := NewRepository(db)
-	if err := repo.Delete(1); err != nil {
+	if err := repo.Delete(context.Background(), 1); err != nil {
 		t.Fatalf("delete todo: %v", err)
 	}
 
-	items, err := repo.List()
+	items, err := repo.List(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("list todos after delete: %v", err)
 	}
@@ -116,13 +243,223 @@ func TestRepositoryDelete(t *testing.T) {
 	}
 }
 
+func TestRepositoryDelete_RemovesComments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	repo := NewRepository(db)
+	parent, err := repo.CreateComment(ctx, Comment{TodoID: 1, AuthorKey: "anonymous", AuthorName: "anonymous", Body: "first"})
+	if err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	if _, err := repo.CreateComment(ctx, Comment{TodoID: 1, ParentID: &parent.ID, AuthorKey: "anonymous", AuthorName: "anonymous", Body: "reply"}); err != nil {
+		t.Fatalf("create reply: %v", err)
+	}
+	if _, err := repo.CreateComment(ctx, Comment{TodoID: 2, AuthorKey: "anonymous", AuthorName: "anonymous", Body: "kept"}); err != nil {
+		t.Fatalf("create comment on other todo: %v", err)
+	}
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("delete todo: %v", err)
+	}
+
+	var orphaned, kept int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM comments WHERE todo_id = 1`).Scan(&orphaned); err != nil {
+		t.Fatalf("count comments: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM comments WHERE todo_id = 2`).Scan(&kept); err != nil {
+		t.Fatalf("count comments: %v", err)
+	}
+	if orphaned != 0 || kept != 1 {
+		t.Fatalf("expected only the other todo's comment to remain, got %d orphaned and %d kept", orphaned, kept)
+	}
+}
+
 func TestRepositoryDelete_NotFound(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
 	repo := NewRepository(db)
-	err := repo.Delete(999)
+	err := repo.Delete(context.Background(), 999)
 	if !errors.Is(err, ErrNotFound) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
+
+func TestRepositoryDelete_RemovesOrphanedBlobs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	blobs := blob.NewMemory()
+	repo := NewRepository(db, WithBlobStore(blobs))
+	ctx := context.Background()
+
+	add := func(todoID int64, content string) Attachment {
+		t.Helper()
+		attachment, err := repo.AddAttachment(ctx, Attachment{TodoID: todoID, Filename: "f.txt", ContentType: "text/plain", UploaderKey: "api_key:1"},
+			strings.NewReader(content), 1024)
+		if err != nil {
+			t.Fatalf("add attachment: %v", err)
+		}
+		return attachment
+	}
+	shared := add(1, "shared")
+	add(1, "only on first")
+	if other := add(2, "shared"); other.BlobKey != shared.BlobKey {
+		t.Fatalf("expected identical content to share a blob")
+	}
+	if blobs.Len() != 2 {
+		t.Fatalf("expected 2 blobs, got %d", blobs.Len())
+	}
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("delete todo: %v", err)
+	}
+	if blobs.Len() != 1 {
+		t.Fatalf("expected only the shared blob to remain, got %d", blobs.Len())
+	}
+	if remaining, _ := repo.ListAttachments(ctx, 1); len(remaining) != 0 {
+		t.Fatalf("expected attachments of the deleted todo to be gone, got %d", len(remaining))
+	}
+
+	if err := repo.Delete(ctx, 2); err != nil {
+		t.Fatalf("delete todo: %v", err)
+	}
+	if blobs.Len() != 0 {
+		t.Fatalf("expected no blobs left, got %d", blobs.Len())
+	}
+}
+
+// pausingBlobStore holds each Put after the content is stored until resume
+// is closed, the window in which a concurrent release used to delete it.
+type pausingBlobStore struct {
+	*blob.Memory
+	stored chan struct{}
+	resume chan struct{}
+}
+
+func (s *pausingBlobStore) Put(ctx context.Context, content io.Reader) (string, int64, error) {
+	key, size, err := s.Memory.Put(ctx, content)
+	close(s.stored)
+	<-s.resume
+	return key, size, err
+}
+
+func TestRepositoryDeleteAttachment_KeepsBlobOfConcurrentUpload(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	memory := blob.NewMemory()
+	ctx := context.Background()
+	meta := Attachment{TodoID: 1, Filename: "f.txt", ContentType: "text/plain", UploaderKey: "api_key:1"}
+
+	existing, err := NewRepository(db, WithBlobStore(memory)).AddAttachment(ctx, meta, strings.NewReader("same"), 1024)
+	if err != nil {
+		t.Fatalf("add attachment: %v", err)
+	}
+
+	blobs := &pausingBlobStore{Memory: memory, stored: make(chan struct{}), resume: make(chan struct{})}
+	repo := NewRepository(db, WithBlobStore(blobs))
+	uploaded := make(chan error, 1)
+	go func() {
+		_, err := repo.AddAttachment(ctx, meta, strings.NewReader("same"), 1024)
+		uploaded <- err
+	}()
+	<-blobs.stored
+
+	deleted := make(chan error, 1)
+	go func() { deleted <- repo.DeleteAttachment(ctx, existing.ID) }()
+	// Give the delete time to reach releaseBlobs while the upload is paused.
+	time.Sleep(50 * time.Millisecond)
+	close(blobs.resume)
+
+	if err := <-uploaded; err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := <-deleted; err != nil {
+		t.Fatalf("delete attachment: %v", err)
+	}
+	content, err := memory.Open(ctx, existing.BlobKey)
+	if err != nil {
+		t.Fatalf("expected the uploaded attachment's blob to remain: %v", err)
+	}
+	content.Close()
+}
+
+func TestRepositoryAddAttachment_Rejected(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	blobs := blob.NewMemory()
+	repo := NewRepository(db, WithBlobStore(blobs))
+	ctx := context.Background()
+
+	if _, err := repo.AddAttachment(ctx, Attachment{TodoID: 1}, strings.NewReader("too long"), 4); !errors.Is(err, ErrAttachmentTooLarge) {
+		t.Fatalf("expected ErrAttachmentTooLarge, got %v", err)
+	}
+	if _, err := repo.AddAttachment(ctx, Attachment{TodoID: 999}, strings.NewReader("data"), 4); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if blobs.Len() != 0 {
+		t.Fatalf("expected rejected attachments to leave no blobs, got %d", blobs.Len())
+	}
+}
+
+func TestRepositoryUpdate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	due := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	title, notes, priority := "File taxes", "bring receipts", 3
+	item, err := repo.Update(ctx, 1, Changes{Title: &title, Notes: &notes, Priority: &priority, SetDue: true, Due: &due}, nil)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if item.Title != title || item.Notes != notes || item.Priority != 3 || item.Due == nil || !item.Due.Equal(due) || item.UID != "todo-1@todoapp" {
+		t.Fatalf("unexpected item: %#v", item)
+	}
+
+	item, err = repo.Update(ctx, 1, Changes{SetDue: true}, nil)
+	if err != nil {
+		t.Fatalf("clear due: %v", err)
+	}
+	if item.Due != nil || item.Notes != notes || item.Priority != 3 {
+		t.Fatalf("expected only the due date to be cleared: %#v", item)
+	}
+
+	if _, err := repo.Update(ctx, 999, Changes{Notes: &notes}, nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// failingSink rejects every event, failing the write that raised it.
+type failingSink struct{}
+
+func (failingSink) Publish(context.Context, *sql.Tx, Event) error {
+	return errors.New("sink unavailable")
+}
+
+func TestRepositoryUpdate_CompletesInTheSameTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	insertUser(t, db, 7)
+	ctx := context.Background()
+
+	title, completed, userID := "Done and renamed", true, int64(7)
+	item, err := NewRepository(db).Update(ctx, 1, Changes{Title: &title, Completed: &completed}, &userID)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if item.Title != title || !item.Completed || item.CompletedAt == nil || item.CompletedBy == nil || item.CompletedBy.ID != 7 {
+		t.Fatalf("unexpected item: %#v", item)
+	}
+
+	renamed, reopened := "Renamed again", false
+	repo := NewRepository(db, WithEvents(failingSink{}))
+	if _, err := repo.Update(ctx, 1, Changes{Title: &renamed, Completed: &reopened}, nil); err == nil {
+		t.Fatal("expected the failed event to fail the update")
+	}
+	if item, _ := repo.Get(ctx, 1); item.Title != title || !item.Completed {
+		t.Fatalf("expected the rename to roll back with the completion: %#v", item)
+	}
+}
@@ -0,0 +1,368 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/backend/internal/db"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fields merged independently by last-writer-wins.
+const (
+	fieldTitle     = "title"
+	fieldNotes     = "notes"
+	fieldCompleted = "completed"
+	fieldDue       = "due"
+	fieldPriority  = "priority"
+)
+
+var syncedFields = []string{fieldTitle, fieldNotes, fieldCompleted, fieldDue, fieldPriority}
+
+func (r *Repository) serverClock() Clock {
+	return Clock{Time: r.now().UnixMilli()}
+}
+
+// ChangesSince returns the todos on listID changed or deleted after the
+// sync token since, and the token to pass next time. A token of zero or
+// less asks for every todo on the list instead.
+func (r *Repository) ChangesSince(ctx context.Context, listID *int64, since int64) (changes ChangeSet, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.ChangesSince", trace.WithAttributes(attribute.Int64("todo.sync.since", since)))
+	defer func() { endSpan(span, err) }()
+
+	err = db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		const tokenQuery = `SELECT COALESCE(MAX(seq), 0) FROM todo_changes`
+		queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "todo_changes", tokenQuery)
+		err := tx.QueryRowContext(queryCtx, tokenQuery).Scan(&changes.Token)
+		endSpan(stmtSpan, err)
+		if err != nil {
+			return err
+		}
+
+		if since <= 0 {
+			changes.Full = true
+			changes.Items, err = queryItems(ctx, tx,
+				`SELECT `+itemColumns+` WHERE todos.list_id IS ? ORDER BY todos.id ASC`, nullInt64(listID))
+			changes.Deleted = []Tombstone{}
+			return err
+		}
+
+		changes.Items, err = queryItems(ctx, tx, `SELECT `+itemColumns+`
+			JOIN todo_changes ON todo_changes.todo_id = todos.id
+			WHERE todos.list_id IS ? AND todo_changes.seq > ? ORDER BY todo_changes.seq ASC`, nullInt64(listID), since)
+		if err != nil {
+			return err
+		}
+		changes.Deleted, err = tombstonesSince(ctx, tx, listID, since)
+		return err
+	})
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	return changes, nil
+}
+
+// ApplyMutations applies queued client mutations to listID in order, in one
+// transaction. Each field is written only if the mutation's clock is not
+// older than the field's last write, so replaying a batch is harmless and
+// every replica converges on the same values. Deletions win over edits:
+// a mutation for a deleted todo is dropped.
+func (r *Repository) ApplyMutations(ctx context.Context, listID *int64, mutations []Mutation, completedBy *int64) (results []MutationResult, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.ApplyMutations", trace.WithAttributes(attribute.Int("todo.sync.mutations", len(mutations))))
+	defer func() { endSpan(span, err) }()
+
+	var blobKeys []string
+	err = db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		results = make([]MutationResult, len(mutations))
+		for i, mutation := range mutations {
+			result, keys, err := r.applyMutation(ctx, tx, listID, mutation, completedBy)
+			if err != nil {
+				return err
+			}
+			results[i] = result
+			blobKeys = append(blobKeys, keys...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.releaseBlobs(ctx, blobKeys...)
+	return results, nil
+}
+
+func (r *Repository) applyMutation(ctx context.Context, tx *sql.Tx, listID *int64, m Mutation, completedBy *int64) (MutationResult, []string, error) {
+	id, err := findByUID(ctx, tx, listID, m.UID)
+	if errors.Is(err, ErrNotFound) {
+		deleted, err := isDeleted(ctx, tx, listID, m.UID)
+		switch {
+		case err != nil:
+			return MutationResult{}, nil, err
+		case deleted || m.Delete:
+			return MutationResult{Status: MutationDeleted}, nil, nil
+		case m.Title == nil:
+			return MutationResult{Status: MutationInvalid}, nil, nil
+		}
+		id, err := r.insertMutation(ctx, tx, listID, m, completedBy)
+		if err != nil {
+			return MutationResult{}, nil, err
+		}
+		item, err := getItem(ctx, tx, id)
+		return MutationResult{Status: MutationApplied, Item: &item}, nil, err
+	}
+	if err != nil {
+		return MutationResult{}, nil, err
+	}
+	if m.Delete {
+		keys, err := r.deleteTodo(ctx, tx, id)
+		return MutationResult{Status: MutationDeleted}, keys, err
+	}
+
+	current, err := getItem(ctx, tx, id)
+	if err != nil {
+		return MutationResult{}, nil, err
+	}
+	clocks, err := fieldClocks(ctx, tx, id)
+	if err != nil {
+		return MutationResult{}, nil, err
+	}
+
+	var (
+		set     []string
+		args    []any
+		applied []string
+		lost    int
+	)
+	apply := func(field string, clause string, values ...any) {
+		if m.Clock.Before(clocks[field]) {
+			lost++
+			return
+		}
+		set = append(set, clause)
+		args = append(args, values...)
+		applied = append(applied, field)
+	}
+	if m.Title != nil {
+		apply(fieldTitle, "title = ?", *m.Title)
+	}
+	if m.Notes != nil {
+		apply(fieldNotes, "notes = ?", *m.Notes)
+	}
+	if m.Completed != nil {
+		completedAt, by := completion(*m.Completed, current, m.Clock, completedBy)
+		apply(fieldCompleted, "completed = ?, completed_at = ?, completed_by = ?", *m.Completed, completedAt, by)
+	}
+	if m.SetDue {
+		apply(fieldDue, "due = ?", nullTime(m.Due))
+	}
+	if m.Priority != nil {
+		apply(fieldPriority, "priority = ?", *m.Priority)
+	}
+
+	if len(set) > 0 {
+		if _, err := execStatement(ctx, tx, "UPDATE", "todos", `UPDATE todos SET `+strings.Join(set, ", ")+` WHERE id = ?`, append(args, id)...); err != nil {
+			return MutationResult{}, nil, err
+		}
+		if err := recordChange(ctx, tx, id, m.Clock, applied...); err != nil {
+			return MutationResult{}, nil, err
+		}
+		if err := r.publishCompletion(ctx, tx, current); err != nil {
+			return MutationResult{}, nil, err
+		}
+	}
+
+	status := MutationApplied
+	switch {
+	case lost > 0 && len(applied) == 0:
+		status = MutationIgnored
+	case lost > 0:
+		status = MutationMerged
+	}
+	item, err := getItem(ctx, tx, id)
+	return MutationResult{Status: status, Item: &item}, nil, err
+}
+
+// insertMutation creates the todo a mutation describes, keeping the
+// client's UID.
+func (r *Repository) insertMutation(ctx context.Context, tx *sql.Tx, listID *int64, m Mutation, completedBy *int64) (int64, error) {
+	item := Item{Title: *m.Title, ListID: listID, Due: m.Due, UID: m.UID}
+	fields := []string{fieldTitle}
+	if m.Notes != nil {
+		item.Notes = *m.Notes
+		fields = append(fields, fieldNotes)
+	}
+	if m.Priority != nil {
+		item.Priority = *m.Priority
+		fields = append(fields, fieldPriority)
+	}
+	if m.SetDue {
+		fields = append(fields, fieldDue)
+	}
+	if m.Completed != nil {
+		fields = append(fields, fieldCompleted)
+		if *m.Completed {
+			completedAt := time.UnixMilli(m.Clock.Time).UTC()
+			item.Completed, item.CompletedAt = true, &completedAt
+			if completedBy != nil {
+				item.CompletedBy = &UserRef{ID: *completedBy}
+			}
+		}
+	}
+	return r.insertTodo(ctx, tx, item, m.Clock, fields...)
+}
+
+// completion returns the completion time and attribution after setting
+// completed on current. Completing an open todo records the mutation's
+// time; a todo that stays completed keeps what it had.
+func completion(completed bool, current Item, clock Clock, completedBy *int64) (sql.NullTime, sql.NullInt64) {
+	switch {
+	case !completed:
+		return sql.NullTime{}, sql.NullInt64{}
+	case current.Completed:
+		var by sql.NullInt64
+		if current.CompletedBy != nil {
+			by = sql.NullInt64{Int64: current.CompletedBy.ID, Valid: true}
+		}
+		return nullTime(current.CompletedAt), by
+	}
+	return sql.NullTime{Time: time.UnixMilli(clock.Time).UTC(), Valid: true}, nullInt64(completedBy)
+}
+
+// recordChange stamps fields of todo id with clock and gives the todo the
+// next change sequence number.
+func recordChange(ctx context.Context, tx *sql.Tx, id int64, clock Clock, fields ...string) error {
+	if len(fields) > 0 {
+		placeholders := make([]string, len(fields))
+		args := make([]any, 0, 4*len(fields))
+		for i, field := range fields {
+			placeholders[i] = "(?, ?, ?, ?)"
+			args = append(args, id, field, clock.Time, clock.ClientID)
+		}
+		_, err := execStatement(ctx, tx, "INSERT", "todo_clocks", `
+			INSERT INTO todo_clocks (todo_id, field, time, client_id) VALUES `+strings.Join(placeholders, ", ")+`
+			ON CONFLICT (todo_id, field) DO UPDATE SET time = excluded.time, client_id = excluded.client_id`, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return writeChange(ctx, tx, id, false)
+}
+
+// recordDeletion turns the change record of todo id into a tombstone. It
+// must run before the todo row is deleted.
+func recordDeletion(ctx context.Context, tx *sql.Tx, id int64) error {
+	return writeChange(ctx, tx, id, true)
+}
+
+// writeChange replaces the todo's change record. REPLACE inserts a new row,
+// so AUTOINCREMENT hands out a sequence number higher than any before.
+func writeChange(ctx context.Context, tx *sql.Tx, id int64, deleted bool) error {
+	_, err := execStatement(ctx, tx, "REPLACE", "todo_changes", `
+		REPLACE INTO todo_changes (todo_id, list_id, uid, deleted)
+		SELECT id, list_id, uid, ? FROM todos WHERE id = ?`, deleted, id)
+	return err
+}
+
+func fieldClocks(ctx context.Context, tx *sql.Tx, id int64) (map[string]Clock, error) {
+	const query = `SELECT field, time, client_id FROM todo_clocks WHERE todo_id = ?`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "todo_clocks", query)
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	clocks := make(map[string]Clock)
+	for rows.Next() {
+		var (
+			field string
+			clock Clock
+		)
+		if err := rows.Scan(&field, &clock.Time, &clock.ClientID); err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		clocks[field] = clock
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	return clocks, err
+}
+
+// isDeleted reports whether a todo with uid was deleted from listID.
+func isDeleted(ctx context.Context, tx *sql.Tx, listID *int64, uid string) (bool, error) {
+	var nativeID int64
+	if match := nativeUIDPattern.FindStringSubmatch(uid); match != nil {
+		nativeID, _ = strconv.ParseInt(match[1], 10, 64)
+	}
+
+	const query = `SELECT COUNT(*) FROM todo_changes
+		WHERE deleted = 1 AND list_id IS ? AND (uid = ? OR (uid IS NULL AND todo_id = ?))`
+	queryCtx, stmtSpan := startStatementSpan(ctx, "SELECT", "todo_changes", query)
+	var count int
+	err := tx.QueryRowContext(queryCtx, query, nullInt64(listID), uid, nativeID).Scan(&count)
+	endSpan(stmtSpan, err)
+	return count > 0, err
+}
+
+func tombstonesSince(ctx context.Context, tx *sql.Tx, listID *int64, since int64) ([]Tombstone, error) {
+	const query = `SELECT todo_id, uid FROM todo_changes WHERE deleted = 1 AND list_id IS ? AND seq > ? ORDER BY seq ASC`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "todo_changes", query)
+	rows, err := tx.QueryContext(ctx, query, nullInt64(listID), since)
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tombstones := make([]Tombstone, 0)
+	for rows.Next() {
+		var (
+			tombstone Tombstone
+			uid       sql.NullString
+		)
+		if err := rows.Scan(&tombstone.ID, &uid); err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		tombstone.UID = uid.String
+		if !uid.Valid {
+			tombstone.UID = nativeUID(tombstone.ID)
+		}
+		tombstones = append(tombstones, tombstone)
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	return tombstones, err
+}
+
+func queryItems(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]Item, error) {
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "todos", query)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]Item, 0)
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	return items, err
+}
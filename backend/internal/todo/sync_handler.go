@@ -0,0 +1,210 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/respond"
+)
+
+const (
+	maxSyncMutations  = 500
+	maxClientIDLength = 64
+	maxUIDLength      = 255
+)
+
+type SyncStore interface {
+	ChangesSince(ctx context.Context, listID *int64, since int64) (ChangeSet, error)
+	ApplyMutations(ctx context.Context, listID *int64, mutations []Mutation, completedBy *int64) ([]MutationResult, error)
+}
+
+// WithSync enables the delta sync endpoints.
+func WithSync(s SyncStore) Option {
+	return func(h *Handler) { h.sync = s }
+}
+
+type syncResponse struct {
+	Token   string      `json:"token"`
+	Full    bool        `json:"full"`
+	Items   []Item      `json:"items"`
+	Deleted []Tombstone `json:"deleted"`
+}
+
+// PullChanges returns the todos on the default list, or the list given by
+// ?list=, that changed since ?since=, plus the token for the next pull.
+// Without a token every todo is returned and full is set, telling the
+// client to replace what it has.
+func (h *Handler) PullChanges(w http.ResponseWriter, r *http.Request) {
+	if h.sync == nil {
+		http.NotFound(w, r)
+		return
+	}
+	var since int64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		var err error
+		if since, err = strconv.ParseInt(raw, 10, 64); err != nil || since < 0 {
+			http.Error(w, "invalid sync token", http.StatusBadRequest)
+			return
+		}
+	}
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, false, "todo list not found") {
+		return
+	}
+
+	changes, err := h.sync.ChangesSince(r.Context(), listID, since)
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch changes", err)
+		return
+	}
+	if since > changes.Token {
+		// The token comes from another database, e.g. one restored from
+		// an older backup; the client must start over.
+		http.Error(w, "sync token is unknown; sync again without a token", http.StatusGone)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, syncResponse{
+		Token:   strconv.FormatInt(changes.Token, 10),
+		Full:    changes.Full,
+		Items:   changes.Items,
+		Deleted: changes.Deleted,
+	})
+}
+
+type pushRequest struct {
+	ClientID  string            `json:"clientId"`
+	Mutations []mutationRequest `json:"mutations"`
+}
+
+// mutationRequest is one queued change. Time is when the client made it,
+// in milliseconds since the Unix epoch, and orders it against other writes.
+type mutationRequest struct {
+	ID     string         `json:"id"`
+	Op     string         `json:"op"`
+	UID    string         `json:"uid"`
+	Time   int64          `json:"time"`
+	Fields mutationFields `json:"fields"`
+}
+
+type mutationFields struct {
+	Title     *string `json:"title"`
+	Notes     *string `json:"notes"`
+	Completed *bool   `json:"completed"`
+	Priority  *int    `json:"priority"`
+	// Due is left alone when absent and cleared by an explicit null.
+	Due OptionalDue `json:"due"`
+}
+
+type pushResponse struct {
+	Results []mutationResponse `json:"results"`
+}
+
+type mutationResponse struct {
+	ID     string `json:"id,omitempty"`
+	UID    string `json:"uid"`
+	Status string `json:"status"`
+	Item   *Item  `json:"item,omitempty"`
+}
+
+// PushChanges applies mutations a client queued, possibly while offline, to
+// the default list or the list given by ?list=. Either every mutation is
+// valid and the batch is applied, or none is. Conflicts are settled per
+// field: the write with the later time wins, the client ID breaks ties,
+// and deletions win over edits.
+func (h *Handler) PushChanges(w http.ResponseWriter, r *http.Request) {
+	if h.sync == nil {
+		http.NotFound(w, r)
+		return
+	}
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, true, "todo list not found") {
+		return
+	}
+	var req pushRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	clientID := strings.TrimSpace(req.ClientID)
+	if clientID == "" || utf8.RuneCountInString(clientID) > maxClientIDLength {
+		http.Error(w, fmt.Sprintf("clientId is required and must be at most %d characters", maxClientIDLength), http.StatusBadRequest)
+		return
+	}
+	if len(req.Mutations) > maxSyncMutations {
+		http.Error(w, fmt.Sprintf("at most %d mutations may be sent at once", maxSyncMutations), http.StatusBadRequest)
+		return
+	}
+	mutations := make([]Mutation, len(req.Mutations))
+	for i, m := range req.Mutations {
+		mutation, err := h.mutation(m, clientID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("mutation %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		mutations[i] = mutation
+	}
+
+	results, err := h.sync.ApplyMutations(r.Context(), listID, mutations, auth.UserID(r.Context()))
+	if err != nil {
+		respond.ServerError(w, r, "failed to apply changes", err)
+		return
+	}
+	response := pushResponse{Results: make([]mutationResponse, len(results))}
+	for i, result := range results {
+		response.Results[i] = mutationResponse{ID: req.Mutations[i].ID, UID: mutations[i].UID, Status: result.Status, Item: result.Item}
+	}
+	respond.JSON(w, r, http.StatusOK, response)
+}
+
+// mutation validates m with the same rules as the REST endpoints.
+func (h *Handler) mutation(m mutationRequest, clientID string) (Mutation, error) {
+	uid := strings.TrimSpace(m.UID)
+	switch {
+	case uid == "" || len(uid) > maxUIDLength:
+		return Mutation{}, fmt.Errorf("uid is required and must be at most %d bytes", maxUIDLength)
+	case m.Time <= 0:
+		return Mutation{}, errors.New("time must be a positive number of milliseconds")
+	case m.Op != "upsert" && m.Op != "delete":
+		return Mutation{}, errors.New(`op must be "upsert" or "delete"`)
+	}
+	mutation := Mutation{UID: uid, Delete: m.Op == "delete", Clock: Clock{Time: m.Time, ClientID: clientID}}
+	if mutation.Delete {
+		return mutation, nil
+	}
+
+	fields := m.Fields
+	if fields.Title != nil {
+		title := strings.TrimSpace(*fields.Title)
+		if title == "" {
+			return Mutation{}, errors.New("title must not be empty")
+		}
+		if utf8.RuneCountInString(title) > h.maxTitleLength {
+			return Mutation{}, fmt.Errorf("title must be at most %d characters", h.maxTitleLength)
+		}
+		mutation.Title = &title
+	}
+	if fields.Notes != nil {
+		if err := CheckNotes(*fields.Notes); err != nil {
+			return Mutation{}, err
+		}
+	}
+	if fields.Priority != nil {
+		if err := CheckPriority(*fields.Priority); err != nil {
+			return Mutation{}, err
+		}
+	}
+	mutation.Changes = Changes{
+		Completed: fields.Completed,
+		Notes:     fields.Notes,
+		Priority:  fields.Priority,
+		SetDue:    fields.Due.Set,
+		Due:       fields.Due.Value.Time(),
+	}
+	return mutation, nil
+}
@@ -0,0 +1,175 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pullChanges(t *testing.T, h *Handler, query string) syncResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.PullChanges(rr, httptest.NewRequest(http.MethodGet, "/api/sync"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%q: expected status 200, got %d: %s", query, rr.Code, rr.Body.String())
+	}
+	var changes syncResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &changes); err != nil {
+		t.Fatalf("decode changes: %v", err)
+	}
+	return changes
+}
+
+func pushChanges(t *testing.T, h *Handler, body string) []mutationResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.PushChanges(rr, httptest.NewRequest(http.MethodPost, "/api/sync", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response pushResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode results: %v", err)
+	}
+	return response.Results
+}
+
+func TestPullChanges_FullThenIncremental(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithSync(repo))
+	ctx := context.Background()
+
+	kept, _ := repo.Create(ctx, Item{Title: "Kept"})
+	removed, _ := repo.Create(ctx, Item{Title: "Removed"})
+	repo.Create(ctx, Item{Title: "Untouched"})
+
+	full := pullChanges(t, h, "")
+	if !full.Full || len(full.Items) != 5 || len(full.Deleted) != 0 {
+		t.Fatalf("expected a full snapshot, got %#v", full)
+	}
+
+	if _, err := repo.UpdateCompleted(ctx, kept.ID, true, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := repo.Delete(ctx, removed.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	delta := pullChanges(t, h, "?since="+full.Token)
+	if delta.Full || len(delta.Items) != 1 || delta.Items[0].ID != kept.ID || !delta.Items[0].Completed {
+		t.Fatalf("expected only the completed todo, got %#v", delta)
+	}
+	if len(delta.Deleted) != 1 || delta.Deleted[0].ID != removed.ID || delta.Deleted[0].UID != removed.UID {
+		t.Fatalf("expected a tombstone for the deleted todo, got %#v", delta.Deleted)
+	}
+	if delta.Token == full.Token {
+		t.Fatal("expected the token to advance")
+	}
+
+	if again := pullChanges(t, h, "?since="+delta.Token); len(again.Items) != 0 || len(again.Deleted) != 0 || again.Token != delta.Token {
+		t.Fatalf("expected no changes, got %#v", again)
+	}
+
+	for query, status := range map[string]int{"?since=abc": http.StatusBadRequest, "?since=-1": http.StatusBadRequest, "?since=999": http.StatusGone} {
+		rr := httptest.NewRecorder()
+		h.PullChanges(rr, httptest.NewRequest(http.MethodGet, "/api/sync"+query, nil))
+		if rr.Code != status {
+			t.Fatalf("%q: expected status %d, got %d", query, status, rr.Code)
+		}
+	}
+}
+
+func TestPushChanges_FieldLevelLastWriterWins(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithSync(repo))
+
+	results := pushChanges(t, h, `{"clientId":"phone","mutations":[
+		{"id":"1","op":"upsert","uid":"a","time":1000,"fields":{"title":"Buy milk","priority":1}}]}`)
+	if results[0].Status != MutationApplied || results[0].Item == nil || results[0].Item.UID != "a" || results[0].Item.Priority != 1 {
+		t.Fatalf("expected the todo to be created, got %#v", results[0])
+	}
+
+	// The laptop renamed the todo later than the phone did, but set the
+	// priority earlier; each field keeps its newest value.
+	pushChanges(t, h, `{"clientId":"laptop","mutations":[
+		{"op":"upsert","uid":"a","time":3000,"fields":{"title":"Buy oat milk"}}]}`)
+	results = pushChanges(t, h, `{"clientId":"phone","mutations":[
+		{"op":"upsert","uid":"a","time":2000,"fields":{"title":"Buy milk!","priority":3}}]}`)
+	if results[0].Status != MutationMerged || results[0].Item.Title != "Buy oat milk" || results[0].Item.Priority != 3 {
+		t.Fatalf("expected a merge, got %#v", results[0])
+	}
+
+	// Equal times fall back to comparing client IDs.
+	results = pushChanges(t, h, `{"clientId":"alpha","mutations":[
+		{"op":"upsert","uid":"a","time":3000,"fields":{"title":"Buy soy milk"}}]}`)
+	if results[0].Status != MutationIgnored || results[0].Item.Title != "Buy oat milk" {
+		t.Fatalf("expected the tie to go to the larger client ID, got %#v", results[0])
+	}
+
+	// Deletions win over edits, whatever their time.
+	results = pushChanges(t, h, `{"clientId":"phone","mutations":[
+		{"op":"delete","uid":"a","time":1500},
+		{"op":"upsert","uid":"a","time":9000,"fields":{"title":"Buy milk"}},
+		{"op":"upsert","uid":"b","time":9000,"fields":{"notes":"no title"}}]}`)
+	if results[0].Status != MutationDeleted || results[1].Status != MutationDeleted || results[2].Status != MutationInvalid {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+	if _, err := repo.GetByUID(context.Background(), nil, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the todo to stay deleted, got %v", err)
+	}
+}
+
+func TestPushChanges_ServerEditsUseServerTime(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	repo.now = func() time.Time { return time.UnixMilli(5000) }
+	h := NewHandler(repo, WithSync(repo))
+
+	pushChanges(t, h, `{"clientId":"phone","mutations":[{"op":"upsert","uid":"a","time":1000,"fields":{"title":"Call"}}]}`)
+	item, _ := repo.GetByUID(context.Background(), nil, "a")
+	if _, err := repo.UpdateCompleted(context.Background(), item.ID, true, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	results := pushChanges(t, h, `{"clientId":"phone","mutations":[
+		{"op":"upsert","uid":"a","time":4000,"fields":{"completed":false}},
+		{"op":"upsert","uid":"a","time":6000,"fields":{"title":"Call mom"}}]}`)
+	if results[0].Status != MutationIgnored || !results[1].Item.Completed || results[1].Item.Title != "Call mom" {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+}
+
+func TestPushChanges_Validation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithSync(repo))
+
+	bodies := []string{
+		`{"mutations":[]}`,
+		`{"clientId":"phone","mutations":[{"op":"upsert","uid":"","time":1,"fields":{"title":"x"}}]}`,
+		`{"clientId":"phone","mutations":[{"op":"upsert","uid":"a","time":0,"fields":{"title":"x"}}]}`,
+		`{"clientId":"phone","mutations":[{"op":"move","uid":"a","time":1}]}`,
+		`{"clientId":"phone","mutations":[{"op":"upsert","uid":"a","time":1,"fields":{"title":"  "}}]}`,
+		`{"clientId":"phone","mutations":[{"op":"upsert","uid":"a","time":1,"fields":{"priority":10}}]}`,
+	}
+	for _, body := range bodies {
+		rr := httptest.NewRecorder()
+		h.PushChanges(rr, httptest.NewRequest(http.MethodPost, "/api/sync", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+	if items, _ := repo.List(context.Background(), nil); len(items) != 2 {
+		t.Fatalf("expected nothing to be written, have %#v", items)
+	}
+}
@@ -0,0 +1,136 @@
+package todo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/respond"
+	"todoapp/backend/internal/takt"
+)
+
+// taskUIDSuffix marks the UIDs of todos imported from takt, which are the
+// task's slug followed by it.
+const taskUIDSuffix = "@takt"
+
+type TaskStore interface {
+	GetByUID(ctx context.Context, listID *int64, uid string) (Item, error)
+	UpsertByUID(ctx context.Context, listID *int64, items []Item) ([]ImportOutcome, error)
+}
+
+// WithTaskImport enables the takt task import.
+func WithTaskImport(store TaskStore) Option {
+	return func(h *Handler) { h.tasks = store }
+}
+
+// ImportTasks applies a .takt/tasks.yaml file to the default list, or the
+// list given by ?list=, one todo per task. A task whose slug was imported
+// before updates that todo's title, notes and completion, leaving the due
+// date and priority set in the app alone; the rest are created. Todos
+// whose task has left the file are kept. Either every task is applied or,
+// when one is invalid, none is.
+func (h *Handler) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	if h.tasks == nil {
+		http.NotFound(w, r)
+		return
+	}
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, true, "todo list not found") {
+		return
+	}
+
+	// The YAML decoder hides why reading failed, so the body is read first
+	// to tell an oversized one apart.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	tasks, err := takt.Decode(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := TaskImportResult{Items: make([]TaskImportItem, len(tasks))}
+	var changed []Item
+	var changedAt []int
+	for i, task := range tasks {
+		item, err := ItemFromTask(task, h.maxTitleLength)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("task %q: %v", task.Slug, err), http.StatusBadRequest)
+			return
+		}
+		result.Items[i] = TaskImportItem{Slug: task.Slug, Status: "created"}
+
+		existing, err := h.tasks.GetByUID(r.Context(), listID, item.UID)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			respond.ServerError(w, r, "failed to look up task", err)
+			return
+		case existing.Title == item.Title && existing.Notes == item.Notes && existing.Completed == item.Completed:
+			result.Items[i] = TaskImportItem{Slug: task.Slug, ID: existing.ID, Status: "unchanged"}
+			result.Unchanged++
+			continue
+		default:
+			item.Due = existing.Due
+			item.Priority = existing.Priority
+		}
+		changed = append(changed, item)
+		changedAt = append(changedAt, i)
+	}
+
+	outcomes, err := h.tasks.UpsertByUID(r.Context(), listID, changed)
+	if err != nil {
+		respond.ServerError(w, r, "failed to import tasks", err)
+		return
+	}
+	for j, outcome := range outcomes {
+		entry := &result.Items[changedAt[j]]
+		entry.ID = outcome.ID
+		if outcome.Updated {
+			entry.Status = "updated"
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+	respond.JSON(w, r, http.StatusOK, result)
+}
+
+// ItemFromTask validates task with the same rules as CreateTodo and
+// converts it into an item whose UID is derived from the task's slug. The
+// task's name becomes the title and its content the notes.
+func ItemFromTask(task takt.Task, maxTitleLength int) (Item, error) {
+	title := strings.TrimSpace(task.Name)
+	if title == "" {
+		title = task.Slug
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return Item{}, fmt.Errorf("name must be at most %d characters", maxTitleLength)
+	}
+	if err := CheckNotes(task.Content); err != nil {
+		return Item{}, fmt.Errorf("content: %w", err)
+	}
+	item := Item{
+		UID:       task.Slug + taskUIDSuffix,
+		Title:     title,
+		Notes:     task.Content,
+		Completed: task.Completed(),
+	}
+	if item.Completed {
+		item.CompletedAt = task.CompletedAt
+	}
+	return item, nil
+}
@@ -0,0 +1,93 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportTasks_CreatesThenUpdatesBySlug(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithTaskImport(repo))
+
+	upload := func(yaml string) TaskImportResult {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		h.ImportTasks(rr, httptest.NewRequest(http.MethodPost, "/api/import/takt", strings.NewReader(yaml)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var result TaskImportResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode result: %v", err)
+		}
+		return result
+	}
+
+	const initial = `tasks:
+  - name: fix-build
+    slug: fix-build
+    status: completed
+    content: Fix the frontend build.
+    completed_at: 2026-02-19T15:36:19.478Z
+  - name: crud
+    slug: crud
+    status: running
+    content: Implement editing.
+`
+	result := upload(initial)
+	if result.Created != 2 || result.Updated != 0 || result.Items[0].Status != "created" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	build, err := repo.Get(context.Background(), result.Items[0].ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if build.UID != "fix-build@takt" || build.Title != "fix-build" || build.Notes != "Fix the frontend build." ||
+		!build.Completed || build.CompletedAt == nil || build.CompletedAt.Year() != 2026 {
+		t.Fatalf("unexpected imported todo: %#v", build)
+	}
+
+	crudID := result.Items[1].ID
+	priority := 2
+	if _, err := repo.Update(context.Background(), crudID, Changes{Priority: &priority}, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	result = upload(initial)
+	if result.Unchanged != 2 || result.Created != 0 || result.Updated != 0 {
+		t.Fatalf("expected an unchanged file to change nothing: %#v", result)
+	}
+
+	result = upload(strings.Replace(strings.Replace(initial, "status: running", "status: completed", 1), "Implement editing.", "Implement editing and deletion.", 1))
+	if result.Updated != 1 || result.Unchanged != 1 || result.Items[1].ID != crudID || result.Items[1].Status != "updated" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	crud, err := repo.Get(context.Background(), crudID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !crud.Completed || crud.Notes != "Implement editing and deletion." || crud.Priority != 2 {
+		t.Fatalf("expected the task to be updated and the priority kept: %#v", crud)
+	}
+
+	for name, body := range map[string]string{
+		"invalid yaml":     "tasks: [",
+		"missing slug":     "tasks:\n  - name: a\n",
+		"content too long": "tasks:\n  - slug: a\n    content: " + strings.Repeat("x", maxNotesLength+1) + "\n",
+	} {
+		rr := httptest.NewRecorder()
+		h.ImportTasks(rr, httptest.NewRequest(http.MethodPost, "/api/import/takt", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", name, rr.Code)
+		}
+	}
+	if items, _ := repo.List(context.Background(), nil); len(items) != 4 {
+		t.Fatalf("expected nothing from the rejected uploads, have %d todos", len(items))
+	}
+}
@@ -0,0 +1,110 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"todoapp/backend/internal/db"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Export calls fn with each todo on listID in id order. Rows are streamed
+// rather than collected, so exports of large lists stay cheap.
+func (r *Repository) Export(ctx context.Context, listID *int64, fn func(Item) error) (err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.Export")
+	defer func() { endSpan(span, err) }()
+
+	const query = `SELECT ` + itemColumns + ` WHERE todos.list_id IS ? ORDER BY todos.id ASC`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "todos", query)
+	defer func() { endSpan(stmtSpan, err) }()
+	rows, err := r.db.QueryContext(ctx, query, nullInt64(listID))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import adds items to listID in a single transaction. Items whose title
+// matches a todo already on the list, or an earlier item in the batch,
+// ignoring case, are skipped as duplicates. With dryRun nothing is written
+// but the outcomes are the same.
+func (r *Repository) Import(ctx context.Context, listID *int64, items []Item, dryRun bool) (outcomes []ImportOutcome, err error) {
+	ctx, span := tracer.Start(ctx, "todo.Repository.Import", trace.WithAttributes(
+		attribute.Int("todo.import.items", len(items)),
+		attribute.Bool("todo.import.dry_run", dryRun),
+	))
+	defer func() { endSpan(span, err) }()
+
+	err = db.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		seen, err := listTitles(ctx, tx, listID)
+		if err != nil {
+			return err
+		}
+
+		outcomes = make([]ImportOutcome, len(items))
+		for i, item := range items {
+			key := titleKey(item.Title)
+			if seen[key] {
+				outcomes[i].Duplicate = true
+				continue
+			}
+			seen[key] = true
+			if dryRun {
+				continue
+			}
+
+			item.ListID = listID
+			if outcomes[i].ID, err = r.insertTodo(ctx, tx, item, Clock{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+func listTitles(ctx context.Context, tx *sql.Tx, listID *int64) (map[string]bool, error) {
+	const query = `SELECT title FROM todos WHERE list_id IS ?`
+	ctx, stmtSpan := startStatementSpan(ctx, "SELECT", "todos", query)
+	rows, err := tx.QueryContext(ctx, query, nullInt64(listID))
+	if err != nil {
+		endSpan(stmtSpan, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := make(map[string]bool)
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			endSpan(stmtSpan, err)
+			return nil, err
+		}
+		titles[titleKey(title)] = true
+	}
+	err = rows.Err()
+	endSpan(stmtSpan, err)
+	return titles, err
+}
+
+// titleKey normalizes a title for duplicate detection.
+func titleKey(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
@@ -0,0 +1,227 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"todoapp/backend/internal/respond"
+	"todoapp/backend/internal/transfer"
+)
+
+type ImportExporter interface {
+	Export(ctx context.Context, listID *int64, fn func(Item) error) error
+	Import(ctx context.Context, listID *int64, items []Item, dryRun bool) ([]ImportOutcome, error)
+}
+
+// ExportTodos streams the default list, or the shared list given by ?list=,
+// as ?format=json (default), csv or todotxt.
+func (h *Handler) ExportTodos(w http.ResponseWriter, r *http.Request) {
+	if h.transfers == nil {
+		http.NotFound(w, r)
+		return
+	}
+	format := transfer.JSON
+	if raw := r.URL.Query().Get("format"); raw != "" {
+		var err error
+		if format, err = transfer.ParseFormat(raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, false, "todo list not found") {
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "todos." + format.Extension()}))
+	encoder := transfer.NewEncoder(w, format)
+	err := h.transfers.Export(r.Context(), listID, func(item Item) error {
+		return encoder.Encode(recordFromItem(item))
+	})
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		// The status line is already on the wire; all we can do is cut
+		// the response short and log why.
+		slog.ErrorContext(r.Context(), "failed to export todos", "error", err)
+	}
+}
+
+type importResult struct {
+	DryRun     bool           `json:"dryRun"`
+	Created    int            `json:"created"`
+	Duplicates int            `json:"duplicates"`
+	Items      []importedItem `json:"items"`
+}
+
+type importedItem struct {
+	Line  int    `json:"line"`
+	Title string `json:"title"`
+	// Status is "new" for items that are (or with dryRun would be) created
+	// and "duplicate" for skipped ones.
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+}
+
+// ImportTodos adds the todos in the body to the default list, or the list
+// given by ?list=. The format comes from ?format= or the Content-Type.
+// With ?dryRun=true the result is previewed without writing anything. The
+// import is all or nothing: any invalid item rejects the whole body.
+func (h *Handler) ImportTodos(w http.ResponseWriter, r *http.Request) {
+	if h.transfers == nil {
+		http.NotFound(w, r)
+		return
+	}
+	format, err := importFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := false
+	if raw := r.URL.Query().Get("dryRun"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			http.Error(w, "dryRun must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	listID, ok := listParam(w, r)
+	if !ok || !h.authorizeList(w, r, listID, true, "todo list not found") {
+		return
+	}
+
+	records, err := transfer.Decode(http.MaxBytesReader(w, r.Body, h.maxBodyBytes), format)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items := make([]Item, len(records))
+	for i, record := range records {
+		item, err := h.itemFromRecord(record)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("line %d: %v", record.Line, err), http.StatusBadRequest)
+			return
+		}
+		items[i] = item
+	}
+
+	outcomes, err := h.transfers.Import(r.Context(), listID, items, dryRun)
+	if err != nil {
+		respond.ServerError(w, r, "failed to import todos", err)
+		return
+	}
+
+	result := importResult{DryRun: dryRun, Items: make([]importedItem, len(items))}
+	for i, outcome := range outcomes {
+		result.Items[i] = importedItem{Line: records[i].Line, Title: items[i].Title, Status: "new", ID: outcome.ID}
+		if outcome.Duplicate {
+			result.Items[i].Status = "duplicate"
+			result.Duplicates++
+		} else {
+			result.Created++
+		}
+	}
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	respond.JSON(w, r, status, result)
+}
+
+// listParam parses the optional ?list= shared list id.
+func listParam(w http.ResponseWriter, r *http.Request) (*int64, bool) {
+	raw := r.URL.Query().Get("list")
+	if raw == "" {
+		return nil, true
+	}
+	id, err := parseID(raw)
+	if err != nil {
+		http.Error(w, "invalid list id", http.StatusBadRequest)
+		return nil, false
+	}
+	return &id, true
+}
+
+func importFormat(r *http.Request) (transfer.Format, error) {
+	if raw := r.URL.Query().Get("format"); raw != "" {
+		return transfer.ParseFormat(raw)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return transfer.CSV, nil
+	case "text/plain":
+		return transfer.TodoTxt, nil
+	}
+	return transfer.JSON, nil
+}
+
+func recordFromItem(item Item) transfer.Record {
+	record := transfer.Record{
+		Title:       item.Title,
+		Completed:   item.Completed,
+		CompletedAt: item.CompletedAt,
+		Notes:       item.Notes,
+		Priority:    priorityLetter(item.Priority),
+	}
+	if item.Due != nil {
+		due := DueTime(item.Due.UTC())
+		record.Due = &due
+	}
+	return record
+}
+
+// itemFromRecord applies the same rules as CreateTodo. Tags have no field
+// of their own; they survive as words in the title.
+func (h *Handler) itemFromRecord(record transfer.Record) (Item, error) {
+	title := strings.TrimSpace(record.Title)
+	if title == "" {
+		return Item{}, errors.New("title is required")
+	}
+	if utf8.RuneCountInString(title) > h.maxTitleLength {
+		return Item{}, fmt.Errorf("title must be at most %d characters", h.maxTitleLength)
+	}
+	if err := CheckNotes(record.Notes); err != nil {
+		return Item{}, err
+	}
+	item := Item{
+		Title:       title,
+		Completed:   record.Completed,
+		CompletedAt: record.CompletedAt,
+		Notes:       record.Notes,
+		Due:         record.Due.Time(),
+		Priority:    priorityFromLetter(record.Priority),
+	}
+	return item, nil
+}
+
+// priorityLetter maps an iCalendar priority onto todo.txt letters: 1 is
+// (A) and 9 is (I).
+func priorityLetter(priority int) string {
+	if priority < 1 || priority > maxPriority {
+		return ""
+	}
+	return string(rune('A' + priority - 1))
+}
+
+// priorityFromLetter is the inverse of priorityLetter; todo.txt letters
+// past I all become the lowest priority, 9.
+func priorityFromLetter(letter string) int {
+	if letter == "" {
+		return 0
+	}
+	return min(int(letter[0]-'A')+1, maxPriority)
+}
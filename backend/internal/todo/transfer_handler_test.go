@@ -0,0 +1,158 @@
+package todo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithImportExport(repo))
+
+	tests := []struct {
+		query       string
+		contentType string
+		body        string
+	}{
+		{query: "", contentType: "application/json", body: "[\n" + `{"title":"First","completed":false},` + "\n" + `{"title":"Second","completed":true}` + "\n]\n"},
+		{query: "?format=csv", contentType: "text/csv; charset=utf-8", body: "title,completed,completed_at,priority,due,tags,notes\nFirst,false,,,,,\nSecond,true,,,,,\n"},
+		{query: "?format=todotxt", contentType: "text/plain; charset=utf-8", body: "First\nx Second\n"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.ExportTodos(rr, httptest.NewRequest(http.MethodGet, "/api/export"+tt.query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d", tt.query, rr.Code)
+		}
+		if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+			t.Fatalf("%q: unexpected content type %q", tt.query, got)
+		}
+		if rr.Body.String() != tt.body {
+			t.Fatalf("%q: unexpected body:\n%s", tt.query, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ExportTodos(rr, httptest.NewRequest(http.MethodGet, "/api/export?format=xml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown formats to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestExportThenImport_KeepsDueTime(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithImportExport(repo))
+	due := time.Date(2026, 11, 1, 15, 30, 0, 0, time.UTC)
+	if _, err := repo.Update(context.Background(), 1, Changes{SetDue: true, Due: &due}, nil); err != nil {
+		t.Fatalf("set due: %v", err)
+	}
+
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ExportTodos(rr, httptest.NewRequest(http.MethodGet, "/api/export?format="+format, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rr.Code)
+			}
+
+			target := setupTestDB(t)
+			defer target.Close()
+			if _, err := target.Exec(`DELETE FROM todos`); err != nil {
+				t.Fatalf("clear todos: %v", err)
+			}
+			targetRepo := NewRepository(target)
+			req := httptest.NewRequest(http.MethodPost, "/api/import?format="+format, rr.Body)
+			imported := httptest.NewRecorder()
+			NewHandler(targetRepo, WithImportExport(targetRepo)).ImportTodos(imported, req)
+			if imported.Code != http.StatusCreated {
+				t.Fatalf("expected status 201, got %d: %s", imported.Code, imported.Body.String())
+			}
+
+			items, _ := targetRepo.List(context.Background(), nil)
+			if len(items) != 2 || items[0].Due == nil || !items[0].Due.Equal(due) {
+				t.Fatalf("expected the due time to survive, got %#v", items)
+			}
+		})
+	}
+}
+
+func TestImportTodos_DryRunThenCommit(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithImportExport(repo))
+	body := "(A) Water plants @home\nx 2026-10-18 Pay rent\n  first  \nwater PLANTS @home\n"
+
+	importTodos := func(query string) importResult {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/plain")
+		rr := httptest.NewRecorder()
+		h.ImportTodos(rr, req)
+		if rr.Code != http.StatusOK && rr.Code != http.StatusCreated {
+			t.Fatalf("expected success, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var result importResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode result: %v", err)
+		}
+		return result
+	}
+
+	preview := importTodos("?dryRun=true")
+	if !preview.DryRun || preview.Created != 2 || preview.Duplicates != 2 {
+		t.Fatalf("unexpected preview: %#v", preview)
+	}
+	if preview.Items[2].Status != "duplicate" || preview.Items[2].Line != 3 || preview.Items[3].Status != "duplicate" {
+		t.Fatalf("expected the existing title and the repeated line to be duplicates: %#v", preview.Items)
+	}
+	if items, _ := repo.List(context.Background(), nil); len(items) != 2 {
+		t.Fatalf("expected a dry run to write nothing, have %d todos", len(items))
+	}
+
+	result := importTodos("")
+	if result.DryRun || result.Created != 2 || result.Items[0].ID == 0 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	items, _ := repo.List(context.Background(), nil)
+	if len(items) != 4 {
+		t.Fatalf("expected 4 todos, got %d", len(items))
+	}
+	if plants := items[2]; plants.Title != "Water plants @home" || plants.Priority != 1 {
+		t.Fatalf("expected (A) to become priority 1: %#v", plants)
+	}
+	rent := items[3]
+	if rent.Title != "Pay rent" || !rent.Completed || rent.CompletedAt == nil || rent.CompletedAt.Format("2006-01-02") != "2026-10-18" {
+		t.Fatalf("unexpected imported todo: %#v", rent)
+	}
+
+	if again := importTodos(""); again.Created != 0 || again.Duplicates != 4 {
+		t.Fatalf("expected a repeated import to create nothing: %#v", again)
+	}
+}
+
+func TestImportTodos_InvalidItemRejectsEverything(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewRepository(db)
+	h := NewHandler(repo, WithImportExport(repo), WithMaxTitleLength(10))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/import?format=csv", strings.NewReader("title\nShort\nFar too long a title\n"))
+	rr := httptest.NewRecorder()
+	h.ImportTodos(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "line 3: title must be at most 10 characters") {
+		t.Fatalf("expected a 400 naming line 3, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if items, _ := repo.List(context.Background(), nil); len(items) != 2 {
+		t.Fatalf("expected nothing to be imported, have %d todos", len(items))
+	}
+}
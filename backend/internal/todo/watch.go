@@ -0,0 +1,79 @@
+package todo
+
+import (
+	"context"
+	"reflect"
+)
+
+// Change is a todo that was created, changed or deleted. Item is nil for a
+// deletion.
+type Change struct {
+	ID   int64
+	UID  string
+	Item *Item
+}
+
+// Watcher turns the sync log of one list into a stream of changes for
+// clients that are pushed events rather than syncing themselves, such as
+// GraphQL subscriptions and the gRPC Watch call.
+type Watcher struct {
+	store  SyncStore
+	listID *int64
+	token  int64
+	// known is the last full listing. A zero token means nothing has
+	// changed yet, and the next change can only be seen in a full listing,
+	// which is compared with this one.
+	known []Item
+}
+
+// NewWatcher starts watching listID from its current state.
+func NewWatcher(ctx context.Context, store SyncStore, listID *int64) (*Watcher, error) {
+	changes, err := store.ChangesSince(ctx, listID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{store: store, listID: listID, token: changes.Token, known: changes.Items}, nil
+}
+
+// Poll returns the changes made since the previous poll, in order.
+func (w *Watcher) Poll(ctx context.Context) ([]Change, error) {
+	next, err := w.store.ChangesSince(ctx, w.listID, w.token)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	if next.Full {
+		changes = diffItems(w.known, next.Items)
+		w.known = next.Items
+	} else {
+		for _, item := range next.Items {
+			changes = append(changes, Change{ID: item.ID, UID: item.UID, Item: &item})
+		}
+		for _, tombstone := range next.Deleted {
+			changes = append(changes, Change{ID: tombstone.ID, UID: tombstone.UID})
+		}
+	}
+	w.token = next.Token
+	return changes, nil
+}
+
+// diffItems returns the changes that turn before into after.
+func diffItems(before, after []Item) []Change {
+	old := make(map[int64]Item, len(before))
+	for _, item := range before {
+		old[item.ID] = item
+	}
+	var changes []Change
+	for _, item := range after {
+		if previous, ok := old[item.ID]; !ok || !reflect.DeepEqual(previous, item) {
+			changes = append(changes, Change{ID: item.ID, UID: item.UID, Item: &item})
+		}
+		delete(old, item.ID)
+	}
+	for _, item := range before {
+		if _, ok := old[item.ID]; ok {
+			changes = append(changes, Change{ID: item.ID, UID: item.UID})
+		}
+	}
+	return changes
+}
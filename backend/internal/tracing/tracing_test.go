@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup_StdoutExporterWritesFile(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	output := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), Config{Exporter: "stdout", Output: output})
+	if err != nil {
+		t.Fatalf("setup tracing: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "offline-span")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown tracing: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read trace output: %v", err)
+	}
+	if !strings.Contains(string(data), `"Name":"offline-span"`) {
+		t.Fatalf("expected span in output, got %q", data)
+	}
+	if !strings.Contains(string(data), serviceName) {
+		t.Fatalf("expected service name in output, got %q", data)
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Fatalf("expected error for unknown exporter")
+	}
+}
@@ -0,0 +1,154 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/backend/pkg/api"
+)
+
+var csvHeader = []string{"title", "completed", "completed_at", "priority", "due", "tags", "notes"}
+
+type csvEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Encode(record Record) error {
+	if !e.headerWritten {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+
+	var completedAt, due string
+	if record.CompletedAt != nil {
+		completedAt = record.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	if record.Due != nil {
+		due = record.Due.String()
+	}
+	return e.w.Write([]string{
+		record.Title,
+		strconv.FormatBool(record.Completed),
+		completedAt,
+		record.Priority,
+		due,
+		strings.Join(record.Tags, " "),
+		record.Notes,
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.headerWritten {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// decodeCSV reads a file with a header row. Columns are matched by name, so
+// they may come in any order and unknown ones are ignored; only title is
+// required.
+func decodeCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &SyntaxError{Line: 1, Err: errors.New("missing header row")}
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, &SyntaxError{Line: 1, Err: errors.New("header must include a title column")}
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		record := Record{
+			Title:    field("title"),
+			Notes:    field("notes"),
+			Priority: strings.ToUpper(field("priority")),
+			Line:     line,
+		}
+		if tags := strings.Fields(field("tags")); len(tags) > 0 {
+			record.Tags = tags
+		}
+		if raw := field("completed"); raw != "" {
+			if record.Completed, err = parseCompleted(raw); err != nil {
+				return nil, &SyntaxError{Line: line, Err: err}
+			}
+		}
+		if raw := field("completed_at"); raw != "" {
+			completedAt, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return nil, &SyntaxError{Line: line, Err: fmt.Errorf("invalid completed_at %q, want RFC 3339", raw)}
+			}
+			record.CompletedAt = &completedAt
+		}
+		if raw := field("due"); raw != "" {
+			parsed, err := api.ParseDue(raw)
+			if err != nil {
+				return nil, &SyntaxError{Line: line, Err: err}
+			}
+			due := api.DueTime(parsed)
+			record.Due = &due
+		}
+		records = append(records, record)
+	}
+}
+
+func parseCompleted(raw string) (bool, error) {
+	switch strings.ToLower(raw) {
+	case "x", "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	completed, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid completed value %q", raw)
+	}
+	return completed, nil
+}
+
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &SyntaxError{Line: parseErr.Line, Err: parseErr.Err}
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonEncoder writes a JSON array, one element per Encode call.
+type jsonEncoder struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *jsonEncoder) Encode(record Record) error {
+	prefix := ",\n"
+	if !e.started {
+		prefix = "[\n"
+		e.started = true
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.w, prefix); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	if !e.started {
+		_, err := io.WriteString(e.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+func decodeJSON(r io.Reader) ([]Record, error) {
+	var records []Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("invalid JSON, want an array of todos: %w", err)
+	}
+	for i := range records {
+		records[i].Line = i + 1
+	}
+	return records, nil
+}
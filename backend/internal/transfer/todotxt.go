@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"todoapp/backend/pkg/api"
+)
+
+// todoTxtEncoder writes one task per line:
+//
+//	(A) Call mom +family due:2026-11-01
+//	x 2026-10-19 Pay rent pri:B
+//
+// Completed tasks drop the (A) prefix in favour of pri:A, as todo.txt
+// clients do. Notes have no place in the format and are not written.
+type todoTxtEncoder struct {
+	w io.Writer
+}
+
+func (e *todoTxtEncoder) Encode(record Record) error {
+	var parts []string
+	switch {
+	case record.Completed:
+		parts = append(parts, "x")
+		if record.CompletedAt != nil {
+			parts = append(parts, record.CompletedAt.UTC().Format(dateLayout))
+		}
+	case record.Priority != "":
+		parts = append(parts, "("+record.Priority+")")
+	}
+
+	words := strings.Fields(record.Title)
+	parts = append(parts, words...)
+	for _, tag := range record.Tags {
+		if !slices.Contains(words, tag) {
+			parts = append(parts, tag)
+		}
+	}
+	if record.Completed && record.Priority != "" {
+		parts = append(parts, "pri:"+record.Priority)
+	}
+	if record.Due != nil {
+		parts = append(parts, "due:"+time.Time(*record.Due).UTC().Format(dateLayout))
+	}
+
+	_, err := io.WriteString(e.w, strings.Join(parts, " ")+"\n")
+	return err
+}
+
+func (e *todoTxtEncoder) Close() error {
+	return nil
+}
+
+var (
+	priorityPrefix = regexp.MustCompile(`^\(([A-Z])\)$`)
+	datePattern    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// decodeTodoTxt parses one task per non-blank line. +project and @context
+// words stay in the title and are also collected as tags; the due: and pri:
+// extensions are taken out of it. Creation dates are read and discarded.
+func decodeTodoTxt(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var records []Record
+	for line := 1; scanner.Scan(); line++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
+		record := Record{Line: line}
+
+		if words[0] == "x" {
+			record.Completed = true
+			words = words[1:]
+			if len(words) > 0 && datePattern.MatchString(words[0]) {
+				completedAt, err := time.Parse(dateLayout, words[0])
+				if err != nil {
+					return nil, &SyntaxError{Line: line, Err: fmt.Errorf("invalid completion date %q", words[0])}
+				}
+				record.CompletedAt = &completedAt
+				words = words[1:]
+			}
+		} else if len(words) > 0 {
+			if match := priorityPrefix.FindStringSubmatch(words[0]); match != nil {
+				record.Priority = match[1]
+				words = words[1:]
+			}
+		}
+		if len(words) > 0 && datePattern.MatchString(words[0]) {
+			words = words[1:]
+		}
+
+		title := make([]string, 0, len(words))
+		for _, word := range words {
+			switch {
+			case strings.HasPrefix(word, "due:"):
+				raw := strings.TrimPrefix(word, "due:")
+				parsed, err := time.Parse(dateLayout, raw)
+				if err != nil {
+					return nil, &SyntaxError{Line: line, Err: fmt.Errorf("invalid date %q, want YYYY-MM-DD", raw)}
+				}
+				due := api.DueTime(parsed)
+				record.Due = &due
+				continue
+			case strings.HasPrefix(word, "pri:") && record.Completed:
+				record.Priority = strings.ToUpper(strings.TrimPrefix(word, "pri:"))
+				continue
+			case len(word) > 1 && (word[0] == '+' || word[0] == '@'):
+				if !slices.Contains(record.Tags, word) {
+					record.Tags = append(record.Tags, word)
+				}
+			}
+			title = append(title, word)
+		}
+		record.Title = strings.Join(title, " ")
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
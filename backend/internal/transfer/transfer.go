@@ -0,0 +1,128 @@
+// Package transfer reads and writes todos in portable formats: JSON, CSV
+// and todo.txt (https://github.com/todotxt/todo.txt).
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"todoapp/backend/pkg/api"
+)
+
+type Format string
+
+const (
+	JSON    Format = "json"
+	CSV     Format = "csv"
+	TodoTxt Format = "todotxt"
+)
+
+// dateLayout is how todo.txt writes dates.
+const dateLayout = "2006-01-02"
+
+var ErrUnknownFormat = errors.New("format must be json, csv or todotxt")
+
+func ParseFormat(raw string) (Format, error) {
+	switch format := Format(raw); format {
+	case JSON, CSV, TodoTxt:
+		return format, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case TodoTxt:
+		return "text/plain; charset=utf-8"
+	}
+	return "application/json"
+}
+
+func (f Format) Extension() string {
+	if f == TodoTxt {
+		return "txt"
+	}
+	return string(f)
+}
+
+// Record is one todo as it appears in an export. Priority and Tags follow
+// todo.txt: a priority is a letter A–Z and tags are +project and @context
+// words. Due keeps its time of day in JSON and CSV, which accept a bare
+// date too; todo.txt has room for the date only.
+type Record struct {
+	Title       string       `json:"title"`
+	Completed   bool         `json:"completed"`
+	CompletedAt *time.Time   `json:"completedAt,omitempty"`
+	Notes       string       `json:"notes,omitempty"`
+	Priority    string       `json:"priority,omitempty"`
+	Due         *api.DueTime `json:"due,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+
+	// Line is where the record started in the decoded input, or its
+	// position for JSON.
+	Line int `json:"-"`
+}
+
+// SyntaxError reports malformed input along with where it was found.
+type SyntaxError struct {
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Encoder writes records one at a time so exports can be streamed.
+type Encoder interface {
+	Encode(record Record) error
+	// Close writes any trailing output; it does not close the writer.
+	Close() error
+}
+
+func NewEncoder(w io.Writer, format Format) Encoder {
+	switch format {
+	case CSV:
+		return newCSVEncoder(w)
+	case TodoTxt:
+		return &todoTxtEncoder{w: w}
+	}
+	return &jsonEncoder{w: w}
+}
+
+// Decode reads every record in r. Malformed input fails with a
+// *SyntaxError naming the offending line.
+func Decode(r io.Reader, format Format) ([]Record, error) {
+	var (
+		records []Record
+		err     error
+	)
+	switch format {
+	case CSV:
+		records, err = decodeCSV(r)
+	case TodoTxt:
+		records, err = decodeTodoTxt(r)
+	default:
+		records, err = decodeJSON(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Priority != "" && !priorityPattern.MatchString(record.Priority) {
+			return nil, &SyntaxError{Line: record.Line, Err: fmt.Errorf("priority must be a letter A-Z, got %q", record.Priority)}
+		}
+	}
+	return records, nil
+}
+
+var priorityPattern = regexp.MustCompile(`^[A-Z]$`)
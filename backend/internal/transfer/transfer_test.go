@@ -0,0 +1,195 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/pkg/api"
+)
+
+func due(t *testing.T, raw string) *api.DueTime {
+	t.Helper()
+	parsed, err := api.ParseDue(raw)
+	if err != nil {
+		t.Fatalf("parse due: %v", err)
+	}
+	return (*api.DueTime)(&parsed)
+}
+
+func TestRoundTrip(t *testing.T) {
+	completedAt := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Title: "Call mom +family", Priority: "A", Due: due(t, "2026-11-01"), Tags: []string{"+family"}},
+		{Title: "Pay rent", Completed: true, CompletedAt: &completedAt, Priority: "B"},
+		{Title: `Quote "this", please`, Notes: "line one\nline two"},
+	}
+
+	for _, format := range []Format{JSON, CSV, TodoTxt} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf, format)
+			for _, record := range records {
+				if err := encoder.Encode(record); err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+			}
+			if err := encoder.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			decoded, err := Decode(&buf, format)
+			if err != nil {
+				t.Fatalf("decode: %v\n%s", err, buf.String())
+			}
+			if len(decoded) != len(records) {
+				t.Fatalf("expected %d records, got %d", len(records), len(decoded))
+			}
+			for i, want := range records {
+				got := decoded[i]
+				got.Line = 0
+				if format == TodoTxt {
+					// todo.txt has no room for notes.
+					want.Notes = ""
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("record %d:\nwant %#v\ngot  %#v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestEncode_Empty(t *testing.T) {
+	for format, want := range map[Format]string{JSON: "[]\n", CSV: strings.Join(csvHeader, ",") + "\n", TodoTxt: ""} {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, format).Close(); err != nil {
+			t.Fatalf("%s: close: %v", format, err)
+		}
+		if buf.String() != want {
+			t.Fatalf("%s: expected %q, got %q", format, want, buf.String())
+		}
+	}
+}
+
+func TestDecodeTodoTxt(t *testing.T) {
+	input := `
+(B) 2026-10-01 Write report @work due:2026-10-20
+x 2026-10-18 2026-10-01 Ship release +app pri:A
+
+x Water plants
+`
+	records, err := Decode(strings.NewReader(input), TodoTxt)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	first := records[0]
+	if first.Title != "Write report @work" || first.Priority != "B" || first.Due.String() != "2026-10-20" ||
+		!reflect.DeepEqual(first.Tags, []string{"@work"}) || first.Line != 2 {
+		t.Fatalf("unexpected first record: %#v", first)
+	}
+	second := records[1]
+	if !second.Completed || second.CompletedAt.Format(dateLayout) != "2026-10-18" || second.Priority != "A" || second.Title != "Ship release +app" {
+		t.Fatalf("unexpected second record: %#v", second)
+	}
+	if third := records[2]; !third.Completed || third.CompletedAt != nil || third.Title != "Water plants" || third.Line != 5 {
+		t.Fatalf("unexpected third record: %#v", third)
+	}
+}
+
+func TestDecodeCSV(t *testing.T) {
+	input := "Notes,Title,Completed,id\nbring cash,Buy milk,yes,7\n,Read docs,,8\n"
+	records, err := Decode(strings.NewReader(input), CSV)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(records) != 2 || records[0].Title != "Buy milk" || !records[0].Completed || records[0].Notes != "bring cash" ||
+		records[1].Title != "Read docs" || records[1].Completed || records[1].Line != 3 {
+		t.Fatalf("unexpected records: %#v", records)
+	}
+}
+
+func TestJSON_DatesAreDateOnly(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf, JSON)
+	if err := encoder.Encode(Record{Title: "Call mom", Due: due(t, "2026-11-01")}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	encoder.Close()
+	if !strings.Contains(buf.String(), `"due":"2026-11-01"`) {
+		t.Fatalf("expected a date-only due, got %s", buf.String())
+	}
+
+	records, err := Decode(strings.NewReader(`[{"title":"Call mom","due":"2026-11-01"}]`), JSON)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if records[0].Due == nil || !records[0].Due.Time().Equal(*due(t, "2026-11-01").Time()) {
+		t.Fatalf("unexpected due: %v", records[0].Due)
+	}
+}
+
+func TestDueKeepsTimeOutsideTodoTxt(t *testing.T) {
+	record := Record{Title: "Call mom", Due: due(t, "2026-11-01T15:30:00Z")}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{format: JSON, want: "2026-11-01T15:30:00Z"},
+		{format: CSV, want: "2026-11-01T15:30:00Z"},
+		{format: TodoTxt, want: "2026-11-01"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf, tt.format)
+			if err := encoder.Encode(record); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			encoder.Close()
+			records, err := Decode(&buf, tt.format)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(records) != 1 || records[0].Due == nil || records[0].Due.String() != tt.want {
+				t.Fatalf("expected due %s, got %#v", tt.want, records)
+			}
+		})
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		line   int
+	}{
+		{name: "csv without title column", format: CSV, input: "name\nmilk\n", line: 1},
+		{name: "csv bad completed", format: CSV, input: "title,completed\nmilk,maybe\n", line: 2},
+		{name: "csv bad quote", format: CSV, input: "title\nok\n\"broken\n", line: 3},
+		{name: "csv bad due", format: CSV, input: "title,due\nmilk,tomorrow\n", line: 2},
+		{name: "todotxt bad due", format: TodoTxt, input: "fine\nCall due:tomorrow\n", line: 2},
+		{name: "json bad priority", format: JSON, input: `[{"title":"a"},{"title":"b","priority":"high"}]`, line: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input), tt.format)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) || syntaxErr.Line != tt.line {
+				t.Fatalf("expected a syntax error on line %d, got %v", tt.line, err)
+			}
+		})
+	}
+
+	if _, err := Decode(strings.NewReader(`{"title":"not an array"}`), JSON); err == nil {
+		t.Fatalf("expected a JSON object to be rejected")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build embedui
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist is frontend/dist, copied here by go generate before building with
+// the embedui tag.
+//
+//go:embed all:dist
+var dist embed.FS
+
+func embedded() (fs.FS, bool) {
+	files, err := fs.Sub(dist, "dist")
+	return files, err == nil
+}
@@ -0,0 +1,11 @@
+//go:build !embedui
+
+package web
+
+import "io/fs"
+
+// embedded reports false: the frontend is only embedded in binaries built
+// with the embedui tag.
+func embedded() (fs.FS, bool) {
+	return nil, false
+}
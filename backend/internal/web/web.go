@@ -0,0 +1,242 @@
+// Package web serves the built frontend, the output of `npm run build` in
+// frontend/, so a single server process can host both the app and the API.
+package web
+
+//go:generate sh -c "rm -rf dist && cp -R ../../../frontend/dist dist"
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	indexFile = "index.html"
+	// assetsDir is where Vite writes files whose names carry a hash of their
+	// content, so they never change under the same URL.
+	assetsDir = "assets/"
+	// apiBaseURLMeta names the meta tag the frontend reads the API base URL
+	// from before falling back to VITE_API_BASE_URL.
+	apiBaseURLMeta = "todo-api-base-url"
+)
+
+// encodings are the precompressed variants looked for next to each file, in
+// order of preference.
+var encodings = []struct {
+	coding string
+	suffix string
+}{
+	{coding: "br", suffix: ".br"},
+	{coding: "gzip", suffix: ".gz"},
+}
+
+// Files returns the frontend to serve: the files in dir when it is set,
+// otherwise the copy embedded in binaries built with the embedui tag. It
+// reports false when there is neither.
+func Files(dir string) (fs.FS, bool) {
+	if dir != "" {
+		return os.DirFS(dir), true
+	}
+	return embedded()
+}
+
+type file struct {
+	content []byte
+	etag    string
+}
+
+// Handler serves a snapshot of the frontend taken when it is created.
+// Paths that name no file and look like a page of the app get index.html,
+// so the app can route them itself.
+type Handler struct {
+	apiBaseURL string
+	files      map[string]file
+	index      file
+}
+
+type Option func(*Handler)
+
+// WithAPIBaseURL sets the URL the frontend sends API requests to. The
+// default, empty, sends them to the origin the app was loaded from.
+func WithAPIBaseURL(url string) Option {
+	return func(h *Handler) {
+		h.apiBaseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+func NewHandler(fsys fs.FS, opts ...Option) (*Handler, error) {
+	h := &Handler{files: make(map[string]file)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		h.files[name] = newFile(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read frontend: %w", err)
+	}
+	index, ok := h.files[indexFile]
+	if !ok {
+		return nil, errors.New("frontend has no " + indexFile)
+	}
+	page, err := injectAPIBaseURL(index.content, h.apiBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	h.index = newFile(page)
+	return h, nil
+}
+
+func newFile(content []byte) file {
+	sum := sha256.Sum256(content)
+	return file{content: content, etag: strconv.Quote(hex.EncodeToString(sum[:16]))}
+}
+
+// injectAPIBaseURL adds the meta tag the frontend reads its API base URL
+// from, so one build can be deployed against any server.
+func injectAPIBaseURL(page []byte, apiBaseURL string) ([]byte, error) {
+	end := bytes.Index(bytes.ToLower(page), []byte("</head>"))
+	if end < 0 {
+		return nil, errors.New(indexFile + " has no </head>")
+	}
+	tag := fmt.Sprintf(`<meta name="%s" content="%s">`, apiBaseURLMeta, html.EscapeString(apiBaseURL))
+	injected := make([]byte, 0, len(page)+len(tag))
+	injected = append(injected, page[:end]...)
+	injected = append(injected, tag...)
+	return append(injected, page[end:]...), nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, ok := h.resolve(r)
+	switch {
+	case !ok:
+		http.NotFound(w, r)
+	case name == indexFile:
+		h.serveIndex(w, r)
+	default:
+		h.serveFile(w, r, name)
+	}
+}
+
+// Fallback sends GET and HEAD requests that no route on mux matches, and
+// that the frontend can answer, to h. Everything else goes to mux, which
+// answers unknown paths with 404 or 405 as before.
+func (h *Handler) Fallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if _, pattern := mux.Handler(r); pattern == "" {
+				if _, ok := h.resolve(r); ok {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// resolve returns the file a request asks for. Paths without an extension
+// that a browser navigates to get index.html; others, such as a missing
+// script, are not found.
+func (h *Handler) resolve(r *http.Request) (string, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || name == indexFile {
+		return indexFile, true
+	}
+	if _, ok := h.files[name]; ok {
+		return name, true
+	}
+	if path.Ext(name) == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return indexFile, true
+	}
+	return "", false
+}
+
+// serveIndex serves index.html, which browsers must revalidate so that a new
+// deployment's assets are picked up on the next load.
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("ETag", h.index.etag)
+	http.ServeContent(w, r, indexFile, time.Time{}, bytes.NewReader(h.index.content))
+}
+
+// serveFile serves a file, or its precompressed variant when one exists and
+// the client accepts it. Hashed assets may be cached for good.
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	header := w.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if strings.HasPrefix(name, assetsDir) {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	served := h.files[name]
+	varies := false
+	for _, encoding := range encodings {
+		variant, ok := h.files[name+encoding.suffix]
+		if !ok {
+			continue
+		}
+		varies = true
+		if acceptsEncoding(r.Header.Get("Accept-Encoding"), encoding.coding) {
+			header.Set("Content-Encoding", encoding.coding)
+			served = variant
+			break
+		}
+	}
+	if varies {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	header.Set("ETag", served.etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(served.content))
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header lists coding
+// with a nonzero quality.
+func acceptsEncoding(header string, coding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		quality, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		q, err := strconv.ParseFloat(quality, 64)
+		return err == nil && q > 0
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestHandler(t *testing.T, opts ...Option) *Handler {
+	t.Helper()
+
+	files := fstest.MapFS{
+		"index.html":                  {Data: []byte(`<!doctype html><html><head><title>TODO App</title></head><body><div id="root"></div></body></html>`)},
+		"favicon.svg":                 {Data: []byte("<svg></svg>")},
+		"assets/index-B1a2c3d4.js":    {Data: []byte("console.log('app')")},
+		"assets/index-B1a2c3d4.js.br": {Data: []byte("brotli")},
+		"assets/index-B1a2c3d4.js.gz": {Data: []byte("gzip")},
+		"assets/index-C5e6f7a8.css":   {Data: []byte("body{}")},
+	}
+	h, err := NewHandler(files, opts...)
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	return h
+}
+
+func get(h http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandler_IndexCarriesAPIBaseURL(t *testing.T) {
+	h := newTestHandler(t, WithAPIBaseURL(`https://api.example.com/"todo"/`))
+
+	rr := get(h, "/", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+	want := `<meta name="todo-api-base-url" content="https://api.example.com/&#34;todo&#34;"></head>`
+	if body := rr.Body.String(); !strings.Contains(body, want) {
+		t.Fatalf("expected injected meta tag, got %s", body)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Fatalf("unexpected cache control: %q", got)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+
+	etag := rr.Header().Get("ETag")
+	if rr = get(h, "/", map[string]string{"If-None-Match": etag}); rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching etag, got %d", rr.Code)
+	}
+}
+
+func TestHandler_DefaultsToSameOrigin(t *testing.T) {
+	rr := get(newTestHandler(t), "/index.html", nil)
+	if body := rr.Body.String(); !strings.Contains(body, `<meta name="todo-api-base-url" content="">`) {
+		t.Fatalf("expected empty api base url, got %s", body)
+	}
+}
+
+func TestHandler_FallsBackToIndexForPages(t *testing.T) {
+	h := newTestHandler(t)
+
+	rr := get(h, "/lists/3", map[string]string{"Accept": "text/html,application/xhtml+xml"})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `<div id="root">`) {
+		t.Fatalf("expected index.html, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr = get(h, "/lists/3", map[string]string{"Accept": "application/json"}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a non-browser request, got %d", rr.Code)
+	}
+	if rr = get(h, "/assets/missing-00000000.js", map[string]string{"Accept": "text/html"}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing asset, got %d", rr.Code)
+	}
+}
+
+func TestHandler_CachesHashedAssets(t *testing.T) {
+	h := newTestHandler(t)
+
+	rr := get(h, "/assets/index-C5e6f7a8.css", nil)
+	if rr.Code != http.StatusOK || rr.Body.String() != "body{}" {
+		t.Fatalf("unexpected response: %d %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Fatalf("unexpected cache control: %q", got)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "text/css; charset=utf-8" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "" {
+		t.Fatalf("expected no vary without compressed variants, got %q", got)
+	}
+
+	rr = get(h, "/favicon.svg", nil)
+	if got := rr.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Fatalf("unexpected cache control for unhashed file: %q", got)
+	}
+}
+
+func TestHandler_ServesPrecompressedVariants(t *testing.T) {
+	h := newTestHandler(t)
+
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+		wantBody       string
+	}{
+		{acceptEncoding: "gzip, deflate, br", wantEncoding: "br", wantBody: "brotli"},
+		{acceptEncoding: "gzip, br;q=0", wantEncoding: "gzip", wantBody: "gzip"},
+		{acceptEncoding: "GZIP;q=0.5", wantEncoding: "gzip", wantBody: "gzip"},
+		{acceptEncoding: "identity", wantEncoding: "", wantBody: "console.log('app')"},
+		{acceptEncoding: "", wantEncoding: "", wantBody: "console.log('app')"},
+	}
+	for _, tt := range tests {
+		rr := get(h, "/assets/index-B1a2c3d4.js", map[string]string{"Accept-Encoding": tt.acceptEncoding})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: unexpected status %d", tt.acceptEncoding, rr.Code)
+		}
+		if got := rr.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Fatalf("%q: unexpected content encoding %q", tt.acceptEncoding, got)
+		}
+		if got := rr.Body.String(); got != tt.wantBody {
+			t.Fatalf("%q: unexpected body %q", tt.acceptEncoding, got)
+		}
+		if got := rr.Header().Get("Content-Type"); got != "text/javascript; charset=utf-8" {
+			t.Fatalf("%q: unexpected content type %q", tt.acceptEncoding, got)
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Fatalf("%q: unexpected vary %q", tt.acceptEncoding, got)
+		}
+	}
+}
+
+func TestHandler_RejectsOtherMethods(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newTestHandler(t).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("unexpected response: %d %q", rr.Code, rr.Header().Get("Allow"))
+	}
+}
+
+func TestNewHandler_RequiresIndex(t *testing.T) {
+	if _, err := NewHandler(fstest.MapFS{"app.js": {Data: []byte("")}}); err == nil {
+		t.Fatal("expected an error without index.html")
+	}
+	if _, err := NewHandler(fstest.MapFS{"index.html": {Data: []byte("<html><body></body></html>")}}); err == nil {
+		t.Fatal("expected an error for index.html without a head")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/todos", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("todos"))
+	})
+	mux.HandleFunc("POST /mcp", func(w http.ResponseWriter, r *http.Request) {})
+	h := newTestHandler(t).Fallback(mux)
+	browser := map[string]string{"Accept": "text/html"}
+
+	if rr := get(h, "/api/todos", browser); rr.Body.String() != "todos" {
+		t.Fatalf("expected the route to win, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get(h, "/", nil); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `<div id="root">`) {
+		t.Fatalf("expected index.html, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get(h, "/settings", browser); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `<div id="root">`) {
+		t.Fatalf("expected index.html for an app page, got %d", rr.Code)
+	}
+	if rr := get(h, "/api/missing", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown API path, got %d", rr.Code)
+	}
+	if rr := get(h, "/mcp", nil); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected the mux's 405, got %d", rr.Code)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for POST /, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256
+// of the request body keyed with the webhook's secret, prefixed "sha256=".
+const (
+	HeaderEvent     = "X-Todoapp-Event"
+	HeaderDelivery  = "X-Todoapp-Delivery"
+	HeaderSignature = "X-Todoapp-Signature"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultBackoff     = 30 * time.Second
+	defaultMaxBackoff  = time.Hour
+	batchSize          = 50
+	// maxResponseBytes bounds how much of a response is read before the
+	// connection is reused.
+	maxResponseBytes = 64 << 10
+)
+
+// Dispatcher posts queued deliveries. A failed attempt, either a transport
+// error or a non-2xx response, is retried after a backoff that doubles
+// with each attempt, until MaxAttempts is reached.
+type Dispatcher struct {
+	store       *Store
+	client      *http.Client
+	now         func() time.Time
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+type DispatcherOption func(*Dispatcher)
+
+func WithHTTPClient(client *http.Client) DispatcherOption {
+	return func(d *Dispatcher) { d.client = client }
+}
+
+// WithRetries sets how many attempts a delivery gets and the backoff after
+// the first failure, which doubles up to maxBackoff.
+func WithRetries(maxAttempts int, backoff time.Duration, maxBackoff time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoff = backoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+func NewDispatcher(store *Store, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+		maxBackoff:  defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run delivers due events every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to deliver webhooks", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue makes one attempt at every delivery that is due and returns
+// how many attempts it made.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		due, err := d.store.due(ctx, d.now(), batchSize)
+		if err != nil {
+			return attempted, err
+		}
+		for _, p := range due {
+			if err := d.attempt(ctx, p); err != nil {
+				return attempted, err
+			}
+			attempted++
+		}
+		if len(due) < batchSize {
+			return attempted, nil
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, p pending) error {
+	responseStatus, err := d.send(ctx, p)
+	now := d.now()
+	attempts := p.Attempts + 1
+	if err == nil {
+		return d.store.recordAttempt(ctx, p.ID, StatusSucceeded, attempts, responseStatus, "", now, nil)
+	}
+
+	slog.WarnContext(ctx, "webhook delivery failed", "delivery", p.ID, "url", p.url, "attempt", attempts, "error", err)
+	if attempts >= d.maxAttempts {
+		return d.store.recordAttempt(ctx, p.ID, StatusFailed, attempts, responseStatus, err.Error(), now, nil)
+	}
+	next := now.Add(d.delay(attempts))
+	return d.store.recordAttempt(ctx, p.ID, StatusPending, attempts, responseStatus, err.Error(), now, &next)
+}
+
+// delay is the backoff after the given number of failed attempts.
+func (d *Dispatcher) delay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
+
+// send posts the delivery and returns the response status, if any.
+func (d *Dispatcher) send(ctx context.Context, p pending) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(p.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todoapp-webhook")
+	req.Header.Set(HeaderEvent, p.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(p.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(p.secret, p.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value for body. Receivers should
+// compute it themselves and compare with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/todo"
+)
+
+// receiver records requests and answers with the queued statuses, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestDispatcher_DeliversSignedPayloads(t *testing.T) {
+	store, repo, now := setupStore(t)
+	ctx := context.Background()
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	hook, _ := store.Create(ctx, server.URL, []string{todo.EventCreated}, "s3cret")
+	repo.Create(ctx, todo.Item{Title: "Ship it"})
+
+	d := NewDispatcher(store)
+	d.now = func() time.Time { return *now }
+	if n, err := d.DeliverDue(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one attempt, got %d: %v", n, err)
+	}
+	if len(rc.requests) != 1 {
+		t.Fatalf("expected one request, got %d", len(rc.requests))
+	}
+	req, body := rc.requests[0], rc.bodies[0]
+	if req.Header.Get(HeaderEvent) != todo.EventCreated || req.Header.Get(HeaderDelivery) != "1" || req.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers %v", req.Header)
+	}
+	if !hmac.Equal([]byte(req.Header.Get(HeaderSignature)), []byte(Sign("s3cret", body))) {
+		t.Fatalf("signature %q does not match the body", req.Header.Get(HeaderSignature))
+	}
+
+	deliveries, _ := store.Deliveries(ctx, hook.ID, 10)
+	if deliveries[0].Status != StatusSucceeded || deliveries[0].Attempts != 1 || *deliveries[0].ResponseStatus != http.StatusOK || deliveries[0].NextAttemptAt != nil {
+		t.Fatalf("unexpected delivery %#v", deliveries[0])
+	}
+	if n, _ := d.DeliverDue(ctx); n != 0 {
+		t.Fatalf("expected nothing left to deliver, made %d attempts", n)
+	}
+}
+
+func TestDispatcher_RetriesWithBackoffThenGivesUp(t *testing.T) {
+	store, repo, now := setupStore(t)
+	ctx := context.Background()
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	hook, _ := store.Create(ctx, server.URL, nil, "")
+	repo.Create(ctx, todo.Item{Title: "Ship it"})
+
+	d := NewDispatcher(store, WithRetries(3, time.Minute, time.Hour))
+	d.now = func() time.Time { return *now }
+	delivery := func() Delivery {
+		t.Helper()
+		deliveries, err := store.Deliveries(ctx, hook.ID, 1)
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("deliveries: %v", err)
+		}
+		return deliveries[0]
+	}
+
+	d.DeliverDue(ctx)
+	first := delivery()
+	if first.Status != StatusPending || first.Attempts != 1 || first.Error != "unexpected status 500" || !first.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected delivery after the first attempt: %#v", first)
+	}
+
+	// Not yet due.
+	*now = now.Add(59 * time.Second)
+	if n, _ := d.DeliverDue(ctx); n != 0 {
+		t.Fatalf("expected the retry to wait for its backoff, made %d attempts", n)
+	}
+
+	*now = now.Add(time.Second)
+	d.DeliverDue(ctx)
+	if second := delivery(); second.Attempts != 2 || !second.NextAttemptAt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("expected the backoff to double, got %#v", second)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	d.DeliverDue(ctx)
+	if last := delivery(); last.Status != StatusFailed || last.Attempts != 3 || last.NextAttemptAt != nil || *last.ResponseStatus != http.StatusServiceUnavailable {
+		t.Fatalf("expected the delivery to fail after three attempts, got %#v", last)
+	}
+
+	redelivered, err := store.Redeliver(ctx, hook.ID, first.ID)
+	if err != nil || redelivered.Status != StatusPending || redelivered.Attempts != 0 {
+		t.Fatalf("unexpected redelivery %#v: %v", redelivered, err)
+	}
+	d.DeliverDue(ctx)
+	if done := delivery(); done.Status != StatusSucceeded || done.Attempts != 1 || len(rc.requests) != 4 {
+		t.Fatalf("expected the redelivery to succeed, got %#v after %d requests", done, len(rc.requests))
+	}
+	if _, err := store.Redeliver(ctx, hook.ID+1, first.ID); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Fatalf("expected ErrDeliveryNotFound for another webhook, got %v", err)
+	}
+}
+
+func TestDispatcher_Delay(t *testing.T) {
+	d := NewDispatcher(nil, WithRetries(10, 30*time.Second, 5*time.Minute))
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, expected := range want {
+		if got := d.delay(i + 1); got != expected {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, expected, got)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"todoapp/backend/internal/respond"
+	"todoapp/backend/internal/todo"
+)
+
+const (
+	maxRequestBytes  = 1 << 16
+	maxSecretLength  = 256
+	deliveryLogLimit = 100
+)
+
+type Handler struct {
+	store *Store
+}
+
+func NewHandler(store *Store) *Handler {
+	return &Handler{store: store}
+}
+
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.store.List(r.Context())
+	if err != nil {
+		respond.ServerError(w, r, "failed to fetch webhooks", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, hooks)
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+type createWebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+	var events []string
+	for _, event := range req.Events {
+		if !slices.Contains(todo.EventTypes, event) {
+			http.Error(w, "unknown event "+strconv.Quote(event)+"; expected one of "+strings.Join(todo.EventTypes, ", "), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(req.Secret) > maxSecretLength {
+		http.Error(w, "secret must be at most "+strconv.Itoa(maxSecretLength)+" bytes", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.store.Create(r.Context(), target.String(), events, req.Secret)
+	if err != nil {
+		respond.ServerError(w, r, "failed to create webhook", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusCreated, createWebhookResponse{Webhook: hook, Secret: hook.Secret})
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id", "invalid webhook id")
+	if !ok {
+		return
+	}
+	if err := h.store.Delete(r.Context(), id); err != nil {
+		h.storeError(w, r, "failed to delete webhook", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first.
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id", "invalid webhook id")
+	if !ok {
+		return
+	}
+	deliveries, err := h.store.Deliveries(r.Context(), id, deliveryLogLimit)
+	if err != nil {
+		h.storeError(w, r, "failed to fetch deliveries", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusOK, deliveries)
+}
+
+// Redeliver queues a delivery again; the dispatcher sends it on its next
+// run.
+func (h *Handler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id", "invalid webhook id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := pathID(w, r, "deliveryId", "invalid delivery id")
+	if !ok {
+		return
+	}
+	delivery, err := h.store.Redeliver(r.Context(), id, deliveryID)
+	if err != nil {
+		h.storeError(w, r, "failed to redeliver", err)
+		return
+	}
+	respond.JSON(w, r, http.StatusAccepted, delivery)
+}
+
+func (h *Handler) storeError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "webhook not found", http.StatusNotFound)
+	case errors.Is(err, ErrDeliveryNotFound):
+		http.Error(w, "delivery not found", http.StatusNotFound)
+	default:
+		respond.ServerError(w, r, message, err)
+	}
+}
+
+func pathID(w http.ResponseWriter, r *http.Request, name string, message string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, message, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todoapp/backend/internal/todo"
+)
+
+func serve(h http.HandlerFunc, method string, target string, body string, pathValues map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, value := range pathValues {
+		req.SetPathValue(name, value)
+	}
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func TestHandler_CreateAndList(t *testing.T) {
+	store, _, _ := setupStore(t)
+	h := NewHandler(store)
+
+	rr := serve(h.CreateWebhook, http.MethodPost, "/api/webhooks", `{"url":"https://chat.example.com/hook","events":["todo.completed","todo.completed"]}`, nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created createWebhookResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil || created.Secret == "" || len(created.Events) != 1 {
+		t.Fatalf("unexpected webhook %s: %v", rr.Body.String(), err)
+	}
+
+	rr = serve(h.ListWebhooks, http.MethodGet, "/api/webhooks", "", nil)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), created.Secret) || !strings.Contains(rr.Body.String(), "chat.example.com") {
+		t.Fatalf("expected the list to omit secrets, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	for _, body := range []string{
+		`{"url":"ftp://example.com"}`,
+		`{"url":"/relative"}`,
+		`{"url":"https://example.com","events":["todo.renamed"]}`,
+		`{"url":"https://example.com","secret":"` + strings.Repeat("x", maxSecretLength+1) + `"}`,
+		`{"url":"https://example.com","extra":true}`,
+	} {
+		if rr := serve(h.CreateWebhook, http.MethodPost, "/api/webhooks", body, nil); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandler_DeliveriesAndRedelivery(t *testing.T) {
+	store, repo, _ := setupStore(t)
+	h := NewHandler(store)
+	ctx := context.Background()
+	hook, _ := store.Create(ctx, "https://chat.example.com/hook", nil, "")
+	repo.Create(ctx, todo.Item{Title: "Ship it"})
+
+	rr := serve(h.ListDeliveries, http.MethodGet, "/api/webhooks/1/deliveries", "", map[string]string{"id": "1"})
+	var deliveries []Delivery
+	if err := json.Unmarshal(rr.Body.Bytes(), &deliveries); err != nil || len(deliveries) != 1 || deliveries[0].Event != todo.EventCreated {
+		t.Fatalf("unexpected delivery log %s: %v", rr.Body.String(), err)
+	}
+
+	rr = serve(h.Redeliver, http.MethodPost, "/api/webhooks/1/deliveries/1/redeliver", "", map[string]string{"id": "1", "deliveryId": "1"})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		handler http.HandlerFunc
+		path    map[string]string
+		status  int
+	}{
+		{h.ListDeliveries, map[string]string{"id": "2"}, http.StatusNotFound},
+		{h.ListDeliveries, map[string]string{"id": "x"}, http.StatusBadRequest},
+		{h.Redeliver, map[string]string{"id": "1", "deliveryId": "2"}, http.StatusNotFound},
+		{h.DeleteWebhook, map[string]string{"id": "2"}, http.StatusNotFound},
+		{h.DeleteWebhook, map[string]string{"id": "1"}, http.StatusNoContent},
+	}
+	for i, tt := range tests {
+		if rr := serve(tt.handler, http.MethodPost, "/", "", tt.path); rr.Code != tt.status {
+			t.Fatalf("case %d: expected status %d, got %d", i, tt.status, rr.Code)
+		}
+	}
+	if hooks, _ := store.List(ctx); len(hooks) != 0 || hook.ID != 1 {
+		t.Fatalf("expected the webhook to be deleted, have %#v", hooks)
+	}
+}
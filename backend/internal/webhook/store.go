@@ -0,0 +1,319 @@
+// Package webhook delivers todo events to subscribed URLs. Events are queued
+// in the database in the same transaction as the change that raised them,
+// and a Dispatcher posts them, signed, retrying failures with backoff.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"todoapp/backend/internal/todo"
+)
+
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Webhook is a subscription. An empty Events list subscribes to every event.
+// The secret signs payloads and is only returned when the webhook is created.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Delivery is one event queued for one webhook, with the outcome of its
+// latest attempt. NextAttemptAt is set while the delivery is pending.
+type Delivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhookId"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"responseStatus,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// Create subscribes url to events, generating a secret when none is given.
+func (s *Store) Create(ctx context.Context, url string, events []string, secret string) (Webhook, error) {
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Webhook{}, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+	hook := Webhook{URL: url, Events: events, Secret: secret, CreatedAt: s.now().UTC()}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhooks (url, events, secret, created_at) VALUES (?, ?, ?, ?)`,
+		hook.URL, strings.Join(hook.Events, ","), hook.Secret, hook.CreatedAt)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if hook.ID, err = result.LastInsertId(); err != nil {
+		return Webhook{}, err
+	}
+	return hook, nil
+}
+
+func (s *Store) List(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, url, events, secret, created_at FROM webhooks ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := make([]Webhook, 0)
+	for rows.Next() {
+		var (
+			hook   Webhook
+			events string
+		)
+		if err := rows.Scan(&hook.ID, &hook.URL, &events, &hook.Secret, &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		hook.Events = splitEvents(events)
+		hooks = append(hooks, hook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// Delete removes a webhook along with its deliveries.
+func (s *Store) Delete(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// Publish queues event for every webhook subscribed to it. It implements
+// todo.EventSink, running in the transaction that changed the todo.
+func (s *Store) Publish(ctx context.Context, tx *sql.Tx, event todo.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	now := s.now().UTC()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, created_at, next_attempt_at)
+		SELECT id, ?, ?, ?, ?, ? FROM webhooks
+		WHERE events = '' OR instr(',' || events || ',', ?) > 0`,
+		event.Type, string(payload), StatusPending, now, now, ","+event.Type+",")
+	return err
+}
+
+// Enqueue queues an event that is not tied to a todo change, such as a
+// fired reminder.
+func (s *Store) Enqueue(ctx context.Context, event todo.Event) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.Publish(ctx, tx, event); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Deliveries returns the latest deliveries of webhook id, newest first.
+func (s *Store) Deliveries(ctx context.Context, id int64, limit int) ([]Delivery, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery of webhook id again, whatever its status, with
+// a fresh set of attempts.
+func (s *Store) Redeliver(ctx context.Context, id int64, deliveryID int64) (Delivery, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE id = ? AND webhook_id = ?`,
+		StatusPending, s.now().UTC(), deliveryID, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return Delivery{}, err
+	}
+	if updated == 0 {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return scanDelivery(s.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, deliveryID))
+}
+
+// pending is a delivery due for an attempt, with where to send it.
+type pending struct {
+	Delivery
+	url    string
+	secret string
+}
+
+func (s *Store) due(ctx context.Context, now time.Time, limit int) ([]pending, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.payload, webhook_deliveries.attempts,
+			webhooks.url, webhooks.secret
+		FROM webhook_deliveries JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+		WHERE webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?
+		ORDER BY webhook_deliveries.next_attempt_at ASC, webhook_deliveries.id ASC LIMIT ?`,
+		StatusPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []pending
+	for rows.Next() {
+		var (
+			p       pending
+			payload string
+		)
+		if err := rows.Scan(&p.ID, &p.Event, &payload, &p.Attempts, &p.url, &p.secret); err != nil {
+			return nil, err
+		}
+		p.Payload = json.RawMessage(payload)
+		due = append(due, p)
+	}
+	return due, rows.Err()
+}
+
+// recordAttempt stores the outcome of an attempt. A nil next means no more
+// attempts will be made.
+func (s *Store) recordAttempt(ctx context.Context, id int64, status string, attempts int, responseStatus int, message string, at time.Time, next *time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_status = ?, error = ?, last_attempt_at = ?, next_attempt_at = ?
+		WHERE id = ?`,
+		status, attempts, sql.NullInt64{Int64: int64(responseStatus), Valid: responseStatus != 0}, message, at.UTC(), nullTime(next), id)
+	return err
+}
+
+const deliveryColumns = `id, webhook_id, event, status, attempts, response_status, error, created_at, last_attempt_at, next_attempt_at, payload`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDelivery(row rowScanner) (Delivery, error) {
+	var (
+		delivery                     Delivery
+		responseStatus               sql.NullInt64
+		lastAttemptAt, nextAttemptAt sql.NullTime
+		payload                      string
+	)
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Status, &delivery.Attempts,
+		&responseStatus, &delivery.Error, &delivery.CreatedAt, &lastAttemptAt, &nextAttemptAt, &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return Delivery{}, err
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	delivery.LastAttemptAt = timePtr(lastAttemptAt)
+	delivery.NextAttemptAt = timePtr(nextAttemptAt)
+	delivery.Payload = json.RawMessage(payload)
+	return delivery, nil
+}
+
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
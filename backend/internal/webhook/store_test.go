@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/todo"
+)
+
+func setupStore(t *testing.T) (*Store, *todo.Repository, *time.Time) {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := NewStore(database)
+	store.now = func() time.Time { return now }
+	return store, todo.NewRepository(database, todo.WithEvents(store)), &now
+}
+
+func TestStore_PublishQueuesMatchingWebhooks(t *testing.T) {
+	store, repo, _ := setupStore(t)
+	ctx := context.Background()
+
+	all, _ := store.Create(ctx, "http://example.com/all", nil, "")
+	completed, _ := store.Create(ctx, "http://example.com/completed", []string{todo.EventCompleted}, "s3cret")
+	if all.Secret == "" || completed.Secret != "s3cret" {
+		t.Fatalf("unexpected secrets %q and %q", all.Secret, completed.Secret)
+	}
+
+	item, err := repo.Create(ctx, todo.Item{Title: "Ship it"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := repo.UpdateCompleted(ctx, item.ID, true, nil); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if err := repo.Delete(ctx, item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	deliveries, err := store.Deliveries(ctx, all.ID, 10)
+	if err != nil {
+		t.Fatalf("deliveries: %v", err)
+	}
+	var events []string
+	for _, d := range deliveries {
+		events = append(events, d.Event)
+	}
+	if len(events) != 3 || events[0] != todo.EventDeleted || events[1] != todo.EventCompleted || events[2] != todo.EventCreated {
+		t.Fatalf("unexpected events, newest first: %v", events)
+	}
+
+	var event todo.Event
+	if err := json.Unmarshal(deliveries[0].Payload, &event); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if event.Item.ID != item.ID || event.Item.Title != "Ship it" || !event.Item.Completed {
+		t.Fatalf("expected the deleted todo as it was, got %#v", event.Item)
+	}
+
+	deliveries, _ = store.Deliveries(ctx, completed.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].Event != todo.EventCompleted || deliveries[0].Status != StatusPending {
+		t.Fatalf("expected only the completion, got %#v", deliveries)
+	}
+}
+
+// queuedEvents returns the events queued for hook, oldest first.
+func queuedEvents(t *testing.T, store *Store, hookID int64) []string {
+	t.Helper()
+	deliveries, err := store.Deliveries(context.Background(), hookID, 100)
+	if err != nil {
+		t.Fatalf("deliveries: %v", err)
+	}
+	events := make([]string, len(deliveries))
+	for i, d := range deliveries {
+		events[len(deliveries)-1-i] = d.Event
+	}
+	return events
+}
+
+func TestStore_PublishesSyncAndImportChanges(t *testing.T) {
+	store, repo, _ := setupStore(t)
+	ctx := context.Background()
+	hook, _ := store.Create(ctx, "http://example.com/hook", nil, "")
+
+	title, done := "Ship it", true
+	clock := todo.Clock{Time: 1, ClientID: "phone"}
+	if _, err := repo.ApplyMutations(ctx, nil, []todo.Mutation{
+		{UID: "a@phone", Clock: clock, Title: &title},
+		{UID: "a@phone", Clock: todo.Clock{Time: 2, ClientID: "phone"}, Changes: todo.Changes{Completed: &done}},
+		{UID: "a@phone", Clock: todo.Clock{Time: 3, ClientID: "phone"}, Delete: true},
+	}, nil); err != nil {
+		t.Fatalf("apply mutations: %v", err)
+	}
+	if _, err := repo.Import(ctx, nil, []todo.Item{{Title: "Imported", Completed: true}}, false); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if _, err := repo.Import(ctx, nil, []todo.Item{{Title: "Dry run"}}, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if _, err := repo.UpsertByUID(ctx, nil, []todo.Item{{UID: "b@calendar", Title: "From a calendar"}}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if _, err := repo.UpsertByUID(ctx, nil, []todo.Item{{UID: "b@calendar", Title: "From a calendar", Completed: true}}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	want := []string{todo.EventCreated, todo.EventCompleted, todo.EventDeleted, todo.EventCreated, todo.EventCreated, todo.EventCompleted}
+	if got := queuedEvents(t, store, hook.ID); !slices.Equal(got, want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestStore_PublishesTaskImports(t *testing.T) {
+	store, repo, _ := setupStore(t)
+	hook, _ := store.Create(context.Background(), "http://example.com/hook", nil, "")
+	h := todo.NewHandler(repo, todo.WithTaskImport(repo))
+
+	upload := func(yaml string) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		h.ImportTasks(rr, httptest.NewRequest(http.MethodPost, "/api/import/takt", strings.NewReader(yaml)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	upload("tasks:\n  - name: fix-build\n    slug: fix-build\n    status: running\n")
+	upload("tasks:\n  - name: fix-build\n    slug: fix-build\n    status: completed\n")
+
+	want := []string{todo.EventCreated, todo.EventCompleted}
+	if got := queuedEvents(t, store, hook.ID); !slices.Equal(got, want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestStore_FailedChangesQueueNothing(t *testing.T) {
+	store, repo, _ := setupStore(t)
+	ctx := context.Background()
+	hook, _ := store.Create(ctx, "http://example.com/hook", nil, "")
+
+	if _, err := repo.UpdateCompleted(ctx, 999, true, nil); !errors.Is(err, todo.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := repo.Delete(ctx, 999); !errors.Is(err, todo.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if deliveries, _ := store.Deliveries(ctx, hook.ID, 10); len(deliveries) != 0 {
+		t.Fatalf("expected no deliveries, got %#v", deliveries)
+	}
+}
+
+func TestStore_DeleteRemovesDeliveries(t *testing.T) {
+	store, repo, _ := setupStore(t)
+	ctx := context.Background()
+	hook, _ := store.Create(ctx, "http://example.com/hook", nil, "")
+	repo.Create(ctx, todo.Item{Title: "Ship it"})
+
+	if err := store.Delete(ctx, hook.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Deliveries(ctx, hook.ID, 10); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.Delete(ctx, hook.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	var left int
+	store.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries`).Scan(&left)
+	if left != 0 {
+		t.Fatalf("expected deliveries to be removed, %d left", left)
+	}
+}
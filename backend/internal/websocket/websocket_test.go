@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func echoServer(t *testing.T, protocols []string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, protocols)
+		if err != nil {
+			return
+		}
+		conn.SetMaxMessageLen(64 << 10)
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(message) == "bye" {
+				conn.Close(4000, "bye")
+				return
+			}
+			if err := conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url string, protocol string) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, url, protocol, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return conn
+}
+
+func TestConn_Echo(t *testing.T) {
+	conn := dial(t, echoServer(t, []string{"chat"}), "chat")
+	defer conn.Close(CloseNormal, "")
+	if conn.Protocol() != "chat" {
+		t.Fatalf("expected the chat subprotocol, got %q", conn.Protocol())
+	}
+
+	for _, message := range []string{"hello", strings.Repeat("x", 300), strings.Repeat("y", 65000)} {
+		if err := conn.WriteMessage([]byte(message)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(got) != message {
+			t.Fatalf("expected %d bytes back, got %d", len(message), len(got))
+		}
+	}
+}
+
+func TestConn_CloseCodes(t *testing.T) {
+	url := echoServer(t, nil)
+
+	conn := dial(t, url, "")
+	conn.WriteMessage([]byte("bye"))
+	_, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != 4000 || closeErr.Reason != "bye" {
+		t.Fatalf("expected close 4000 bye, got %v", err)
+	}
+
+	conn = dial(t, url, "")
+	conn.WriteMessage([]byte(strings.Repeat("z", 70000)))
+	if _, err := conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Fatalf("expected close %d, got %v", CloseMessageTooBig, err)
+	}
+}
+
+func TestUpgrade_RejectsBadHandshakes(t *testing.T) {
+	url := echoServer(t, []string{"chat"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := Dial(ctx, url, "other", nil); err == nil || !strings.Contains(err.Error(), "unsupported WebSocket subprotocol") {
+		t.Fatalf("expected an unknown subprotocol to be rejected, got %v", err)
+	}
+
+	resp, err := http.Get("http" + strings.TrimPrefix(url, "ws"))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a plain request, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,86 @@
+// Package api defines the request and response types of the todoapp REST
+// API. The server and the Go client share them, and the package depends
+// only on the standard library so that importing the client stays cheap.
+package api
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound reports a todo that does not exist or that the caller may not
+// see.
+var ErrNotFound = errors.New("todo not found")
+
+type Item struct {
+	ID        int64  `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	// Notes holds free-form Markdown. NotesHTML is its sanitized rendering,
+	// filled in only when a client asks for it with ?render=html.
+	Notes     string `json:"notes,omitempty"`
+	NotesHTML string `json:"notesHtml,omitempty"`
+	// ListID is nil for todos on the default, unshared list.
+	ListID      *int64     `json:"listId,omitempty"`
+	CompletedBy *UserRef   `json:"completedBy,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// Due is a point in time; date-only due dates are stored as midnight
+	// UTC. Priority follows iCalendar: 1 is highest, 9 lowest, 0 none.
+	Due      *time.Time `json:"due,omitempty"`
+	Priority int        `json:"priority,omitempty"`
+	// UID identifies the todo to calendar clients. Todos created here get
+	// one derived from their ID; imported ones keep the UID they came with.
+	UID string `json:"uid,omitempty"`
+}
+
+// UserRef names the user who acted on an item.
+type UserRef struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// Changes lists the fields an update edits. Nil fields are left alone; Due
+// is applied only when SetDue is true, so that nil can clear it.
+type Changes struct {
+	Title     *string
+	Completed *bool
+	Notes     *string
+	Priority  *int
+	SetDue    bool
+	Due       *time.Time
+}
+
+// CreateTodoRequest is the body of POST /api/todos.
+type CreateTodoRequest struct {
+	Title    string   `json:"title"`
+	Notes    string   `json:"notes,omitempty"`
+	ListID   *int64   `json:"listId,omitempty"`
+	Due      *DueTime `json:"due,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+}
+
+// UpdateTodoRequest is the body of PATCH /api/todos/{id}. Nil fields are
+// left alone.
+type UpdateTodoRequest struct {
+	Title     *string `json:"title,omitempty"`
+	Completed *bool   `json:"completed,omitempty"`
+	Notes     *string `json:"notes,omitempty"`
+	Priority  *int    `json:"priority,omitempty"`
+	// Due is left alone when absent and cleared by an explicit null.
+	Due OptionalDue `json:"due,omitzero"`
+}
+
+// TaskImportResult is the response of POST /api/import/takt.
+type TaskImportResult struct {
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Items     []TaskImportItem `json:"items"`
+}
+
+type TaskImportItem struct {
+	Slug string `json:"slug"`
+	ID   int64  `json:"id"`
+	// Status is "created", "updated" or "unchanged".
+	Status string `json:"status"`
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DueTime accepts either an RFC 3339 timestamp or a bare date, which is
+// stored as midnight UTC. It marshals midnight UTC back to a bare date.
+type DueTime time.Time
+
+func (d DueTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *DueTime) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	parsed, err := ParseDue(raw)
+	if err != nil {
+		return err
+	}
+	*d = DueTime(parsed)
+	return nil
+}
+
+// String formats d as a bare date when it is midnight UTC and as an RFC
+// 3339 timestamp otherwise.
+func (d DueTime) String() string {
+	t := time.Time(d).UTC()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Time returns d as a time, nil when d is nil.
+func (d *DueTime) Time() *time.Time {
+	if d == nil {
+		return nil
+	}
+	t := time.Time(*d)
+	return &t
+}
+
+// ParseDue parses an RFC 3339 timestamp or a bare date, returning it in
+// UTC.
+func ParseDue(raw string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		if parsed, err = time.Parse(time.DateOnly, raw); err != nil {
+			return time.Time{}, fmt.Errorf("due must be an RFC 3339 time or a YYYY-MM-DD date, got %q", raw)
+		}
+	}
+	return parsed.UTC(), nil
+}
+
+// OptionalDue tells an absent due field apart from an explicit null. Set
+// with a nil Value clears the due date.
+type OptionalDue struct {
+	Set   bool
+	Value *DueTime
+}
+
+// SetDue returns an OptionalDue that sets the due date to due, or clears it
+// when due is nil.
+func SetDue(due *time.Time) OptionalDue {
+	if due == nil {
+		return OptionalDue{Set: true}
+	}
+	value := DueTime(*due)
+	return OptionalDue{Set: true, Value: &value}
+}
+
+// IsZero reports whether the field is absent, for the omitzero option.
+func (o OptionalDue) IsZero() bool {
+	return !o.Set
+}
+
+func (o OptionalDue) MarshalJSON() ([]byte, error) {
+	if o.Value == nil {
+		return []byte("null"), nil
+	}
+	return o.Value.MarshalJSON()
+}
+
+func (o *OptionalDue) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		return nil
+	}
+	o.Value = new(DueTime)
+	return o.Value.UnmarshalJSON(data)
+}
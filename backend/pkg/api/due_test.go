@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDueTime_RoundTrip(t *testing.T) {
+	for _, raw := range []string{`"2026-03-15"`, `"2026-03-15T09:30:00Z"`} {
+		var due DueTime
+		if err := json.Unmarshal([]byte(raw), &due); err != nil {
+			t.Fatalf("unmarshal %s: %v", raw, err)
+		}
+		out, err := json.Marshal(due)
+		if err != nil || string(out) != raw {
+			t.Fatalf("expected %s back, got %s (%v)", raw, out, err)
+		}
+	}
+
+	var due DueTime
+	if err := json.Unmarshal([]byte(`"tomorrow"`), &due); err == nil {
+		t.Fatal("expected an invalid due date to be rejected")
+	}
+	if got := DueTime(time.Date(2026, 3, 15, 9, 30, 0, 0, time.FixedZone("", 3600))).String(); got != "2026-03-15T08:30:00Z" {
+		t.Fatalf("expected the due time in UTC, got %s", got)
+	}
+}
+
+func TestOptionalDue(t *testing.T) {
+	var req UpdateTodoRequest
+	if err := json.Unmarshal([]byte(`{}`), &req); err != nil || req.Due.Set {
+		t.Fatalf("expected an absent due to be unset, got %+v (%v)", req.Due, err)
+	}
+	if err := json.Unmarshal([]byte(`{"due":null}`), &req); err != nil || !req.Due.Set || req.Due.Value != nil {
+		t.Fatalf("expected null to clear the due date, got %+v (%v)", req.Due, err)
+	}
+	out, err := json.Marshal(UpdateTodoRequest{Due: SetDue(nil)})
+	if err != nil || string(out) != `{"due":null}` {
+		t.Fatalf("unexpected json: %s (%v)", out, err)
+	}
+}
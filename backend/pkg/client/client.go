@@ -0,0 +1,312 @@
+// Package client is a typed Go client for the todoapp REST API.
+//
+//	c, err := client.New("https://todo.example.com", client.WithToken(apiKey))
+//	item, err := c.Create(ctx, client.Item{Title: "Buy milk"})
+//	item, err = c.UpdateCompleted(ctx, item.ID, true)
+//
+// Its methods mirror the server's repository. Calls that are safe to
+// repeat (GET, PATCH and DELETE) are retried with backoff when the server
+// cannot be reached or answers 429 or 5xx; creates are sent once.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"todoapp/backend/pkg/api"
+)
+
+// The request and response types are the ones the server uses, from the
+// dependency-free pkg/api, so the client cannot drift from the API.
+type (
+	Item              = api.Item
+	UserRef           = api.UserRef
+	Changes           = api.Changes
+	CreateTodoRequest = api.CreateTodoRequest
+	UpdateTodoRequest = api.UpdateTodoRequest
+	DueTime           = api.DueTime
+	OptionalDue       = api.OptionalDue
+	TaskImportResult  = api.TaskImportResult
+	TaskImportItem    = api.TaskImportItem
+)
+
+const (
+	defaultMaxAttempts = 4
+	defaultBackoff     = 200 * time.Millisecond
+	defaultMaxBackoff  = 5 * time.Second
+	// maxErrorBytes bounds how much of an error response is kept.
+	maxErrorBytes = 1 << 10
+)
+
+type Client struct {
+	base        *url.URL
+	token       string
+	http        *http.Client
+	userAgent   string
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the client used for requests, e.g. one with a custom
+// transport or timeout. The default times out after 30 seconds.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) { c.http = client }
+}
+
+// WithToken authenticates requests with an API key or session token.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithRetries lets an idempotent call make up to maxAttempts requests in
+// all. The client waits backoff before the first retry and twice as long
+// before each one after; a Retry-After header from the server replaces
+// that wait. No wait exceeds maxBackoff. One attempt disables retries.
+func WithRetries(maxAttempts int, backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = max(maxAttempts, 1)
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New returns a client for the server at baseURL, e.g.
+// "https://todo.example.com" or "http://localhost:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("client: base URL must be an absolute http or https URL, got %q", baseURL)
+	}
+	c := &Client{
+		base:        base,
+		http:        &http.Client{Timeout: 30 * time.Second},
+		userAgent:   "todoapp-go-client",
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		sleep:       sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// List returns the todos on the default list, or on the shared list
+// listID.
+func (c *Client) List(ctx context.Context, listID *int64) ([]Item, error) {
+	query := url.Values{}
+	if listID != nil {
+		query.Set("list", strconv.FormatInt(*listID, 10))
+	}
+	var items []Item
+	err := c.do(ctx, http.MethodGet, "api/todos", query, nil, &items)
+	return items, err
+}
+
+func (c *Client) Get(ctx context.Context, id int64) (Item, error) {
+	var item Item
+	err := c.do(ctx, http.MethodGet, todoPath(id), nil, nil, &item)
+	return item, err
+}
+
+// Create adds a todo with the title, notes, list, due date and priority of
+// item; the server assigns the rest.
+func (c *Client) Create(ctx context.Context, item Item) (Item, error) {
+	req := CreateTodoRequest{Title: item.Title, Notes: item.Notes, ListID: item.ListID, Priority: item.Priority}
+	if item.Due != nil {
+		due := DueTime(*item.Due)
+		req.Due = &due
+	}
+	var created Item
+	err := c.do(ctx, http.MethodPost, "api/todos", nil, req, &created)
+	return created, err
+}
+
+// UpdateCompleted marks a todo completed or not. The server credits the
+// signed-in user with the completion, if any.
+func (c *Client) UpdateCompleted(ctx context.Context, id int64, completed bool) (Item, error) {
+	return c.patch(ctx, id, UpdateTodoRequest{Completed: &completed})
+}
+
+// Update applies changes; nil fields are left alone, and Due is applied
+// only when SetDue is true, so that nil clears it.
+func (c *Client) Update(ctx context.Context, id int64, changes Changes) (Item, error) {
+	req := UpdateTodoRequest{Title: changes.Title, Completed: changes.Completed, Notes: changes.Notes, Priority: changes.Priority}
+	if changes.SetDue {
+		req.Due = api.SetDue(changes.Due)
+	}
+	return c.patch(ctx, id, req)
+}
+
+// Delete removes a todo. If a retry follows an attempt whose response was
+// lost, the todo may already be gone and Delete returns ErrNotFound.
+func (c *Client) Delete(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, todoPath(id), nil, nil, nil)
+}
+
+// ImportTasks applies a .takt/tasks.yaml file to the default list, or to
+// the shared list listID, creating or updating one todo per task.
+func (c *Client) ImportTasks(ctx context.Context, tasks []byte, listID *int64) (TaskImportResult, error) {
+	query := url.Values{}
+	if listID != nil {
+		query.Set("list", strconv.FormatInt(*listID, 10))
+	}
+	var result TaskImportResult
+	err := c.send(ctx, http.MethodPost, "api/import/takt", query, "application/yaml", tasks, &result)
+	return result, err
+}
+
+func (c *Client) patch(ctx context.Context, id int64, req UpdateTodoRequest) (Item, error) {
+	var item Item
+	err := c.do(ctx, http.MethodPatch, todoPath(id), nil, req, &item)
+	return item, err
+}
+
+func todoPath(id int64) string {
+	return "api/todos/" + strconv.FormatInt(id, 10)
+}
+
+// do sends a request with body encoded as JSON and decodes the response
+// into out, retrying idempotent methods.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	return c.send(ctx, method, path, query, "application/json", payload, out)
+}
+
+// send is do for a payload already encoded as contentType.
+func (c *Client) send(ctx context.Context, method string, path string, query url.Values, contentType string, payload []byte, out any) error {
+	target := c.base.JoinPath(path)
+	target.RawQuery = query.Encode()
+
+	attempts := 1
+	if method != http.MethodPost {
+		attempts = c.maxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, target.String(), contentType, payload, out)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+		if err := c.sleep(ctx, c.delay(attempt, retryAfter)); err != nil {
+			return err
+		}
+	}
+}
+
+// attempt sends one request, returning the server's Retry-After delay
+// along with any error.
+func (c *Client) attempt(ctx context.Context, method string, target string, contentType string, payload []byte, out any) (time.Duration, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return 0, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+		return retryAfter(resp.Header.Get("Retry-After")), &Error{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+	if out == nil {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBytes))
+		return 0, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("client: invalid response: %w", err)
+	}
+	return 0, nil
+}
+
+// retryable reports whether a failed attempt may succeed if repeated:
+// transport errors, rate limiting and server errors, but not a cancelled
+// context.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// delay is how long to wait before retrying a call whose attempt failed.
+// The server's Retry-After, if any, is honoured but held to maxBackoff too,
+// so that a server asking for hours cannot stall the caller.
+func (c *Client) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, c.maxBackoff)
+	}
+	delay := c.backoff
+	for range attempt - 1 {
+		if delay >= c.maxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, c.maxBackoff)
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
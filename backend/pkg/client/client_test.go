@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"todoapp/backend/internal/auth"
+	"todoapp/backend/internal/db"
+	"todoapp/backend/internal/todo"
+)
+
+// newServer serves the real todo handlers behind API key authentication
+// and returns a read-write key for it.
+func newServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	if err := db.Migrate(database); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	keys := auth.NewKeyStore(database)
+	_, key, err := keys.Create(context.Background(), "test", auth.ScopeReadWrite, nil)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	authenticator := auth.NewAuthenticator(keys, auth.NewSessionStore(database, 0), "")
+
+	repo := todo.NewRepository(database)
+	h := todo.NewHandler(repo, todo.WithTaskImport(repo))
+	write := func(h http.HandlerFunc) http.Handler {
+		return auth.RequireScope(auth.ScopeReadWrite, false, h)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/todos", write(h.ListTodos))
+	mux.Handle("POST /api/todos", write(h.CreateTodo))
+	mux.Handle("GET /api/todos/{id}", write(h.GetTodo))
+	mux.Handle("PATCH /api/todos/{id}", write(h.UpdateTodo))
+	mux.Handle("DELETE /api/todos/{id}", write(h.DeleteTodo))
+	mux.Handle("POST /api/import/takt", write(h.ImportTasks))
+	server := httptest.NewServer(authenticator.Middleware(mux))
+	t.Cleanup(server.Close)
+	return server, key
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestClient_EndToEnd(t *testing.T) {
+	server, key := newServer(t)
+	c, err := New(server.URL, WithToken(key))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+
+	due := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	created, err := c.Create(ctx, Item{Title: "File taxes", Notes: "bring receipts", Due: &due, Priority: 1})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.ID == 0 || created.Title != "File taxes" || created.Due == nil || !created.Due.Equal(due) || created.Priority != 1 {
+		t.Fatalf("unexpected todo %#v", created)
+	}
+
+	got, err := c.Get(ctx, created.ID)
+	if err != nil || got.Notes != "bring receipts" {
+		t.Fatalf("get: %#v %v", got, err)
+	}
+
+	completed, err := c.UpdateCompleted(ctx, created.ID, true)
+	if err != nil || !completed.Completed || completed.CompletedAt == nil {
+		t.Fatalf("complete: %#v %v", completed, err)
+	}
+
+	updated, err := c.Update(ctx, created.ID, Changes{Title: ptr("File the taxes"), Priority: ptr(2), SetDue: true})
+	if err != nil || updated.Title != "File the taxes" || updated.Priority != 2 || updated.Due != nil || updated.Notes != "bring receipts" {
+		t.Fatalf("update: %#v %v", updated, err)
+	}
+
+	items, err := c.List(ctx, nil)
+	if err != nil || len(items) != 1 || items[0].ID != created.ID {
+		t.Fatalf("list: %#v %v", items, err)
+	}
+
+	if err := c.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := c.Get(ctx, created.ID); !errors.Is(err, ErrNotFound) || !errors.Is(err, todo.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := c.Delete(ctx, created.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestClient_ImportTasks(t *testing.T) {
+	server, key := newServer(t)
+	c, _ := New(server.URL, WithToken(key))
+	ctx := context.Background()
+
+	tasks := []byte("tasks:\n  - name: fix-build\n    slug: fix-build\n    status: completed\n    content: Fix the build.\n")
+	result, err := c.ImportTasks(ctx, tasks, nil)
+	if err != nil || result.Created != 1 || result.Items[0].Slug != "fix-build" {
+		t.Fatalf("import: %#v %v", result, err)
+	}
+	if result, err = c.ImportTasks(ctx, tasks, nil); err != nil || result.Unchanged != 1 {
+		t.Fatalf("reimport: %#v %v", result, err)
+	}
+	item, err := c.Get(ctx, result.Items[0].ID)
+	if err != nil || item.Title != "fix-build" || !item.Completed || item.Notes != "Fix the build." {
+		t.Fatalf("get: %#v %v", item, err)
+	}
+
+	var apiErr *Error
+	if _, err := c.ImportTasks(ctx, []byte("tasks: ["), nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the server to reject invalid YAML, got %v", err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	server, key := newServer(t)
+	ctx := context.Background()
+
+	anonymous, _ := New(server.URL)
+	if _, err := anonymous.List(ctx, nil); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+
+	c, _ := New(server.URL, WithToken(key))
+	_, err := c.Create(ctx, Item{Title: "x", Priority: 10})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "priority must be between 0 and 9" {
+		t.Fatalf("expected the server's validation error, got %v", err)
+	}
+
+	if _, err := New("localhost:8080"); err == nil {
+		t.Fatalf("expected a base URL without a scheme to be rejected")
+	}
+}
+
+func TestClient_RetriesIdempotentCalls(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		switch {
+		case n == 1:
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case n == 2 || r.Method == http.MethodPost:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"id":1,"title":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	c, _ := New(server.URL, WithRetries(3, time.Second, 10*time.Second))
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	ctx := context.Background()
+
+	item, err := c.Get(ctx, 1)
+	if err != nil || item.Title != "ok" {
+		t.Fatalf("expected the third attempt to succeed, got %#v %v", item, err)
+	}
+	if len(delays) != 2 || delays[0] != 7*time.Second || delays[1] != 2*time.Second {
+		t.Fatalf("expected Retry-After then doubled backoff, got %v", delays)
+	}
+
+	calls.Store(0)
+	delays = nil
+	c.maxBackoff = 5 * time.Second
+	if _, err := c.Get(ctx, 1); err != nil || len(delays) != 2 || delays[0] != 5*time.Second {
+		t.Fatalf("expected Retry-After to be capped at the maximum backoff, got %v %v", delays, err)
+	}
+
+	calls.Store(2)
+	if _, err := c.Create(ctx, Item{Title: "x"}); err == nil || calls.Load() != 3 {
+		t.Fatalf("expected creates not to be retried, got %d calls: %v", calls.Load(), err)
+	}
+
+	calls.Store(0)
+	c.maxAttempts = 2
+	if _, err := c.UpdateCompleted(ctx, 1, true); !errors.As(err, new(*Error)) || calls.Load() != 2 {
+		t.Fatalf("expected the error after the last attempt, got %d calls: %v", calls.Load(), err)
+	}
+
+	calls.Store(0)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	c.sleep = sleep
+	if _, err := c.Get(cancelled, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled context to stop retries, got %v", err)
+	}
+}
+
+func TestClient_HTTPClient(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{next: http.DefaultTransport}
+	c, _ := New(server.URL+"/", WithHTTPClient(&http.Client{Transport: transport}), WithUserAgent("reporting/1.0"))
+	if items, err := c.List(context.Background(), ptr(int64(4))); err != nil || len(items) != 0 {
+		t.Fatalf("list: %#v %v", items, err)
+	}
+	if transport.requests != 1 || transport.lastURL != server.URL+"/api/todos?list=4" || userAgent != "reporting/1.0" {
+		t.Fatalf("expected the custom client to be used, got %d requests to %q as %q", transport.requests, transport.lastURL, userAgent)
+	}
+}
+
+type countingTransport struct {
+	next     http.RoundTripper
+	requests int
+	lastURL  string
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	t.lastURL = req.URL.String()
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"todoapp/backend/pkg/api"
+)
+
+var (
+	// ErrNotFound is api.ErrNotFound, which the server's repository also
+	// returns, so errors.Is works the same against the client and the
+	// repository.
+	ErrNotFound     = api.ErrNotFound
+	ErrUnauthorized = errors.New("authentication required")
+	ErrForbidden    = errors.New("permission denied")
+)
+
+// Error is a non-2xx response. Message is the text the server sent.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is maps 404, 401 and 403 to ErrNotFound, ErrUnauthorized and
+// ErrForbidden.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestImports_StayDependencyFree keeps the client and pkg/api on the
+// standard library, so that importing the client does not pull in the
+// server and its drivers.
+func TestImports_StayDependencyFree(t *testing.T) {
+	allowed := map[string]bool{"todoapp/backend/pkg/api": true}
+	for _, dir := range []string{".", "../api"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatalf("list %s: %v", dir, err)
+		}
+		for _, name := range files {
+			if strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			file, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.ImportsOnly)
+			if err != nil {
+				t.Fatalf("parse %s: %v", name, err)
+			}
+			for _, spec := range file.Imports {
+				path, _ := strconv.Unquote(spec.Path.Value)
+				first, _, _ := strings.Cut(path, "/")
+				if strings.Contains(first, ".") || strings.HasPrefix(path, "todoapp/") && !allowed[path] {
+					t.Errorf("%s imports %s", name, path)
+				}
+			}
+		}
+	}
+}
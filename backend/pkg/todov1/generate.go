@@ -0,0 +1,5 @@
+// Package todov1 is the code generated from proto/todo/v1/todo.proto: the
+// messages of the todo.v1 API and the TodoService gRPC client and server.
+package todov1
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=module=todoapp/backend/pkg/todov1 --go-grpc_out=. --go-grpc_opt=module=todoapp/backend/pkg/todov1 todo/v1/todo.proto
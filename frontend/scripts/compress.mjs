@@ -0,0 +1,28 @@
+// Writes .br and .gz copies of the compressible files in dist/ so the Go
+// server can send them to clients that accept those encodings.
+import { readdir, readFile, writeFile } from 'node:fs/promises'
+import { join } from 'node:path'
+import { brotliCompressSync, constants, gzipSync } from 'node:zlib'
+
+const dist = new URL('../dist/', import.meta.url).pathname
+const compressible = /\.(js|mjs|css|svg|json|txt|map|wasm)$/
+// Tiny files are not worth the extra request for a variant.
+const minBytes = 1024
+
+for (const entry of await readdir(dist, { recursive: true, withFileTypes: true })) {
+  const path = join(entry.parentPath ?? entry.path, entry.name)
+  if (!entry.isFile() || !compressible.test(entry.name)) {
+    continue
+  }
+  const content = await readFile(path)
+  if (content.length < minBytes) {
+    continue
+  }
+  await writeFile(
+    `${path}.br`,
+    brotliCompressSync(content, {
+      params: { [constants.BROTLI_PARAM_QUALITY]: constants.BROTLI_MAX_QUALITY },
+    }),
+  )
+  await writeFile(`${path}.gz`, gzipSync(content, { level: 9 }))
+}
@@ -99,6 +99,23 @@ describe('todo api', () => {
     )
   })
 
+  it('prefers the base URL injected by the server', async () => {
+    const meta = document.createElement('meta')
+    meta.name = 'todo-api-base-url'
+    meta.content = ''
+    document.head.append(meta)
+    globalThis.fetch = vi.fn().mockResolvedValueOnce(createJSONResponse(200, []))
+
+    try {
+      await expect(listTodos()).resolves.toEqual([])
+      expect(globalThis.fetch).toHaveBeenCalledWith('/api/todos', {
+        method: 'GET',
+      })
+    } finally {
+      meta.remove()
+    }
+  })
+
   it('fails fast when base URL is not configured', async () => {
     vi.unstubAllEnvs()
 
@@ -9,6 +9,14 @@ type TodoUpdateInput = {
 }
 
 function getApiBaseURL(): string {
+  // A server hosting the built app names the API in this tag at runtime;
+  // empty content means the API shares the app's origin.
+  const injected = document.querySelector<HTMLMetaElement>(
+    'meta[name="todo-api-base-url"]',
+  )
+  if (injected) {
+    return injected.content
+  }
   const baseURL = import.meta.env.VITE_API_BASE_URL
   if (!baseURL) {
     throw new Error('VITE_API_BASE_URL is required')
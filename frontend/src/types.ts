@@ -2,4 +2,35 @@ export type TodoItem = {
   id: number
   title: string
   completed: boolean
+  notes?: string
+  notesHtml?: string
+  listId?: number
+  completedBy?: { id: number; name: string }
+  completedAt?: string
+  due?: string
+  priority?: number
+  uid?: string
+}
+
+export type TodoComment = {
+  id: number
+  todoId: number
+  parentId?: number
+  authorName: string
+  body: string
+  bodyHtml?: string
+  deleted?: boolean
+  createdAt: string
+  updatedAt: string
+  replies?: TodoComment[]
+}
+
+export type TodoAttachment = {
+  id: number
+  todoId: number
+  filename: string
+  contentType: string
+  size: number
+  sha256: string
+  createdAt: string
 }